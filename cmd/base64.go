@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
 
 	"github.com/cyberspacesec/go-iconhash/pkg/hasher"
@@ -63,14 +64,10 @@ func runBase64(cmd *cobra.Command, args []string) {
 	}
 	h := hasher.New(options)
 
-	// Debug info if enabled
-	if Debug {
-		fmt.Fprintf(os.Stderr, "🔍 Base64 File: %s\n", Base64Path)
-		fmt.Fprintf(os.Stderr, "🔧 Options: uint32=%v\n", options.UseUint32)
-	}
+	slog.Debug("Hash options", "uint32", options.UseUint32)
 
 	// Read file data
-	fmt.Fprintf(os.Stderr, "📂 Reading base64 file %s...\n", Base64Path)
+	slog.Info("Reading base64 file", "path", Base64Path)
 	fileData, err := os.ReadFile(Base64Path)
 	if err != nil {
 		color.Red("❌ Error reading file: %v", err)
@@ -78,7 +75,7 @@ func runBase64(cmd *cobra.Command, args []string) {
 	}
 
 	// Calculate hash
-	fmt.Fprintf(os.Stderr, "🧮 Calculating hash...\n")
+	slog.Debug("Calculating hash", "bytes", len(fileData))
 	hash, err := h.HashFromBase64(string(fileData))
 	if err != nil {
 		color.Red("❌ Error calculating hash: %v", err)
@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"time"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/config"
 )
 
 // Version information
@@ -13,17 +15,57 @@ var (
 
 // Global flags
 var (
-	Debug        bool
-	Uint32Flag   bool
-	URL          string
-	FilePath     string
-	Base64Path   string
-	UserAgent    string
-	FofaFormat   bool
-	ShodanFormat bool
-	SkipVerify   bool
-	Timeout      time.Duration
-	OutputFormat string
+	Debug          bool
+	Uint32Flag     bool
+	URL            string
+	FilePath       string
+	Base64Path     string
+	UserAgent      string
+	FofaFormat     bool
+	ShodanFormat   bool
+	BodyHash       bool
+	SkipVerify     bool
+	Timeout        time.Duration
+	OutputFormat   string
+	LogLevel       string
+	LogFormat      string
+	LogFile        string
+	ConfigFile     string
+	Profile        string
+	Proxies        []string
+	ProxyRules     []string
+	Headers        []string
+	CookieFile     string
+	BasicAuth      string
+	BearerToken    string
+	ClientCert     string
+	ClientKey      string
+	Retries        int
+	RetryBackoff   time.Duration
+	RetryMaxTime   time.Duration
+	CacheEnabled   bool
+	NoCache        bool
+	RefreshCache   bool
+	CacheDir       string
+	CacheTTL       time.Duration
+	CacheMaxSize   int64
+	Strict         bool
+	HashMode       string
+	AcceptEncoding string
+	MaxRedirects   int
+	NoFollow       bool
+	SameHost       bool
+	MetaRefresh    bool
+	Resolve        []string
+	ConnectTo      []string
+	DNSServer      string
+)
+
+// Effective is the merged configuration of the running command, and
+// ConfigSource the config file it was read from, if any
+var (
+	Effective    *config.Config
+	ConfigSource string
 )
 
 // Server flags
@@ -31,8 +73,30 @@ var (
 	Host         string
 	Port         int
 	AuthToken    string
+	KeysFile     string
+	QueryToken   bool
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
+	Metrics      bool
+	MetricsAddr  string
+	MetricsToken string
+	RateLimit    float64
+	RateBurst    int
+	DailyQuota   int
+	HostRate     float64
+	HostBurst    int
+	TrustProxy   bool
+
+	AllowedRequestHeaders []string
+	AllowRequestCookies   bool
+	AllowRequestAuth      bool
+	CredentialHosts       []string
+)
+
+// Local file access flags, shared by the server and mcp commands
+var (
+	FileRoots   []string
+	MaxFileSize int64
 )
 
 // MonitorData stores favicon monitoring information
@@ -58,6 +122,13 @@ var (
 		ErrorHandling string
 	}{}
 
+	// Keygen command options
+	KeygenOptions = struct {
+		Name    string
+		Scopes  []string
+		Expires time.Duration
+	}{}
+
 	// Compare command options
 	CompareOptions = struct {
 		FirstSource  string
@@ -109,6 +180,33 @@ var (
 		ChangeThreshold float64
 	}{}
 
+	// Vhosts command options
+	VHostsOptions = struct {
+		Wordlist     string
+		Scheme       string
+		Port         int
+		Path         string
+		Concurrency  int
+		NoBaseline   bool
+		ShowBaseline bool
+	}{}
+
+	// Paths command options
+	PathsOptions = struct {
+		Wordlist       string
+		NoDefaultPaths bool
+		Concurrency    int
+		NoBaseline     bool
+	}{}
+
+	// Crawl command options
+	CrawlOptions = struct {
+		Depth        int
+		MaxPages     int
+		Concurrency  int
+		IgnoreRobots bool
+	}{}
+
 	// Scan command options
 	ScanOptions = struct {
 		Targets       []string
@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// flagKeys maps config keys to the flags that override them
+var flagKeys = []struct {
+	key  string
+	flag string
+}{
+	{"log.level", "log-level"},
+	{"log.format", "log-format"},
+	{"log.file", "log-file"},
+	{"fetch.timeout", "timeout"},
+	{"fetch.user_agent", "user-agent"},
+	{"fetch.insecure", "insecure"},
+	{"fetch.proxies", "proxy"},
+	{"fetch.proxy_rules", "proxy-rule"},
+	{"fetch.headers", "header"},
+	{"fetch.cookie_file", "cookie-file"},
+	{"fetch.basic_auth", "basic-auth"},
+	{"fetch.bearer_token", "bearer-token"},
+	{"fetch.client_cert", "client-cert"},
+	{"fetch.client_key", "client-key"},
+	{"fetch.retries", "retries"},
+	{"fetch.retry_backoff", "retry-backoff"},
+	{"fetch.retry_max_time", "retry-max-time"},
+	{"fetch.strict", "strict"},
+	{"fetch.accept_encoding", "accept-encoding"},
+	{"fetch.hash_mode", "hash-mode"},
+	{"fetch.max_redirects", "max-redirects"},
+	{"fetch.same_host_redirects", "same-host-redirects"},
+	{"fetch.follow_meta_refresh", "follow-meta-refresh"},
+	{"fetch.resolve", "resolve"},
+	{"fetch.connect_to", "connect-to"},
+	{"fetch.dns_server", "dns-server"},
+	{"output.uint32", "uint32"},
+	{"output.fofa", "fofa"},
+	{"output.shodan", "shodan"},
+	{"server.host", "host"},
+	{"server.port", "port"},
+	{"server.read_timeout", "read-timeout"},
+	{"server.write_timeout", "write-timeout"},
+	{"server.auth_token", "auth-token"},
+	{"server.keys_file", "keys-file"},
+	{"server.allow_query_token", "allow-query-token"},
+	{"server.metrics", "metrics"},
+	{"server.metrics_addr", "metrics-addr"},
+	{"server.metrics_token", "metrics-token"},
+	{"server.rate_limit", "rate-limit"},
+	{"server.rate_burst", "rate-burst"},
+	{"server.daily_quota", "daily-quota"},
+	{"server.host_rate_limit", "host-rate-limit"},
+	{"server.host_burst", "host-burst"},
+	{"server.trust_proxy", "trust-proxy"},
+	{"server.allowed_request_headers", "allow-request-header"},
+	{"server.allow_request_cookies", "allow-request-cookies"},
+	{"server.allow_request_auth", "allow-request-auth"},
+	{"server.credential_hosts", "credential-host"},
+	{"files.roots", "file-root"},
+	{"files.max_size", "max-file-size"},
+	{"cache.enabled", "cache"},
+	{"cache.dir", "cache-dir"},
+	{"cache.ttl", "cache-ttl"},
+	{"cache.max_size", "cache-max-size"},
+}
+
+// NewConfigCommand 创建配置命令
+func NewConfigCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration",
+		Long: `Inspect the configuration.
+
+Settings are merged in this order, each overriding the previous one:
+  1. built-in defaults
+  2. the config file: --config, ICONHASH_CONFIG, or config.yaml, config.yml,
+     config.toml or config.json in the iconhash user config directory
+  3. the profile: --profile, ICONHASH_PROFILE, or "profile" in the file;
+     "stealth" and "fast" are built in, and the file may define more
+  4. ICONHASH_* environment variables, such as ICONHASH_SERVER_PORT
+  5. command line flags`,
+	}
+
+	var output string
+	show := &cobra.Command{
+		Use:   "show",
+		Short: "Print the effective configuration",
+		Long: `Print the effective configuration after merging the defaults, the config
+file, the profile, the environment and the flags. Secrets are redacted.
+
+Examples:
+  iconhash config show
+  iconhash config show --profile stealth --output json
+  ICONHASH_SERVER_PORT=9000 iconhash config show`,
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{skipLogoAnnotation: "true"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := config.Encode(Effective.Redact(), output)
+			if err != nil {
+				return err
+			}
+			if output != "json" {
+				source := ConfigSource
+				if source == "" {
+					source = "none"
+				}
+				fmt.Printf("# Config file: %s\n", source)
+			}
+			_, err = os.Stdout.Write(data)
+			return err
+		},
+	}
+	show.Flags().StringVar(&output, "output", "yaml", "Output format (yaml, toml, json)")
+
+	cmd.AddCommand(show)
+	return cmd
+}
+
+// preRun loads the configuration into the flags and sets up logging
+func preRun(cmd *cobra.Command, args []string) error {
+	if err := loadConfig(cmd.Flags()); err != nil {
+		return err
+	}
+	return setupLogging(cmd, args)
+}
+
+// loadConfig merges the configuration and the flags. Flags given on the
+// command line override the config; every other flag takes its value from
+// the config, so the globals hold the effective settings.
+func loadConfig(flags *pflag.FlagSet) error {
+	cfg, source, err := config.Load(config.LoadOptions{File: ConfigFile, Profile: Profile})
+	if err != nil {
+		return err
+	}
+
+	for _, binding := range flagKeys {
+		flag := flags.Lookup(binding.flag)
+		if flag == nil {
+			continue
+		}
+
+		if flag.Changed {
+			var err error
+			if slice, ok := flag.Value.(pflag.SliceValue); ok {
+				err = cfg.SetList(binding.key, slice.GetSlice())
+			} else {
+				err = cfg.Set(binding.key, flag.Value.String())
+			}
+			if err != nil {
+				return fmt.Errorf("--%s: %w", binding.flag, err)
+			}
+			continue
+		}
+
+		var err error
+		if slice, ok := flag.Value.(pflag.SliceValue); ok {
+			var items []string
+			if items, err = cfg.List(binding.key); err == nil {
+				err = slice.Replace(items)
+			}
+		} else {
+			var value string
+			if value, err = cfg.Get(binding.key); err == nil {
+				err = flag.Value.Set(value)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", binding.key, err)
+		}
+	}
+
+	Effective, ConfigSource = cfg, source
+	return nil
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/hasher"
+	"github.com/cyberspacesec/go-iconhash/pkg/recon"
+	"github.com/spf13/cobra"
+)
+
+// NewCrawlCommand 创建站点爬取命令
+func NewCrawlCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "crawl [url]",
+		Short: "Crawl a site to find the favicons of its applications",
+		Long: `Crawl a site and report the distinct favicons declared by its pages.
+
+Large hosts often run several applications under different paths, each with
+its own icon. This command visits the pages of the site breadth first,
+following links to the same scheme, host and port, and extracts the icons
+declared by every page. Each distinct icon is reported with the pages that
+declare it, together with the /favicon.ico of the site.
+
+Pages disallowed by the robots.txt of the site are not visited unless
+--ignore-robots is given.
+
+Examples:
+  iconhash crawl https://example.com
+  iconhash crawl https://example.com/portal/ --depth 3 --max-pages 200
+  iconhash crawl https://example.com --format json`,
+		Args: cobra.ExactArgs(1),
+		RunE: runCrawl,
+	}
+
+	cmd.Flags().IntVar(&CrawlOptions.Depth, "depth", recon.DefaultMaxDepth, "Links to follow from the start page (0 = only the start page)")
+	cmd.Flags().IntVar(&CrawlOptions.MaxPages, "max-pages", recon.DefaultMaxPages, "Pages to visit at most")
+	cmd.Flags().IntVarP(&CrawlOptions.Concurrency, "concurrency", "c", recon.DefaultConcurrency, "Pages or icons requested at once")
+	cmd.Flags().BoolVar(&CrawlOptions.IgnoreRobots, "ignore-robots", false, "Also visit the pages disallowed by robots.txt")
+
+	return cmd
+}
+
+// runCrawl handles the crawl command execution
+func runCrawl(cmd *cobra.Command, args []string) error {
+	if CrawlOptions.Depth == 0 {
+		// 0 means the default depth to recon.Crawl
+		CrawlOptions.Depth = -1
+	}
+
+	proxy, err := newProxySelector()
+	if err != nil {
+		return err
+	}
+	options := &hasher.HashOptions{
+		UseUint32:          Uint32Flag,
+		RequestTimeout:     Timeout,
+		InsecureSkipVerify: SkipVerify,
+		UserAgent:          UserAgent,
+		Proxy:              proxy,
+		Retry:              newRetryPolicy(),
+		Cache:              newCache(),
+		RefreshCache:       RefreshCache,
+		Strict:             Strict,
+	}
+	if err := applyRequestOptions(options); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	slog.Info("Crawling site", "url", args[0], "depth", CrawlOptions.Depth, "max_pages", CrawlOptions.MaxPages)
+	report, err := recon.Crawl(ctx, hasher.New(options), args[0], &recon.CrawlOptions{
+		MaxDepth:     CrawlOptions.Depth,
+		MaxPages:     CrawlOptions.MaxPages,
+		Concurrency:  CrawlOptions.Concurrency,
+		IgnoreRobots: CrawlOptions.IgnoreRobots,
+	})
+	if err != nil {
+		return err
+	}
+	for _, page := range report.Pages {
+		if page.Error != "" {
+			slog.Warn("Page not crawled", "url", page.URL, "error", page.Error)
+		}
+	}
+	if report.Disallowed > 0 {
+		slog.Info("Links disallowed by robots.txt", "count", report.Disallowed)
+	}
+
+	if OutputFormat == "json" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	printIcons(report.Icons, fmt.Sprintf("%d pages", len(report.Pages)))
+	return nil
+}
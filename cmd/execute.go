@@ -1,5 +1,15 @@
 package cmd
 
+import "os"
+
+// skipLogoAnnotation marks commands whose stdout must contain nothing but
+// their own output, such as the MCP stdio transport
+const skipLogoAnnotation = "iconhash/skip-logo"
+
+// logLevelAnnotation sets the log level of a command when neither the flags
+// nor the config choose one; other commands log warnings and errors only
+const logLevelAnnotation = "iconhash/log-level"
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once.
 func Execute() {
@@ -7,11 +17,28 @@ func Execute() {
 	Initialize()
 
 	// Print logo (before any Cobra output)
-	PrintLogo()
+	if !skipLogo(os.Args[1:]) {
+		PrintLogo()
+	}
 
 	// Execute the root command
 	err := RootCmd.Execute()
+	if logCloser != nil {
+		logCloser.Close()
+	}
 	if err != nil {
 		HandleError(err)
 	}
 }
+
+// skipLogo reports whether the command selected by args suppresses the logo,
+// or whether args ask for JSON output
+func skipLogo(args []string) bool {
+	for i, arg := range args {
+		if arg == "--format=json" || (arg == "--format" && i+1 < len(args) && args[i+1] == "json") {
+			return true
+		}
+	}
+	cmd, _, err := RootCmd.Find(args)
+	return err == nil && cmd.Annotations[skipLogoAnnotation] == "true"
+}
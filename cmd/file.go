@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
 
 	"github.com/cyberspacesec/go-iconhash/pkg/hasher"
@@ -63,14 +64,10 @@ func runFile(cmd *cobra.Command, args []string) {
 	}
 	h := hasher.New(options)
 
-	// Debug info if enabled
-	if Debug {
-		fmt.Fprintf(os.Stderr, "🔍 File: %s\n", FilePath)
-		fmt.Fprintf(os.Stderr, "🔧 Options: uint32=%v\n", options.UseUint32)
-	}
+	slog.Debug("Hash options", "uint32", options.UseUint32)
 
 	// Read file data
-	fmt.Fprintf(os.Stderr, "📂 Reading file %s...\n", FilePath)
+	slog.Info("Reading file", "path", FilePath)
 	fileData, err := os.ReadFile(FilePath)
 	if err != nil {
 		color.Red("❌ Error reading file: %v", err)
@@ -78,7 +75,7 @@ func runFile(cmd *cobra.Command, args []string) {
 	}
 
 	// Calculate hash
-	fmt.Fprintf(os.Stderr, "🧮 Calculating hash...\n")
+	slog.Debug("Calculating hash", "bytes", len(fileData))
 	hash, err := h.HashFromBytes(fileData)
 	if err != nil {
 		color.Red("❌ Error calculating hash: %v", err)
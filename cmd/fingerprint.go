@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/hasher"
+	"github.com/cyberspacesec/go-iconhash/pkg/logging"
+	"github.com/cyberspacesec/go-iconhash/pkg/util"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// NewFingerprintCommand 创建站点指纹命令
+func NewFingerprintCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "fingerprint [url]",
+		Short: "Collect the favicon hash and pivot metadata of a site",
+		Long: `Collect the favicon hash of a site together with the metadata search engines
+index, for pivoting to related hosts: the page title, the Server and
+X-Powered-By headers, the HTML hash and the TLS certificate subject, issuer,
+SANs, SHA-256 fingerprint and serial.
+
+The page is fingerprinted whatever its HTTP status, which is printed too, so
+that login and error pages can be pivoted on. It is fetched without the
+cache, and its favicon with the same connection when the server keeps it
+alive. The favicon is the first icon declared by the page that can be
+fetched, or /favicon.ico.
+
+Each field is printed as a query for the engines selected with --fofa and
+--shodan, or for both when neither is set.
+
+Examples:
+  iconhash fingerprint https://example.com
+  iconhash fingerprint https://example.com --shodan
+  iconhash fingerprint https://example.com --format json`,
+		Args: cobra.ExactArgs(1),
+		RunE: runFingerprint,
+	}
+
+	return cmd
+}
+
+// fingerprintQuery is a field of a fingerprint formatted for an engine
+type fingerprintQuery struct {
+	Field string `json:"field"`
+	Value string `json:"value"`
+	Query string `json:"query"`
+}
+
+// fingerprintOutput is the JSON output of the fingerprint command
+type fingerprintOutput struct {
+	*hasher.Fingerprint
+	// Queries are the queries of each engine, by engine name
+	Queries map[string][]fingerprintQuery `json:"queries"`
+}
+
+// runFingerprint handles the fingerprint command execution
+func runFingerprint(cmd *cobra.Command, args []string) error {
+	proxy, err := newProxySelector()
+	if err != nil {
+		return err
+	}
+	options := &hasher.HashOptions{
+		UseUint32:          Uint32Flag,
+		RequestTimeout:     Timeout,
+		InsecureSkipVerify: SkipVerify,
+		UserAgent:          UserAgent,
+		Proxy:              proxy,
+		Retry:              newRetryPolicy(),
+		Strict:             Strict,
+	}
+	if err := applyRequestOptions(options); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	slog.Info("Fingerprinting", "url", logging.RedactURL(args[0]))
+	result, err := hasher.New(options).Fingerprint(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	logAttempts(result.Fetch.Attempts)
+
+	engines := fingerprintEngines()
+	queries := make(map[string][]fingerprintQuery)
+	for _, engine := range engines {
+		queries[engine.Name] = fingerprintQueries(result, engine.Format)
+	}
+
+	if OutputFormat == "json" {
+		data, err := json.MarshalIndent(fingerprintOutput{Fingerprint: result, Queries: queries}, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	boldGreen := color.New(color.FgGreen, color.Bold)
+	boldCyan := color.New(color.FgCyan, color.Bold)
+	boldGreen.Println("✅ Fingerprint collected!")
+	fmt.Println()
+
+	field := func(name, value string) {
+		if value != "" {
+			boldCyan.Printf("%s: ", name)
+			fmt.Println(value)
+		}
+	}
+	if result.FinalURL != result.URL {
+		field("Final URL", result.FinalURL)
+	}
+	field("Status", strconv.Itoa(result.StatusCode))
+	field("Favicon hash", result.FaviconHash)
+	field("Favicon URL", result.FaviconURL)
+	field("Title", result.Title)
+	field("Server", result.Server)
+	field("X-Powered-By", result.PoweredBy)
+	field("HTML hash", result.HTMLHash)
+	if cert := result.Certificate; cert != nil {
+		boldCyan.Println("Certificate:")
+		fmt.Printf("  Subject: %s\n", cert.Subject)
+		fmt.Printf("  Issuer:  %s\n", cert.Issuer)
+		for _, san := range cert.SANs {
+			fmt.Printf("  SAN:     %s\n", san)
+		}
+		fmt.Printf("  SHA-256: %s\n", cert.SHA256)
+		fmt.Printf("  Serial:  %s\n", cert.Serial)
+	}
+	if result.FaviconError != "" {
+		color.Yellow("⚠️  No favicon could be hashed: %s", result.FaviconError)
+	}
+
+	for _, engine := range engines {
+		fmt.Println()
+		boldCyan.Printf("%s queries:\n", engine.Title)
+		for _, query := range queries[engine.Name] {
+			fmt.Printf("  %s\n", query.Query)
+		}
+	}
+	return nil
+}
+
+// fingerprintEngines returns the engines selected with --fofa and --shodan,
+// or both when neither is set
+func fingerprintEngines() []util.Engine {
+	var engines []util.Engine
+	for _, engine := range util.Engines() {
+		switch engine.Format {
+		case util.FormatFofa:
+			if FofaFormat || !ShodanFormat {
+				engines = append(engines, engine)
+			}
+		case util.FormatShodan:
+			if ShodanFormat || !FofaFormat {
+				engines = append(engines, engine)
+			}
+		}
+	}
+	return engines
+}
+
+// fingerprintQueries formats the fields of a fingerprint as queries, leaving
+// out empty fields and fields the engine does not index
+func fingerprintQueries(result *hasher.Fingerprint, format util.OutputFormat) []fingerprintQuery {
+	fields := [][2]string{
+		{util.FieldFavicon, result.FaviconHash},
+		{util.FieldTitle, result.Title},
+		{util.FieldServer, result.Server},
+		{util.FieldPoweredBy, result.PoweredBy},
+		{util.FieldHTML, result.HTMLHash},
+	}
+	if cert := result.Certificate; cert != nil {
+		fields = append(fields,
+			[2]string{util.FieldCertSubject, cert.SubjectCN},
+			[2]string{util.FieldCertIssuer, cert.IssuerCN})
+		for _, san := range cert.SANs {
+			fields = append(fields, [2]string{util.FieldCertSAN, san})
+		}
+		fields = append(fields,
+			[2]string{util.FieldCertSHA256, cert.SHA256},
+			[2]string{util.FieldCertSerial, cert.Serial})
+	}
+
+	var queries []fingerprintQuery
+	for _, f := range fields {
+		if f[1] == "" {
+			continue
+		}
+		if query := util.FormatQuery(f[0], f[1], format); query != "" {
+			queries = append(queries, fingerprintQuery{Field: f[0], Value: f[1], Query: query})
+		}
+	}
+	return queries
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/api"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// NewKeygenCommand 创建API密钥生成命令
+func NewKeygenCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "keygen",
+		Short: "Generate an API key for the server",
+		Long: `Generate a new API key for the HTTP API server.
+
+The secret is printed once and is not stored anywhere. Add the printed JSON
+entry to the "keys" array of the key file passed to "iconhash server --keys-file".
+
+Scopes: ` + strings.Join(api.AllScopes, ", ") + ` (admin grants every scope)
+
+Examples:
+  iconhash keygen --name ci --scope hash:url
+  iconhash keygen --name agent --scope mcp --scope hash:url --expires 720h`,
+		Args: cobra.NoArgs,
+		RunE: runKeygen,
+	}
+
+	cmd.Flags().StringVar(&KeygenOptions.Name, "name", "", "Name of the key (required)")
+	cmd.Flags().StringSliceVar(&KeygenOptions.Scopes, "scope", []string{api.ScopeHashURL}, "Scopes granted to the key (repeatable)")
+	cmd.Flags().DurationVar(&KeygenOptions.Expires, "expires", 0, "Key lifetime, e.g. 720h (0 = never expires)")
+	cmd.MarkFlagRequired("name")
+
+	return cmd
+}
+
+// runKeygen handles the keygen command execution
+func runKeygen(cmd *cobra.Command, args []string) error {
+	var expires *time.Time
+	if KeygenOptions.Expires > 0 {
+		t := time.Now().Add(KeygenOptions.Expires).UTC().Truncate(time.Second)
+		expires = &t
+	}
+
+	secret, key, err := api.GenerateKey(KeygenOptions.Name, KeygenOptions.Scopes, expires)
+	if err != nil {
+		return err
+	}
+
+	entry, err := json.MarshalIndent(key, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	yellow := color.New(color.FgYellow).SprintFunc()
+	fmt.Printf("%s %s\n", yellow("Secret (shown once):"), secret)
+	fmt.Printf("\n%s\n%s\n", yellow("Key file entry:"), entry)
+	return nil
+}
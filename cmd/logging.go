@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"io"
+	"log/slog"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/logging"
+	"github.com/spf13/cobra"
+)
+
+// logCloser closes the --log-file once the command has finished
+var logCloser io.Closer
+
+// setupLogging installs the default slog logger configured by the log
+// flags. --debug lowers the level to debug unless --log-level is given.
+// Without a level, one-shot commands only log warnings and errors.
+func setupLogging(cmd *cobra.Command, args []string) error {
+	options := &logging.Options{
+		Level:  LogLevel,
+		Format: LogFormat,
+		File:   LogFile,
+	}
+	if Debug && !cmd.Flags().Changed("log-level") {
+		options.Level = "debug"
+	}
+	if options.Level == "" {
+		options.Level = "warn"
+		if level := cmd.Annotations[logLevelAnnotation]; level != "" {
+			options.Level = level
+		}
+	}
+
+	logger, closer, err := logging.New(options)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	logCloser = closer
+	return nil
+}
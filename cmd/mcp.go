@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/hasher"
+	"github.com/cyberspacesec/go-iconhash/pkg/mcp"
+	"github.com/spf13/cobra"
+)
+
+// NewMCPCommand 创建MCP stdio命令
+func NewMCPCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "mcp",
+		Short: "Serve the Model Context Protocol over stdio",
+		Long: `Serve the Model Context Protocol over stdin and stdout.
+
+MCP clients such as desktop AI assistants launch this command as a subprocess
+and exchange newline-delimited JSON-RPC messages with it. The tools are the
+same as on the HTTP server's /v1/mcp route. Logs are written to stderr.
+
+The hash_file tool, which hashes local files, is only offered when at least
+one --file-root directory is given, and only reads files below those roots.
+
+Example client configuration:
+  {"mcpServers": {"iconhash": {"command": "iconhash", "args": ["mcp", "--file-root", "/path/to/icons"]}}}`,
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{skipLogoAnnotation: "true"},
+		RunE:        runMCP,
+	}
+
+	addFileAccessFlags(cmd)
+
+	return cmd
+}
+
+// addFileAccessFlags adds the flags that enable sandboxed local file hashing
+func addFileAccessFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&FileRoots, "file-root", nil, "Allow hashing files below this directory (repeatable; disabled by default)")
+	cmd.Flags().Int64Var(&MaxFileSize, "max-file-size", hasher.DefaultMaxFileSize, "Maximum size in bytes of local files")
+}
+
+// newFileSandbox creates the sandbox for --file-root, or nil when local file
+// access is disabled
+func newFileSandbox() (*hasher.FileSandbox, error) {
+	if len(FileRoots) == 0 {
+		return nil, nil
+	}
+	sandbox, err := hasher.NewFileSandbox(FileRoots, MaxFileSize)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --file-root: %w", err)
+	}
+	return sandbox, nil
+}
+
+// runMCP handles the mcp command execution
+func runMCP(cmd *cobra.Command, args []string) error {
+	proxy, err := newProxySelector()
+	if err != nil {
+		return err
+	}
+
+	options := &hasher.HashOptions{
+		RequestTimeout:     Timeout,
+		InsecureSkipVerify: SkipVerify,
+		UserAgent:          UserAgent,
+		Proxy:              proxy,
+		Retry:              newRetryPolicy(),
+		Cache:              newCache(),
+		RefreshCache:       RefreshCache,
+		Strict:             Strict,
+	}
+	if err := applyRequestOptions(options); err != nil {
+		return err
+	}
+	if options.UserAgent == "" {
+		options.UserAgent = hasher.DefaultOptions().UserAgent
+	}
+
+	mcp.ServerVersion = Version
+	handler := mcp.NewHandlerWithHasher(hasher.New(options), slog.Default())
+
+	sandbox, err := newFileSandbox()
+	if err != nil {
+		return err
+	}
+	if sandbox != nil {
+		handler.SetFileSandbox(sandbox)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	slog.Debug("MCP server ready on stdio", "version", Version)
+
+	if err := handler.ServeStdio(ctx, os.Stdin, os.Stdout); err != nil && err != context.Canceled {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/hasher"
+	"github.com/cyberspacesec/go-iconhash/pkg/recon"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// NewPathsCommand 创建常见图标路径探测命令
+func NewPathsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "paths [url]",
+		Short: "Probe common favicon paths of a host",
+		Long: `Probe the favicon paths of a built-in wordlist and report every distinct icon.
+
+Many appliances do not serve /favicon.ico but do serve product specific paths
+such as /static/img/favicon.png or /vpn/images/AccessGateway.ico. This command
+requests each path of the wordlist and reports the distinct icons found, each
+with the URLs that served it. Responses that are not images, and responses
+matching those to random paths that do not exist, are skipped.
+
+Paths are resolved against the URL, so paths starting with / are relative to
+the root of the host. Extend the wordlist with --wordlist, or replace it with
+--wordlist and --no-default-paths.
+
+Examples:
+  iconhash paths https://example.com
+  iconhash paths https://example.com -w extra-paths.txt -c 20
+  iconhash paths https://example.com --format json`,
+		Args: cobra.ExactArgs(1),
+		RunE: runPaths,
+	}
+
+	cmd.Flags().StringVarP(&PathsOptions.Wordlist, "wordlist", "w", "", "File of extra paths to probe, one per line")
+	cmd.Flags().BoolVar(&PathsOptions.NoDefaultPaths, "no-default-paths", false, "Only probe the paths of --wordlist")
+	cmd.Flags().IntVarP(&PathsOptions.Concurrency, "concurrency", "c", recon.DefaultConcurrency, "Paths requested at once")
+	cmd.Flags().BoolVar(&PathsOptions.NoBaseline, "no-baseline", false, "Do not request random paths to detect responses sent for any path")
+
+	return cmd
+}
+
+// runPaths handles the paths command execution
+func runPaths(cmd *cobra.Command, args []string) error {
+	var paths []string
+	if !PathsOptions.NoDefaultPaths {
+		paths = recon.DefaultPaths()
+	}
+	if PathsOptions.Wordlist != "" {
+		file, err := os.Open(PathsOptions.Wordlist)
+		if err != nil {
+			return err
+		}
+		words, err := recon.ReadWordlist(file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read --wordlist: %w", err)
+		}
+		paths = append(paths, words...)
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no paths: --no-default-paths requires --wordlist")
+	}
+
+	proxy, err := newProxySelector()
+	if err != nil {
+		return err
+	}
+	options := &hasher.HashOptions{
+		UseUint32:          Uint32Flag,
+		RequestTimeout:     Timeout,
+		InsecureSkipVerify: SkipVerify,
+		UserAgent:          UserAgent,
+		Proxy:              proxy,
+		Retry:              newRetryPolicy(),
+		Cache:              newCache(),
+		RefreshCache:       RefreshCache,
+		Strict:             Strict,
+	}
+	if err := applyRequestOptions(options); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	slog.Info("Probing favicon paths", "url", args[0], "paths", len(paths))
+	report, err := recon.ProbePaths(ctx, hasher.New(options), args[0], paths, &recon.PathOptions{
+		Concurrency: PathsOptions.Concurrency,
+		NoBaseline:  PathsOptions.NoBaseline,
+	})
+	if err != nil {
+		return err
+	}
+
+	if OutputFormat == "json" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	printIcons(report.Icons, fmt.Sprintf("%d paths", report.Probed))
+	return nil
+}
+
+// printIcons prints the distinct icons found in what
+func printIcons(icons []recon.Icon, what string) {
+	if len(icons) == 0 {
+		color.Yellow("⚠️  No favicon found in %s", what)
+		return
+	}
+
+	boldGreen := color.New(color.FgGreen, color.Bold)
+	boldCyan := color.New(color.FgCyan, color.Bold)
+	boldGreen.Printf("✅ %d distinct favicons found in %s\n", len(icons), what)
+	for _, icon := range icons {
+		fmt.Println()
+		boldCyan.Printf("Hash: ")
+		fmt.Printf("%s (%s, %d bytes)\n", icon.Hash, icon.Type, icon.Length)
+		for _, url := range icon.URLs {
+			fmt.Printf("  %s\n", url)
+		}
+		if len(icon.Pages) > 0 {
+			boldCyan.Println("  Declared by:")
+			for _, page := range icon.Pages {
+				fmt.Printf("    %s\n", page)
+			}
+		}
+	}
+}
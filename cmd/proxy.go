@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/hasher"
+)
+
+// newProxySelector creates the proxy selector for --proxy and --proxy-rule,
+// or nil to use the proxy environment variables
+func newProxySelector() (*hasher.ProxySelector, error) {
+	if len(Proxies) == 0 && len(ProxyRules) == 0 {
+		return nil, nil
+	}
+
+	rules := make([]hasher.ProxyRule, 0, len(ProxyRules))
+	for _, s := range ProxyRules {
+		rule, err := hasher.ParseProxyRule(s)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	selector, err := hasher.NewProxySelector(Proxies, rules)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy settings: %w", err)
+	}
+	return selector, nil
+}
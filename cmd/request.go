@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/cache"
+	"github.com/cyberspacesec/go-iconhash/pkg/hasher"
+)
+
+// applyRequestOptions adds the headers, cookies and credentials of --header,
+// --cookie-file, --basic-auth, --bearer-token and --client-cert, the
+// encodings of --accept-encoding and --hash-mode, and the redirect policy of
+// --max-redirects, --no-follow, --same-host-redirects and
+// --follow-meta-refresh, and the connection overrides of --resolve,
+// --connect-to and --dns-server to options
+func applyRequestOptions(options *hasher.HashOptions) error {
+	if !hasher.ValidHashMode(HashMode) {
+		return fmt.Errorf("invalid --hash-mode %q: expected %s", HashMode, strings.Join(hasher.HashModes, ", "))
+	}
+	options.HashMode = HashMode
+	options.AcceptEncoding = AcceptEncoding
+
+	if MaxRedirects < 0 {
+		return fmt.Errorf("invalid --max-redirects %d", MaxRedirects)
+	}
+	options.Redirect = &hasher.RedirectPolicy{
+		MaxRedirects:      MaxRedirects,
+		SameHost:          SameHost,
+		FollowMetaRefresh: MetaRefresh,
+	}
+	if NoFollow {
+		options.Redirect.MaxRedirects = 0
+	}
+
+	for _, s := range Resolve {
+		rule, err := hasher.ParseResolve(s)
+		if err != nil {
+			return fmt.Errorf("invalid --resolve: %w", err)
+		}
+		options.Resolve = append(options.Resolve, rule)
+	}
+	for _, s := range ConnectTo {
+		rule, err := hasher.ParseConnectTo(s)
+		if err != nil {
+			return fmt.Errorf("invalid --connect-to: %w", err)
+		}
+		options.ConnectTo = append(options.ConnectTo, rule)
+	}
+	options.DNSServer = DNSServer
+
+	headers, err := hasher.ParseHeaders(Headers)
+	if err != nil {
+		return fmt.Errorf("invalid --header: %w", err)
+	}
+	options.Headers = headers
+
+	if CookieFile != "" {
+		jar, err := hasher.LoadCookieFile(CookieFile)
+		if err != nil {
+			return err
+		}
+		options.Cookies = jar
+	}
+
+	if BasicAuth != "" {
+		auth, err := hasher.ParseBasicAuth(BasicAuth)
+		if err != nil {
+			return fmt.Errorf("invalid --basic-auth: %w", err)
+		}
+		options.Auth = auth
+	}
+	if BearerToken != "" {
+		if options.Auth == nil {
+			options.Auth = &hasher.Credentials{}
+		}
+		options.Auth.BearerToken = BearerToken
+	}
+
+	if ClientCert != "" {
+		cert, err := hasher.LoadClientCertificate(ClientCert, ClientKey)
+		if err != nil {
+			return err
+		}
+		options.ClientCert = cert
+	} else if ClientKey != "" {
+		return fmt.Errorf("--client-key requires --client-cert")
+	}
+	return nil
+}
+
+// newRetryPolicy creates the retry policy of --retries, --retry-backoff and
+// --retry-max-time, or nil to fetch once
+func newRetryPolicy() *hasher.RetryPolicy {
+	if Retries <= 0 {
+		return nil
+	}
+	policy := hasher.DefaultRetryPolicy()
+	policy.MaxAttempts = Retries + 1
+	policy.InitialBackoff = RetryBackoff
+	policy.MaxElapsed = RetryMaxTime
+	return policy
+}
+
+// newCache opens the fetch cache of --cache-dir, --cache-ttl and
+// --cache-max-size, or returns nil if it is disabled. A cache that cannot be
+// opened is logged and skipped rather than failing the command.
+func newCache() *cache.Cache {
+	if !CacheEnabled || NoCache {
+		return nil
+	}
+	dir := CacheDir
+	if dir == "" {
+		var err error
+		if dir, err = cache.DefaultDir(); err != nil {
+			slog.Warn("Fetch cache disabled", "error", err)
+			return nil
+		}
+	}
+	c, err := cache.Open(dir, &cache.Options{TTL: CacheTTL, MaxSize: CacheMaxSize})
+	if err != nil {
+		slog.Warn("Fetch cache disabled", "error", err)
+		return nil
+	}
+	slog.Debug("Using fetch cache", "dir", dir, "ttl", CacheTTL)
+	return c
+}
+
+// logAttempts logs the failed attempts of a fetch that was retried
+func logAttempts(attempts []hasher.Attempt) {
+	for _, attempt := range attempts {
+		if attempt.Error == "" {
+			continue
+		}
+		slog.Warn("Fetch attempt failed", "attempt", attempt.Number, "status", attempt.StatusCode,
+			"reason", attempt.Reason, "duration", attempt.Duration, "retry_in", attempt.Wait)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/cyberspacesec/go-iconhash/pkg/config"
 	"github.com/cyberspacesec/go-iconhash/pkg/util"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
@@ -14,6 +15,8 @@ var RootCmd = &cobra.Command{
 	Use:     "iconhash [flags] [url or file]",
 	Short:   "Icon Hash Calculator - A tool for cybersecurity reconnaissance",
 	Version: Version,
+	// Every command reads the configuration and logs through the default slog logger
+	PersistentPreRunE: preRun,
 	Long: `Icon Hash Calculator - A tool for cybersecurity reconnaissance
 
 Calculate the MMH3 hash of a favicon.ico file for use with search engines like
@@ -97,9 +100,20 @@ func Initialize() {
 	RootCmd.AddCommand(NewFileCommand())
 	RootCmd.AddCommand(NewBase64Command())
 	RootCmd.AddCommand(NewServerCommand())
+	RootCmd.AddCommand(NewKeygenCommand())
+	RootCmd.AddCommand(NewMCPCommand())
+	RootCmd.AddCommand(NewConfigCommand())
+	RootCmd.AddCommand(NewVHostsCommand())
+	RootCmd.AddCommand(NewPathsCommand())
+	RootCmd.AddCommand(NewCrawlCommand())
+	RootCmd.AddCommand(NewFingerprintCommand())
 
-	// Define global flags
-	RootCmd.PersistentFlags().BoolVarP(&Debug, "debug", "d", false, "Enable debug output")
+	// Define global flags. Their defaults come from the config package;
+	// flags left unset take the value of the config file or environment.
+	defaults := config.Default()
+	RootCmd.PersistentFlags().StringVar(&ConfigFile, "config", "", "Config file (YAML, TOML or JSON; env: ICONHASH_CONFIG)")
+	RootCmd.PersistentFlags().StringVar(&Profile, "profile", "", "Config profile, such as stealth or fast (env: ICONHASH_PROFILE)")
+	RootCmd.PersistentFlags().BoolVarP(&Debug, "debug", "d", false, "Enable debug output (same as --log-level debug)")
 	RootCmd.PersistentFlags().BoolVarP(&Uint32Flag, "uint32", "n", false, "Output hash as uint32 instead of int32")
 	RootCmd.PersistentFlags().StringVarP(&URL, "url", "u", "", "URL to favicon")
 	RootCmd.PersistentFlags().StringVarP(&FilePath, "file", "f", "", "Path to favicon file")
@@ -107,7 +121,38 @@ func Initialize() {
 	RootCmd.PersistentFlags().StringVarP(&UserAgent, "user-agent", "a", "", "User agent for HTTP requests")
 	RootCmd.PersistentFlags().BoolVarP(&FofaFormat, "fofa", "o", false, "Format output for Fofa search")
 	RootCmd.PersistentFlags().BoolVarP(&ShodanFormat, "shodan", "s", false, "Format output for Shodan search")
+	RootCmd.PersistentFlags().BoolVar(&BodyHash, "body", false, "Hash the page body and title instead of the favicon (Shodan http.html_hash)")
 	RootCmd.PersistentFlags().BoolVarP(&SkipVerify, "insecure", "k", false, "Skip TLS certificate verification")
-	RootCmd.PersistentFlags().DurationVarP(&Timeout, "timeout", "t", 30*time.Second, "HTTP request timeout")
+	RootCmd.PersistentFlags().StringArrayVar(&Proxies, "proxy", nil, "Proxy URL: http, https, socks5 or socks5h, with user:password (repeatable; rotated)")
+	RootCmd.PersistentFlags().StringArrayVar(&ProxyRules, "proxy-rule", nil, "Route hosts through a proxy, as pattern=proxy or pattern=direct (repeatable)")
+	RootCmd.PersistentFlags().StringArrayVarP(&Headers, "header", "H", nil, "Extra request header, as \"Name: value\" (repeatable)")
+	RootCmd.PersistentFlags().StringVar(&CookieFile, "cookie-file", "", "Send cookies from a Netscape cookies.txt file")
+	RootCmd.PersistentFlags().StringVar(&BasicAuth, "basic-auth", "", "Basic authentication, as user:password")
+	RootCmd.PersistentFlags().StringVar(&BearerToken, "bearer-token", "", "Bearer token sent in the Authorization header")
+	RootCmd.PersistentFlags().StringVar(&ClientCert, "client-cert", "", "PEM client certificate for mutual TLS")
+	RootCmd.PersistentFlags().StringVar(&ClientKey, "client-key", "", "PEM key of the client certificate (default: the --client-cert file)")
+	RootCmd.PersistentFlags().IntVar(&Retries, "retries", defaults.Fetch.Retries, "Retries of fetches failing with a timeout, dropped connection, 429 or 5xx")
+	RootCmd.PersistentFlags().DurationVar(&RetryBackoff, "retry-backoff", time.Duration(defaults.Fetch.RetryBackoff), "Wait before the first retry, doubled for each later one")
+	RootCmd.PersistentFlags().DurationVar(&RetryMaxTime, "retry-max-time", time.Duration(defaults.Fetch.RetryMaxTime), "Give up retrying once a fetch has taken this long (0 = no limit)")
+	RootCmd.PersistentFlags().BoolVar(&Strict, "strict", defaults.Fetch.Strict, "Fail when a fetched favicon is not an image, such as an HTML error page")
+	RootCmd.PersistentFlags().StringVar(&AcceptEncoding, "accept-encoding", defaults.Fetch.AcceptEncoding, "Accept-Encoding header of fetches (gzip, deflate and br are decoded; identity asks for none)")
+	RootCmd.PersistentFlags().StringVar(&HashMode, "hash-mode", defaults.Fetch.HashMode, "Bytes to hash: decoded (as search engines do), raw (as transferred) or both")
+	RootCmd.PersistentFlags().IntVar(&MaxRedirects, "max-redirects", defaults.Fetch.MaxRedirects, "Redirects to follow before giving up")
+	RootCmd.PersistentFlags().BoolVar(&NoFollow, "no-follow", false, "Do not follow redirects (same as --max-redirects 0)")
+	RootCmd.PersistentFlags().BoolVar(&SameHost, "same-host-redirects", defaults.Fetch.SameHostRedirects, "Only follow redirects to the same host and port")
+	RootCmd.PersistentFlags().BoolVar(&MetaRefresh, "follow-meta-refresh", defaults.Fetch.FollowMetaRefresh, "Follow <meta http-equiv=\"refresh\"> on pages searched for favicons")
+	RootCmd.PersistentFlags().StringArrayVar(&Resolve, "resolve", nil, "Connect to an address for a host, as host:port:addr[,addr] (repeatable; like curl)")
+	RootCmd.PersistentFlags().StringArrayVar(&ConnectTo, "connect-to", nil, "Connect to another host and port, as host:port:tohost:toport (repeatable; like curl)")
+	RootCmd.PersistentFlags().StringVar(&DNSServer, "dns-server", "", "DNS server resolving fetched hosts, as host[:port]")
+	RootCmd.PersistentFlags().BoolVar(&CacheEnabled, "cache", defaults.Cache.Enabled, "Cache fetched favicons on disk and revalidate them with conditional requests")
+	RootCmd.PersistentFlags().BoolVar(&NoCache, "no-cache", false, "Neither read nor write the fetch cache")
+	RootCmd.PersistentFlags().BoolVar(&RefreshCache, "refresh", false, "Ignore cached favicons and fetch them again, updating the cache")
+	RootCmd.PersistentFlags().StringVar(&CacheDir, "cache-dir", "", "Fetch cache directory (default: iconhash in the user cache directory)")
+	RootCmd.PersistentFlags().DurationVar(&CacheTTL, "cache-ttl", time.Duration(defaults.Cache.TTL), "Serve cached favicons without revalidation for this long")
+	RootCmd.PersistentFlags().Int64Var(&CacheMaxSize, "cache-max-size", defaults.Cache.MaxSize, "Trim the fetch cache to this many bytes, least recently used first")
+	RootCmd.PersistentFlags().DurationVarP(&Timeout, "timeout", "t", time.Duration(defaults.Fetch.Timeout), "HTTP request timeout")
 	RootCmd.PersistentFlags().StringVarP(&OutputFormat, "format", "", "text", "Output format (text, json, csv)")
+	RootCmd.PersistentFlags().StringVar(&LogLevel, "log-level", defaults.Log.Level, "Log level (debug, info, warn, error; default: info for server, warn otherwise)")
+	RootCmd.PersistentFlags().StringVar(&LogFormat, "log-format", defaults.Log.Format, "Log format (text, json)")
+	RootCmd.PersistentFlags().StringVar(&LogFile, "log-file", "", "Append logs to this file instead of stderr")
 }
@@ -2,11 +2,18 @@ package cmd
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/cyberspacesec/go-iconhash/pkg/api"
+	"github.com/cyberspacesec/go-iconhash/pkg/config"
+	"github.com/cyberspacesec/go-iconhash/pkg/hasher"
+	"github.com/cyberspacesec/go-iconhash/pkg/logging"
+	"github.com/cyberspacesec/go-iconhash/pkg/mcp"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
@@ -25,22 +32,85 @@ hash calculation, and supports the Model Context Protocol (MCP).
 Examples:
   iconhash server
   iconhash server -p 8080 --host 0.0.0.0
-  iconhash server --auth-token secret123 --debug`,
-		Run: runServer,
+  iconhash server --auth-token secret123 --debug
+  iconhash server --keys-file keys.json
+
+Server files can be hashed through /v1/hash/path and the MCP hash_file tool
+only when --file-root directories are given; this is disabled by default.
+
+API keys are read from --keys-file, the file named by ICONHASH_API_KEYS_FILE,
+or the JSON in ICONHASH_API_KEYS. Create keys with "iconhash keygen".`,
+		Run:         runServer,
+		Annotations: map[string]string{logLevelAnnotation: "info"},
 	}
 
 	// 添加服务器特定的标志
-	cmd.Flags().StringVarP(&Host, "host", "H", "127.0.0.1", "Host to bind server")
-	cmd.Flags().IntVarP(&Port, "port", "p", 8000, "Port to bind server")
-	cmd.Flags().StringVar(&AuthToken, "auth-token", "", "Authentication token for API requests")
-	cmd.Flags().DurationVar(&ReadTimeout, "read-timeout", 30, "HTTP server read timeout in seconds")
-	cmd.Flags().DurationVar(&WriteTimeout, "write-timeout", 30, "HTTP server write timeout in seconds")
+	defaults := config.Default().Server
+	cmd.Flags().StringVar(&Host, "host", defaults.Host, "Host to bind server")
+	cmd.Flags().IntVarP(&Port, "port", "p", defaults.Port, "Port to bind server")
+	cmd.Flags().StringVar(&AuthToken, "auth-token", "", "Authentication token for API requests (granted every scope)")
+	cmd.Flags().StringVar(&KeysFile, "keys-file", "", "JSON file of hashed API keys (env: ICONHASH_API_KEYS_FILE)")
+	cmd.Flags().BoolVar(&QueryToken, "allow-query-token", false, "Accept API tokens in the ?token= query parameter")
+	cmd.Flags().DurationVar(&ReadTimeout, "read-timeout", time.Duration(defaults.ReadTimeout), "HTTP server read timeout")
+	cmd.Flags().DurationVar(&WriteTimeout, "write-timeout", time.Duration(defaults.WriteTimeout), "HTTP server write timeout")
+	cmd.Flags().BoolVar(&Metrics, "metrics", false, "Serve Prometheus metrics at /metrics")
+	cmd.Flags().StringVar(&MetricsAddr, "metrics-addr", "", "Serve metrics on a separate address (e.g. 127.0.0.1:9090) instead of the API address")
+	cmd.Flags().StringVar(&MetricsToken, "metrics-token", "", "Bearer token required to read metrics")
+	cmd.Flags().Float64Var(&RateLimit, "rate-limit", defaults.RateLimit, "Requests per second per API key or client IP (0 = unlimited)")
+	cmd.Flags().IntVar(&RateBurst, "rate-burst", defaults.RateBurst, "Requests a client may make in a burst")
+	cmd.Flags().IntVar(&DailyQuota, "daily-quota", defaults.DailyQuota, "Requests per API key or client IP per UTC day (0 = unlimited)")
+	cmd.Flags().Float64Var(&HostRate, "host-rate-limit", defaults.HostRateLimit, "Outbound fetches per second per destination host (0 = unlimited)")
+	cmd.Flags().IntVar(&HostBurst, "host-burst", defaults.HostBurst, "Outbound fetches per destination host in a burst")
+	cmd.Flags().BoolVar(&TrustProxy, "trust-proxy", false, "Take the client IP from X-Forwarded-For/X-Real-IP (only behind a reverse proxy)")
+	cmd.Flags().StringArrayVar(&AllowedRequestHeaders, "allow-request-header", nil, "Header API clients may send with fetches (repeatable; * for any)")
+	cmd.Flags().BoolVar(&AllowRequestCookies, "allow-request-cookies", false, "Let API clients send cookies with fetches")
+	cmd.Flags().BoolVar(&AllowRequestAuth, "allow-request-auth", false, "Let API clients send basic or bearer credentials with fetches")
+	cmd.Flags().StringArrayVar(&CredentialHosts, "credential-host", nil, "Host pattern the -H, --cookie-file, --basic-auth, --bearer-token and --client-cert credentials are sent to (repeatable)")
+	addFileAccessFlags(cmd)
 
 	return cmd
 }
 
 // runServer handles the server command execution
 func runServer(cmd *cobra.Command, args []string) {
+	keys, err := loadAPIKeys()
+	if err != nil {
+		color.Red("❌ %v", err)
+		os.Exit(1)
+	}
+
+	sandbox, err := newFileSandbox()
+	if err != nil {
+		color.Red("❌ %v", err)
+		os.Exit(1)
+	}
+
+	proxy, err := newProxySelector()
+	if err != nil {
+		color.Red("❌ %v", err)
+		os.Exit(1)
+	}
+
+	var fetch hasher.HashOptions
+	if err := applyRequestOptions(&fetch); err != nil {
+		color.Red("❌ %v", err)
+		// -H used to be the shorthand of --host
+		for _, header := range Headers {
+			if !strings.Contains(header, ":") {
+				color.Yellow("⚠️  -H sets a request header; use --host to set the address to bind to")
+				break
+			}
+		}
+		os.Exit(1)
+	}
+	// API clients choose the hosts fetched, so the operator's credentials
+	// must be scoped to known hosts
+	hasCredentials := len(fetch.Headers) > 0 || fetch.Cookies != nil || fetch.Auth != nil || fetch.ClientCert != nil
+	if hasCredentials && len(CredentialHosts) == 0 {
+		color.Red("❌ -H, --cookie-file, --basic-auth, --bearer-token and --client-cert require --credential-host in server mode")
+		os.Exit(1)
+	}
+
 	// Create server config from flags
 	config := &api.Config{
 		Host:               Host,
@@ -48,12 +118,46 @@ func runServer(cmd *cobra.Command, args []string) {
 		ReadTimeout:        ReadTimeout,
 		WriteTimeout:       WriteTimeout,
 		AuthToken:          AuthToken,
+		Keys:               keys,
+		AllowQueryToken:    QueryToken,
+		Logger:             slog.Default(),
 		EnableDebug:        Debug,
 		InsecureSkipVerify: SkipVerify,
 		RequestTimeout:     Timeout,
+		EnableMetrics:      Metrics || MetricsAddr != "",
+		MetricsAddr:        MetricsAddr,
+		MetricsToken:       MetricsToken,
+		RateLimit:          RateLimit,
+		RateBurst:          RateBurst,
+		DailyQuota:         DailyQuota,
+		HostRateLimit:      HostRate,
+		HostBurst:          HostBurst,
+		TrustProxyHeaders:  TrustProxy,
+		FileSandbox:        sandbox,
+		Proxy:              proxy,
+		Retry:              newRetryPolicy(),
+		Cache:              newCache(),
+		RefreshCache:       RefreshCache,
+		Strict:             Strict,
+		HashMode:           fetch.HashMode,
+		AcceptEncoding:     fetch.AcceptEncoding,
+		Redirect:           fetch.Redirect,
+		Resolve:            fetch.Resolve,
+		ConnectTo:          fetch.ConnectTo,
+		DNSServer:          fetch.DNSServer,
+		Headers:            fetch.Headers,
+		Cookies:            fetch.Cookies,
+		Auth:               fetch.Auth,
+		ClientCert:         fetch.ClientCert,
+		CredentialHosts:    CredentialHosts,
+
+		AllowedRequestHeaders: AllowedRequestHeaders,
+		AllowRequestCookies:   AllowRequestCookies,
+		AllowRequestAuth:      AllowRequestAuth,
 	}
 
 	// Create and start the server
+	mcp.ServerVersion = Version
 	server := api.NewServer(config)
 
 	// Handle graceful shutdown
@@ -72,10 +176,31 @@ func runServer(cmd *cobra.Command, args []string) {
 
 	fmt.Println("🚀", cyan("Starting IconHash API Server"))
 	fmt.Printf("⚙️  %s: %s\n", yellow("Configuration"), fmt.Sprintf("%s:%d", Host, Port))
-	fmt.Printf("🔑 %s: %v\n", yellow("Authentication"), AuthToken != "")
+	fmt.Printf("🔑 %s: %v\n", yellow("Authentication"), AuthToken != "" || len(keys) > 0)
 	fmt.Printf("🐛 %s: %v\n", yellow("Debug Mode"), Debug)
 	fmt.Printf("⏱️  %s: %v\n", yellow("Request Timeout"), Timeout)
 	fmt.Printf("🔐 %s: %v\n", yellow("Insecure Skip Verify"), SkipVerify)
+	fmt.Printf("🚦 %s: %s\n", yellow("Rate Limits"), describeLimits(config))
+	if proxy != nil {
+		fmt.Printf("🌐 %s: %s\n", yellow("Proxies"), describeProxies())
+	}
+	if len(CredentialHosts) > 0 {
+		fmt.Printf("🔏 %s: %s\n", yellow("Credential Hosts"), strings.Join(CredentialHosts, ", "))
+	}
+	if policy := describeRequestPolicy(config); policy != "" {
+		fmt.Printf("📨 %s: %s\n", yellow("Client Fetch Options"), policy)
+	}
+	if sandbox != nil {
+		fmt.Printf("📁 %s: %s (max %d bytes, requires the %s scope)\n", yellow("Local Files"),
+			strings.Join(sandbox.Roots(), ", "), sandbox.MaxSize(), api.ScopeHashPath)
+	}
+	if config.EnableMetrics {
+		metricsAt := "/metrics on the API address"
+		if MetricsAddr != "" {
+			metricsAt = fmt.Sprintf("http://%s/metrics", MetricsAddr)
+		}
+		fmt.Printf("📈 %s: %s (token required: %v)\n", yellow("Metrics"), metricsAt, MetricsToken != "")
+	}
 
 	// Construct the base URL for easy access
 	scheme := "http"
@@ -85,29 +210,109 @@ func runServer(cmd *cobra.Command, args []string) {
 	}
 
 	fmt.Println("\n📋", cyan("API Endpoints:"))
-	fmt.Printf("  %s: %s/health\n", yellow("Health Check"), baseURL)
-	fmt.Printf("  %s: %s/hash/url?url=...\n", yellow("URL Hash"), baseURL)
-	fmt.Printf("  %s: %s/hash/file\n", yellow("File Hash"), baseURL)
-	fmt.Printf("  %s: %s/hash/base64\n", yellow("Base64 Hash"), baseURL)
-	fmt.Printf("  %s: %s/mcp\n", yellow("Model Context Protocol"), baseURL)
+	for _, route := range api.EnabledRoutes(config) {
+		fmt.Printf("  %s: %s%s (%s)\n", yellow(route.Summary), baseURL, route.Path, strings.Join(route.Methods, ", "))
+	}
+	fmt.Printf("  %s: unversioned routes remain available as aliases\n", yellow("Compatibility"))
 
-	fmt.Println("\n🔍", cyan("Query Parameters:"))
+	fmt.Println("\n🔍", cyan("Parameters (query, form or JSON body):"))
 	fmt.Printf("  %s: uint32=true|false - Use uint32 format\n", yellow("Optional"))
 	fmt.Printf("  %s: format=fofa|shodan|plain - Output format\n", yellow("Optional"))
 
-	if AuthToken != "" {
+	if AuthToken != "" || len(keys) > 0 {
 		fmt.Println("\n🔒", cyan("Authentication:"))
-		fmt.Printf("  %s: \"Authorization: Bearer %s\"\n", yellow("Header"), AuthToken)
-		fmt.Printf("  %s: \"?token=%s\"\n", yellow("Query"), AuthToken)
+		if AuthToken != "" {
+			fmt.Printf("  %s: --auth-token (all scopes)\n", yellow("default"))
+		}
+		for _, key := range keys {
+			expires := "never expires"
+			if key.Expires != nil {
+				expires = "expires " + key.Expires.Format(time.RFC3339)
+			}
+			fmt.Printf("  %s: %s (%s)\n", yellow(key.Name), strings.Join(key.Scopes, ", "), expires)
+		}
+		fmt.Printf("  %s: \"Authorization: Bearer <token>\"\n", yellow("Header"))
+		if QueryToken {
+			fmt.Printf("  %s: \"?token=<token>\"\n", yellow("Query"))
+		}
 	}
 
 	fmt.Println("\n📢", cyan("Press Ctrl+C to stop the server"))
 	fmt.Println(yellow("--------------------------------------------------"))
 
 	// Start the server
-	err := server.Start()
+	err = server.Start()
 	if err != nil {
 		color.Red("❌ Server error: %v", err)
 		os.Exit(1)
 	}
 }
+
+// loadAPIKeys reads API keys from the key file flag or the environment
+func loadAPIKeys() ([]api.APIKey, error) {
+	path := KeysFile
+	if path == "" {
+		path = os.Getenv("ICONHASH_API_KEYS_FILE")
+	}
+	if path != "" {
+		return api.LoadKeyFile(path)
+	}
+
+	if data := os.Getenv("ICONHASH_API_KEYS"); data != "" {
+		keys, err := api.ParseKeys([]byte(data))
+		if err != nil {
+			return nil, fmt.Errorf("ICONHASH_API_KEYS: %w", err)
+		}
+		return keys, nil
+	}
+
+	return nil, nil
+}
+
+// describeProxies summarizes the proxy settings for the startup banner,
+// without proxy credentials
+func describeProxies() string {
+	parts := make([]string, 0, len(Proxies)+len(ProxyRules))
+	for _, proxy := range Proxies {
+		parts = append(parts, logging.RedactURL(proxy))
+	}
+	for _, rule := range ProxyRules {
+		pattern, proxy, _ := strings.Cut(rule, "=")
+		parts = append(parts, pattern+" via "+logging.RedactURL(proxy))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// describeRequestPolicy summarizes the headers, cookies and credentials API
+// clients may send with fetches, or returns "" if they may send none
+func describeRequestPolicy(config *api.Config) string {
+	var parts []string
+	if len(config.AllowedRequestHeaders) > 0 {
+		parts = append(parts, "headers "+strings.Join(config.AllowedRequestHeaders, ", "))
+	}
+	if config.AllowRequestCookies {
+		parts = append(parts, "cookies")
+	}
+	if config.AllowRequestAuth {
+		parts = append(parts, "credentials")
+	}
+	return strings.Join(parts, "; ")
+}
+
+// describeLimits summarizes the rate limits for the startup banner
+func describeLimits(config *api.Config) string {
+	limits := make([]string, 0, 3)
+	if config.RateLimit > 0 {
+		limits = append(limits, fmt.Sprintf("%g/s per client (burst %d)", config.RateLimit, config.RateBurst))
+	}
+	if config.DailyQuota > 0 {
+		limits = append(limits, fmt.Sprintf("%d/day per client", config.DailyQuota))
+	}
+	if config.HostRateLimit > 0 {
+		limits = append(limits, fmt.Sprintf("%g/s per destination host (burst %d)", config.HostRateLimit, config.HostBurst))
+	}
+	if len(limits) == 0 {
+		return "none"
+	}
+	return strings.Join(limits, ", ")
+}
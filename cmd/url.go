@@ -1,10 +1,13 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
 
 	"github.com/cyberspacesec/go-iconhash/pkg/hasher"
+	"github.com/cyberspacesec/go-iconhash/pkg/logging"
 	"github.com/cyberspacesec/go-iconhash/pkg/util"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
@@ -23,7 +26,8 @@ The hash can be formatted for use with search engines like Fofa or Shodan.
 Examples:
   iconhash url https://example.com
   iconhash url -u https://example.com/favicon.ico --shodan
-  iconhash url https://example.com --uint32`,
+  iconhash url https://example.com --uint32
+  iconhash url https://example.com --body --shodan`,
 		Run: runURL,
 		Args: func(cmd *cobra.Command, args []string) error {
 			// If URL is provided as positional arg, set it in the flags
@@ -47,28 +51,49 @@ Examples:
 
 // runURL handles the URL command execution
 func runURL(cmd *cobra.Command, args []string) {
+	proxy, err := newProxySelector()
+	if err != nil {
+		color.Red("❌ %v", err)
+		os.Exit(1)
+	}
+
 	// Create a new hasher with the options from root command
 	options := &hasher.HashOptions{
 		UseUint32:          Uint32Flag,
 		RequestTimeout:     Timeout,
 		InsecureSkipVerify: SkipVerify,
 		UserAgent:          UserAgent,
+		Proxy:              proxy,
+		Retry:              newRetryPolicy(),
+		Cache:              newCache(),
+		RefreshCache:       RefreshCache,
+		Strict:             Strict,
+	}
+	if err := applyRequestOptions(options); err != nil {
+		color.Red("❌ %v", err)
+		os.Exit(1)
 	}
 	h := hasher.New(options)
 
-	// Debug info if enabled
-	if Debug {
-		fmt.Fprintf(os.Stderr, "🔍 URL: %s\n", URL)
-		fmt.Fprintf(os.Stderr, "🔧 Options: uint32=%v, timeout=%.0fs, skip-verify=%v\n",
-			options.UseUint32, options.RequestTimeout.Seconds(), options.InsecureSkipVerify)
-		if options.UserAgent != "" {
-			fmt.Fprintf(os.Stderr, "🕵️ User-Agent: %s\n", options.UserAgent)
-		}
+	slog.Debug("Hash options", "uint32", options.UseUint32, "timeout", options.RequestTimeout,
+		"skip_verify", options.InsecureSkipVerify, "user_agent", options.UserAgent)
+
+	if BodyHash {
+		runBodyHash(h)
+		return
 	}
 
 	// Calculate hash
-	fmt.Fprintf(os.Stderr, "🌐 Fetching favicon from %s...\n", URL)
-	hash, err := h.HashFromURL(URL)
+	slog.Info("Fetching favicon", "url", logging.RedactURL(URL))
+	result, err := h.Fetch(context.Background(), URL)
+	if err != nil {
+		color.Red("❌ Error calculating hash: %v", err)
+		os.Exit(1)
+	}
+	logAttempts(result.Attempts)
+	slog.Debug("Fetched favicon", "type", result.Type, "content_type", result.ContentType, "bytes", len(result.Data))
+
+	hash, rawHash, err := h.HashFetched(result)
 	if err != nil {
 		color.Red("❌ Error calculating hash: %v", err)
 		os.Exit(1)
@@ -100,4 +125,72 @@ func runURL(cmd *cobra.Command, args []string) {
 		boldCyan.Printf("Formatted: ")
 		fmt.Println(formatted)
 	}
+	if len(result.Attempts) > 1 {
+		boldCyan.Printf("Attempts: ")
+		fmt.Println(len(result.Attempts))
+	}
+	if result.Cache != "" {
+		boldCyan.Printf("Cache: ")
+		fmt.Println(result.Cache)
+	}
+	boldCyan.Printf("Type: ")
+	fmt.Println(result.Type)
+	if len(result.Redirects) > 0 {
+		boldCyan.Println("Redirects:")
+		for _, redirect := range result.Redirects {
+			fmt.Printf("  %d %s\n", redirect.StatusCode, redirect.URL)
+		}
+		boldCyan.Printf("Final URL: ")
+		fmt.Println(result.FinalURL)
+	}
+	if result.ContentEncoding != "" {
+		boldCyan.Printf("Encoding: ")
+		fmt.Println(result.ContentEncoding)
+	}
+	if rawHash != "" {
+		boldCyan.Printf("Raw hash: ")
+		fmt.Println(rawHash)
+	}
+	if result.Warning != "" {
+		color.Yellow("⚠️  Warning: %s; the hash is probably not a favicon hash (use --strict to fail instead)", result.Warning)
+	}
+}
+
+// runBodyHash prints the HTML body hash and title hash of the page at URL
+func runBodyHash(h *hasher.IconHasher) {
+	slog.Info("Fetching page", "url", logging.RedactURL(URL))
+	result, err := h.HashBody(context.Background(), URL)
+	if err != nil {
+		color.Red("❌ Error calculating hash: %v", err)
+		os.Exit(1)
+	}
+	logAttempts(result.Fetch.Attempts)
+
+	boldGreen := color.New(color.FgGreen, color.Bold)
+	boldGreen.Println("✅ Hash calculated successfully!")
+	fmt.Println()
+
+	boldCyan := color.New(color.FgCyan, color.Bold)
+	boldCyan.Printf("HTML hash: ")
+	fmt.Println(result.Hash)
+	if ShodanFormat {
+		boldCyan.Printf("Formatted: ")
+		fmt.Println(util.FormatBodyHash(result.Hash, util.FormatShodan))
+	} else if FofaFormat {
+		color.Yellow("⚠️  Fofa does not index HTML body hashes")
+	}
+	boldCyan.Printf("Status: ")
+	fmt.Println(result.StatusCode)
+	boldCyan.Printf("Length: ")
+	fmt.Println(result.Length)
+	if result.Title != "" {
+		boldCyan.Printf("Title: ")
+		fmt.Println(result.Title)
+		boldCyan.Printf("Title hash: ")
+		fmt.Println(result.TitleHash)
+	}
+	if len(result.Fetch.Redirects) > 0 {
+		boldCyan.Printf("Final URL: ")
+		fmt.Println(result.Fetch.FinalURL)
+	}
 }
@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/hasher"
+	"github.com/cyberspacesec/go-iconhash/pkg/recon"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// NewVHostsCommand 创建虚拟主机枚举命令
+func NewVHostsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "vhosts [ip] [host...]",
+		Short: "Find the virtual hosts of an IP address by their favicons",
+		Long: `Request the favicon of each candidate host name from a single IP address.
+
+Each host name is sent as the TLS server name and Host header, and the hosts
+are grouped by the hash and length of the favicon they received, so that the
+virtual hosts serving different applications on a shared server stand out.
+Hosts receiving the same response as the bare IP address or as a random host
+name are most likely not configured on the server; they are hidden unless
+--show-baseline is given.
+
+Certificates rarely match every candidate host, so use --insecure to see the
+favicons of hosts presenting another certificate.
+
+The IP address is connected to directly, so --proxy and the proxy
+environment variables do not apply.
+
+Examples:
+  iconhash vhosts 203.0.113.7 -w hosts.txt -k
+  iconhash vhosts 203.0.113.7 www.example.com mail.example.com --scheme http
+  iconhash vhosts 203.0.113.7 -w hosts.txt -k --port 8443 --format json`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: runVHosts,
+	}
+
+	cmd.Flags().StringVarP(&VHostsOptions.Wordlist, "wordlist", "w", "", "File of candidate host names, one per line")
+	cmd.Flags().StringVar(&VHostsOptions.Scheme, "scheme", "https", "Scheme of the requests (http or https)")
+	cmd.Flags().IntVar(&VHostsOptions.Port, "port", 0, "Port to connect to (default: 443 for https, 80 for http)")
+	cmd.Flags().StringVar(&VHostsOptions.Path, "path", "/favicon.ico", "Path of the favicon")
+	cmd.Flags().IntVarP(&VHostsOptions.Concurrency, "concurrency", "c", recon.DefaultConcurrency, "Hosts requested at once")
+	cmd.Flags().BoolVar(&VHostsOptions.NoBaseline, "no-baseline", false, "Do not request the IP address and a random host name to detect unknown hosts")
+	cmd.Flags().BoolVar(&VHostsOptions.ShowBaseline, "show-baseline", false, "Also show the hosts that received the baseline response")
+
+	return cmd
+}
+
+// runVHosts handles the vhosts command execution
+func runVHosts(cmd *cobra.Command, args []string) error {
+	hosts := args[1:]
+	if VHostsOptions.Wordlist != "" {
+		file, err := os.Open(VHostsOptions.Wordlist)
+		if err != nil {
+			return err
+		}
+		words, err := recon.ReadWordlist(file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read --wordlist: %w", err)
+		}
+		hosts = append(hosts, words...)
+	}
+	if len(hosts) == 0 {
+		return fmt.Errorf("no host names: give them as arguments or with --wordlist")
+	}
+
+	proxy, err := newProxySelector()
+	if err != nil {
+		return err
+	}
+	options := &hasher.HashOptions{
+		UseUint32:          Uint32Flag,
+		RequestTimeout:     Timeout,
+		InsecureSkipVerify: SkipVerify,
+		UserAgent:          UserAgent,
+		Proxy:              proxy,
+		Retry:              newRetryPolicy(),
+		Strict:             Strict,
+	}
+	if err := applyRequestOptions(options); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	slog.Info("Enumerating virtual hosts", "address", args[0], "hosts", len(hosts))
+	report, err := recon.EnumerateVHosts(ctx, options, args[0], hosts, &recon.VHostOptions{
+		Scheme:      VHostsOptions.Scheme,
+		Port:        VHostsOptions.Port,
+		Path:        VHostsOptions.Path,
+		Concurrency: VHostsOptions.Concurrency,
+		NoBaseline:  VHostsOptions.NoBaseline,
+	})
+	if err != nil {
+		return err
+	}
+
+	if !VHostsOptions.ShowBaseline {
+		groups := report.Groups[:0]
+		for _, group := range report.Groups {
+			if !group.Baseline {
+				groups = append(groups, group)
+			}
+		}
+		report.Groups = groups
+	}
+
+	if OutputFormat == "json" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	printVHostReport(report)
+	return nil
+}
+
+// printVHostReport prints the groups of a vhosts report
+func printVHostReport(report *recon.VHostReport) {
+	boldCyan := color.New(color.FgCyan, color.Bold)
+	for _, resp := range report.Baseline {
+		boldCyan.Printf("Baseline: ")
+		fmt.Printf("%s → %s\n", resp.URL, describeVHostResponse(resp.Hash, resp.Length, resp.Error))
+	}
+	if len(report.Baseline) > 0 {
+		fmt.Println()
+	}
+
+	if len(report.Groups) == 0 {
+		color.Yellow("⚠️  Every host received the baseline response")
+		return
+	}
+	boldGreen := color.New(color.FgGreen, color.Bold)
+	boldGreen.Printf("✅ %d distinct responses from %s\n", len(report.Groups), report.Address)
+	for _, group := range report.Groups {
+		fmt.Println()
+		boldCyan.Printf("%s", describeVHostResponse(group.Hash, group.Length, group.Error))
+		if group.Baseline {
+			color.New(color.FgYellow).Printf(" (baseline)")
+		}
+		fmt.Println()
+		for _, host := range group.Hosts {
+			fmt.Printf("  %s\n", host)
+		}
+	}
+}
+
+// describeVHostResponse summarizes a response as its hash and length, or its error
+func describeVHostResponse(hash string, length int, errMsg string) string {
+	if errMsg != "" {
+		return "Error: " + errMsg
+	}
+	return fmt.Sprintf("Hash: %s (%d bytes)", hash, length)
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/logging"
+)
+
+// accessInfo collects request details that inner handlers learn, such as
+// the authenticated key, for the access log written by the outer middleware
+type accessInfo struct {
+	keyName string
+}
+
+// accessInfoFromContext returns the access log details of the request, if any
+func accessInfoFromContext(ctx context.Context) *accessInfo {
+	info, _ := ctx.Value(accessInfoKey).(*accessInfo)
+	return info
+}
+
+// accessLogMiddleware logs one line per request once it has been served.
+// Query secrets such as ?token= are redacted; request bodies and headers
+// are never logged. Server errors are logged at error level.
+func (s *Server) accessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		info := &accessInfo{}
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		ctx := context.WithValue(r.Context(), accessInfoKey, info)
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		level := slog.LevelInfo
+		if rec.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", logging.RedactURL(r.URL.RequestURI())),
+			slog.Int("status", rec.status),
+			slog.Int64("bytes", rec.written),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("client", s.clientIP(r)),
+		}
+		if info.keyName != "" {
+			attrs = append(attrs, slog.String("key", info.keyName))
+		}
+		if ua := r.UserAgent(); ua != "" {
+			attrs = append(attrs, slog.String("user_agent", ua))
+		}
+		s.logger.LogAttrs(ctx, level, "HTTP request", attrs...)
+	})
+}
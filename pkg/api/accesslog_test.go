@@ -0,0 +1,49 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAccessLog(t *testing.T) {
+	secret, key, _ := GenerateKey("ci", []string{ScopeHashURL}, nil)
+
+	var logs bytes.Buffer
+	config := DefaultConfig()
+	config.Keys = []APIKey{key}
+	config.AllowQueryToken = true
+	config.Logger = slog.New(slog.NewJSONHandler(&logs, nil))
+	handler := NewServer(config).Handler()
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/hash/base64?format=fofa&token="+secret, nil)
+	req.Header.Set("X-Request-ID", "req-42")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(logs.Bytes(), &record); err != nil {
+		t.Fatalf("Expected one JSON log line, got %q: %v", logs.String(), err)
+	}
+	if bytes.Contains(logs.Bytes(), []byte(secret)) {
+		t.Errorf("Access log leaks the token: %s", logs.String())
+	}
+
+	expected := map[string]interface{}{
+		"msg":        "HTTP request",
+		"method":     http.MethodGet,
+		"path":       "/v1/hash/base64?format=fofa&token=REDACTED",
+		"key":        "ci",
+		"request_id": "req-42",
+	}
+	for field, value := range expected {
+		if record[field] != value {
+			t.Errorf("Expected %s %v, got %v", field, value, record[field])
+		}
+	}
+	if record["status"] != float64(http.StatusForbidden) {
+		t.Errorf("Expected status 403, got %v", record["status"])
+	}
+}
@@ -0,0 +1,318 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Scopes that can be granted to API keys
+const (
+	ScopeHashURL  = "hash:url"
+	ScopeHashFile = "hash:file"
+	ScopeHashPath = "hash:path"
+	ScopeMCP      = "mcp"
+	ScopeAdmin    = "admin"
+)
+
+// AllScopes lists every known scope
+var AllScopes = []string{ScopeHashURL, ScopeHashFile, ScopeHashPath, ScopeMCP, ScopeAdmin}
+
+// keyHashAlgorithm prefixes stored key hashes
+const keyHashAlgorithm = "sha256"
+
+// Authentication errors
+var (
+	ErrInvalidKey = errors.New("invalid API key")
+	ErrExpiredKey = errors.New("API key has expired")
+)
+
+// APIKey is a named API key. Only a salted hash of the secret is stored.
+type APIKey struct {
+	Name    string     `json:"name"`
+	Hash    string     `json:"hash"`
+	Scopes  []string   `json:"scopes"`
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// keyFile is the on-disk format of a key file
+type keyFile struct {
+	Keys []APIKey `json:"keys"`
+}
+
+// HasScope reports whether the key grants the scope. The admin scope grants every scope.
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// Expired reports whether the key has expired at the given time
+func (k *APIKey) Expired(now time.Time) bool {
+	return k.Expires != nil && !now.Before(*k.Expires)
+}
+
+// LoadKeyFile reads API keys from a JSON key file
+func LoadKeyFile(path string) ([]APIKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	return ParseKeys(data)
+}
+
+// ParseKeys parses and validates the JSON key file format:
+//
+//	{"keys": [{"name": "ci", "hash": "sha256:<salt>:<digest>", "scopes": ["hash:url"], "expires": "2030-01-01T00:00:00Z"}]}
+func ParseKeys(data []byte) ([]APIKey, error) {
+	var file keyFile
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&file); err != nil {
+		return nil, fmt.Errorf("invalid key file: %w", err)
+	}
+
+	names := make(map[string]bool)
+	for _, key := range file.Keys {
+		if key.Name == "" {
+			return nil, errors.New("invalid key file: key without name")
+		}
+		if names[key.Name] {
+			return nil, fmt.Errorf("invalid key file: duplicate key name %q", key.Name)
+		}
+		names[key.Name] = true
+
+		if _, _, err := parseKeyHash(key.Hash); err != nil {
+			return nil, fmt.Errorf("invalid key file: key %q: %w", key.Name, err)
+		}
+		for _, scope := range key.Scopes {
+			if !isKnownScope(scope) {
+				return nil, fmt.Errorf("invalid key file: key %q: unknown scope %q", key.Name, scope)
+			}
+		}
+	}
+
+	return file.Keys, nil
+}
+
+// GenerateKey creates a new random secret and the APIKey entry storing its hash
+func GenerateKey(name string, scopes []string, expires *time.Time) (string, APIKey, error) {
+	for _, scope := range scopes {
+		if !isKnownScope(scope) {
+			return "", APIKey{}, fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", APIKey{}, err
+	}
+	secret := "ih_" + base64.RawURLEncoding.EncodeToString(raw)
+
+	hash, err := HashKey(secret)
+	if err != nil {
+		return "", APIKey{}, err
+	}
+
+	return secret, APIKey{Name: name, Hash: hash, Scopes: scopes, Expires: expires}, nil
+}
+
+// HashKey returns the salted hash of a secret in the key file format
+func HashKey(secret string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	digest := keyDigest(salt, secret)
+	return keyHashAlgorithm + ":" + hex.EncodeToString(salt) + ":" + hex.EncodeToString(digest), nil
+}
+
+// keyDigest hashes a secret with a salt
+func keyDigest(salt []byte, secret string) []byte {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte(secret))
+	return h.Sum(nil)
+}
+
+// parseKeyHash splits a stored key hash into salt and digest
+func parseKeyHash(stored string) ([]byte, []byte, error) {
+	parts := strings.Split(stored, ":")
+	if len(parts) != 3 || parts[0] != keyHashAlgorithm {
+		return nil, nil, errors.New("hash must have the form sha256:<salt>:<digest>")
+	}
+	salt, err := hex.DecodeString(parts[1])
+	if err != nil || len(salt) == 0 {
+		return nil, nil, errors.New("invalid hash salt")
+	}
+	digest, err := hex.DecodeString(parts[2])
+	if err != nil || len(digest) != sha256.Size {
+		return nil, nil, errors.New("invalid hash digest")
+	}
+	return salt, digest, nil
+}
+
+// isKnownScope reports whether a scope name is valid
+func isKnownScope(scope string) bool {
+	for _, s := range AllScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyStore authenticates secrets against a set of API keys
+type KeyStore struct {
+	keys []storedKey
+}
+
+// storedKey is an API key with its decoded hash
+type storedKey struct {
+	key    APIKey
+	salt   []byte
+	digest []byte
+}
+
+// NewKeyStore creates a KeyStore. Keys with malformed hashes are skipped;
+// use ParseKeys to validate keys from untrusted sources.
+func NewKeyStore(keys []APIKey) *KeyStore {
+	ks := &KeyStore{}
+	for _, key := range keys {
+		salt, digest, err := parseKeyHash(key.Hash)
+		if err != nil {
+			continue
+		}
+		ks.keys = append(ks.keys, storedKey{key: key, salt: salt, digest: digest})
+	}
+	return ks
+}
+
+// Len returns the number of keys in the store
+func (ks *KeyStore) Len() int {
+	return len(ks.keys)
+}
+
+// Authenticate returns the key matching the secret. Every key is checked with
+// a constant-time comparison, so the timing does not reveal which key matched.
+func (ks *KeyStore) Authenticate(secret string, now time.Time) (*APIKey, error) {
+	var match *APIKey
+	for i := range ks.keys {
+		stored := &ks.keys[i]
+		if subtle.ConstantTimeCompare(keyDigest(stored.salt, secret), stored.digest) == 1 && match == nil {
+			match = &stored.key
+		}
+	}
+
+	if match == nil {
+		return nil, ErrInvalidKey
+	}
+	if match.Expired(now) {
+		return nil, ErrExpiredKey
+	}
+	return match, nil
+}
+
+// newKeyStore builds the server key store from the configured keys and the
+// legacy single auth token, which is granted the admin scope
+func newKeyStore(config *Config) *KeyStore {
+	keys := append([]APIKey(nil), config.Keys...)
+	if config.AuthToken != "" {
+		if hash, err := HashKey(config.AuthToken); err == nil {
+			keys = append(keys, APIKey{Name: "default", Hash: hash, Scopes: []string{ScopeAdmin}})
+		}
+	}
+	return NewKeyStore(keys)
+}
+
+// authEnabled reports whether requests must be authenticated. Configured keys
+// enable authentication even if none of them is usable, so that a malformed
+// key file never leaves the server open.
+func (s *Server) authEnabled() bool {
+	return s.keys.Len() > 0 || len(s.config.Keys) > 0
+}
+
+// authMiddleware authenticates requests and stores the API key in the request context
+func (s *Server) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Skip auth for public endpoints
+		if isPublicPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Check for token in header, then in the query string if allowed
+		token := bearerToken(r)
+		if token == "" && s.config.AllowQueryToken {
+			token = r.URL.Query().Get("token")
+		}
+		if token == "" {
+			sendErrorResponse(w, r, newAPIError(http.StatusUnauthorized, CodeUnauthorized, "Unauthorized: Invalid or missing authentication token"))
+			return
+		}
+
+		key, err := s.keys.Authenticate(token, time.Now())
+		if errors.Is(err, ErrExpiredKey) {
+			sendErrorResponse(w, r, newAPIError(http.StatusUnauthorized, CodeTokenExpired, "Unauthorized: API key has expired"))
+			return
+		}
+		if err != nil {
+			sendErrorResponse(w, r, newAPIError(http.StatusUnauthorized, CodeUnauthorized, "Unauthorized: Invalid or missing authentication token"))
+			return
+		}
+
+		if info := accessInfoFromContext(r.Context()); info != nil {
+			info.keyName = key.Name
+		}
+
+		ctx := context.WithValue(r.Context(), apiKeyKey, key)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requireScope wraps a route handler to reject keys without the scope
+func (s *Server) requireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.authEnabled() {
+			next(w, r)
+			return
+		}
+
+		key := apiKeyFromContext(r.Context())
+		if key == nil || !key.HasScope(scope) {
+			sendErrorResponse(w, r, newAPIError(http.StatusForbidden, CodeForbidden, "Forbidden: API key lacks the "+scope+" scope").
+				WithDetail("scope", scope))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer" header
+func bearerToken(r *http.Request) string {
+	authHeader := r.Header.Get("Authorization")
+	if len(authHeader) > 7 && strings.EqualFold(authHeader[:7], "Bearer ") {
+		return authHeader[7:]
+	}
+	return ""
+}
+
+// apiKeyFromContext returns the authenticated API key, if any
+func apiKeyFromContext(ctx context.Context) *APIKey {
+	key, _ := ctx.Value(apiKeyKey).(*APIKey)
+	return key
+}
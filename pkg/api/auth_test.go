@@ -0,0 +1,131 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerateAndAuthenticateKey(t *testing.T) {
+	secret, key, err := GenerateKey("ci", []string{ScopeHashURL}, nil)
+	if err != nil {
+		t.Fatalf("GenerateKey() returned error: %v", err)
+	}
+	if strings.Contains(key.Hash, secret) {
+		t.Error("Stored hash contains the secret")
+	}
+
+	past := time.Now().Add(-time.Hour)
+	expiredSecret, expiredKey, _ := GenerateKey("old", []string{ScopeAdmin}, &past)
+
+	store := NewKeyStore([]APIKey{key, expiredKey})
+
+	matched, err := store.Authenticate(secret, time.Now())
+	if err != nil || matched.Name != "ci" {
+		t.Fatalf("Authenticate(secret) = %v, %v; expected key ci", matched, err)
+	}
+	if !matched.HasScope(ScopeHashURL) || matched.HasScope(ScopeMCP) {
+		t.Errorf("Unexpected scopes for key ci: %v", matched.Scopes)
+	}
+
+	if _, err := store.Authenticate("wrong", time.Now()); err != ErrInvalidKey {
+		t.Errorf("Authenticate(wrong) error = %v, expected ErrInvalidKey", err)
+	}
+	if _, err := store.Authenticate(expiredSecret, time.Now()); err != ErrExpiredKey {
+		t.Errorf("Authenticate(expired) error = %v, expected ErrExpiredKey", err)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	_, key, _ := GenerateKey("ci", []string{ScopeMCP}, nil)
+	valid, _ := json.Marshal(keyFile{Keys: []APIKey{key}})
+
+	tests := []struct {
+		name        string
+		data        string
+		expectError bool
+	}{
+		{"Valid key file", string(valid), false},
+		{"Malformed hash", `{"keys":[{"name":"a","hash":"plaintext","scopes":["mcp"]}]}`, true},
+		{"Unknown scope", `{"keys":[{"name":"a","hash":"` + key.Hash + `","scopes":["root"]}]}`, true},
+		{"Missing name", `{"keys":[{"hash":"` + key.Hash + `","scopes":["mcp"]}]}`, true},
+		{"Unknown field", `{"keys":[],"token":"secret"}`, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseKeys([]byte(test.data))
+			if test.expectError && err == nil {
+				t.Error("Expected error, got nil")
+			}
+			if !test.expectError && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestScopeEnforcement(t *testing.T) {
+	urlSecret, urlKey, _ := GenerateKey("url-only", []string{ScopeHashURL}, nil)
+	adminSecret, adminKey, _ := GenerateKey("admin", []string{ScopeAdmin}, nil)
+
+	config := DefaultConfig()
+	config.Keys = []APIKey{urlKey, adminKey}
+	handler := NewServer(config).Handler()
+
+	tests := []struct {
+		name           string
+		target         string
+		token          string
+		expectedStatus int
+		expectedCode   string
+	}{
+		{"Scope granted", "/v1/hash/base64?data=AAAA", adminSecret, http.StatusOK, ""},
+		{"Scope missing", "/v1/hash/base64?data=AAAA", urlSecret, http.StatusForbidden, CodeForbidden},
+		{"Query token disabled", "/v1/hash/base64?data=AAAA&token=" + adminSecret, "", http.StatusUnauthorized, CodeUnauthorized},
+		{"Public route", "/v1/health", "", http.StatusOK, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			method := http.MethodPost
+			if strings.HasSuffix(test.target, "health") {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, test.target, nil)
+			if test.token != "" {
+				req.Header.Set("Authorization", "Bearer "+test.token)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d: %s", test.expectedStatus, w.Code, w.Body.String())
+			}
+			if test.expectedCode != "" {
+				var resp ErrorResponse
+				json.Unmarshal(w.Body.Bytes(), &resp)
+				if resp.Error == nil || resp.Error.Code != test.expectedCode {
+					t.Errorf("Expected error code %q, got %+v", test.expectedCode, resp.Error)
+				}
+			}
+		})
+	}
+}
+
+func TestMalformedKeysKeepAuthEnabled(t *testing.T) {
+	config := DefaultConfig()
+	config.Keys = []APIKey{{Name: "broken", Hash: "not-a-hash", Scopes: []string{ScopeAdmin}}}
+	handler := NewServer(config).Handler()
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/hash/base64?data=AAAA", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status code %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Machine-readable error codes returned in the "code" field of an error response
+const (
+	CodeInvalidRequest   = "invalid_request"
+	CodeMissingParameter = "missing_parameter"
+	CodeInvalidParameter = "invalid_parameter"
+	CodePayloadTooLarge  = "payload_too_large"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeUnauthorized     = "unauthorized"
+	CodeTokenExpired     = "token_expired"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeRateLimited      = "rate_limited"
+	CodeQuotaExceeded    = "quota_exceeded"
+	CodeFetchFailed      = "fetch_failed"
+	CodeHashFailed       = "hash_failed"
+	CodeInternal         = "internal_error"
+)
+
+// APIError describes a failed API request
+type APIError struct {
+	Status    int                    `json:"-"`
+	Code      string                 `json:"code"`
+	Message   string                 `json:"message"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
+}
+
+// ErrorResponse is the response body for failed requests on versioned routes
+type ErrorResponse struct {
+	Error *APIError `json:"error"`
+}
+
+// newAPIError creates an APIError with the given HTTP status, code and message
+func newAPIError(status int, code, message string) *APIError {
+	return &APIError{
+		Status:  status,
+		Code:    code,
+		Message: message,
+	}
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// WithDetail attaches a detail value to the error and returns it
+func (e *APIError) WithDetail(key string, value interface{}) *APIError {
+	if e.Details == nil {
+		e.Details = make(map[string]interface{})
+	}
+	e.Details[key] = value
+	return e
+}
+
+// errMethodNotAllowed returns the error for an unsupported request method
+func errMethodNotAllowed(method string, allowed ...string) *APIError {
+	return newAPIError(http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed").
+		WithDetail("method", method).
+		WithDetail("allowed", allowed)
+}
+
+// errMissingParameter returns the error for a required parameter that was not supplied
+func errMissingParameter(name string) *APIError {
+	return newAPIError(http.StatusBadRequest, CodeMissingParameter, name+" parameter is required").
+		WithDetail("parameter", name)
+}
+
+// errInvalidParameter returns the error for a parameter with an unusable value
+func errInvalidParameter(name, reason string) *APIError {
+	return newAPIError(http.StatusBadRequest, CodeInvalidParameter, "Invalid "+name+" parameter: "+reason).
+		WithDetail("parameter", name)
+}
+
+// isLegacyRoute reports whether the request was made against an unversioned
+// compatibility route, which keeps the original error shape
+func isLegacyRoute(r *http.Request) bool {
+	return !strings.HasPrefix(r.URL.Path, "/v1/")
+}
+
+// sendErrorResponse sends an error response. Versioned routes receive the
+// structured ErrorResponse; legacy routes keep the HashResponse error string.
+func sendErrorResponse(w http.ResponseWriter, r *http.Request, apiErr *APIError) {
+	if allowed, ok := apiErr.Details["allowed"].([]string); ok && apiErr.Status == http.StatusMethodNotAllowed {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.Status)
+
+	if isLegacyRoute(r) {
+		json.NewEncoder(w).Encode(HashResponse{
+			Error: apiErr.Message,
+		})
+		return
+	}
+
+	apiErr.RequestID = requestIDFromContext(r.Context())
+	json.NewEncoder(w).Encode(ErrorResponse{Error: apiErr})
+}
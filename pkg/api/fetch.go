@@ -0,0 +1,86 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/hasher"
+)
+
+// refusedRequestHeaders can never be set by clients: hop-by-hop and framing
+// headers would corrupt the fetch, and Host or Proxy-Authorization would let
+// a client reach other virtual hosts or use the server's proxy credentials
+var refusedRequestHeaders = map[string]bool{
+	"Connection":          true,
+	"Content-Length":      true,
+	"Host":                true,
+	"Keep-Alive":          true,
+	"Proxy-Authenticate":  true,
+	"Proxy-Authorization": true,
+	"Proxy-Connection":    true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Transfer-Encoding":   true,
+	"Upgrade":             true,
+}
+
+// requestFields are headers with a dedicated field, and so a policy of their own
+var requestFields = map[string]string{
+	"Authorization": "auth",
+	"Cookie":        "cookie",
+}
+
+// errFetchOptionNotAllowed returns the error for a fetch option the server
+// policy does not allow
+func errFetchOptionNotAllowed(parameter string) *APIError {
+	return newAPIError(http.StatusForbidden, CodeForbidden, "The server does not allow the "+parameter+" parameter").
+		WithDetail("parameter", parameter)
+}
+
+// requestHeaders validates the headers and cookie of a request against the
+// server policy and returns them as fetch headers
+func (s *Server) requestHeaders(req *HashRequest) (http.Header, *APIError) {
+	if len(req.Headers) == 0 && req.Cookie == "" {
+		return nil, nil
+	}
+
+	headers := make(http.Header, len(req.Headers)+1)
+	for name, value := range req.Headers {
+		name, value, err := hasher.ParseHeader(name + ":" + value)
+		if err != nil {
+			return nil, errInvalidParameter("headers", err.Error())
+		}
+		if refusedRequestHeaders[name] {
+			return nil, errInvalidParameter("headers", name+" cannot be set").WithDetail("header", name)
+		}
+		if field, ok := requestFields[name]; ok {
+			return nil, errInvalidParameter("headers", "use the "+field+" parameter instead of "+name).
+				WithDetail("header", name)
+		}
+		if !s.headerAllowed(name) {
+			return nil, errFetchOptionNotAllowed("headers").WithDetail("header", name)
+		}
+		headers.Set(name, value)
+	}
+
+	if req.Cookie != "" {
+		if !s.config.AllowRequestCookies {
+			return nil, errFetchOptionNotAllowed("cookie")
+		}
+		if strings.ContainsAny(req.Cookie, "\r\n") {
+			return nil, errInvalidParameter("cookie", "expected a Cookie header value")
+		}
+		headers.Set("Cookie", req.Cookie)
+	}
+	return headers, nil
+}
+
+// headerAllowed reports whether clients may set a header
+func (s *Server) headerAllowed(name string) bool {
+	for _, allowed := range s.config.AllowedRequestHeaders {
+		if allowed == "*" || strings.EqualFold(allowed, name) {
+			return true
+		}
+	}
+	return false
+}
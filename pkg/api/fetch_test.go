@@ -0,0 +1,366 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/cache"
+	"github.com/cyberspacesec/go-iconhash/pkg/hasher"
+)
+
+func TestFetchOptionsPolicy(t *testing.T) {
+	var got *http.Request
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.Write([]byte{0, 0, 1, 0})
+	}))
+	defer upstream.Close()
+
+	post := func(handler http.Handler, fields string) (int, *APIError) {
+		body := `{"url":"` + upstream.URL + `"` + fields + `}`
+		req := httptest.NewRequest(http.MethodPost, "/v1/hash/url", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		var resp ErrorResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp.Error
+	}
+
+	config := DefaultConfig()
+	config.RateLimit, config.HostRateLimit = 0, 0
+	denied := NewServer(config).Handler()
+
+	// Fetch options are refused by default
+	for _, fields := range []string{
+		`,"headers":{"Accept":"image/*"}`,
+		`,"cookie":"session=abc"`,
+		`,"auth":{"username":"user","password":"pass"}`,
+	} {
+		if code, apiErr := post(denied, fields); code != http.StatusForbidden || apiErr == nil || apiErr.Code != CodeForbidden {
+			t.Errorf("Expected 403 for %s, got %d %+v", fields, code, apiErr)
+		}
+	}
+	if code, _ := post(denied, `,"auth":{}`); code != http.StatusOK {
+		t.Errorf("Expected empty credentials to be ignored, got %d", code)
+	}
+
+	config.AllowedRequestHeaders = []string{"accept", "X-Tenant"}
+	config.AllowRequestCookies = true
+	config.AllowRequestAuth = true
+	allowed := NewServer(config).Handler()
+
+	code, apiErr := post(allowed, `,"headers":{"Accept":"image/*","x-tenant":"blue"},"cookie":"session=abc","auth":{"bearer_token":"t0ken"}`)
+	if code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d %+v", code, apiErr)
+	}
+	if got.Header.Get("Accept") != "image/*" || got.Header.Get("X-Tenant") != "blue" ||
+		got.Header.Get("Cookie") != "session=abc" || got.Header.Get("Authorization") != "Bearer t0ken" {
+		t.Errorf("Expected the fetch options on the upstream request, got %v", got.Header)
+	}
+
+	// Headers outside the allow list, refused headers and dedicated fields
+	tests := []struct {
+		fields string
+		status int
+	}{
+		{`,"headers":{"Referer":"https://example.com"}`, http.StatusForbidden},
+		{`,"headers":{"Host":"internal"}`, http.StatusBadRequest},
+		{`,"headers":{"Transfer-Encoding":"chunked"}`, http.StatusBadRequest},
+		{`,"headers":{"Authorization":"Bearer x"}`, http.StatusBadRequest},
+		{`,"headers":{"X-Tenant":"a\r\nInjected: b"}`, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		if code, _ := post(allowed, test.fields); code != test.status {
+			t.Errorf("Expected %d for %s, got %d", test.status, test.fields, code)
+		}
+	}
+
+	config.AllowedRequestHeaders = []string{"*"}
+	if code, _ := post(NewServer(config).Handler(), `,"headers":{"Referer":"https://example.com"}`); code != http.StatusOK {
+		t.Errorf("Expected * to allow any header, got %d", code)
+	}
+}
+
+func TestServerCredentialHosts(t *testing.T) {
+	var got *http.Request
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.Write([]byte{0, 0, 1, 0})
+	}))
+	defer upstream.Close()
+
+	fetch := func(handler http.Handler, url string) {
+		req := httptest.NewRequest(http.MethodGet, "/v1/hash/url?url="+url, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+	leaked := func() bool {
+		return got.Header.Get("Authorization") != "" || got.Header.Get("X-Api-Key") != ""
+	}
+
+	config := DefaultConfig()
+	config.RateLimit, config.HostRateLimit = 0, 0
+	config.Headers = http.Header{"X-Api-Key": {"secret"}}
+	config.Auth = &hasher.Credentials{BearerToken: "secret"}
+
+	// Without patterns, the server's credentials are never sent
+	fetch(NewServer(config).Handler(), upstream.URL)
+	if leaked() {
+		t.Errorf("Expected no credentials without credential hosts, got %v", got.Header)
+	}
+
+	// With patterns, only the matching hosts receive them
+	config.CredentialHosts = []string{"localhost"}
+	handler := NewServer(config).Handler()
+	fetch(handler, upstream.URL)
+	if leaked() {
+		t.Errorf("Expected no credentials for a host outside the patterns, got %v", got.Header)
+	}
+	fetch(handler, strings.Replace(upstream.URL, "127.0.0.1", "localhost", 1))
+	if got.Header.Get("Authorization") != "Bearer secret" || got.Header.Get("X-Api-Key") != "secret" {
+		t.Errorf("Expected the credentials for an allowed host, got %v", got.Header)
+	}
+}
+
+func TestHashURLRetries(t *testing.T) {
+	requests := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests++; requests == 1 || r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte{0, 0, 1, 0})
+	}))
+	defer upstream.Close()
+
+	config := DefaultConfig()
+	config.RateLimit, config.HostRateLimit = 0, 0
+	config.Retry = hasher.DefaultRetryPolicy()
+	config.Retry.InitialBackoff = time.Millisecond
+	handler := NewServer(config).Handler()
+
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/hash/url?url="+target, nil))
+		return w
+	}
+
+	w := get(upstream.URL)
+	var resp HashResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || len(resp.Attempts) != 2 || resp.Attempts[0].StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected a hash after 2 attempts, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = get(upstream.URL + "/down")
+	var errResp ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &errResp)
+	if attempts, _ := errResp.Error.Details["attempts"].([]interface{}); w.Code != http.StatusBadGateway || len(attempts) != 3 {
+		t.Errorf("Expected 502 with 3 attempts, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHashURLCache(t *testing.T) {
+	requests := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte{0, 0, 1, 0})
+	}))
+	defer upstream.Close()
+
+	c, err := cache.Open(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("Open() returned error: %v", err)
+	}
+	config := DefaultConfig()
+	config.RateLimit, config.HostRateLimit = 0, 0
+	config.Cache = c
+	config.AllowRequestAuth = true
+	handler := NewServer(config).Handler()
+
+	post := func(fields string) HashResponse {
+		req := httptest.NewRequest(http.MethodPost, "/v1/hash/url", strings.NewReader(`{"url":"`+upstream.URL+`"`+fields+`}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		var resp HashResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+
+	if resp := post(""); resp.Cache != hasher.CacheMiss {
+		t.Errorf("Expected a cache miss, got %+v", resp)
+	}
+	if resp := post(""); resp.Cache != hasher.CacheHit || requests != 1 {
+		t.Errorf("Expected a cache hit without a request, got %+v after %d requests", resp, requests)
+	}
+
+	// Fetches with client credentials bypass the shared cache
+	if resp := post(`,"auth":{"bearer_token":"t0ken"}`); resp.Cache != "" || requests != 2 {
+		t.Errorf("Expected the cache to be bypassed, got %+v after %d requests", resp, requests)
+	}
+}
+
+func TestHashURLContentType(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<!DOCTYPE html><title>Just a moment...</title>"))
+	}))
+	defer upstream.Close()
+
+	config := DefaultConfig()
+	config.RateLimit, config.HostRateLimit = 0, 0
+	handler := NewServer(config).Handler()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/hash/url?url="+upstream.URL, nil))
+	var resp HashResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || resp.Type != hasher.TypeHTML || resp.Warning == "" {
+		t.Errorf("Expected a hash with a warning, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/hash/url?strict=true&url="+upstream.URL, nil))
+	var errResp ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &errResp)
+	if w.Code != http.StatusBadGateway || errResp.Error == nil || errResp.Error.Details["type"] != hasher.TypeHTML {
+		t.Errorf("Expected 502 in strict mode, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHashURLEncoding(t *testing.T) {
+	var gzipped bytes.Buffer
+	zw := gzip.NewWriter(&gzipped)
+	zw.Write([]byte{0, 0, 1, 0})
+	zw.Close()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(gzipped.Bytes())
+	}))
+	defer upstream.Close()
+
+	config := DefaultConfig()
+	config.RateLimit, config.HostRateLimit = 0, 0
+	handler := NewServer(config).Handler()
+
+	get := func(query string) (*httptest.ResponseRecorder, HashResponse) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/hash/url?url="+upstream.URL+query, nil))
+		var resp HashResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w, resp
+	}
+
+	h := hasher.New(nil)
+	decoded, _ := h.HashFromBytes([]byte{0, 0, 1, 0})
+	raw, _ := h.HashFromBytes(gzipped.Bytes())
+
+	w, resp := get("&hash_mode=both")
+	if w.Code != http.StatusOK || resp.Hash != decoded || resp.RawHash != raw || resp.ContentEncoding != "gzip" {
+		t.Errorf("Expected decoded and raw hashes of a gzip body, got %d %s", w.Code, w.Body.String())
+	}
+	if _, resp := get("&hash_mode=raw"); resp.Hash != raw || resp.RawHash != "" {
+		t.Errorf("Expected the raw hash, got %+v", resp)
+	}
+	if w, _ := get("&hash_mode=transfer"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid hash mode, got %d", w.Code)
+	}
+}
+
+func TestHashURLRedirects(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/favicon.ico" {
+			http.Redirect(w, r, "/static/favicon.ico", http.StatusFound)
+			return
+		}
+		w.Write([]byte{0, 0, 1, 0})
+	}))
+	defer upstream.Close()
+
+	config := DefaultConfig()
+	config.RateLimit, config.HostRateLimit = 0, 0
+	get := func(handler http.Handler) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/hash/url?url="+upstream.URL+"/favicon.ico", nil))
+		return w
+	}
+
+	w := get(NewServer(config).Handler())
+	var resp HashResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || resp.FinalURL != upstream.URL+"/static/favicon.ico" ||
+		len(resp.Redirects) != 1 || resp.Redirects[0].StatusCode != http.StatusFound {
+		t.Errorf("Expected the redirect chain, got %d %s", w.Code, w.Body.String())
+	}
+
+	config.Redirect = &hasher.RedirectPolicy{}
+	w = get(NewServer(config).Handler())
+	var errResp ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &errResp)
+	if redirects, _ := errResp.Error.Details["redirects"].([]interface{}); w.Code != http.StatusBadGateway || len(redirects) != 1 {
+		t.Errorf("Expected 502 with the refused redirect, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHashBody(t *testing.T) {
+	page := "<!DOCTYPE html><title>Router Login</title><body>Sign in</body>"
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(page))
+	}))
+	defer upstream.Close()
+
+	config := DefaultConfig()
+	config.RateLimit, config.HostRateLimit = 0, 0
+	handler := NewServer(config).Handler()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/hash/body?url="+upstream.URL, nil))
+	var resp HashResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	hash, _ := hasher.New(nil).HashHTML([]byte(page))
+	titleHash, _ := hasher.New(nil).HashHTML([]byte("Router Login"))
+	if w.Code != http.StatusOK || resp.Hash != hash || resp.Formatted != "http.html_hash:"+hash {
+		t.Fatalf("Expected html hash %s as a Shodan query, got %d %s", hash, w.Code, w.Body.String())
+	}
+	if resp.Title != "Router Login" || resp.TitleHash != titleHash || resp.Length != len(page) {
+		t.Errorf("Unexpected title or length in %s", w.Body.String())
+	}
+
+	// Fofa has no body hash field
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/hash/body?format=fofa&url="+upstream.URL, nil))
+	resp = HashResponse{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Format != "fofa" || resp.Formatted != "" || resp.Hash != hash {
+		t.Errorf("Expected no fofa query, got %s", w.Body.String())
+	}
+
+	// Error pages are hashed too, with their status
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/hash/body?url="+upstream.URL+"/missing", nil))
+	resp = HashResponse{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	notFound, _ := hasher.New(nil).HashHTML([]byte("404 page not found\n"))
+	if w.Code != http.StatusOK || resp.StatusCode != http.StatusNotFound || resp.Hash != notFound {
+		t.Errorf("Expected the hash of the 404 page, got %d %s", w.Code, w.Body.String())
+	}
+}
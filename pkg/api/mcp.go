@@ -0,0 +1,318 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/mcp"
+)
+
+// Headers of the MCP Streamable HTTP transport
+const (
+	mcpSessionHeader  = "Mcp-Session-Id"
+	mcpProtocolHeader = "MCP-Protocol-Version"
+)
+
+const (
+	// mcpSessionIdleTimeout expires MCP sessions without activity
+	mcpSessionIdleTimeout = 30 * time.Minute
+	// mcpMaxSessions bounds the number of concurrent MCP sessions
+	mcpMaxSessions = 1000
+	// sseKeepAlive is the interval of keep-alive comments on SSE streams
+	sseKeepAlive = 15 * time.Second
+)
+
+// handleMCP implements the MCP Streamable HTTP transport. POST carries
+// client messages and is answered with JSON or an SSE stream, GET opens an
+// SSE stream for server-to-client messages of a session, and DELETE ends a
+// session.
+func (s *Server) handleMCP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		s.handleMCPPost(w, r)
+	case http.MethodGet:
+		s.handleMCPStream(w, r)
+	case http.MethodDelete:
+		s.handleMCPDelete(w, r)
+	default:
+		sendErrorResponse(w, r, errMethodNotAllowed(r.Method, http.MethodPost, http.MethodGet, http.MethodDelete))
+	}
+}
+
+// handleMCPPost handles client messages
+func (s *Server) handleMCPPost(w http.ResponseWriter, r *http.Request) {
+	// Read request body
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+	if err != nil {
+		sendErrorResponse(w, r, bodyError(err))
+		return
+	}
+
+	if !mcp.IsJSONRPC(body) {
+		s.handleLegacyMCP(w, r, body)
+		return
+	}
+
+	if apiErr := checkMCPProtocolVersion(r); apiErr != nil {
+		sendErrorResponse(w, r, apiErr)
+		return
+	}
+
+	// Requests without a session ID are handled statelessly, except for
+	// initialize, which starts a session
+	ctx := s.mcpContext(r)
+	if r.Header.Get(mcpSessionHeader) != "" {
+		session, apiErr := s.mcpSession(r)
+		if apiErr != nil {
+			sendErrorResponse(w, r, apiErr)
+			return
+		}
+		ctx = mcp.WithSession(ctx, session)
+	} else if mcp.IsInitializeRequest(body) {
+		session := s.mcpSessions.Create(mcpSessionOwner(r))
+		w.Header().Set(mcpSessionHeader, session.ID)
+		ctx = mcp.WithSession(ctx, session)
+	}
+
+	// Notifications and responses are only acknowledged
+	if !mcp.HasRequests(body) {
+		s.mcpHandler.HandleMessage(ctx, body)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	if acceptsEventStream(r) {
+		stream := newSSEStream(w, s.config.WriteTimeout)
+		ctx = mcp.WithNotifier(ctx, stream.send)
+
+		done := make(chan []byte, 1)
+		go func() { done <- s.mcpHandler.HandleMessage(ctx, body) }()
+
+		ticker := time.NewTicker(sseKeepAlive)
+		defer ticker.Stop()
+		for {
+			select {
+			case resp := <-done:
+				if resp != nil {
+					stream.send(resp)
+				}
+				return
+			case <-ticker.C:
+				stream.keepAlive()
+			}
+		}
+	}
+
+	// Progress notifications cannot be sent in a JSON response; they go to
+	// the session's GET stream, if one is open
+	resp := s.mcpHandler.HandleMessage(ctx, body)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resp)
+}
+
+// handleMCPStream serves the SSE stream of server-to-client messages of a session
+func (s *Server) handleMCPStream(w http.ResponseWriter, r *http.Request) {
+	if !acceptsEventStream(r) {
+		sendErrorResponse(w, r, newAPIError(http.StatusNotAcceptable, CodeInvalidRequest, "The MCP stream requires Accept: text/event-stream"))
+		return
+	}
+	if r.Header.Get(mcpSessionHeader) == "" {
+		sendErrorResponse(w, r, errMissingParameter(mcpSessionHeader))
+		return
+	}
+	session, apiErr := s.mcpSession(r)
+	if apiErr != nil {
+		sendErrorResponse(w, r, apiErr)
+		return
+	}
+
+	stream := newSSEStream(w, s.config.WriteTimeout)
+	ticker := time.NewTicker(sseKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-session.Done():
+			return
+		case msg := <-session.Outbound():
+			stream.send(msg)
+		case <-ticker.C:
+			stream.keepAlive()
+		}
+	}
+}
+
+// handleMCPDelete ends a session and cancels its in-flight requests
+func (s *Server) handleMCPDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get(mcpSessionHeader) == "" {
+		sendErrorResponse(w, r, errMissingParameter(mcpSessionHeader))
+		return
+	}
+	session, apiErr := s.mcpSession(r)
+	if apiErr != nil {
+		sendErrorResponse(w, r, apiErr)
+		return
+	}
+
+	s.mcpSessions.Delete(session.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleLegacyMCP handles a request in the legacy message format
+func (s *Server) handleLegacyMCP(w http.ResponseWriter, r *http.Request, body []byte) {
+	// Parse MCP request
+	var req mcp.Request
+	if err := json.Unmarshal(body, &req); err != nil {
+		sendErrorResponse(w, r, newAPIError(http.StatusBadRequest, CodeInvalidRequest, "Invalid MCP request: "+err.Error()))
+		return
+	}
+
+	// Process the request
+	resp, err := s.mcpHandler.ProcessContext(s.mcpContext(r), &req)
+	if err != nil {
+		sendErrorResponse(w, r, newAPIError(http.StatusBadRequest, CodeInvalidRequest, "Error processing MCP request: "+err.Error()))
+		return
+	}
+
+	// Serialize response
+	respData, err := json.Marshal(resp)
+	if err != nil {
+		sendErrorResponse(w, r, newAPIError(http.StatusInternalServerError, CodeInternal, "Error serializing response"))
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respData)
+}
+
+// mcpContext returns the context for MCP requests. Fetching URLs and reading
+// server files require the same scopes as /v1/hash/url and /v1/hash/path.
+func (s *Server) mcpContext(r *http.Request) context.Context {
+	ctx := r.Context()
+	if !s.authEnabled() {
+		return ctx
+	}
+	key := apiKeyFromContext(ctx)
+	if key == nil || !key.HasScope(ScopeHashURL) {
+		ctx = mcp.WithoutURLAccess(ctx)
+	}
+	if key == nil || !key.HasScope(ScopeHashPath) {
+		ctx = mcp.WithoutFileAccess(ctx)
+	}
+	return ctx
+}
+
+// mcpSession returns the session named by the Mcp-Session-Id header. Sessions
+// can only be used by the client that created them.
+func (s *Server) mcpSession(r *http.Request) (*mcp.Session, *APIError) {
+	id := r.Header.Get(mcpSessionHeader)
+	session, ok := s.mcpSessions.Get(id)
+	if !ok || session.Owner != mcpSessionOwner(r) {
+		return nil, newAPIError(http.StatusNotFound, CodeNotFound, "Unknown or expired MCP session").
+			WithDetail("session", id)
+	}
+	return session, nil
+}
+
+// mcpSessionOwner identifies the client for session ownership
+func mcpSessionOwner(r *http.Request) string {
+	if key := apiKeyFromContext(r.Context()); key != nil {
+		return key.Name
+	}
+	return ""
+}
+
+// checkMCPProtocolVersion rejects requests for a protocol version the server does not speak
+func checkMCPProtocolVersion(r *http.Request) *APIError {
+	version := r.Header.Get(mcpProtocolHeader)
+	if version == "" {
+		return nil
+	}
+	for _, v := range mcp.SupportedProtocolVersions {
+		if v == version {
+			return nil
+		}
+	}
+	return newAPIError(http.StatusBadRequest, CodeInvalidParameter, "Unsupported MCP protocol version: "+version).
+		WithDetail("supported", mcp.SupportedProtocolVersions)
+}
+
+// acceptsEventStream reports whether the client accepts SSE responses
+func acceptsEventStream(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			mediaType, _, _ := strings.Cut(part, ";")
+			if strings.TrimSpace(mediaType) == "text/event-stream" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sseStream writes server-sent events. Every write extends the connection's
+// write deadline, so streams can outlive the server's write timeout as long
+// as events or keep-alives keep flowing.
+type sseStream struct {
+	mu           sync.Mutex
+	w            http.ResponseWriter
+	rc           *http.ResponseController
+	writeTimeout time.Duration
+}
+
+// newSSEStream sends the SSE response headers
+func newSSEStream(w http.ResponseWriter, writeTimeout time.Duration) *sseStream {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	stream := &sseStream{w: w, rc: http.NewResponseController(w), writeTimeout: writeTimeout}
+	stream.mu.Lock()
+	stream.flush()
+	stream.mu.Unlock()
+	return stream
+}
+
+// send writes a JSON-RPC message as a message event
+func (st *sseStream) send(msg []byte) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.extendDeadline()
+	fmt.Fprintf(st.w, "event: message\ndata: %s\n\n", msg)
+	st.flush()
+}
+
+// keepAlive writes an SSE comment to keep idle connections open
+func (st *sseStream) keepAlive() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.extendDeadline()
+	io.WriteString(st.w, ": keep-alive\n\n")
+	st.flush()
+}
+
+// extendDeadline moves the write deadline past the next write
+func (st *sseStream) extendDeadline() {
+	if st.writeTimeout > 0 {
+		// Not every ResponseWriter supports deadlines; streams then rely on
+		// the server configuration alone
+		_ = st.rc.SetWriteDeadline(time.Now().Add(st.writeTimeout))
+	}
+}
+
+// flush sends buffered data to the client
+func (st *sseStream) flush() {
+	_ = st.rc.Flush()
+}
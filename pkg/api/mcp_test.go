@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mcpPost sends a JSON-RPC message to the MCP endpoint
+func mcpPost(handler http.Handler, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/mcp", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+const mcpInitialize = `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`
+
+func TestMCPSessions(t *testing.T) {
+	handler := NewServer(DefaultConfig()).Handler()
+
+	w := mcpPost(handler, mcpInitialize, nil)
+	session := w.Header().Get(mcpSessionHeader)
+	if w.Code != http.StatusOK || session == "" {
+		t.Fatalf("Expected initialize to start a session, got %d %q: %s", w.Code, session, w.Body.String())
+	}
+
+	w = mcpPost(handler, `{"jsonrpc":"2.0","id":2,"method":"ping"}`, map[string]string{mcpSessionHeader: session})
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected ping in session to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = mcpPost(handler, `{"jsonrpc":"2.0","id":2,"method":"ping"}`, map[string]string{mcpSessionHeader: "unknown"})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown session, got %d", w.Code)
+	}
+
+	w = mcpPost(handler, `{"jsonrpc":"2.0","id":2,"method":"ping"}`, map[string]string{mcpProtocolHeader: "1999-01-01"})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unsupported protocol version, got %d", w.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/v1/mcp", nil)
+	req.Header.Set(mcpSessionHeader, session)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204 when terminating the session, got %d", w.Code)
+	}
+
+	w = mcpPost(handler, `{"jsonrpc":"2.0","id":3,"method":"ping"}`, map[string]string{mcpSessionHeader: session})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 after the session was terminated, got %d", w.Code)
+	}
+}
+
+func TestMCPSessionOwnership(t *testing.T) {
+	aliceSecret, alice, _ := GenerateKey("alice", []string{ScopeMCP}, nil)
+	bobSecret, bob, _ := GenerateKey("bob", []string{ScopeMCP}, nil)
+
+	config := DefaultConfig()
+	config.Keys = []APIKey{alice, bob}
+	handler := NewServer(config).Handler()
+
+	w := mcpPost(handler, mcpInitialize, map[string]string{"Authorization": "Bearer " + aliceSecret})
+	session := w.Header().Get(mcpSessionHeader)
+	if session == "" {
+		t.Fatalf("Expected a session, got %d: %s", w.Code, w.Body.String())
+	}
+
+	ping := `{"jsonrpc":"2.0","id":2,"method":"ping"}`
+	if w := mcpPost(handler, ping, map[string]string{"Authorization": "Bearer " + aliceSecret, mcpSessionHeader: session}); w.Code != http.StatusOK {
+		t.Errorf("Expected the owner to use the session, got %d", w.Code)
+	}
+	if w := mcpPost(handler, ping, map[string]string{"Authorization": "Bearer " + bobSecret, mcpSessionHeader: session}); w.Code != http.StatusNotFound {
+		t.Errorf("Expected another key to be refused the session, got %d", w.Code)
+	}
+}
+
+func TestMCPEventStream(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("icon"))
+	}))
+	defer ts.Close()
+
+	server := NewServer(DefaultConfig())
+	handler := server.Handler()
+
+	body := `{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"hash_urls","arguments":{"urls":["` +
+		ts.URL + `/favicon.ico"]},"_meta":{"progressToken":1}}}`
+	w := mcpPost(handler, body, map[string]string{"Accept": "application/json, text/event-stream"})
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %q: %s", ct, w.Body.String())
+	}
+	events := strings.Split(strings.TrimSpace(w.Body.String()), "\n\n")
+	if len(events) != 3 {
+		t.Fatalf("Expected 2 progress events and the response, got %d: %s", len(events), w.Body.String())
+	}
+	if !strings.Contains(events[0], "event: message\ndata: ") || !strings.Contains(events[0], "notifications/progress") {
+		t.Errorf("Unexpected progress event %q", events[0])
+	}
+	if !strings.Contains(events[2], `"id":5`) || !strings.Contains(events[2], "favicon.ico") {
+		t.Errorf("Expected the response as the last event, got %q", events[2])
+	}
+
+	// Messages queued for a session are delivered on its GET stream
+	session := server.mcpSessions.Create("")
+	session.Send([]byte(`{"jsonrpc":"2.0","method":"notifications/message"}`))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/v1/mcp", nil).WithContext(ctx)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set(mcpSessionHeader, session.ID)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if !strings.Contains(w.Body.String(), "notifications/message") {
+		t.Errorf("Expected the queued message on the stream, got %q", w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/mcp", nil)
+	req.Header.Set(mcpSessionHeader, session.ID)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusNotAcceptable {
+		t.Errorf("Expected 406 without Accept: text/event-stream, got %d", w.Code)
+	}
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/hasher"
+	"github.com/cyberspacesec/go-iconhash/pkg/metrics"
+)
+
+// fetchBuckets are histogram buckets for outbound fetch durations in seconds
+var fetchBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// serverMetrics holds the metrics exported by the API server. It implements
+// hasher.Observer to record outbound fetches and hashed bytes.
+type serverMetrics struct {
+	registry *metrics.Registry
+
+	requests      *metrics.Counter
+	duration      *metrics.Histogram
+	inFlight      *metrics.Gauge
+	fetchDuration *metrics.Histogram
+	fetchFailures *metrics.Counter
+	hashedBytes   *metrics.Counter
+	cacheRequests *metrics.Counter
+	rateLimited   *metrics.Counter
+}
+
+// newServerMetrics creates the server metrics in a new registry
+func newServerMetrics() *serverMetrics {
+	r := metrics.NewRegistry()
+	return &serverMetrics{
+		registry: r,
+		requests: r.NewCounter("iconhash_http_requests_total",
+			"Total HTTP requests by route, method and status code.", "route", "method", "status"),
+		duration: r.NewHistogram("iconhash_http_request_duration_seconds",
+			"HTTP request latency by route and status code.", nil, "route", "status"),
+		inFlight: r.NewGauge("iconhash_http_requests_in_flight",
+			"HTTP requests currently being served."),
+		fetchDuration: r.NewHistogram("iconhash_fetch_duration_seconds",
+			"Outbound favicon fetch duration by result.", fetchBuckets, "result"),
+		fetchFailures: r.NewCounter("iconhash_fetch_failures_total",
+			"Failed outbound favicon fetches by reason.", "reason"),
+		hashedBytes: r.NewCounter("iconhash_hashed_bytes_total",
+			"Total bytes fed to the hash function."),
+		cacheRequests: r.NewCounter("iconhash_cache_requests_total",
+			"Fetch cache lookups by result (hit, revalidated or miss).", "result"),
+		rateLimited: r.NewCounter("iconhash_rate_limited_total",
+			"Requests rejected by a rate limit or quota, by limit (client, quota or host).", "limit"),
+	}
+}
+
+// ObserveFetch implements hasher.Observer
+func (m *serverMetrics) ObserveFetch(duration time.Duration, err error) {
+	if err != nil {
+		m.fetchDuration.Observe(duration.Seconds(), "failure")
+		m.fetchFailures.Inc(hasher.FailureReason(err))
+		return
+	}
+	m.fetchDuration.Observe(duration.Seconds(), "success")
+}
+
+// ObserveHash implements hasher.Observer
+func (m *serverMetrics) ObserveHash(size int) {
+	m.hashedBytes.Add(float64(size))
+}
+
+// ObserveCache implements hasher.CacheObserver
+func (m *serverMetrics) ObserveCache(result string) {
+	m.cacheRequests.Inc(result)
+}
+
+// instrument wraps a route handler to record request counts, latency and
+// in-flight requests under the route pattern
+func (m *serverMetrics) instrument(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		m.inFlight.Inc()
+		defer m.inFlight.Dec()
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+
+		status := strconv.Itoa(rec.status)
+		m.requests.Inc(route, r.Method, status)
+		m.duration.Observe(time.Since(start).Seconds(), route, status)
+	}
+}
+
+// handler serves the metrics, requiring the bearer token when one is set
+func (m *serverMetrics) handler(token string) http.HandlerFunc {
+	serve := m.registry.Handler()
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			sendErrorResponse(w, r, errMethodNotAllowed(r.Method, http.MethodGet))
+			return
+		}
+
+		if token != "" {
+			provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				sendErrorResponse(w, r, newAPIError(http.StatusUnauthorized, CodeUnauthorized, "Unauthorized: Invalid or missing metrics token"))
+				return
+			}
+		}
+
+		serve.ServeHTTP(w, r)
+	}
+}
+
+// statusRecorder captures the status code and body size written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	written     int64
+}
+
+// WriteHeader records the status code
+func (r *statusRecorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.status = code
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Write records an implicit 200 status
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(b)
+	r.written += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher for streaming responses
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying ResponseWriter for http.ResponseController
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
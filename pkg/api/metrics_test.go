@@ -0,0 +1,96 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	config := DefaultConfig()
+	config.AuthToken = "api-token"
+	config.EnableMetrics = true
+	config.MetricsToken = "metrics-token"
+	server := NewServer(config)
+	handler := server.Handler()
+
+	// Generate some traffic
+	req := httptest.NewRequest(http.MethodPost, "/v1/hash/base64", strings.NewReader("data=AAAA"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer api-token")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	tests := []struct {
+		name           string
+		authorization  string
+		expectedStatus int
+	}{
+		{"Metrics token", "Bearer metrics-token", http.StatusOK},
+		{"API token is not accepted", "Bearer api-token", http.StatusUnauthorized},
+		{"Missing token", "", http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if test.authorization != "" {
+				req.Header.Set("Authorization", test.authorization)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d", test.expectedStatus, w.Code)
+			}
+			if test.expectedStatus != http.StatusOK {
+				return
+			}
+
+			body := w.Body.String()
+			for _, expected := range []string{
+				`iconhash_http_requests_total{route="/v1/hash/base64",method="POST",status="200"} 1`,
+				`iconhash_http_request_duration_seconds_count{route="/v1/hash/base64",status="200"} 1`,
+				"iconhash_hashed_bytes_total 5",
+				"# TYPE iconhash_http_requests_in_flight gauge",
+				"# TYPE iconhash_fetch_duration_seconds histogram",
+			} {
+				if !strings.Contains(body, expected) {
+					t.Errorf("Expected %q in metrics output:\n%s", expected, body)
+				}
+			}
+		})
+	}
+}
+
+func TestMetricsDisabledByDefault(t *testing.T) {
+	handler := NewServer(nil).Handler()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestObserveFetch(t *testing.T) {
+	m := newServerMetrics()
+	m.ObserveFetch(0, nil)
+	m.ObserveFetch(0, &testStatusError{})
+
+	if n := m.fetchDuration.Count("success"); n != 1 {
+		t.Errorf("Expected 1 successful fetch, got %d", n)
+	}
+	if n := m.fetchDuration.Count("failure"); n != 1 {
+		t.Errorf("Expected 1 failed fetch, got %d", n)
+	}
+	if v := m.fetchFailures.Value("other"); v != 1 {
+		t.Errorf("Expected 1 failure with reason other, got %v", v)
+	}
+}
+
+// testStatusError is an error that FailureReason cannot classify
+type testStatusError struct{}
+
+func (e *testStatusError) Error() string { return "test error" }
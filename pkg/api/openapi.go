@@ -0,0 +1,379 @@
+package api
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// OpenAPIVersion is the OpenAPI specification version of the generated document
+const OpenAPIVersion = "3.0.3"
+
+//go:embed assets/explorer.html
+var explorerHTML []byte
+
+// OpenAPI is the root of an OpenAPI document
+type OpenAPI struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+// Info holds the API metadata of an OpenAPI document
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// PathItem maps lower-case HTTP methods to operations
+type PathItem map[string]*Operation
+
+// Operation describes a single API operation on a path
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter describes an operation parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody describes an operation request body
+type RequestBody struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+// MediaType holds the schema of a request or response body
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Response describes an operation response
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// Schema is a subset of the OpenAPI schema object
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+	Example              interface{}        `json:"example,omitempty"`
+}
+
+// Components holds the reusable schemas and security schemes
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+// SecurityScheme describes an authentication method
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// ref returns a schema referencing a component schema
+func ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// jsonContent returns JSON content with the given schema
+func jsonContent(schema *Schema) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: schema}}
+}
+
+// componentSchemas returns the schemas shared by the operations
+func componentSchemas() map[string]*Schema {
+	return map[string]*Schema{
+		"HashResponse": {
+			Type: "object",
+			Properties: map[string]*Schema{
+				"hash":      {Type: "string", Description: "MMH3 hash of the favicon", Example: "-1424097501"},
+				"format":    {Type: "string", Description: "Output format used for the formatted field", Enum: formatNames},
+				"formatted": {Type: "string", Description: "Hash formatted as a search query", Example: `icon_hash="-1424097501"`},
+				"error":     {Type: "string", Description: "Error message (unversioned routes only)"},
+				"attempts": {Type: "array", Items: ref("FetchAttempt"),
+					Description: "Every fetch attempt, when the fetch had to be retried"},
+				"cache": {Type: "string", Enum: []string{"hit", "revalidated", "miss"},
+					Description: "Whether the favicon came from the server's fetch cache, when it has one. Fetches with headers, cookie or auth bypass the cache."},
+				"type": {Type: "string", Enum: []string{"ico", "png", "gif", "jpeg", "svg", "webp", "bmp", "html", "unknown"},
+					Description: "Content type of a fetched favicon, detected from its magic bytes and Content-Type"},
+				"warning":          {Type: "string", Description: "Why a fetched favicon is probably not one, such as an HTML error page served with 200 OK"},
+				"content_encoding": {Type: "string", Description: "Content-Encoding the favicon was sent with, if any", Example: "gzip"},
+				"raw_hash":         {Type: "string", Description: "Hash of the bytes as transferred, in the both hash mode"},
+				"final_url":        {Type: "string", Format: "uri", Description: "URL the favicon was fetched from, when the fetch was redirected"},
+				"redirects": {Type: "array", Items: ref("Redirect"),
+					Description: "Redirects followed to the final URL. Fetches stopped by the redirect policy list them in the redirects error detail."},
+				"status_code": {Type: "integer", Description: "HTTP status of the page hashed by the body endpoint, which is hashed whatever its status", Example: 200},
+				"length":      {Type: "integer", Description: "Length of the page hashed by the body endpoint"},
+				"title":       {Type: "string", Description: "Title of the page hashed by the body endpoint, if it has one"},
+				"title_hash":  {Type: "string", Description: "MMH3 hash of the page title"},
+			},
+			Required: []string{"hash"},
+		},
+		"Redirect": {
+			Type:        "object",
+			Description: "A response that redirected a fetch",
+			Properties: map[string]*Schema{
+				"url":         {Type: "string", Format: "uri", Description: "URL that was redirected"},
+				"status_code": {Type: "integer", Description: "Redirect status code, or 0 for a meta refresh", Example: 301},
+			},
+		},
+		"FetchAttempt": {
+			Type:        "object",
+			Description: "One attempt of a retried fetch. Failed fetches list their attempts in the attempts error detail.",
+			Properties: map[string]*Schema{
+				"number":      {Type: "integer", Example: 1},
+				"start":       {Type: "string", Format: "date-time"},
+				"duration":    {Type: "string", Description: "Duration of the attempt", Example: "120ms"},
+				"status_code": {Type: "integer", Description: "HTTP status received, absent if there was no response", Example: 503},
+				"reason":      {Type: "string", Description: "Failure reason, such as http_status, timeout or connection_reset"},
+				"error":       {Type: "string", Description: "Error of a failed attempt"},
+				"wait":        {Type: "string", Description: "Wait before the next attempt, honoring Retry-After", Example: "1s"},
+			},
+			Required: []string{"number", "start", "duration"},
+		},
+		"APIError": {
+			Type: "object",
+			Properties: map[string]*Schema{
+				"code":       {Type: "string", Description: "Machine-readable error code", Example: CodeMissingParameter},
+				"message":    {Type: "string", Description: "Human-readable error message"},
+				"details":    {Type: "object", Description: "Additional error context", AdditionalProperties: true},
+				"request_id": {Type: "string", Description: "ID of the failed request, also sent in X-Request-ID"},
+			},
+			Required: []string{"code", "message"},
+		},
+		"ErrorResponse": {
+			Type:       "object",
+			Properties: map[string]*Schema{"error": ref("APIError")},
+			Required:   []string{"error"},
+		},
+		"HealthResponse": {
+			Type: "object",
+			Properties: map[string]*Schema{
+				"status":  {Type: "string", Example: "ok"},
+				"version": {Type: "string", Example: "v1"},
+				"time":    {Type: "string", Format: "date-time"},
+			},
+		},
+		"HashURLRequest": {
+			Type: "object",
+			Properties: map[string]*Schema{
+				"url":    {Type: "string", Format: "uri", Description: "URL of the favicon"},
+				"format": {Type: "string", Enum: formatNames},
+				"uint32": {Type: "boolean"},
+				"strict": {Type: "boolean", Description: "Reject content that is not an image"},
+				"hash_mode": {Type: "string", Enum: []string{"decoded", "raw", "both"},
+					Description: "Hash the decoded body, the bytes as transferred, or both"},
+				"headers": {Type: "object", AdditionalProperties: &Schema{Type: "string"},
+					Description: "Headers sent with the fetch; only those allowed by the server (JSON bodies only)",
+					Example:     map[string]string{"Accept-Language": "en"}},
+				"cookie": {Type: "string", Description: "Cookie header sent with the fetch, if the server allows it (JSON bodies only)",
+					Example: "session=abc"},
+				"auth": ref("Credentials"),
+			},
+			Required: []string{"url"},
+		},
+		"Credentials": {
+			Type:        "object",
+			Description: "Credentials sent with the fetch, if the server allows it (JSON bodies only). A bearer token takes precedence.",
+			Properties: map[string]*Schema{
+				"username":     {Type: "string"},
+				"password":     {Type: "string", Format: "password"},
+				"bearer_token": {Type: "string", Format: "password"},
+			},
+		},
+		"HashFileRequest": {
+			Type: "object",
+			Properties: map[string]*Schema{
+				"file":   {Type: "string", Format: "byte", Description: "Base64 encoded file content"},
+				"format": {Type: "string", Enum: formatNames},
+				"uint32": {Type: "boolean"},
+			},
+			Required: []string{"file"},
+		},
+		"HashPathRequest": {
+			Type: "object",
+			Properties: map[string]*Schema{
+				"path":   {Type: "string", Description: "Path of the favicon file, absolute or relative to the first root directory"},
+				"format": {Type: "string", Enum: formatNames},
+				"uint32": {Type: "boolean"},
+			},
+			Required: []string{"path"},
+		},
+		"HashBase64Request": {
+			Type: "object",
+			Properties: map[string]*Schema{
+				"data":   {Type: "string", Description: "Base64 encoded favicon data, optionally with a data URL prefix"},
+				"format": {Type: "string", Enum: formatNames},
+				"uint32": {Type: "boolean"},
+			},
+			Required: []string{"data"},
+		},
+		"MCPRequest": {
+			Type: "object",
+			Description: "JSON-RPC 2.0 Model Context Protocol message (initialize, ping, tools/*, resources/*, prompts/*). " +
+				"Bodies without a jsonrpc member use the legacy message format.",
+			Properties: map[string]*Schema{
+				"jsonrpc": {Type: "string", Enum: []string{"2.0"}},
+				"id":      {Description: "Request ID; omitted for notifications"},
+				"method":  {Type: "string", Example: "tools/call"},
+				"params":  {Type: "object", AdditionalProperties: true},
+			},
+			AdditionalProperties: true,
+		},
+		"MCPResponse": {
+			Type:                 "object",
+			Description:          "JSON-RPC 2.0 response with a result or an error member",
+			AdditionalProperties: true,
+		},
+	}
+}
+
+// BuildOpenAPI generates the OpenAPI document from the route table
+func BuildOpenAPI() *OpenAPI {
+	doc := &OpenAPI{
+		OpenAPI: OpenAPIVersion,
+		Info: Info{
+			Title:       "IconHash API",
+			Description: "Calculate favicon MMH3 hashes for Fofa and Shodan searches.",
+			Version:     "1.0",
+		},
+		Paths: make(map[string]PathItem),
+		Components: Components{
+			Schemas: componentSchemas(),
+			SecuritySchemes: map[string]SecurityScheme{
+				"bearerAuth": {
+					Type:        "http",
+					Scheme:      "bearer",
+					Description: "Required when the server is started with authentication enabled",
+				},
+			},
+		},
+	}
+
+	for _, route := range Routes() {
+		doc.Paths[route.Path] = route.pathItem(false)
+		if route.Legacy != "" {
+			doc.Paths[route.Legacy] = route.pathItem(true)
+		}
+	}
+
+	return doc
+}
+
+// pathItem builds the OpenAPI operations of a route
+func (route Route) pathItem(legacy bool) PathItem {
+	item := make(PathItem)
+	for _, method := range route.Methods {
+		op := &Operation{
+			OperationID: route.operationID(method, legacy),
+			Summary:     route.Summary,
+			Description: route.Description,
+			Tags:        []string{route.Tag},
+			Deprecated:  legacy,
+			Parameters:  route.Parameters,
+			Responses:   route.responses(legacy),
+		}
+		if method != http.MethodGet {
+			op.RequestBody = route.RequestBody
+		}
+		if !route.Public {
+			op.Security = []map[string][]string{{"bearerAuth": {}}}
+		}
+		if route.Scope != "" {
+			op.Description = strings.TrimSpace(op.Description + " Requires an API key with the " + route.Scope + " scope.")
+		}
+		item[strings.ToLower(method)] = op
+	}
+	return item
+}
+
+// operationID returns a unique operation ID for a method of the route
+func (route Route) operationID(method string, legacy bool) string {
+	id := route.Name
+	if len(route.Methods) > 1 {
+		id += method[:1] + strings.ToLower(method[1:])
+	}
+	if legacy {
+		id += "Legacy"
+	}
+	return id
+}
+
+// responses returns the route responses, adding the error responses shared by
+// all operations
+func (route Route) responses(legacy bool) map[string]Response {
+	errSchema := ref("ErrorResponse")
+	if legacy {
+		errSchema = ref("HashResponse")
+	}
+
+	responses := make(map[string]Response, len(route.Responses)+3)
+	for code, resp := range route.Responses {
+		responses[code] = resp
+	}
+	if !route.Public {
+		responses["401"] = Response{Description: "Missing, invalid or expired authentication token", Content: jsonContent(errSchema)}
+		responses["429"] = Response{Description: "Rate limit or daily quota exceeded; retry after the Retry-After header", Content: jsonContent(errSchema)}
+	}
+	if route.Scope != "" {
+		responses["403"] = Response{Description: "API key lacks the " + route.Scope + " scope", Content: jsonContent(errSchema)}
+	}
+	if route.Errors {
+		responses["default"] = Response{Description: "Error", Content: jsonContent(errSchema)}
+	}
+	return responses
+}
+
+// handleOpenAPI serves the OpenAPI document
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendErrorResponse(w, r, errMethodNotAllowed(r.Method, http.MethodGet))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(BuildOpenAPI())
+}
+
+// handleDocs serves the embedded API explorer
+func (s *Server) handleDocs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendErrorResponse(w, r, errMethodNotAllowed(r.Method, http.MethodGet))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(explorerHTML)
+}
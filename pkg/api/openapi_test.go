@@ -0,0 +1,133 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/hasher"
+)
+
+func TestEveryRouteHasSpecEntry(t *testing.T) {
+	// Enable optional routes so that every documented path is served
+	config := DefaultConfig()
+	config.EnableMetrics = true
+	config.FileSandbox, _ = hasher.NewFileSandbox([]string{t.TempDir()}, 0)
+	server := NewServer(config)
+	mux := server.newMux()
+	doc := BuildOpenAPI()
+
+	if len(mux.patterns) == 0 {
+		t.Fatal("No routes registered")
+	}
+
+	for _, pattern := range mux.patterns {
+		item, ok := doc.Paths[pattern]
+		if !ok {
+			t.Errorf("Route %q has no entry in the OpenAPI document", pattern)
+			continue
+		}
+		if len(item) == 0 {
+			t.Errorf("Route %q has no operations in the OpenAPI document", pattern)
+		}
+	}
+
+	for path := range doc.Paths {
+		found := false
+		for _, pattern := range mux.patterns {
+			if pattern == path {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("OpenAPI path %q is not served", path)
+		}
+	}
+}
+
+func TestOpenAPIReferences(t *testing.T) {
+	data, err := json.Marshal(BuildOpenAPI())
+	if err != nil {
+		t.Fatalf("Failed to marshal OpenAPI document: %v", err)
+	}
+
+	schemas := componentSchemas()
+	for _, part := range strings.Split(string(data), `"$ref":"#/components/schemas/`)[1:] {
+		name := part[:strings.Index(part, `"`)]
+		if _, ok := schemas[name]; !ok {
+			t.Errorf("OpenAPI document references unknown schema %q", name)
+		}
+	}
+
+	ids := make(map[string]bool)
+	for path, item := range BuildOpenAPI().Paths {
+		for method, op := range item {
+			if ids[op.OperationID] {
+				t.Errorf("Duplicate operation ID %q at %s %s", op.OperationID, method, path)
+			}
+			ids[op.OperationID] = true
+		}
+	}
+}
+
+func TestOpenAPIEndpoints(t *testing.T) {
+	config := DefaultConfig()
+	config.AuthToken = "test-token"
+	handler := NewServer(config).Handler()
+
+	// The document is public even when authentication is enabled
+	req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var doc OpenAPI
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("Failed to parse OpenAPI document: %v", err)
+	}
+	if doc.OpenAPI != OpenAPIVersion {
+		t.Errorf("Expected openapi %q, got %q", OpenAPIVersion, doc.OpenAPI)
+	}
+	if op := doc.Paths["/v1/hash/url"]["post"]; op == nil || op.RequestBody == nil {
+		t.Error("Expected POST /v1/hash/url with a request body")
+	}
+	if op := doc.Paths["/hash/url"]["get"]; op == nil || !op.Deprecated {
+		t.Error("Expected deprecated GET /hash/url alias")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/docs", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "openapi.json") {
+		t.Errorf("Expected API explorer page, got status %d", w.Code)
+	}
+}
+
+func TestGetServerInfoListsEnabledRoutes(t *testing.T) {
+	config := DefaultConfig()
+	info := GetServerInfo(config)
+	if !strings.Contains(info, "/v1/hash/url") {
+		t.Error("Expected /v1/hash/url to be listed")
+	}
+	for _, path := range []string{"/v1/hash/path", "/metrics"} {
+		if strings.Contains(info, path) {
+			t.Errorf("Expected disabled route %s not to be listed", path)
+		}
+	}
+
+	config.FileSandbox, _ = hasher.NewFileSandbox([]string{t.TempDir()}, 0)
+	config.EnableMetrics = true
+	info = GetServerInfo(config)
+	for _, path := range []string{"/v1/hash/path", "/metrics"} {
+		if !strings.Contains(info, path) {
+			t.Errorf("Expected enabled route %s to be listed", path)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package api
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxLimiterKeys bounds the number of tracked clients or hosts
+const maxLimiterKeys = 10000
+
+// rateLimiter is a set of token buckets keyed by client or host. Each bucket
+// holds up to burst tokens and refills at rate tokens per second.
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   int
+	buckets map[string]*tokenBucket
+	now     func() time.Time
+}
+
+// tokenBucket is the state of a single bucket
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// limitResult describes the outcome of taking a token
+type limitResult struct {
+	allowed    bool
+	remaining  int
+	retryAfter time.Duration
+	reset      time.Duration
+}
+
+// newRateLimiter creates a limiter, or returns nil if rate is not positive
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = int(math.Ceil(rate))
+	}
+	return &rateLimiter{
+		rate:    rate,
+		burst:   burst,
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// take removes a token from the bucket for key if one is available
+func (l *rateLimiter) take(key string) limitResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxLimiterKeys {
+			l.prune(now)
+		}
+		b = &tokenBucket{tokens: float64(l.burst), last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(l.burst), b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	res := limitResult{allowed: b.tokens >= 1}
+	if res.allowed {
+		b.tokens--
+	} else {
+		res.retryAfter = l.duration(1 - b.tokens)
+	}
+	res.remaining = int(b.tokens)
+	res.reset = l.duration(float64(l.burst) - b.tokens)
+	return res
+}
+
+// Allow implements hasher.HostLimiter
+func (l *rateLimiter) Allow(host string) (bool, time.Duration) {
+	res := l.take(host)
+	return res.allowed, res.retryAfter
+}
+
+// duration returns the time needed to refill the given number of tokens
+func (l *rateLimiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// prune removes buckets that have refilled completely, since they are
+// indistinguishable from new ones. If every bucket is still in use, the
+// least recently used one is evicted, so that the map never grows past
+// maxLimiterKeys.
+func (l *rateLimiter) prune(now time.Time) {
+	full := l.duration(float64(l.burst))
+	var oldest string
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= full {
+			delete(l.buckets, key)
+		} else if oldest == "" || b.last.Before(l.buckets[oldest].last) {
+			oldest = key
+		}
+	}
+	if len(l.buckets) >= maxLimiterKeys {
+		delete(l.buckets, oldest)
+	}
+}
+
+// quotaCounter counts requests per client and UTC day, for at most
+// maxLimiterKeys clients
+type quotaCounter struct {
+	mu     sync.Mutex
+	limit  int
+	day    string
+	counts map[string]int
+	now    func() time.Time
+}
+
+// newQuotaCounter creates a quota counter, or returns nil if limit is not positive
+func newQuotaCounter(limit int) *quotaCounter {
+	if limit <= 0 {
+		return nil
+	}
+	return &quotaCounter{
+		limit:  limit,
+		counts: make(map[string]int),
+		now:    time.Now,
+	}
+}
+
+// take counts a request for key if the daily quota allows it. The reset
+// duration is the time until the quota resets at midnight UTC.
+func (q *quotaCounter) take(key string) limitResult {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now().UTC()
+	if day := now.Format("2006-01-02"); day != q.day {
+		q.day = day
+		q.counts = make(map[string]int)
+	}
+
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	res := limitResult{reset: midnight.Sub(now)}
+
+	if q.counts[key] >= q.limit {
+		res.retryAfter = res.reset
+		return res
+	}
+
+	if _, ok := q.counts[key]; !ok && len(q.counts) >= maxLimiterKeys {
+		q.evict()
+	}
+	q.counts[key]++
+	res.allowed = true
+	res.remaining = q.limit - q.counts[key]
+	return res
+}
+
+// evict forgets the client with the fewest requests, so that flooding the
+// counter with new clients frees their own entries first rather than
+// resetting the quota of busy clients
+func (q *quotaCounter) evict() {
+	var fewest string
+	for key, count := range q.counts {
+		if fewest == "" || count < q.counts[fewest] {
+			fewest = key
+		}
+	}
+	delete(q.counts, fewest)
+}
+
+// rateLimit wraps a route handler to enforce the per-client rate limit and
+// daily quota. Authenticated requests are limited per API key, anonymous
+// requests per source IP.
+func (s *Server) rateLimit(next http.HandlerFunc) http.HandlerFunc {
+	if s.clientLimiter == nil && s.quota == nil {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		client := s.clientKey(r)
+
+		if s.clientLimiter != nil {
+			res := s.clientLimiter.take(client)
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(s.clientLimiter.burst))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(res.remaining))
+			w.Header().Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(res.reset)))
+			if !res.allowed {
+				s.metrics.rateLimited.Inc("client")
+				sendRateLimited(w, r, newAPIError(http.StatusTooManyRequests, CodeRateLimited, "Too many requests"), res.retryAfter)
+				return
+			}
+		}
+
+		if s.quota != nil {
+			res := s.quota.take(client)
+			w.Header().Set("X-RateLimit-Quota-Limit", strconv.Itoa(s.quota.limit))
+			w.Header().Set("X-RateLimit-Quota-Remaining", strconv.Itoa(res.remaining))
+			w.Header().Set("X-RateLimit-Quota-Reset", strconv.Itoa(ceilSeconds(res.reset)))
+			if !res.allowed {
+				s.metrics.rateLimited.Inc("quota")
+				sendRateLimited(w, r, newAPIError(http.StatusTooManyRequests, CodeQuotaExceeded, "Daily quota exceeded").
+					WithDetail("quota", s.quota.limit), res.retryAfter)
+				return
+			}
+		}
+
+		next(w, r)
+	}
+}
+
+// clientKey identifies the client for rate limiting
+func (s *Server) clientKey(r *http.Request) string {
+	if key := apiKeyFromContext(r.Context()); key != nil {
+		return "key:" + key.Name
+	}
+	return "ip:" + s.clientIP(r)
+}
+
+// clientIP returns the source IP of a request. Proxy headers are only
+// honoured when the server is configured to trust them. The last
+// X-Forwarded-For entry is the one the trusted proxy appended; the entries
+// before it are sent by the client and can be forged.
+func (s *Server) clientIP(r *http.Request) string {
+	if s.config.TrustProxyHeaders {
+		if fwd := r.Header.Values("X-Forwarded-For"); len(fwd) > 0 {
+			entries := strings.Split(fwd[len(fwd)-1], ",")
+			if ip := strings.TrimSpace(entries[len(entries)-1]); ip != "" {
+				return ip
+			}
+		}
+		if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); ip != "" {
+			return ip
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// sendRateLimited sends a 429 response with a Retry-After header
+func sendRateLimited(w http.ResponseWriter, r *http.Request, apiErr *APIError, retryAfter time.Duration) {
+	seconds := ceilSeconds(retryAfter)
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	sendErrorResponse(w, r, apiErr.WithDetail("retry_after", seconds))
+}
+
+// ceilSeconds rounds a duration up to whole seconds
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,216 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterRefill(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := newRateLimiter(2, 3)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if res := l.take("client"); !res.allowed {
+			t.Fatalf("Request %d rejected within burst", i+1)
+		}
+	}
+
+	res := l.take("client")
+	if res.allowed {
+		t.Fatal("Request allowed after burst was exhausted")
+	}
+	if res.retryAfter != 500*time.Millisecond {
+		t.Errorf("Expected retry after 500ms, got %v", res.retryAfter)
+	}
+
+	// Other clients have their own bucket
+	if res := l.take("other"); !res.allowed {
+		t.Error("Request from another client rejected")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if res := l.take("client"); !res.allowed {
+		t.Error("Request rejected after the bucket refilled")
+	}
+}
+
+func TestRateLimiterEvictsLeastRecentlyUsed(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := newRateLimiter(0.001, 1)
+	l.now = func() time.Time { return now }
+
+	// No bucket refills before the limit is reached
+	for i := 0; i < maxLimiterKeys; i++ {
+		now = now.Add(time.Millisecond)
+		l.take(fmt.Sprintf("client-%d", i))
+	}
+	l.take("client-0")
+
+	now = now.Add(time.Millisecond)
+	l.take("new")
+	if len(l.buckets) != maxLimiterKeys {
+		t.Fatalf("Expected %d buckets, got %d", maxLimiterKeys, len(l.buckets))
+	}
+	if _, ok := l.buckets["client-1"]; ok {
+		t.Error("Expected the least recently used bucket to be evicted")
+	}
+	if _, ok := l.buckets["client-0"]; !ok {
+		t.Error("Expected the recently used bucket to be kept")
+	}
+}
+
+func TestQuotaCounterResetsDaily(t *testing.T) {
+	now := time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC)
+	q := newQuotaCounter(2)
+	q.now = func() time.Time { return now }
+
+	q.take("client")
+	if res := q.take("client"); !res.allowed || res.remaining != 0 {
+		t.Fatalf("Unexpected result within quota: %+v", res)
+	}
+
+	res := q.take("client")
+	if res.allowed {
+		t.Fatal("Request allowed over the daily quota")
+	}
+	if res.retryAfter != time.Hour {
+		t.Errorf("Expected retry after 1h, got %v", res.retryAfter)
+	}
+
+	now = now.Add(time.Hour)
+	if res := q.take("client"); !res.allowed {
+		t.Error("Quota did not reset at midnight UTC")
+	}
+}
+
+func TestQuotaCounterIsBounded(t *testing.T) {
+	q := newQuotaCounter(5)
+	for i := 0; i < 3; i++ {
+		q.take("busy")
+	}
+	for i := 0; i < maxLimiterKeys+10; i++ {
+		q.take(fmt.Sprintf("client-%d", i))
+	}
+
+	if len(q.counts) != maxLimiterKeys {
+		t.Errorf("Expected %d counted clients, got %d", maxLimiterKeys, len(q.counts))
+	}
+	if q.counts["busy"] != 3 {
+		t.Errorf("Expected the busiest client to keep its count, got %d", q.counts["busy"])
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	config := DefaultConfig()
+	config.RateLimit = 1
+	config.RateBurst = 2
+	config.DailyQuota = 100
+	handler := NewServer(config).Handler()
+
+	send := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/hash/base64?data=AAAA", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := send("192.0.2.1:1234"); w.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected status %d, got %d", i+1, http.StatusOK, w.Code)
+		}
+	}
+
+	w := send("192.0.2.1:5678")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status %d, got %d", http.StatusTooManyRequests, w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header")
+	}
+	if w.Header().Get("X-RateLimit-Limit") != "2" || w.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Errorf("Unexpected rate limit headers: %v", w.Header())
+	}
+	if w.Header().Get("X-RateLimit-Quota-Remaining") != "" {
+		t.Error("Rejected request should not consume the daily quota")
+	}
+
+	var resp ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Error == nil || resp.Error.Code != CodeRateLimited {
+		t.Errorf("Expected error code %q, got %+v", CodeRateLimited, resp.Error)
+	}
+
+	// Another source IP is limited separately
+	if w := send("192.0.2.2:1234"); w.Code != http.StatusOK {
+		t.Errorf("Expected status %d for another client, got %d", http.StatusOK, w.Code)
+	}
+
+	// Public routes are never limited
+	for i := 0; i < 5; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/v1/health", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Health check limited: status %d", w.Code)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+
+	config := DefaultConfig()
+	if ip := NewServer(config).clientIP(req); ip != "192.0.2.1" {
+		t.Errorf("Expected remote address when proxy headers are untrusted, got %q", ip)
+	}
+
+	config.TrustProxyHeaders = true
+	if ip := NewServer(config).clientIP(req); ip != "203.0.113.7" {
+		t.Errorf("Expected forwarded address, got %q", ip)
+	}
+
+	// The proxy appends the real address to the one the client forged
+	req.Header.Set("X-Forwarded-For", "1.2.3.4, 203.0.113.7")
+	if ip := NewServer(config).clientIP(req); ip != "203.0.113.7" {
+		t.Errorf("Expected the address appended by the proxy, got %q", ip)
+	}
+	req.Header.Set("X-Forwarded-For", "1.2.3.4")
+	req.Header.Add("X-Forwarded-For", "203.0.113.7")
+	if ip := NewServer(config).clientIP(req); ip != "203.0.113.7" {
+		t.Errorf("Expected the address of the last header, got %q", ip)
+	}
+}
+
+func TestHostRateLimit(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte{0, 0, 1, 0})
+	}))
+	defer upstream.Close()
+
+	config := DefaultConfig()
+	config.RateLimit = 0
+	config.HostRateLimit = 1
+	config.HostBurst = 1
+	handler := NewServer(config).Handler()
+
+	codes := make([]int, 2)
+	for i := range codes {
+		req := httptest.NewRequest(http.MethodGet, "/v1/hash/url?url="+upstream.URL, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		codes[i] = w.Code
+	}
+
+	if codes[0] != http.StatusOK || codes[1] != http.StatusTooManyRequests {
+		t.Errorf("Expected statuses [200 429], got %v", codes)
+	}
+}
@@ -0,0 +1,256 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/hasher"
+	"github.com/cyberspacesec/go-iconhash/pkg/logging"
+)
+
+// maxRequestBodySize limits the size of request bodies, including file uploads
+const maxRequestBodySize = 10 << 20
+
+// contextKey is the type of context keys defined by this package
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	apiKeyKey
+	accessInfoKey
+)
+
+// HashRequest holds the parameters accepted by the hash endpoints. Every field
+// may be given in the query string, as a form value or in a JSON body; values
+// from the body take precedence over the query string.
+type HashRequest struct {
+	// URL is the favicon URL for the URL endpoint
+	URL string `json:"url,omitempty"`
+	// Data is base64 encoded favicon data for the base64 endpoint
+	Data string `json:"data,omitempty"`
+	// File is the base64 encoded file content for JSON uploads to the file endpoint
+	File string `json:"file,omitempty"`
+	// Path is the server-side file path for the path endpoint
+	Path string `json:"path,omitempty"`
+	// Format is the output format: plain, fofa or shodan
+	Format string `json:"format,omitempty"`
+	// Uint32 selects uint32 instead of int32 hash output
+	Uint32 bool `json:"uint32,omitempty"`
+	// Strict rejects fetched content that is not an image
+	Strict bool `json:"strict,omitempty"`
+	// HashMode selects the bytes of a fetched favicon to hash: decoded, raw
+	// or both
+	HashMode string `json:"hash_mode,omitempty"`
+
+	// Headers, Cookie and Auth are sent with the fetch of the URL endpoint.
+	// They are only read from a JSON body, so that they stay out of URLs and
+	// access logs, and only if the server policy allows them.
+	Headers map[string]string   `json:"headers,omitempty"`
+	Cookie  string              `json:"cookie,omitempty"`
+	Auth    *hasher.Credentials `json:"auth,omitempty"`
+}
+
+// jsonHashRequest mirrors HashRequest for decoding, so that fields absent from
+// the body can be told apart from zero values
+type jsonHashRequest struct {
+	URL      *string `json:"url"`
+	Data     *string `json:"data"`
+	File     *string `json:"file"`
+	Path     *string `json:"path"`
+	Format   *string `json:"format"`
+	Uint32   *bool   `json:"uint32"`
+	Strict   *bool   `json:"strict"`
+	HashMode *string `json:"hash_mode"`
+
+	Headers map[string]string   `json:"headers"`
+	Cookie  string              `json:"cookie"`
+	Auth    *hasher.Credentials `json:"auth"`
+}
+
+// decodeHashRequest collects hash parameters from the query string and from a
+// JSON, URL-encoded or multipart request body
+func decodeHashRequest(w http.ResponseWriter, r *http.Request) (*HashRequest, *APIError) {
+	values := r.URL.Query()
+
+	if r.Body != nil && r.Method != http.MethodGet {
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	}
+
+	mediaType := ""
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, _ = mime.ParseMediaType(ct)
+	}
+
+	var body jsonHashRequest
+	switch {
+	case r.Method == http.MethodGet:
+		// Query string only
+	case mediaType == "application/json":
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+			return nil, bodyError(err)
+		}
+	case mediaType == "multipart/form-data":
+		if err := r.ParseMultipartForm(maxRequestBodySize); err != nil {
+			return nil, bodyError(err)
+		}
+		mergeValues(values, r.MultipartForm.Value)
+	case mediaType == "application/x-www-form-urlencoded":
+		if err := r.ParseForm(); err != nil {
+			return nil, bodyError(err)
+		}
+		mergeValues(values, r.PostForm)
+	}
+
+	req := &HashRequest{
+		URL:      values.Get("url"),
+		Data:     values.Get("data"),
+		Path:     values.Get("path"),
+		Format:   values.Get("format"),
+		HashMode: values.Get("hash_mode"),
+	}
+
+	useUint32, err := parseBoolParam(values.Get("uint32"))
+	if err != nil {
+		return nil, errInvalidParameter("uint32", "expected true or false")
+	}
+	req.Uint32 = useUint32
+
+	strict, err := parseBoolParam(values.Get("strict"))
+	if err != nil {
+		return nil, errInvalidParameter("strict", "expected true or false")
+	}
+	req.Strict = strict
+
+	if body.URL != nil {
+		req.URL = *body.URL
+	}
+	if body.Data != nil {
+		req.Data = *body.Data
+	}
+	if body.File != nil {
+		req.File = *body.File
+	}
+	if body.Path != nil {
+		req.Path = *body.Path
+	}
+	if body.Format != nil {
+		req.Format = *body.Format
+	}
+	if body.Uint32 != nil {
+		req.Uint32 = *body.Uint32
+	}
+	if body.Strict != nil {
+		req.Strict = *body.Strict
+	}
+	if body.HashMode != nil {
+		req.HashMode = *body.HashMode
+	}
+	req.Headers, req.Cookie = body.Headers, body.Cookie
+	if body.Auth != nil && *body.Auth != (hasher.Credentials{}) {
+		req.Auth = body.Auth
+	}
+
+	if !isValidFormat(req.Format) {
+		return nil, errInvalidParameter("format", "expected plain, fofa or shodan").
+			WithDetail("value", req.Format)
+	}
+	if !hasher.ValidHashMode(req.HashMode) {
+		return nil, errInvalidParameter("hash_mode", "expected "+strings.Join(hasher.HashModes, ", ")).
+			WithDetail("value", req.HashMode)
+	}
+
+	return req, nil
+}
+
+// bodyError converts a body decoding error into an APIError
+func bodyError(err error) *APIError {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return newAPIError(http.StatusRequestEntityTooLarge, CodePayloadTooLarge, "Request body too large").
+			WithDetail("limit", maxErr.Limit)
+	}
+	return newAPIError(http.StatusBadRequest, CodeInvalidRequest, "Error parsing request body: "+err.Error())
+}
+
+// mergeValues copies non-empty form values over the query values
+func mergeValues(dst, src map[string][]string) {
+	for key, vals := range src {
+		if len(vals) > 0 && vals[0] != "" {
+			dst[key] = vals
+		}
+	}
+}
+
+// parseBoolParam parses a boolean query or form parameter
+func parseBoolParam(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "", "false", "0":
+		return false, nil
+	case "true", "1":
+		return true, nil
+	default:
+		return false, errors.New("invalid boolean")
+	}
+}
+
+// isValidFormat reports whether the format parameter is empty or a known format
+func isValidFormat(formatStr string) bool {
+	switch formatStr {
+	case "", "plain", "fofa", "shodan":
+		return true
+	default:
+		return false
+	}
+}
+
+// requestIDMiddleware assigns every request an ID, reusing a well-formed
+// X-Request-ID header from the client when present
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !isValidRequestID(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set("X-Request-ID", id)
+		ctx := logging.WithRequestID(context.WithValue(r.Context(), requestIDKey, id), id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requestIDFromContext returns the request ID stored in the context
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// newRequestID generates a random request ID
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// isValidRequestID reports whether a client supplied request ID is safe to echo
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
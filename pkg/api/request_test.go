@@ -0,0 +1,178 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/hasher"
+)
+
+func TestDecodeHashRequest(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		expected    HashRequest
+		expectCode  string
+	}{
+		{
+			name:     "Query parameters",
+			method:   http.MethodGet,
+			target:   "/v1/hash/url?url=https://example.com/favicon.ico&format=shodan&uint32=1",
+			expected: HashRequest{URL: "https://example.com/favicon.ico", Format: "shodan", Uint32: true},
+		},
+		{
+			name:        "JSON body",
+			method:      http.MethodPost,
+			target:      "/v1/hash/url",
+			contentType: "application/json",
+			body:        `{"url":"https://example.com/favicon.ico","format":"plain","uint32":true}`,
+			expected:    HashRequest{URL: "https://example.com/favicon.ico", Format: "plain", Uint32: true},
+		},
+		{
+			name:        "JSON body overrides query",
+			method:      http.MethodPost,
+			target:      "/v1/hash/url?url=https://query.example&format=fofa&uint32=true",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"url":"https://body.example","uint32":false}`,
+			expected:    HashRequest{URL: "https://body.example", Format: "fofa", Uint32: false},
+		},
+		{
+			name:        "Form body with query format",
+			method:      http.MethodPost,
+			target:      "/v1/hash/base64?format=shodan",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "data=AAAA&uint32=true",
+			expected:    HashRequest{Data: "AAAA", Format: "shodan", Uint32: true},
+		},
+		{
+			name:        "Fetch options in JSON body",
+			method:      http.MethodPost,
+			target:      "/v1/hash/url?cookie=ignored",
+			contentType: "application/json",
+			body:        `{"url":"https://example.com","headers":{"Accept":"image/*"},"cookie":"a=b","auth":{"bearer_token":"t"}}`,
+			expected: HashRequest{URL: "https://example.com", Headers: map[string]string{"Accept": "image/*"},
+				Cookie: "a=b", Auth: &hasher.Credentials{BearerToken: "t"}},
+		},
+		{
+			name:        "Unknown JSON field",
+			method:      http.MethodPost,
+			target:      "/v1/hash/url",
+			contentType: "application/json",
+			body:        `{"uri":"https://example.com"}`,
+			expectCode:  CodeInvalidRequest,
+		},
+		{
+			name:       "Invalid format",
+			method:     http.MethodGet,
+			target:     "/v1/hash/url?url=https://example.com&format=bing",
+			expectCode: CodeInvalidParameter,
+		},
+		{
+			name:       "Invalid uint32",
+			method:     http.MethodGet,
+			target:     "/v1/hash/url?url=https://example.com&uint32=maybe",
+			expectCode: CodeInvalidParameter,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+			if test.contentType != "" {
+				req.Header.Set("Content-Type", test.contentType)
+			}
+
+			result, apiErr := decodeHashRequest(httptest.NewRecorder(), req)
+			if test.expectCode != "" {
+				if apiErr == nil || apiErr.Code != test.expectCode {
+					t.Fatalf("Expected error code %q, got %v", test.expectCode, apiErr)
+				}
+				return
+			}
+
+			if apiErr != nil {
+				t.Fatalf("Unexpected error: %v", apiErr)
+			}
+			if !reflect.DeepEqual(*result, test.expected) {
+				t.Errorf("decodeHashRequest() = %+v, expected %+v", *result, test.expected)
+			}
+		})
+	}
+}
+
+func TestErrorResponseSchema(t *testing.T) {
+	server := NewServer(nil)
+	handler := requestIDMiddleware(http.HandlerFunc(server.handleHashURL))
+
+	// Versioned route returns the structured error
+	req := httptest.NewRequest(http.MethodGet, "/v1/hash/url", nil)
+	req.Header.Set("X-Request-ID", "test-request-1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	var errResp ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &errResp); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if errResp.Error == nil {
+		t.Fatal("Expected error object in response")
+	}
+	if errResp.Error.Code != CodeMissingParameter {
+		t.Errorf("Expected code %q, got %q", CodeMissingParameter, errResp.Error.Code)
+	}
+	if errResp.Error.RequestID != "test-request-1" {
+		t.Errorf("Expected request ID 'test-request-1', got %q", errResp.Error.RequestID)
+	}
+	if errResp.Error.Details["parameter"] != "url" {
+		t.Errorf("Expected parameter detail 'url', got %v", errResp.Error.Details["parameter"])
+	}
+
+	// Legacy route keeps the original error string
+	req = httptest.NewRequest(http.MethodGet, "/hash/url", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	var legacy HashResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &legacy); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if legacy.Error != "url parameter is required" {
+		t.Errorf("Expected legacy error message, got %q", legacy.Error)
+	}
+	if w.Header().Get("X-Request-ID") == "" {
+		t.Error("Expected generated X-Request-ID header")
+	}
+}
+
+func TestHashBase64JSONBody(t *testing.T) {
+	server := NewServer(nil)
+
+	body := `{"data":"AAABAAEAEBAAAAEAIABoBAAAFgAAAA==","format":"shodan"}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/hash/base64", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	server.handleHashBase64(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var resp HashResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if resp.Format != "shodan" || !strings.HasPrefix(resp.Formatted, "http.favicon.hash:") {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+}
@@ -0,0 +1,413 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// formatNames lists the accepted values of the format parameter
+var formatNames = []string{"plain", "fofa", "shodan"}
+
+// Route describes an API endpoint. The route table returned by Routes is the
+// single source of truth for request routing, the OpenAPI document and the
+// endpoint listings printed at startup.
+type Route struct {
+	// Name is the base OpenAPI operation ID
+	Name string
+	// Path is the versioned path of the endpoint
+	Path string
+	// Legacy is the unversioned compatibility alias, if any
+	Legacy string
+	// Methods lists the accepted HTTP methods
+	Methods []string
+	// Summary is a one-line description of the endpoint
+	Summary string
+	// Description is a longer description of the endpoint
+	Description string
+	// Tag groups related endpoints in the OpenAPI document
+	Tag string
+	// Public endpoints never require authentication
+	Public bool
+	// Scope is the API key scope required when authentication is enabled
+	Scope string
+	// Errors reports whether the endpoint returns structured error responses
+	Errors bool
+	// Parameters lists the query parameters of the endpoint
+	Parameters []Parameter
+	// RequestBody describes the accepted request bodies
+	RequestBody *RequestBody
+	// Responses maps status codes to successful responses
+	Responses map[string]Response
+
+	handler func(*Server, http.ResponseWriter, *http.Request)
+	// enabled reports whether an optional route is served with the given config
+	enabled func(*Config) bool
+}
+
+var (
+	formatParam = Parameter{
+		Name:        "format",
+		In:          "query",
+		Description: "Output format of the formatted field (default: fofa)",
+		Schema:      &Schema{Type: "string", Enum: formatNames},
+	}
+	pathParam = Parameter{
+		Name:        "path",
+		In:          "query",
+		Description: "Path of the favicon file, absolute or relative to the first root directory",
+		Schema:      &Schema{Type: "string"},
+	}
+	uint32Param = Parameter{
+		Name:        "uint32",
+		In:          "query",
+		Description: "Output the hash as uint32 instead of int32",
+		Schema:      &Schema{Type: "boolean"},
+	}
+	hashResponses = map[string]Response{
+		"200": {Description: "Hash calculated", Content: jsonContent(ref("HashResponse"))},
+	}
+)
+
+// Routes returns the API route table
+func Routes() []Route {
+	return []Route{
+		{
+			Name:      "health",
+			Path:      "/v1/health",
+			Legacy:    "/health",
+			Methods:   []string{http.MethodGet},
+			Summary:   "Health check",
+			Tag:       "meta",
+			Public:    true,
+			Responses: map[string]Response{"200": {Description: "Server is healthy", Content: jsonContent(ref("HealthResponse"))}},
+			handler:   (*Server).handleHealth,
+		},
+		{
+			Name:    "hashURL",
+			Path:    "/v1/hash/url",
+			Legacy:  "/hash/url",
+			Methods: []string{http.MethodGet, http.MethodPost},
+			Summary: "Hash from URL",
+			Description: "Fetch a favicon from a URL and calculate its hash. " +
+				"Parameters may be given in the query string, as form values or in a JSON body. " +
+				"Headers, cookies and credentials for the fetch are accepted in JSON bodies only, " +
+				"and are refused with 403 unless the server allows them. " +
+				"Responses report the detected content type and warn about content that is not an image; " +
+				"with strict, such content fails with 502.",
+			Tag:    "hash",
+			Errors: true,
+			Scope:  ScopeHashURL,
+			Parameters: []Parameter{
+				{Name: "url", In: "query", Description: "URL of the favicon", Schema: &Schema{Type: "string", Format: "uri"}},
+				formatParam,
+				uint32Param,
+				{Name: "strict", In: "query", Description: "Reject content that is not an image, such as HTML error pages",
+					Schema: &Schema{Type: "boolean"}},
+				{Name: "hash_mode", In: "query", Description: "Hash the decoded body (default), the bytes as transferred, or both",
+					Schema: &Schema{Type: "string", Enum: []string{"decoded", "raw", "both"}}},
+			},
+			RequestBody: &RequestBody{
+				Content: map[string]MediaType{
+					"application/json":                  {Schema: ref("HashURLRequest")},
+					"application/x-www-form-urlencoded": {Schema: ref("HashURLRequest")},
+				},
+			},
+			Responses: hashResponses,
+			handler:   (*Server).handleHashURL,
+		},
+		{
+			Name:    "hashBody",
+			Path:    "/v1/hash/body",
+			Methods: []string{http.MethodGet, http.MethodPost},
+			Summary: "HTML body hash from URL",
+			Description: "Fetch a web page and calculate the MMH3 hash of its body, as indexed by Shodan in http.html_hash, " +
+				"and of its title, if it has one. The page is hashed whatever its HTTP status, which is returned as status_code. " +
+				"Parameters are those of the URL endpoint, except strict and hash_mode. " +
+				"The format defaults to shodan; Fofa does not index body hashes, so the fofa format has no formatted field.",
+			Tag:    "hash",
+			Errors: true,
+			Scope:  ScopeHashURL,
+			Parameters: []Parameter{
+				{Name: "url", In: "query", Description: "URL of the web page", Schema: &Schema{Type: "string", Format: "uri"}},
+				formatParam,
+				uint32Param,
+			},
+			RequestBody: &RequestBody{
+				Content: map[string]MediaType{
+					"application/json":                  {Schema: ref("HashURLRequest")},
+					"application/x-www-form-urlencoded": {Schema: ref("HashURLRequest")},
+				},
+			},
+			Responses: hashResponses,
+			handler:   (*Server).handleHashBody,
+		},
+		{
+			Name:        "hashFile",
+			Path:        "/v1/hash/file",
+			Legacy:      "/hash/file",
+			Methods:     []string{http.MethodPost},
+			Summary:     "Hash from file upload",
+			Description: "Calculate the hash of an uploaded favicon file (at most 10 MB).",
+			Tag:         "hash",
+			Errors:      true,
+			Scope:       ScopeHashFile,
+			Parameters:  []Parameter{formatParam, uint32Param},
+			RequestBody: &RequestBody{
+				Required: true,
+				Content: map[string]MediaType{
+					"multipart/form-data": {Schema: &Schema{
+						Type: "object",
+						Properties: map[string]*Schema{
+							"file":   {Type: "string", Format: "binary"},
+							"format": {Type: "string", Enum: formatNames},
+							"uint32": {Type: "boolean"},
+						},
+						Required: []string{"file"},
+					}},
+					"application/json": {Schema: ref("HashFileRequest")},
+				},
+			},
+			Responses: hashResponses,
+			handler:   (*Server).handleHashFile,
+		},
+		{
+			Name:    "hashPath",
+			Path:    "/v1/hash/path",
+			Methods: []string{http.MethodGet, http.MethodPost},
+			Summary: "Hash from server file",
+			Description: "Calculate the hash of a favicon file on the server. Only files below the configured " +
+				"root directories can be read; this endpoint is disabled unless file roots are configured.",
+			Tag:        "hash",
+			Errors:     true,
+			Scope:      ScopeHashPath,
+			Parameters: []Parameter{pathParam, formatParam, uint32Param},
+			RequestBody: &RequestBody{
+				Required: true,
+				Content:  jsonContent(ref("HashPathRequest")),
+			},
+			Responses: hashResponses,
+			handler:   (*Server).handleHashPath,
+			enabled:   func(c *Config) bool { return c.FileSandbox != nil },
+		},
+		{
+			Name:        "hashBase64",
+			Path:        "/v1/hash/base64",
+			Legacy:      "/hash/base64",
+			Methods:     []string{http.MethodPost},
+			Summary:     "Hash from base64 data",
+			Description: "Calculate the hash of base64 encoded favicon data.",
+			Tag:         "hash",
+			Errors:      true,
+			Scope:       ScopeHashFile,
+			Parameters:  []Parameter{formatParam, uint32Param},
+			RequestBody: &RequestBody{
+				Required: true,
+				Content: map[string]MediaType{
+					"application/json":                  {Schema: ref("HashBase64Request")},
+					"application/x-www-form-urlencoded": {Schema: ref("HashBase64Request")},
+				},
+			},
+			Responses: hashResponses,
+			handler:   (*Server).handleHashBase64,
+		},
+		{
+			Name:    "mcp",
+			Path:    "/v1/mcp",
+			Legacy:  "/mcp",
+			Methods: []string{http.MethodPost, http.MethodGet, http.MethodDelete},
+			Summary: "Model Context Protocol",
+			Description: "MCP Streamable HTTP endpoint implementing tools " +
+				"(hash_url, hash_urls, hash_body, hash_base64, discover_favicons, format_query), " +
+				"resources (engine syntax, fingerprints, recent results, iconhash://hash/{value}) and prompts. " +
+				"POST sends JSON-RPC 2.0 messages; requests are answered with JSON, or with an SSE stream carrying " +
+				"progress notifications when the client accepts text/event-stream, and notifications with 202 Accepted. " +
+				"An initialize request starts a session whose ID is returned in the Mcp-Session-Id header. " +
+				"GET opens the SSE stream of a session and DELETE terminates it.",
+			Tag:    "mcp",
+			Errors: true,
+			Scope:  ScopeMCP,
+			Parameters: []Parameter{
+				{
+					Name:        mcpSessionHeader,
+					In:          "header",
+					Description: "Session ID returned by initialize; required for GET and DELETE",
+					Schema:      &Schema{Type: "string"},
+				},
+			},
+			RequestBody: &RequestBody{
+				Required: true,
+				Content:  jsonContent(ref("MCPRequest")),
+			},
+			Responses: map[string]Response{
+				"200": {Description: "MCP response, or an SSE stream of JSON-RPC messages", Content: map[string]MediaType{
+					"application/json":  {Schema: ref("MCPResponse")},
+					"text/event-stream": {Schema: &Schema{Type: "string"}},
+				}},
+				"202": {Description: "Notification accepted"},
+				"204": {Description: "Session terminated"},
+			},
+			handler: (*Server).handleMCP,
+		},
+		{
+			Name:    "openapi",
+			Path:    "/openapi.json",
+			Methods: []string{http.MethodGet},
+			Summary: "OpenAPI document",
+			Tag:     "meta",
+			Public:  true,
+			Responses: map[string]Response{"200": {
+				Description: "OpenAPI 3 document describing this API",
+				Content:     jsonContent(&Schema{Type: "object"}),
+			}},
+			handler: (*Server).handleOpenAPI,
+		},
+		{
+			Name:    "docs",
+			Path:    "/docs",
+			Methods: []string{http.MethodGet},
+			Summary: "API explorer",
+			Tag:     "meta",
+			Public:  true,
+			Responses: map[string]Response{"200": {
+				Description: "Interactive HTML explorer for the OpenAPI document",
+				Content:     map[string]MediaType{"text/html": {Schema: &Schema{Type: "string"}}},
+			}},
+			handler: (*Server).handleDocs,
+		},
+		{
+			Name:    "metrics",
+			Path:    "/metrics",
+			Methods: []string{http.MethodGet},
+			Summary: "Prometheus metrics",
+			Description: "Request, fetch and cache metrics in the Prometheus text format. " +
+				"Served only when metrics are enabled; protected by the metrics token rather than the API token.",
+			Tag:    "meta",
+			Public: true,
+			Responses: map[string]Response{"200": {
+				Description: "Metrics in the Prometheus text exposition format",
+				Content:     map[string]MediaType{"text/plain": {Schema: &Schema{Type: "string"}}},
+			}},
+			handler: (*Server).handleMetrics,
+			enabled: func(c *Config) bool { return c.EnableMetrics && c.MetricsAddr == "" },
+		},
+	}
+}
+
+// routeMux is a ServeMux that remembers registered patterns, so that tests can
+// check every route against the OpenAPI document
+type routeMux struct {
+	*http.ServeMux
+	patterns []string
+}
+
+// Handle registers the handler for the given pattern
+func (m *routeMux) Handle(pattern string, handler http.Handler) {
+	m.patterns = append(m.patterns, pattern)
+	m.ServeMux.Handle(pattern, handler)
+}
+
+// HandleFunc registers the handler function for the given pattern
+func (m *routeMux) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	m.Handle(pattern, http.HandlerFunc(handler))
+}
+
+// newMux creates the router with every route from the route table
+func (s *Server) newMux() *routeMux {
+	mux := &routeMux{ServeMux: http.NewServeMux()}
+
+	for _, route := range EnabledRoutes(s.config) {
+		handler := route.bind(s)
+		if route.Scope != "" {
+			handler = s.requireScope(route.Scope, handler)
+		}
+		if !route.Public {
+			handler = s.rateLimit(handler)
+		}
+		mux.HandleFunc(route.Path, s.metrics.instrument(route.Path, handler))
+		if route.Legacy != "" {
+			mux.HandleFunc(route.Legacy, s.metrics.instrument(route.Legacy, handler))
+		}
+	}
+
+	return mux
+}
+
+// Enabled reports whether the route is served with the given config.
+// Optional routes, such as /v1/hash/path and /metrics, depend on it.
+func (route Route) Enabled(config *Config) bool {
+	return route.enabled == nil || route.enabled(config)
+}
+
+// EnabledRoutes returns the routes served with the given config
+func EnabledRoutes(config *Config) []Route {
+	var routes []Route
+	for _, route := range Routes() {
+		if route.Enabled(config) {
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
+// bind returns the route handler bound to a server
+func (route Route) bind(s *Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		route.handler(s, w, r)
+	}
+}
+
+// isPublicPath reports whether a request path belongs to a public route
+func isPublicPath(path string) bool {
+	for _, route := range Routes() {
+		if route.Public && (path == route.Path || path == route.Legacy) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetServerInfo returns formatted information about the API server and the
+// endpoints it serves with the given config
+func GetServerInfo(config *Config) string {
+	addr := config.Host
+	if addr == "0.0.0.0" {
+		addr = "localhost"
+	}
+	baseURL := fmt.Sprintf("http://%s:%d", addr, config.Port)
+	authEnabled := config.AuthToken != "" || len(config.Keys) > 0
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\nAPI Server running at %s\n\nEndpoints:\n", baseURL)
+	for _, route := range EnabledRoutes(config) {
+		fmt.Fprintf(&b, "  %-36s - %s (%s)\n", baseURL+route.Path, route.Summary, strings.Join(route.Methods, "/"))
+	}
+
+	b.WriteString(`
+The unversioned routes (/health, /hash/url, ...) remain available as aliases.
+
+Parameters (query string, form value or JSON body):
+  format=plain|fofa|shodan   - Output format (default: fofa)
+  uint32=true|false          - Use uint32 format (default: false)
+  strict=true|false          - Reject fetched content that is not an image
+  hash_mode=decoded|raw|both - Bytes of a fetched favicon to hash
+`)
+
+	if authEnabled {
+		b.WriteString(`
+Authentication:
+  Add token in "Authorization: Bearer <token>" header
+  (or "?token=<token>" in the URL if query tokens are allowed)
+`)
+	}
+
+	fmt.Fprintf(&b, `
+Example curl commands:
+  curl -X GET "%[1]s/v1/hash/url?url=https://example.com/favicon.ico"
+  curl -X POST -H "Content-Type: application/json" -d '{"url":"https://example.com/favicon.ico","format":"shodan"}' %[1]s/v1/hash/url
+  curl -X POST -F "file=@favicon.ico" %[1]s/v1/hash/file
+  curl -X POST -d "data=$(base64 -i favicon.ico)" %[1]s/v1/hash/base64
+`, baseURL)
+
+	return b.String()
+}
@@ -1,13 +1,20 @@
 package api
 
 import (
+	"crypto/tls"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"io"
+	"io/fs"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/cyberspacesec/go-iconhash/pkg/cache"
 	"github.com/cyberspacesec/go-iconhash/pkg/hasher"
+	"github.com/cyberspacesec/go-iconhash/pkg/logging"
 	"github.com/cyberspacesec/go-iconhash/pkg/mcp"
 	"github.com/cyberspacesec/go-iconhash/pkg/util"
 )
@@ -16,21 +23,109 @@ import (
 type Server struct {
 	config     *Config
 	iconHasher *hasher.IconHasher
-	logger     *util.Logger
+	logger     *slog.Logger
 	mcpHandler *mcp.Handler
-	debug      bool
+	metrics    *serverMetrics
+	keys       *KeyStore
+
+	clientLimiter *rateLimiter
+	quota         *quotaCounter
+	mcpSessions   *mcp.SessionStore
 }
 
 // Config holds the server configuration
 type Config struct {
-	Host               string
-	Port               int
-	ReadTimeout        time.Duration
-	WriteTimeout       time.Duration
-	AuthToken          string
+	Host         string
+	Port         int
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	// AuthToken is a single token granted every scope
+	AuthToken string
+	// Keys are named API keys with scopes, usually loaded with LoadKeyFile
+	Keys []APIKey
+	// AllowQueryToken accepts the token in the ?token= query parameter, which
+	// may end up in access logs
+	AllowQueryToken bool
+	// Logger receives the access log and debug logs. When nil, the default
+	// slog logger is used, or a stderr logger at debug level if EnableDebug
+	// is set.
+	Logger             *slog.Logger
 	EnableDebug        bool
 	InsecureSkipVerify bool
 	RequestTimeout     time.Duration
+
+	// EnableMetrics serves Prometheus metrics at /metrics
+	EnableMetrics bool
+	// MetricsAddr serves metrics on a separate listener instead of the API address
+	MetricsAddr string
+	// MetricsToken is the bearer token required by /metrics, independent of AuthToken
+	MetricsToken string
+
+	// RateLimit is the sustained number of requests per second allowed per
+	// client (API key, or source IP for anonymous requests); 0 disables it
+	RateLimit float64
+	// RateBurst is the number of requests a client may make in a burst
+	RateBurst int
+	// DailyQuota is the number of requests allowed per client and UTC day; 0 disables it
+	DailyQuota int
+	// HostRateLimit is the number of outbound fetches per second allowed per
+	// destination host, so the server cannot be used to flood a site; 0 disables it
+	HostRateLimit float64
+	// HostBurst is the number of fetches from one host allowed in a burst
+	HostBurst int
+	// TrustProxyHeaders takes the client IP from the last X-Forwarded-For
+	// entry or from X-Real-IP. Enable it only behind a single reverse proxy
+	// that sets these headers.
+	TrustProxyHeaders bool
+
+	// FileSandbox enables /v1/hash/path and the MCP hash_file tool for the
+	// files it allows. Nil, the default, disables access to server files.
+	FileSandbox *hasher.FileSandbox
+	// Proxy chooses the proxy of outbound fetches. Nil uses the proxy
+	// environment variables.
+	Proxy *hasher.ProxySelector
+	// Retry decides whether failed fetches are retried. Nil fetches once.
+	Retry *hasher.RetryPolicy
+	// Cache serves and revalidates fetched favicons; nil disables it.
+	// Fetches with headers, cookies or credentials, the server's or a
+	// client's, bypass it.
+	Cache *cache.Cache
+	// RefreshCache fetches every URL again instead of serving cached entries
+	RefreshCache bool
+	// Strict rejects fetched content that is not an image for every
+	// request; clients may also ask for it with the strict parameter
+	Strict bool
+	// HashMode is the default hash mode of fetched favicons, overridden
+	// by the hash_mode parameter, and AcceptEncoding the Accept-Encoding
+	// header of fetches
+	HashMode       string
+	AcceptEncoding string
+	// Redirect decides which redirects fetches follow. Nil follows up to 10
+	// redirects to any host.
+	Redirect *hasher.RedirectPolicy
+	// Resolve, ConnectTo and DNSServer override the addresses fetches
+	// connect to, for every request
+	Resolve   []hasher.ResolveRule
+	ConnectTo []hasher.ConnectToRule
+	DNSServer string
+	// Headers, Cookies, Auth and ClientCert are sent with the outbound
+	// fetches of the hosts matching CredentialHosts, patterns written as
+	// for hasher.HashOptions.CredentialHosts. Without patterns they are
+	// not sent at all, since clients choose the hosts fetched.
+	Headers         http.Header
+	Cookies         http.CookieJar
+	Auth            *hasher.Credentials
+	ClientCert      *tls.Certificate
+	CredentialHosts []string
+
+	// AllowedRequestHeaders are the headers clients may add to fetches in
+	// the "headers" field of a JSON body; "*" allows any header. Hop-by-hop
+	// and framing headers are always refused.
+	AllowedRequestHeaders []string
+	// AllowRequestCookies accepts the "cookie" field of a JSON body
+	AllowRequestCookies bool
+	// AllowRequestAuth accepts the "auth" field of a JSON body
+	AllowRequestAuth bool
 }
 
 // DefaultConfig returns a default server configuration
@@ -44,6 +139,10 @@ func DefaultConfig() *Config {
 		EnableDebug:        false,
 		InsecureSkipVerify: true,
 		RequestTimeout:     10 * time.Second,
+		RateLimit:          10,
+		RateBurst:          20,
+		HostRateLimit:      2,
+		HostBurst:          5,
 	}
 }
 
@@ -59,99 +158,119 @@ func NewServer(config *Config) *Server {
 		RequestTimeout:     config.RequestTimeout,
 		InsecureSkipVerify: config.InsecureSkipVerify,
 		UserAgent:          "IconHash API Server",
+		Proxy:              config.Proxy,
+		Retry:              config.Retry,
+		Cache:              config.Cache,
+		RefreshCache:       config.RefreshCache,
+		Strict:             config.Strict,
+		HashMode:           config.HashMode,
+		AcceptEncoding:     config.AcceptEncoding,
+		Redirect:           config.Redirect,
+		Resolve:            config.Resolve,
+		ConnectTo:          config.ConnectTo,
+		DNSServer:          config.DNSServer,
+	}
+	if len(config.CredentialHosts) > 0 {
+		options.Headers, options.Cookies = config.Headers, config.Cookies
+		options.Auth, options.ClientCert = config.Auth, config.ClientCert
+		options.CredentialHosts = config.CredentialHosts
 	}
 
-	// Create the server
-	logger := util.NewLogger(config.EnableDebug)
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+		if config.EnableDebug {
+			logger, _, _ = logging.New(&logging.Options{Level: "debug"})
+		}
+	}
+	logger = logging.WithRequestIDs(logger)
+
+	// Record outbound fetches in the server metrics
+	m := newServerMetrics()
+	options.Observer = m
+
+	// Throttle fetches per destination host
+	if hostLimiter := newRateLimiter(config.HostRateLimit, config.HostBurst); hostLimiter != nil {
+		options.HostLimiter = hostLimiter
+	}
 
 	// Create standard icon hasher
 	h := hasher.New(options)
 
+	mcpHandler := mcp.NewHandlerWithHasher(h, logger)
+	if config.FileSandbox != nil {
+		mcpHandler.SetFileSandbox(config.FileSandbox)
+	}
+
 	return &Server{
 		config:     config,
 		iconHasher: h,
 		logger:     logger,
-		mcpHandler: mcp.NewHandler(config.EnableDebug),
-		debug:      config.EnableDebug,
+		mcpHandler: mcpHandler,
+		metrics:    m,
+		keys:       newKeyStore(config),
+
+		clientLimiter: newRateLimiter(config.RateLimit, config.RateBurst),
+		quota:         newQuotaCounter(config.DailyQuota),
+		mcpSessions:   mcp.NewSessionStore(mcpSessionIdleTimeout, mcpMaxSessions),
 	}
 }
 
-// Start starts the HTTP server
-func (s *Server) Start() error {
-	// Create router
-	mux := http.NewServeMux()
-
-	// Setup routes
-	mux.HandleFunc("/health", s.handleHealth)
-	mux.HandleFunc("/hash/url", s.handleHashURL)
-	mux.HandleFunc("/hash/file", s.handleHashFile)
-	mux.HandleFunc("/hash/base64", s.handleHashBase64)
-	mux.HandleFunc("/mcp", s.handleMCP)
+// Handler returns the HTTP handler serving every API route
+func (s *Server) Handler() http.Handler {
+	var handler http.Handler = s.newMux()
 
-	// Wrap with auth middleware if token is set
-	var handler http.Handler = mux
-	if s.config.AuthToken != "" {
-		handler = s.authMiddleware(mux)
+	// Wrap with auth middleware if keys are configured
+	if s.authEnabled() {
+		handler = s.authMiddleware(handler)
 	}
 
+	return requestIDMiddleware(s.accessLogMiddleware(handler))
+}
+
+// Start starts the HTTP server
+func (s *Server) Start() error {
 	// Create server
 	addr := s.config.Host + ":" + strconv.Itoa(s.config.Port)
 	server := &http.Server{
 		Addr:         addr,
-		Handler:      handler,
+		Handler:      s.Handler(),
 		ReadTimeout:  s.config.ReadTimeout,
 		WriteTimeout: s.config.WriteTimeout,
 	}
 
-	// Log startup
-	if s.debug {
-		s.logger.Debugf("Starting server on %s", addr)
-		s.logger.Debugf("Auth token: %v", s.config.AuthToken != "")
-		s.logger.Debugf("Debug enabled: %v", s.config.EnableDebug)
+	s.logger.Info("Starting server", "addr", addr, "api_keys", s.keys.Len())
+
+	// Serve metrics on their own listener if requested
+	if s.config.MetricsAddr != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", s.metrics.handler(s.config.MetricsToken))
+		metricsServer := &http.Server{
+			Addr:         s.config.MetricsAddr,
+			Handler:      metricsMux,
+			ReadTimeout:  s.config.ReadTimeout,
+			WriteTimeout: s.config.WriteTimeout,
+		}
+
+		errChan := make(chan error, 2)
+		go func() { errChan <- metricsServer.ListenAndServe() }()
+		go func() { errChan <- server.ListenAndServe() }()
+		return <-errChan
 	}
 
 	// Start server
 	return server.ListenAndServe()
 }
 
-// authMiddleware adds authentication to routes
-func (s *Server) authMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Skip auth for health endpoint
-		if r.URL.Path == "/health" {
-			next.ServeHTTP(w, r)
-			return
-		}
-
-		// Check for token in header
-		authHeader := r.Header.Get("Authorization")
-		if authHeader != "" {
-			// Check if it's a Bearer token
-			if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
-				token := authHeader[7:]
-				if token == s.config.AuthToken {
-					next.ServeHTTP(w, r)
-					return
-				}
-			}
-		}
-
-		// Check for token in query parameter
-		token := r.URL.Query().Get("token")
-		if token == s.config.AuthToken {
-			next.ServeHTTP(w, r)
-			return
-		}
-
-		// Unauthorized
-		sendErrorResponse(w, "Unauthorized: Invalid or missing authentication token", http.StatusUnauthorized)
-	})
+// handleMetrics serves Prometheus metrics on the API listener
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	s.metrics.handler(s.config.MetricsToken)(w, r)
 }
 
 // handleHealth handles the health check endpoint
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		sendErrorResponse(w, "Method not allowed", http.StatusMethodNotAllowed)
+		sendErrorResponse(w, r, errMethodNotAllowed(r.Method, http.MethodGet))
 		return
 	}
 
@@ -164,162 +283,270 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// handleMCP handles the Model Context Protocol endpoint
-func (s *Server) handleMCP(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		sendErrorResponse(w, "Method not allowed", http.StatusMethodNotAllowed)
+// HashResponse is the response format for hash endpoints
+type HashResponse struct {
+	Hash      string `json:"hash"`
+	Format    string `json:"format,omitempty"`
+	Formatted string `json:"formatted,omitempty"`
+	Error     string `json:"error,omitempty"`
+	// Attempts lists every fetch attempt when the fetch had to be retried
+	Attempts []hasher.Attempt `json:"attempts,omitempty"`
+	// Cache is hit, revalidated or miss when the server has a fetch cache
+	Cache string `json:"cache,omitempty"`
+	// Type is the detected content type of a fetched favicon, and Warning
+	// explains why it is probably not a favicon
+	Type    string `json:"type,omitempty"`
+	Warning string `json:"warning,omitempty"`
+	// ContentEncoding is the Content-Encoding the favicon was sent with, and
+	// RawHash the hash of the transferred bytes in the both hash mode
+	ContentEncoding string `json:"content_encoding,omitempty"`
+	RawHash         string `json:"raw_hash,omitempty"`
+	// FinalURL and Redirects report where a fetch was redirected to
+	FinalURL  string            `json:"final_url,omitempty"`
+	Redirects []hasher.Redirect `json:"redirects,omitempty"`
+	// StatusCode, Length, Title and TitleHash describe the page hashed by
+	// the body endpoint
+	StatusCode int    `json:"status_code,omitempty"`
+	Length     int    `json:"length,omitempty"`
+	Title      string `json:"title,omitempty"`
+	TitleHash  string `json:"title_hash,omitempty"`
+}
+
+// handleHashURL handles the hash from URL endpoint
+func (s *Server) handleHashURL(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		sendErrorResponse(w, r, errMethodNotAllowed(r.Method, http.MethodGet, http.MethodPost))
 		return
 	}
 
-	// Read request body
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		sendErrorResponse(w, "Error reading request body", http.StatusBadRequest)
+	req, apiErr := decodeHashRequest(w, r)
+	if apiErr != nil {
+		sendErrorResponse(w, r, apiErr)
 		return
 	}
 
-	// Parse MCP request
-	var req mcp.Request
-	if err := json.Unmarshal(body, &req); err != nil {
-		sendErrorResponse(w, "Invalid MCP request: "+err.Error(), http.StatusBadRequest)
+	// Validate URL
+	if req.URL == "" {
+		sendErrorResponse(w, r, errMissingParameter("url"))
 		return
 	}
 
-	// Process the request
-	resp, err := s.mcpHandler.Process(&req)
+	format := parseFormatParam(req.Format)
+
+	s.logger.DebugContext(r.Context(), "URL hash request", "url", logging.RedactURL(req.URL),
+		"format", getFormatName(format), "uint32", req.Uint32)
+
+	// Calculate hash
+	h, apiErr := s.hasherFor(req)
+	if apiErr != nil {
+		sendErrorResponse(w, r, apiErr)
+		return
+	}
+	result, err := h.Fetch(r.Context(), req.URL)
 	if err != nil {
-		sendErrorResponse(w, "Error processing MCP request: "+err.Error(), http.StatusInternalServerError)
+		s.sendFetchError(w, r, req.URL, err)
 		return
 	}
+	if len(result.Attempts) > 1 {
+		s.logger.InfoContext(r.Context(), "Fetch succeeded after retries", "url", logging.RedactURL(req.URL),
+			"attempts", len(result.Attempts))
+	}
 
-	// Serialize response
-	respData, err := json.Marshal(resp)
+	hash, rawHash, err := h.HashFetched(result)
 	if err != nil {
-		sendErrorResponse(w, "Error serializing response", http.StatusInternalServerError)
+		sendErrorResponse(w, r, newAPIError(http.StatusInternalServerError, CodeHashFailed, "Error calculating hash: "+err.Error()))
 		return
 	}
 
-	// Send response
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(respData)
+	// Format hash based on requested format, reporting retries for auditing
+	resp := HashResponse{Hash: hash, Format: getFormatName(format), Formatted: util.FormatHash(hash, format)}
+	if len(result.Attempts) > 1 {
+		resp.Attempts = result.Attempts
+	}
+	resp.Cache, resp.Type, resp.Warning = result.Cache, result.Type, result.Warning
+	resp.ContentEncoding, resp.RawHash = result.ContentEncoding, rawHash
+	if len(result.Redirects) > 0 {
+		resp.FinalURL, resp.Redirects = result.FinalURL, result.Redirects
+	}
+	writeHashResponse(w, resp)
 }
 
-// HashResponse is the response format for hash endpoints
-type HashResponse struct {
-	Hash      string `json:"hash"`
-	Format    string `json:"format,omitempty"`
-	Formatted string `json:"formatted,omitempty"`
-	Error     string `json:"error,omitempty"`
-}
+// sendFetchError reports a failed fetch of url, with the host limit, retries,
+// detected type or redirects that caused it
+func (s *Server) sendFetchError(w http.ResponseWriter, r *http.Request, url string, err error) {
+	var limitErr *hasher.HostLimitError
+	if errors.As(err, &limitErr) {
+		s.metrics.rateLimited.Inc("host")
+		sendRateLimited(w, r, newAPIError(http.StatusTooManyRequests, CodeRateLimited, "Too many requests to "+limitErr.Host).
+			WithDetail("host", limitErr.Host), limitErr.RetryAfter)
+		return
+	}
 
-// handleHashURL handles the hash from URL endpoint
-func (s *Server) handleHashURL(w http.ResponseWriter, r *http.Request) {
-	// Set content type
-	w.Header().Set("Content-Type", "application/json")
+	apiErr := newAPIError(http.StatusBadGateway, CodeFetchFailed, "Error calculating hash: "+err.Error()).
+		WithDetail("url", url)
+	var retryErr *hasher.RetryError
+	if errors.As(err, &retryErr) {
+		apiErr.WithDetail("attempts", retryErr.Attempts)
+	}
+	var typeErr *hasher.ContentTypeError
+	if errors.As(err, &typeErr) {
+		apiErr.WithDetail("type", typeErr.Type)
+	}
+	var redirectErr *hasher.RedirectError
+	if errors.As(err, &redirectErr) {
+		apiErr.WithDetail("redirects", redirectErr.Redirects).WithDetail("location", redirectErr.Location)
+	}
+	sendErrorResponse(w, r, apiErr)
+}
 
-	// Get URL from query or form
-	var urlStr string
-	if r.Method == http.MethodGet {
-		urlStr = r.URL.Query().Get("url")
-	} else if r.Method == http.MethodPost {
-		if err := r.ParseForm(); err != nil {
-			sendErrorResponse(w, "Error parsing form", http.StatusBadRequest)
-			return
-		}
-		urlStr = r.FormValue("url")
-	} else {
-		sendErrorResponse(w, "Method not allowed", http.StatusMethodNotAllowed)
+// handleHashBody handles the HTML body hash endpoint
+func (s *Server) handleHashBody(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		sendErrorResponse(w, r, errMethodNotAllowed(r.Method, http.MethodGet, http.MethodPost))
 		return
 	}
 
-	// Validate URL
-	if urlStr == "" {
-		sendErrorResponse(w, "URL parameter is required", http.StatusBadRequest)
+	req, apiErr := decodeHashRequest(w, r)
+	if apiErr != nil {
+		sendErrorResponse(w, r, apiErr)
+		return
+	}
+	if req.URL == "" {
+		sendErrorResponse(w, r, errMissingParameter("url"))
 		return
 	}
 
-	// Check for format parameter
-	formatStr := r.URL.Query().Get("format")
-	format := parseFormatParam(formatStr)
+	// Fofa does not index body hashes, so queries default to Shodan
+	if req.Format == "" {
+		req.Format = "shodan"
+	}
+	format := parseFormatParam(req.Format)
 
-	// Update hasher options if uint32 is specified
-	uint32Param := r.URL.Query().Get("uint32")
-	useUint32 := uint32Param == "true" || uint32Param == "1"
-	s.updateHasherOptions(useUint32)
+	s.logger.DebugContext(r.Context(), "Body hash request", "url", logging.RedactURL(req.URL),
+		"format", getFormatName(format), "uint32", req.Uint32)
 
-	// Debug output
-	if s.debug {
-		s.logger.Debugf("URL hash request: %s", urlStr)
-		s.logger.Debugf("Format: %s", getFormatName(format))
-		s.logger.Debugf("UseUint32: %v", useUint32)
+	h, apiErr := s.hasherFor(req)
+	if apiErr != nil {
+		sendErrorResponse(w, r, apiErr)
+		return
 	}
-
-	// Calculate hash
-	hash, err := s.iconHasher.HashFromURL(urlStr)
+	result, err := h.HashBody(r.Context(), req.URL)
 	if err != nil {
-		sendErrorResponse(w, "Error calculating hash: "+err.Error(), http.StatusInternalServerError)
+		s.sendFetchError(w, r, req.URL, err)
 		return
 	}
 
-	// Format hash based on requested format
-	formatted := util.FormatHash(hash, format)
-	sendHashResponse(w, hash, getFormatName(format), formatted)
+	resp := HashResponse{
+		Hash:       result.Hash,
+		Format:     getFormatName(format),
+		Formatted:  util.FormatBodyHash(result.Hash, format),
+		Cache:      result.Fetch.Cache,
+		StatusCode: result.StatusCode,
+		Length:     result.Length,
+		Title:      result.Title,
+		TitleHash:  result.TitleHash,
+	}
+	if len(result.Fetch.Attempts) > 1 {
+		resp.Attempts = result.Fetch.Attempts
+	}
+	if len(result.Fetch.Redirects) > 0 {
+		resp.FinalURL, resp.Redirects = result.Fetch.FinalURL, result.Fetch.Redirects
+	}
+	writeHashResponse(w, resp)
 }
 
 // handleHashFile handles the hash from file upload endpoint
 func (s *Server) handleHashFile(w http.ResponseWriter, r *http.Request) {
-	// Set content type
-	w.Header().Set("Content-Type", "application/json")
-
 	// Validate method
 	if r.Method != http.MethodPost {
-		sendErrorResponse(w, "Method not allowed", http.StatusMethodNotAllowed)
+		sendErrorResponse(w, r, errMethodNotAllowed(r.Method, http.MethodPost))
 		return
 	}
 
-	// Parse multipart form
-	err := r.ParseMultipartForm(10 << 20) // Max 10MB
-	if err != nil {
-		sendErrorResponse(w, "Error parsing multipart form", http.StatusBadRequest)
+	req, apiErr := decodeHashRequest(w, r)
+	if apiErr != nil {
+		sendErrorResponse(w, r, apiErr)
 		return
 	}
 
-	// Get file from form
-	file, _, err := r.FormFile("file")
-	if err != nil {
-		sendErrorResponse(w, "Error getting file from form", http.StatusBadRequest)
-		return
+	// Get file content from the multipart form or the JSON body
+	var fileData []byte
+	if req.File != "" {
+		data, err := base64.StdEncoding.DecodeString(req.File)
+		if err != nil {
+			sendErrorResponse(w, r, errInvalidParameter("file", "expected base64 encoded content"))
+			return
+		}
+		fileData = data
+	} else {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			sendErrorResponse(w, r, errMissingParameter("file"))
+			return
+		}
+		defer file.Close()
+
+		fileData, err = io.ReadAll(file)
+		if err != nil {
+			sendErrorResponse(w, r, newAPIError(http.StatusInternalServerError, CodeInternal, "Error reading file"))
+			return
+		}
 	}
-	defer file.Close()
 
-	// Read file
-	fileData, err := io.ReadAll(file)
+	format := parseFormatParam(req.Format)
+
+	s.logger.DebugContext(r.Context(), "File hash request", "bytes", len(fileData),
+		"format", getFormatName(format), "uint32", req.Uint32)
+
+	// Calculate hash
+	h, apiErr := s.hasherFor(req)
+	if apiErr != nil {
+		sendErrorResponse(w, r, apiErr)
+		return
+	}
+	hash, err := h.HashFromBytes(fileData)
 	if err != nil {
-		sendErrorResponse(w, "Error reading file", http.StatusInternalServerError)
+		sendErrorResponse(w, r, newAPIError(http.StatusInternalServerError, CodeHashFailed, "Error calculating hash: "+err.Error()))
 		return
 	}
 
-	// Check for format parameter
-	formatStr := r.URL.Query().Get("format")
-	format := parseFormatParam(formatStr)
+	// Format hash based on requested format
+	formatted := util.FormatHash(hash, format)
+	sendHashResponse(w, hash, getFormatName(format), formatted)
+}
 
-	// Update hasher options if uint32 is specified
-	uint32Param := r.URL.Query().Get("uint32")
-	useUint32 := uint32Param == "true" || uint32Param == "1"
-	s.updateHasherOptions(useUint32)
+// handleHashPath handles the hash from server-side file endpoint
+func (s *Server) handleHashPath(w http.ResponseWriter, r *http.Request) {
+	// Validate method
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		sendErrorResponse(w, r, errMethodNotAllowed(r.Method, http.MethodGet, http.MethodPost))
+		return
+	}
 
-	// Debug output
-	if s.debug {
-		s.logger.Debugf("File hash request: %d bytes", len(fileData))
-		s.logger.Debugf("Format: %s", getFormatName(format))
-		s.logger.Debugf("UseUint32: %v", useUint32)
+	req, apiErr := decodeHashRequest(w, r)
+	if apiErr != nil {
+		sendErrorResponse(w, r, apiErr)
+		return
 	}
+	if req.Path == "" {
+		sendErrorResponse(w, r, errMissingParameter("path"))
+		return
+	}
+
+	format := parseFormatParam(req.Format)
+
+	s.logger.DebugContext(r.Context(), "Path hash request", "path", req.Path,
+		"format", getFormatName(format), "uint32", req.Uint32)
 
 	// Calculate hash
-	hash, err := s.iconHasher.HashFromBytes(fileData)
+	h, apiErr := s.hasherFor(req)
+	if apiErr != nil {
+		sendErrorResponse(w, r, apiErr)
+		return
+	}
+	hash, err := h.HashFromSandboxedFile(s.config.FileSandbox, req.Path)
 	if err != nil {
-		sendErrorResponse(w, "Error calculating hash: "+err.Error(), http.StatusInternalServerError)
+		sendErrorResponse(w, r, pathError(req.Path, err))
 		return
 	}
 
@@ -328,50 +555,58 @@ func (s *Server) handleHashFile(w http.ResponseWriter, r *http.Request) {
 	sendHashResponse(w, hash, getFormatName(format), formatted)
 }
 
+// pathError converts a sandboxed file error into an APIError. Paths outside
+// the roots and missing files get the same response, so that clients cannot
+// probe the file system beyond the roots.
+func pathError(path string, err error) *APIError {
+	switch {
+	case errors.Is(err, hasher.ErrFileTooLarge):
+		return newAPIError(http.StatusRequestEntityTooLarge, CodePayloadTooLarge, "File too large").
+			WithDetail("path", path)
+	case errors.Is(err, hasher.ErrOutsideRoots), errors.Is(err, fs.ErrNotExist), errors.Is(err, fs.ErrPermission):
+		return newAPIError(http.StatusNotFound, CodeNotFound, "File not found in the allowed directories").
+			WithDetail("path", path)
+	case errors.Is(err, hasher.ErrNotRegularFile):
+		return errInvalidParameter("path", "not a regular file").WithDetail("value", path)
+	default:
+		return newAPIError(http.StatusInternalServerError, CodeHashFailed, "Error calculating hash: "+err.Error())
+	}
+}
+
 // handleHashBase64 handles the hash from base64 endpoint
 func (s *Server) handleHashBase64(w http.ResponseWriter, r *http.Request) {
-	// Set content type
-	w.Header().Set("Content-Type", "application/json")
-
 	// Validate method
 	if r.Method != http.MethodPost {
-		sendErrorResponse(w, "Method not allowed", http.StatusMethodNotAllowed)
+		sendErrorResponse(w, r, errMethodNotAllowed(r.Method, http.MethodPost))
 		return
 	}
 
-	// Parse form
-	if err := r.ParseForm(); err != nil {
-		sendErrorResponse(w, "Error parsing form", http.StatusBadRequest)
+	req, apiErr := decodeHashRequest(w, r)
+	if apiErr != nil {
+		sendErrorResponse(w, r, apiErr)
 		return
 	}
 
-	// Get base64 data from form
-	base64Data := r.FormValue("data")
-	if base64Data == "" {
-		sendErrorResponse(w, "Base64 data is required", http.StatusBadRequest)
+	// Validate base64 data
+	if req.Data == "" {
+		sendErrorResponse(w, r, errMissingParameter("data"))
 		return
 	}
 
-	// Check for format parameter
-	formatStr := r.URL.Query().Get("format")
-	format := parseFormatParam(formatStr)
-
-	// Update hasher options if uint32 is specified
-	uint32Param := r.URL.Query().Get("uint32")
-	useUint32 := uint32Param == "true" || uint32Param == "1"
-	s.updateHasherOptions(useUint32)
+	format := parseFormatParam(req.Format)
 
-	// Debug output
-	if s.debug {
-		s.logger.Debugf("Base64 hash request: %d bytes", len(base64Data))
-		s.logger.Debugf("Format: %s", getFormatName(format))
-		s.logger.Debugf("UseUint32: %v", useUint32)
-	}
+	s.logger.DebugContext(r.Context(), "Base64 hash request", "bytes", len(req.Data),
+		"format", getFormatName(format), "uint32", req.Uint32)
 
 	// Calculate hash
-	hash, err := s.iconHasher.HashFromBase64(base64Data)
+	h, apiErr := s.hasherFor(req)
+	if apiErr != nil {
+		sendErrorResponse(w, r, apiErr)
+		return
+	}
+	hash, err := h.HashFromBase64(req.Data)
 	if err != nil {
-		sendErrorResponse(w, "Error calculating hash: "+err.Error(), http.StatusInternalServerError)
+		sendErrorResponse(w, r, newAPIError(http.StatusBadRequest, CodeHashFailed, "Error calculating hash: "+err.Error()))
 		return
 	}
 
@@ -380,36 +615,46 @@ func (s *Server) handleHashBase64(w http.ResponseWriter, r *http.Request) {
 	sendHashResponse(w, hash, getFormatName(format), formatted)
 }
 
-// updateHasherOptions updates the hash options
-func (s *Server) updateHasherOptions(useUint32 bool) {
-	// Apply the standard options
-	options := &hasher.HashOptions{
-		UseUint32:          useUint32,
-		RequestTimeout:     s.config.RequestTimeout,
-		InsecureSkipVerify: s.config.InsecureSkipVerify,
-		UserAgent:          "IconHash API Server",
+// hasherFor returns a hasher configured for the per-request options, or an
+// error if the request asks for fetch options the server does not allow
+func (s *Server) hasherFor(req *HashRequest) (*hasher.IconHasher, *APIError) {
+	headers, apiErr := s.requestHeaders(req)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if req.Auth != nil && !s.config.AllowRequestAuth {
+		return nil, errFetchOptionNotAllowed("auth")
 	}
 
-	// Create new hasher with updated options
-	s.iconHasher = hasher.New(options)
+	return s.iconHasher.WithOptions(func(o *hasher.HashOptions) {
+		o.UseUint32 = req.Uint32
+		o.Strict = o.Strict || req.Strict
+		if req.HashMode != "" {
+			o.HashMode = req.HashMode
+		}
+		// A client's own headers and credentials replace the server's, which
+		// are scoped to the credential hosts while the client's are not
+		if len(headers) > 0 || req.Auth != nil {
+			o.Headers, o.Auth = headers, req.Auth
+			o.Cookies, o.ClientCert, o.CredentialHosts = nil, nil, nil
+		}
+	}), nil
 }
 
 // sendHashResponse sends a hash response
 func sendHashResponse(w http.ResponseWriter, hash, formatName, formatted string) {
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(HashResponse{
+	writeHashResponse(w, HashResponse{
 		Hash:      hash,
 		Format:    formatName,
 		Formatted: formatted,
 	})
 }
 
-// sendErrorResponse sends an error response
-func sendErrorResponse(w http.ResponseWriter, errMessage string, statusCode int) {
-	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(HashResponse{
-		Error: errMessage,
-	})
+// writeHashResponse writes a successful hash response
+func writeHashResponse(w http.ResponseWriter, resp HashResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
 }
 
 // parseFormatParam parses the format parameter
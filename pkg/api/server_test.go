@@ -6,8 +6,12 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/cyberspacesec/go-iconhash/pkg/hasher"
 	"github.com/cyberspacesec/go-iconhash/pkg/util"
 )
 
@@ -47,6 +51,7 @@ func TestAuthMiddleware(t *testing.T) {
 	// Create a server with auth token
 	config := DefaultConfig()
 	config.AuthToken = "test-token"
+	config.AllowQueryToken = true
 	server := NewServer(config)
 
 	// Create a handler that returns success
@@ -184,3 +189,135 @@ func createMultipartRequest(t *testing.T, fieldName, fileName string, fileConten
 // but would require mocking the hasher's functions, which is beyond the scope
 // of this example. In a real implementation, you'd use a mock or a test double
 // for the hasher.
+
+func TestMCPEndpoint(t *testing.T) {
+	handler := NewServer(DefaultConfig()).Handler()
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectContains string
+	}{
+		{"JSON-RPC request", `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`, http.StatusOK, `"hash_url"`},
+		{"JSON-RPC error", `{"jsonrpc":"2.0","id":1,"method":"unknown"}`, http.StatusOK, `"code":-32601`},
+		{"Notification", `{"jsonrpc":"2.0","method":"notifications/initialized"}`, http.StatusAccepted, ""},
+		{"Legacy request", `{"version":"1.0","protocol":"Model Context Protocol","context":{"messages":[{"role":"user","content":"help"}]}}`, http.StatusOK, "IconHash"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/mcp", strings.NewReader(test.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d: %s", test.expectedStatus, w.Code, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), test.expectContains) {
+				t.Errorf("Expected body containing %q, got %s", test.expectContains, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHashPathEndpoint(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "favicon.ico"), []byte("icon"), 0o644)
+	os.WriteFile(filepath.Join(root, "large.ico"), make([]byte, 64), 0o644)
+
+	// The endpoint is disabled by default
+	req := httptest.NewRequest(http.MethodGet, "/v1/hash/path?path=favicon.ico", nil)
+	w := httptest.NewRecorder()
+	NewServer(DefaultConfig()).Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected 404 without file roots, got %d", w.Code)
+	}
+
+	sandbox, err := hasher.NewFileSandbox([]string{root}, 32)
+	if err != nil {
+		t.Fatalf("NewFileSandbox() returned error: %v", err)
+	}
+	config := DefaultConfig()
+	config.FileSandbox = sandbox
+	handler := NewServer(config).Handler()
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+	}{
+		{"Relative path", "favicon.ico", http.StatusOK},
+		{"Absolute path", filepath.Join(root, "favicon.ico"), http.StatusOK},
+		{"Escaping path", "../favicon.ico", http.StatusNotFound},
+		{"Outside roots", "/etc/passwd", http.StatusNotFound},
+		{"Missing file", "missing.ico", http.StatusNotFound},
+		{"Too large", "large.ico", http.StatusRequestEntityTooLarge},
+		{"Missing path", "", http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body, _ := json.Marshal(map[string]string{"path": test.path})
+			req := httptest.NewRequest(http.MethodPost, "/v1/hash/path", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatus {
+				t.Errorf("Expected status code %d, got %d: %s", test.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestMCPFileAccessScope(t *testing.T) {
+	root := t.TempDir()
+	sandbox, _ := hasher.NewFileSandbox([]string{root}, 0)
+
+	mcpSecret, mcpKey, _ := GenerateKey("assistant", []string{ScopeMCP}, nil)
+	pathSecret, pathKey, _ := GenerateKey("local", []string{ScopeMCP, ScopeHashPath}, nil)
+
+	config := DefaultConfig()
+	config.Keys = []APIKey{mcpKey, pathKey}
+	config.FileSandbox = sandbox
+	handler := NewServer(config).Handler()
+
+	list := `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`
+	if w := mcpPost(handler, list, map[string]string{"Authorization": "Bearer " + mcpSecret}); strings.Contains(w.Body.String(), "hash_file") {
+		t.Error("Expected hash_file to be hidden from keys without the hash:path scope")
+	}
+	if w := mcpPost(handler, list, map[string]string{"Authorization": "Bearer " + pathSecret}); !strings.Contains(w.Body.String(), "hash_file") {
+		t.Errorf("Expected hash_file for keys with the hash:path scope, got %s", w.Body.String())
+	}
+}
+
+func TestMCPURLAccessScope(t *testing.T) {
+	var fetched bool
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched = true
+		w.Write([]byte{0, 0, 1, 0})
+	}))
+	defer upstream.Close()
+
+	mcpSecret, mcpKey, _ := GenerateKey("assistant", []string{ScopeMCP}, nil)
+	urlSecret, urlKey, _ := GenerateKey("fetcher", []string{ScopeMCP, ScopeHashURL}, nil)
+
+	config := DefaultConfig()
+	config.Keys = []APIKey{mcpKey, urlKey}
+	config.RateLimit, config.HostRateLimit = 0, 0
+	handler := NewServer(config).Handler()
+
+	list := `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`
+	if w := mcpPost(handler, list, map[string]string{"Authorization": "Bearer " + mcpSecret}); strings.Contains(w.Body.String(), "hash_url") {
+		t.Error("Expected hash_url to be hidden from keys without the hash:url scope")
+	}
+	hashURL := `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"hash_url","arguments":{"url":"` + upstream.URL + `"}}}`
+	if w := mcpPost(handler, hashURL, map[string]string{"Authorization": "Bearer " + mcpSecret}); !strings.Contains(w.Body.String(), "Unknown tool") || fetched {
+		t.Errorf("Expected hash_url to be refused without the hash:url scope, got %s", w.Body.String())
+	}
+	if w := mcpPost(handler, hashURL, map[string]string{"Authorization": "Bearer " + urlSecret}); !fetched {
+		t.Errorf("Expected hash_url for keys with the hash:url scope, got %s", w.Body.String())
+	}
+}
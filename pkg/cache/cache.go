@@ -0,0 +1,287 @@
+// Package cache stores fetched favicons on disk so that later runs can reuse
+// them, revalidating stale entries with conditional requests.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default cache settings
+const (
+	DefaultTTL     = time.Hour
+	DefaultMaxSize = 100 << 20
+)
+
+// Entry is a cached response body with the validators needed to revalidate it
+type Entry struct {
+	URL         string `json:"url"`
+	Body        []byte `json:"body"`
+	ContentType string `json:"content_type,omitempty"`
+	// Raw is the body as transferred, kept only if it was Content-Encoded
+	Raw             []byte `json:"raw,omitempty"`
+	ContentEncoding string `json:"content_encoding,omitempty"`
+	ETag            string `json:"etag,omitempty"`
+	LastModified    string `json:"last_modified,omitempty"`
+	// FinalURL is the URL the body was fetched from after Redirects
+	FinalURL  string     `json:"final_url,omitempty"`
+	Redirects []Redirect `json:"redirects,omitempty"`
+	// Hash is the int32 MMH3 hash of the body
+	Hash string `json:"hash,omitempty"`
+	// StoredAt is when the body was last fetched or revalidated
+	StoredAt time.Time `json:"stored_at"`
+}
+
+// Redirect is a redirect followed to fetch an entry
+type Redirect struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code"`
+}
+
+// CanRevalidate reports whether the entry has a validator for a conditional request
+func (e *Entry) CanRevalidate() bool {
+	return e.ETag != "" || e.LastModified != ""
+}
+
+// Options configures a Cache
+type Options struct {
+	// TTL is how long entries are served without revalidation
+	TTL time.Duration
+	// MaxSize is the size in bytes the cache directory is trimmed to,
+	// evicting the least recently used entries first
+	MaxSize int64
+}
+
+// DefaultOptions returns the default cache options
+func DefaultOptions() *Options {
+	return &Options{
+		TTL:     DefaultTTL,
+		MaxSize: DefaultMaxSize,
+	}
+}
+
+// Cache is an on-disk cache of fetched URLs, safe for concurrent use by
+// several goroutines and processes. Each entry is a JSON file named after
+// the SHA-256 of its URL; file modification times record when an entry was
+// last used.
+type Cache struct {
+	dir     string
+	ttl     time.Duration
+	maxSize int64
+
+	mu sync.Mutex
+}
+
+// DefaultDir returns the iconhash directory in the user cache directory
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "iconhash"), nil
+}
+
+// Open opens the cache in dir, creating the directory if needed. Nil options
+// use DefaultOptions.
+func Open(dir string, options *Options) (*Cache, error) {
+	if options == nil {
+		options = DefaultOptions()
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return &Cache{dir: dir, ttl: options.TTL, maxSize: options.MaxSize}, nil
+}
+
+// Dir returns the cache directory
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// TTL returns how long entries are served without revalidation
+func (c *Cache) TTL() time.Duration {
+	return c.ttl
+}
+
+// Fresh reports whether an entry may be served without revalidation
+func (c *Cache) Fresh(e *Entry) bool {
+	return time.Since(e.StoredAt) < c.ttl
+}
+
+// path returns the file of a URL's entry
+func (c *Cache) path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// Get returns the entry of a URL, or nil if there is none. Stale entries are
+// returned only if they can be revalidated; others are evicted.
+func (c *Cache) Get(url string) *Entry {
+	path := c.path(url)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.URL != url {
+		return nil
+	}
+	if !c.Fresh(&entry) && !entry.CanRevalidate() {
+		os.Remove(path)
+		return nil
+	}
+
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return &entry
+}
+
+// Put stores an entry, replacing any previous entry of its URL, and then
+// evicts entries beyond the size limit
+func (c *Cache) Put(entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if c.maxSize > 0 && int64(len(data)) > c.maxSize {
+		return nil
+	}
+
+	// Write to a temporary file first so that readers never see a partial entry
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	tmp.Close()
+	if err := os.Rename(tmp.Name(), c.path(entry.URL)); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+
+	return c.evict()
+}
+
+// Refresh marks an entry as fresh again after a successful revalidation
+func (c *Cache) Refresh(entry *Entry, etag, lastModified string) error {
+	entry.StoredAt = time.Now()
+	if etag != "" {
+		entry.ETag = etag
+	}
+	if lastModified != "" {
+		entry.LastModified = lastModified
+	}
+	return c.Put(entry)
+}
+
+// Delete removes the entry of a URL
+func (c *Cache) Delete(url string) error {
+	err := os.Remove(c.path(url))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Stats returns the number of entries and their total size in bytes
+func (c *Cache) Stats() (int, int64, error) {
+	files, err := c.files()
+	if err != nil {
+		return 0, 0, err
+	}
+	var size int64
+	for _, f := range files {
+		size += f.size
+	}
+	return len(files), size, nil
+}
+
+// Clear removes every entry
+func (c *Cache) Clear() error {
+	files, err := c.files()
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := os.Remove(f.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// cacheFile is an entry file found in the cache directory
+type cacheFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// files lists the entry files of the cache
+func (c *Cache) files() ([]cacheFile, error) {
+	dirEntries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]cacheFile, 0, len(dirEntries))
+	for _, d := range dirEntries {
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".json") {
+			continue
+		}
+		info, err := d.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, cacheFile{path: filepath.Join(c.dir, d.Name()), size: info.Size(), modTime: info.ModTime()})
+	}
+	return files, nil
+}
+
+// evict removes the least recently used entries until the cache fits in
+// its size limit
+func (c *Cache) evict() error {
+	if c.maxSize <= 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	files, err := c.files()
+	if err != nil {
+		return err
+	}
+	var size int64
+	for _, f := range files {
+		size += f.size
+	}
+	if size <= c.maxSize {
+		return nil
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, f := range files {
+		if size <= c.maxSize {
+			break
+		}
+		if err := os.Remove(f.path); err == nil {
+			size -= f.size
+		}
+	}
+	return nil
+}
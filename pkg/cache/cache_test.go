@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPutGet(t *testing.T) {
+	c, err := Open(filepath.Join(t.TempDir(), "iconhash"), nil)
+	if err != nil {
+		t.Fatalf("Open() returned error: %v", err)
+	}
+
+	entry := &Entry{URL: "https://example.com/favicon.ico", Body: []byte("icon"), ETag: `"v1"`, Hash: "123", StoredAt: time.Now()}
+	if err := c.Put(entry); err != nil {
+		t.Fatalf("Put() returned error: %v", err)
+	}
+
+	got := c.Get(entry.URL)
+	if got == nil || !bytes.Equal(got.Body, entry.Body) || got.ETag != entry.ETag || got.Hash != "123" || !c.Fresh(got) {
+		t.Fatalf("Get() = %+v, expected %+v", got, entry)
+	}
+	if c.Get("https://example.com/other.ico") != nil {
+		t.Error("Expected no entry for another URL")
+	}
+
+	// Stale entries are kept only if they can be revalidated
+	entry.StoredAt = time.Now().Add(-2 * DefaultTTL)
+	c.Put(entry)
+	stale := c.Get(entry.URL)
+	if stale == nil || c.Fresh(stale) {
+		t.Fatalf("Expected a stale entry to revalidate, got %+v", stale)
+	}
+	if err := c.Refresh(stale, `"v2"`, ""); err != nil {
+		t.Fatalf("Refresh() returned error: %v", err)
+	}
+	if got := c.Get(entry.URL); got == nil || !c.Fresh(got) || got.ETag != `"v2"` {
+		t.Errorf("Expected Refresh() to make the entry fresh, got %+v", got)
+	}
+
+	entry.ETag = ""
+	entry.StoredAt = time.Now().Add(-2 * DefaultTTL)
+	c.Put(entry)
+	if c.Get(entry.URL) != nil {
+		t.Error("Expected a stale entry without validators to be evicted")
+	}
+	if count, _, _ := c.Stats(); count != 0 {
+		t.Errorf("Expected an empty cache, got %d entries", count)
+	}
+}
+
+func TestEviction(t *testing.T) {
+	dir := t.TempDir()
+	body := bytes.Repeat([]byte("x"), 1000)
+	c, _ := Open(dir, &Options{TTL: time.Hour, MaxSize: 6000})
+
+	urls := []string{"https://a.example/", "https://b.example/", "https://c.example/", "https://d.example/"}
+	for i, url := range urls {
+		c.Put(&Entry{URL: url, Body: body, StoredAt: time.Now()})
+		// Older entries were used longer ago
+		past := time.Now().Add(time.Duration(i-len(urls)) * time.Minute)
+		os.Chtimes(c.path(url), past, past)
+	}
+	// Reading an entry marks it as recently used
+	c.Get(urls[0])
+
+	c.Put(&Entry{URL: "https://e.example/", Body: body, StoredAt: time.Now()})
+
+	count, size, _ := c.Stats()
+	if size > 6000 {
+		t.Errorf("Expected the cache to fit in 6000 bytes, got %d in %d entries", size, count)
+	}
+	if c.Get(urls[0]) == nil || c.Get(urls[1]) != nil {
+		t.Error("Expected the least recently used entry to be evicted first")
+	}
+
+	if err := c.Clear(); err != nil {
+		t.Fatalf("Clear() returned error: %v", err)
+	}
+	if count, _, _ := c.Stats(); count != 0 {
+		t.Errorf("Expected Clear() to remove every entry, got %d", count)
+	}
+}
@@ -0,0 +1,185 @@
+// Package config merges the settings of the CLI and the API server from
+// defaults, a YAML, TOML or JSON config file, a named profile, ICONHASH_*
+// environment variables and command line flags, in that order of precedence.
+package config
+
+import (
+	"time"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/cache"
+	"github.com/cyberspacesec/go-iconhash/pkg/hasher"
+)
+
+// Config holds every setting that can be given in a config file
+type Config struct {
+	// Profile names the profile applied on top of the config file
+	Profile string       `json:"profile" yaml:"profile" toml:"profile"`
+	Log     LogConfig    `json:"log" yaml:"log" toml:"log"`
+	Fetch   FetchConfig  `json:"fetch" yaml:"fetch" toml:"fetch"`
+	Output  OutputConfig `json:"output" yaml:"output" toml:"output"`
+	Server  ServerConfig `json:"server" yaml:"server" toml:"server"`
+	Files   FilesConfig  `json:"files" yaml:"files" toml:"files"`
+	Cache   CacheConfig  `json:"cache" yaml:"cache" toml:"cache"`
+	// Profiles are named partial configs, selected with Profile
+	Profiles map[string]map[string]interface{} `json:"profiles,omitempty" yaml:"profiles,omitempty" toml:"profiles,omitempty"`
+}
+
+// LogConfig configures logging
+type LogConfig struct {
+	// Level is debug, info, warn or error. Empty logs at info for the
+	// server and at warn for the other commands.
+	Level  string `json:"level" yaml:"level" toml:"level"`
+	Format string `json:"format" yaml:"format" toml:"format"`
+	File   string `json:"file" yaml:"file" toml:"file"`
+}
+
+// FetchConfig configures outbound favicon fetches
+type FetchConfig struct {
+	Timeout   Duration `json:"timeout" yaml:"timeout" toml:"timeout"`
+	UserAgent string   `json:"user_agent" yaml:"user_agent" toml:"user_agent"`
+	Insecure  bool     `json:"insecure" yaml:"insecure" toml:"insecure"`
+	// Proxies are proxy URLs used in rotation
+	Proxies []string `json:"proxies" yaml:"proxies" toml:"proxies" secret:"url"`
+	// ProxyRules route hosts to proxies, written as "pattern=proxy"
+	ProxyRules []string `json:"proxy_rules" yaml:"proxy_rules" toml:"proxy_rules" secret:"url"`
+	// Headers are extra request headers, written as "Name: value"
+	Headers []string `json:"headers" yaml:"headers" toml:"headers" secret:"header"`
+	// CookieFile is a cookie jar in the Netscape cookies.txt format
+	CookieFile string `json:"cookie_file" yaml:"cookie_file" toml:"cookie_file"`
+	// BasicAuth is written as "user:password"
+	BasicAuth   string `json:"basic_auth" yaml:"basic_auth" toml:"basic_auth" secret:"true"`
+	BearerToken string `json:"bearer_token" yaml:"bearer_token" toml:"bearer_token" secret:"true"`
+	// ClientCert and ClientKey are PEM files for mutual TLS
+	ClientCert string `json:"client_cert" yaml:"client_cert" toml:"client_cert"`
+	ClientKey  string `json:"client_key" yaml:"client_key" toml:"client_key"`
+	// Retries is the number of times a failed fetch is retried, waiting
+	// RetryBackoff and then exponentially longer, for at most RetryMaxTime
+	Retries      int      `json:"retries" yaml:"retries" toml:"retries"`
+	RetryBackoff Duration `json:"retry_backoff" yaml:"retry_backoff" toml:"retry_backoff"`
+	RetryMaxTime Duration `json:"retry_max_time" yaml:"retry_max_time" toml:"retry_max_time"`
+	// Strict rejects responses that are not images
+	Strict bool `json:"strict" yaml:"strict" toml:"strict"`
+	// AcceptEncoding is the Accept-Encoding header of fetches, and HashMode
+	// selects the decoded body, the raw transferred bytes or both for hashing
+	AcceptEncoding string `json:"accept_encoding" yaml:"accept_encoding" toml:"accept_encoding"`
+	HashMode       string `json:"hash_mode" yaml:"hash_mode" toml:"hash_mode"`
+	// MaxRedirects is the number of redirects followed, 0 following none;
+	// SameHostRedirects refuses redirects to other hosts, and
+	// FollowMetaRefresh follows meta refreshes of discovered pages
+	MaxRedirects      int  `json:"max_redirects" yaml:"max_redirects" toml:"max_redirects"`
+	SameHostRedirects bool `json:"same_host_redirects" yaml:"same_host_redirects" toml:"same_host_redirects"`
+	FollowMetaRefresh bool `json:"follow_meta_refresh" yaml:"follow_meta_refresh" toml:"follow_meta_refresh"`
+	// Resolve pins hosts to addresses, written as "host:port:addr", and
+	// ConnectTo sends connections elsewhere, written as
+	// "host:port:tohost:toport"
+	Resolve   []string `json:"resolve" yaml:"resolve" toml:"resolve"`
+	ConnectTo []string `json:"connect_to" yaml:"connect_to" toml:"connect_to"`
+	// DNSServer resolves fetched hosts instead of the system resolver
+	DNSServer string `json:"dns_server" yaml:"dns_server" toml:"dns_server"`
+}
+
+// OutputConfig configures how hashes are printed
+type OutputConfig struct {
+	Uint32 bool `json:"uint32" yaml:"uint32" toml:"uint32"`
+	Fofa   bool `json:"fofa" yaml:"fofa" toml:"fofa"`
+	Shodan bool `json:"shodan" yaml:"shodan" toml:"shodan"`
+}
+
+// ServerConfig configures the API server
+type ServerConfig struct {
+	Host            string   `json:"host" yaml:"host" toml:"host"`
+	Port            int      `json:"port" yaml:"port" toml:"port"`
+	ReadTimeout     Duration `json:"read_timeout" yaml:"read_timeout" toml:"read_timeout"`
+	WriteTimeout    Duration `json:"write_timeout" yaml:"write_timeout" toml:"write_timeout"`
+	AuthToken       string   `json:"auth_token" yaml:"auth_token" toml:"auth_token" secret:"true"`
+	KeysFile        string   `json:"keys_file" yaml:"keys_file" toml:"keys_file"`
+	AllowQueryToken bool     `json:"allow_query_token" yaml:"allow_query_token" toml:"allow_query_token"`
+	Metrics         bool     `json:"metrics" yaml:"metrics" toml:"metrics"`
+	MetricsAddr     string   `json:"metrics_addr" yaml:"metrics_addr" toml:"metrics_addr"`
+	MetricsToken    string   `json:"metrics_token" yaml:"metrics_token" toml:"metrics_token" secret:"true"`
+	RateLimit       float64  `json:"rate_limit" yaml:"rate_limit" toml:"rate_limit"`
+	RateBurst       int      `json:"rate_burst" yaml:"rate_burst" toml:"rate_burst"`
+	DailyQuota      int      `json:"daily_quota" yaml:"daily_quota" toml:"daily_quota"`
+	HostRateLimit   float64  `json:"host_rate_limit" yaml:"host_rate_limit" toml:"host_rate_limit"`
+	HostBurst       int      `json:"host_burst" yaml:"host_burst" toml:"host_burst"`
+	TrustProxy      bool     `json:"trust_proxy" yaml:"trust_proxy" toml:"trust_proxy"`
+	// AllowedRequestHeaders are the headers API clients may set on fetches;
+	// "*" allows any header
+	AllowedRequestHeaders []string `json:"allowed_request_headers" yaml:"allowed_request_headers" toml:"allowed_request_headers"`
+	AllowRequestCookies   bool     `json:"allow_request_cookies" yaml:"allow_request_cookies" toml:"allow_request_cookies"`
+	AllowRequestAuth      bool     `json:"allow_request_auth" yaml:"allow_request_auth" toml:"allow_request_auth"`
+	// CredentialHosts are the host patterns the fetch headers, cookies,
+	// credentials and client certificate are sent to in server mode
+	CredentialHosts []string `json:"credential_hosts" yaml:"credential_hosts" toml:"credential_hosts"`
+}
+
+// FilesConfig configures sandboxed local file access
+type FilesConfig struct {
+	Roots   []string `json:"roots" yaml:"roots" toml:"roots"`
+	MaxSize int64    `json:"max_size" yaml:"max_size" toml:"max_size"`
+}
+
+// CacheConfig configures the on-disk fetch cache
+type CacheConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled" toml:"enabled"`
+	// Dir defaults to iconhash in the user cache directory
+	Dir string `json:"dir" yaml:"dir" toml:"dir"`
+	// TTL is how long entries are served without revalidation
+	TTL Duration `json:"ttl" yaml:"ttl" toml:"ttl"`
+	// MaxSize is the size in bytes the cache is trimmed to
+	MaxSize int64 `json:"max_size" yaml:"max_size" toml:"max_size"`
+}
+
+// Default returns the built-in defaults
+func Default() *Config {
+	return &Config{
+		Log: LogConfig{
+			Format: "text",
+		},
+		Fetch: FetchConfig{
+			Timeout:        Duration(30 * time.Second),
+			Retries:        2,
+			RetryBackoff:   Duration(500 * time.Millisecond),
+			RetryMaxTime:   Duration(time.Minute),
+			AcceptEncoding: hasher.DefaultAcceptEncoding,
+			HashMode:       hasher.HashDecoded,
+			MaxRedirects:   hasher.DefaultMaxRedirects,
+		},
+		Server: ServerConfig{
+			Host:          "127.0.0.1",
+			Port:          8080,
+			ReadTimeout:   Duration(30 * time.Second),
+			WriteTimeout:  Duration(30 * time.Second),
+			RateLimit:     10,
+			RateBurst:     20,
+			HostRateLimit: 2,
+			HostBurst:     5,
+		},
+		Files: FilesConfig{
+			MaxSize: hasher.DefaultMaxFileSize,
+		},
+		Cache: CacheConfig{
+			TTL:     Duration(cache.DefaultTTL),
+			MaxSize: cache.DefaultMaxSize,
+		},
+	}
+}
+
+// Duration is a time.Duration written as a string such as "30s" or "1m30s"
+// in config files and environment variables
+type Duration time.Duration
+
+// MarshalText formats the duration
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText parses a duration string
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
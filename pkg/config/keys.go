@@ -0,0 +1,196 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/logging"
+)
+
+// Redacted replaces secret values in Redact
+const Redacted = "REDACTED"
+
+// Keys returns the dotted keys of every setting, such as server.port, in
+// declaration order
+func Keys() []string {
+	var keys []string
+	walk(reflect.ValueOf(Default()).Elem(), "", func(key string, _ reflect.Value, _ reflect.StructField) {
+		keys = append(keys, key)
+	})
+	return keys
+}
+
+// walk calls fn for every setting below v. Profiles are not settings.
+func walk(v reflect.Value, prefix string, fn func(key string, field reflect.Value, info reflect.StructField)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		info := t.Field(i)
+		name, _, _ := strings.Cut(info.Tag.Get("json"), ",")
+		if name == "" || name == "-" || name == "profiles" {
+			continue
+		}
+		key := prefix + name
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct && info.Type != reflect.TypeOf(Duration(0)) {
+			walk(field, key+".", fn)
+			continue
+		}
+		fn(key, field, info)
+	}
+}
+
+// lookup returns the field of a dotted key
+func (c *Config) lookup(key string) (reflect.Value, reflect.StructField, error) {
+	var (
+		found reflect.Value
+		info  reflect.StructField
+	)
+	walk(reflect.ValueOf(c).Elem(), "", func(k string, field reflect.Value, fieldInfo reflect.StructField) {
+		if k == key {
+			found, info = field, fieldInfo
+		}
+	})
+	if !found.IsValid() {
+		return found, info, fmt.Errorf("unknown setting %q", key)
+	}
+	return found, info, nil
+}
+
+// Get returns the value of a setting as text, in the form accepted by Set
+func (c *Config) Get(key string) (string, error) {
+	field, _, err := c.lookup(key)
+	if err != nil {
+		return "", err
+	}
+
+	if m, ok := field.Interface().(encoding.TextMarshaler); ok {
+		text, err := m.MarshalText()
+		return string(text), err
+	}
+	switch field.Kind() {
+	case reflect.Slice:
+		return strings.Join(field.Interface().([]string), ","), nil
+	default:
+		return fmt.Sprint(field.Interface()), nil
+	}
+}
+
+// Set parses text into a setting. Lists are comma-separated.
+func (c *Config) Set(key, value string) error {
+	field, _, err := c.lookup(key)
+	if err != nil {
+		return err
+	}
+
+	if u, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		if err := u.UnmarshalText([]byte(value)); err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: expected true or false", key)
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s: expected an integer", key)
+		}
+		field.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s: expected a number", key)
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting %q", key)
+	}
+	return nil
+}
+
+// List returns a copy of a list setting. Unlike Get, it keeps items that
+// contain commas, such as header values.
+func (c *Config) List(key string) ([]string, error) {
+	field, _, err := c.lookup(key)
+	if err != nil {
+		return nil, err
+	}
+	items, ok := field.Interface().([]string)
+	if !ok {
+		return nil, fmt.Errorf("setting %q is not a list", key)
+	}
+	return append([]string(nil), items...), nil
+}
+
+// SetList replaces a list setting without splitting its items
+func (c *Config) SetList(key string, items []string) error {
+	field, _, err := c.lookup(key)
+	if err != nil {
+		return err
+	}
+	if _, ok := field.Interface().([]string); !ok {
+		return fmt.Errorf("setting %q is not a list", key)
+	}
+	field.Set(reflect.ValueOf(append([]string(nil), items...)))
+	return nil
+}
+
+// Redact returns a copy of the config with secrets, such as the server
+// auth token or proxy passwords, replaced, for printing. Profiles are left
+// out, since the selected one has already been applied.
+func (c *Config) Redact() *Config {
+	redacted := *c
+	redacted.Profiles = nil
+	walk(reflect.ValueOf(&redacted).Elem(), "", func(_ string, field reflect.Value, info reflect.StructField) {
+		switch info.Tag.Get("secret") {
+		case "true":
+			if field.String() != "" {
+				field.SetString(Redacted)
+			}
+		case "url":
+			// Lists of URLs or pattern=URL rules keep everything but credentials
+			items := make([]string, field.Len())
+			for i := range items {
+				item := field.Index(i).String()
+				if pattern, u, ok := strings.Cut(item, "="); ok && !strings.Contains(pattern, "://") {
+					items[i] = pattern + "=" + logging.RedactURL(u)
+				} else {
+					items[i] = logging.RedactURL(item)
+				}
+			}
+			field.Set(reflect.ValueOf(items))
+		case "header":
+			// "Name: value" headers keep the values of headers that are not secrets
+			items := make([]string, field.Len())
+			for i := range items {
+				item := field.Index(i).String()
+				if name, value, ok := strings.Cut(item, ":"); ok {
+					items[i] = name + ": " + logging.RedactHeader(strings.TrimSpace(name), strings.TrimSpace(value))
+				} else {
+					items[i] = item
+				}
+			}
+			field.Set(reflect.ValueOf(items))
+		}
+	})
+	return &redacted
+}
@@ -0,0 +1,268 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is the prefix of the environment variables read by ApplyEnv.
+// A key such as server.port is read from ICONHASH_SERVER_PORT.
+const EnvPrefix = "ICONHASH_"
+
+// fileNames are the config file names looked up in the user config directory
+var fileNames = []string{"config.yaml", "config.yml", "config.toml", "config.json"}
+
+// builtinProfiles are the profiles available without a config file
+var builtinProfiles = map[string]map[string]interface{}{
+	// stealth looks like a browser and fetches slowly from each host
+	"stealth": {
+		"fetch": map[string]interface{}{
+			"timeout":       "60s",
+			"retries":       3,
+			"retry_backoff": "2s",
+			"user_agent":    "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+		},
+		"server": map[string]interface{}{
+			"host_rate_limit": 0.5,
+			"host_burst":      1,
+		},
+	},
+	// fast gives up early and allows more fetches per host
+	"fast": {
+		"fetch": map[string]interface{}{
+			"timeout": "5s",
+			"retries": 0,
+		},
+		"server": map[string]interface{}{
+			"host_rate_limit": 20,
+			"host_burst":      40,
+		},
+	},
+}
+
+// LoadOptions selects the config file and profile for Load
+type LoadOptions struct {
+	// File is the config file; when empty, ICONHASH_CONFIG or the first
+	// config file found in the user config directory is used
+	File string
+	// Profile overrides the profile named by ICONHASH_PROFILE or the file
+	Profile string
+	// LookupEnv reads environment variables; nil means os.LookupEnv
+	LookupEnv func(string) (string, bool)
+}
+
+// Load merges the defaults, the config file, the selected profile and the
+// environment. It returns the config and the path of the file read, if any.
+// Flags are applied afterwards by the caller with Set.
+func Load(options LoadOptions) (*Config, string, error) {
+	lookup := options.LookupEnv
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+
+	cfg := Default()
+
+	path := options.File
+	if path == "" {
+		path, _ = lookup(EnvPrefix + "CONFIG")
+	}
+	if path == "" {
+		path = FindFile()
+	}
+	if path != "" {
+		if err := LoadFile(cfg, path); err != nil {
+			return nil, "", err
+		}
+	}
+
+	profile := options.Profile
+	if profile == "" {
+		profile, _ = lookup(EnvPrefix + "PROFILE")
+	}
+	if profile == "" {
+		profile = cfg.Profile
+	}
+	if profile != "" {
+		if err := ApplyProfile(cfg, profile); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := ApplyEnv(cfg, lookup); err != nil {
+		return nil, "", err
+	}
+	cfg.Profile = profile
+	return cfg, path, nil
+}
+
+// FindFile returns the first iconhash config file in the user config
+// directory, such as ~/.config/iconhash/config.yaml, or "" if there is none
+func FindFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	for _, name := range fileNames {
+		path := filepath.Join(dir, "iconhash", name)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path
+		}
+	}
+	return ""
+}
+
+// LoadFile decodes a config file over cfg, so that settings missing from
+// the file keep their values. The format follows the file extension: .yaml,
+// .yml, .toml or .json. Unknown settings are errors, to catch typos.
+func LoadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err := Decode(cfg, data, formatOf(path)); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// formatOf returns the format of a config file from its extension
+func formatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		return "toml"
+	case ".json":
+		return "json"
+	default:
+		return "yaml"
+	}
+}
+
+// Decode decodes a yaml, toml or json document over cfg
+func Decode(cfg *Config, data []byte, format string) error {
+	switch format {
+	case "yaml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		return nil
+	case "toml":
+		meta, err := toml.Decode(string(data), cfg)
+		if err != nil {
+			return err
+		}
+		for _, key := range meta.Undecoded() {
+			if len(key) > 0 && key[0] != "profiles" {
+				return fmt.Errorf("unknown setting %q", key.String())
+			}
+		}
+		return nil
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		return dec.Decode(cfg)
+	default:
+		return fmt.Errorf("unknown config format %q (expected yaml, toml or json)", format)
+	}
+}
+
+// Encode encodes cfg as yaml, toml or json
+func Encode(cfg *Config, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	switch format {
+	case "yaml":
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(cfg); err != nil {
+			return nil, err
+		}
+	case "toml":
+		if err := toml.NewEncoder(&buf).Encode(cfg); err != nil {
+			return nil, err
+		}
+	case "json":
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(cfg); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown config format %q (expected yaml, toml or json)", format)
+	}
+	return buf.Bytes(), nil
+}
+
+// Profiles returns the names of the built-in profiles and those defined in cfg
+func Profiles(cfg *Config) []string {
+	seen := make(map[string]bool)
+	for name := range builtinProfiles {
+		seen[name] = true
+	}
+	for name := range cfg.Profiles {
+		seen[name] = true
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ApplyProfile applies a named profile over cfg. Profiles defined in the
+// config file replace built-in profiles of the same name.
+func ApplyProfile(cfg *Config, name string) error {
+	profile, ok := cfg.Profiles[name]
+	if !ok {
+		profile, ok = builtinProfiles[name]
+	}
+	if !ok {
+		return fmt.Errorf("unknown profile %q (available: %s)", name, strings.Join(Profiles(cfg), ", "))
+	}
+
+	// Profiles are decoded from any file format into plain maps, so they are
+	// applied by round-tripping through JSON
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return fmt.Errorf("invalid profile %q: %w", name, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return fmt.Errorf("invalid profile %q: %w", name, err)
+	}
+	return nil
+}
+
+// ApplyEnv sets every key that has an ICONHASH_* environment variable, such
+// as ICONHASH_SERVER_PORT for server.port. Lists are comma-separated and
+// empty variables are ignored.
+func ApplyEnv(cfg *Config, lookup func(string) (string, bool)) error {
+	for _, key := range Keys() {
+		name := EnvName(key)
+		value, ok := lookup(name)
+		if !ok || value == "" {
+			continue
+		}
+		if err := cfg.Set(key, value); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// EnvName returns the environment variable of a key
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
@@ -0,0 +1,126 @@
+// Package fingerprint maps favicon hashes to the products that serve them.
+package fingerprint
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Entry is a known favicon hash
+type Entry struct {
+	// Hash is the favicon hash as a decimal int32
+	Hash string `json:"hash"`
+	// Product is the product serving the favicon
+	Product string `json:"product"`
+	// Vendor is the product vendor
+	Vendor string `json:"vendor,omitempty"`
+	// Category groups products, such as vpn or ci
+	Category string `json:"category,omitempty"`
+}
+
+// DB is a fingerprint database indexed by hash
+type DB struct {
+	entries []Entry
+	byHash  map[string][]Entry
+}
+
+// dbFile is the file format of a fingerprint database
+type dbFile struct {
+	Fingerprints []Entry `json:"fingerprints"`
+}
+
+//go:embed fingerprints.json
+var defaultData []byte
+
+var (
+	defaultOnce sync.Once
+	defaultDB   *DB
+)
+
+// Default returns the database embedded in the binary
+func Default() *DB {
+	defaultOnce.Do(func() {
+		db, err := Parse(defaultData)
+		if err != nil {
+			panic("fingerprint: invalid embedded database: " + err.Error())
+		}
+		defaultDB = db
+	})
+	return defaultDB
+}
+
+// Parse parses a fingerprint database. Hashes may be given as int32 or
+// uint32 and are stored as int32.
+func Parse(data []byte) (*DB, error) {
+	var file dbFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid fingerprint database: %w", err)
+	}
+
+	db := &DB{byHash: make(map[string][]Entry, len(file.Fingerprints))}
+	for i, entry := range file.Fingerprints {
+		hash, err := Normalize(entry.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("fingerprint %d: %w", i+1, err)
+		}
+		if entry.Product == "" {
+			return nil, fmt.Errorf("fingerprint %d: product is required", i+1)
+		}
+		entry.Hash = hash
+		db.entries = append(db.entries, entry)
+		db.byHash[hash] = append(db.byHash[hash], entry)
+	}
+	return db, nil
+}
+
+// Normalize converts a decimal int32 or uint32 hash to its int32 form, which
+// is the form the search engines index
+func Normalize(hash string) (string, error) {
+	v, err := strconv.ParseInt(strings.TrimSpace(hash), 10, 64)
+	if err != nil || v < -1<<31 || v > 1<<32-1 {
+		return "", fmt.Errorf("invalid hash %q: expected a decimal int32 or uint32", hash)
+	}
+	return strconv.FormatInt(int64(int32(uint32(v))), 10), nil
+}
+
+// Lookup returns the entries matching a hash in int32 or uint32 form
+func (db *DB) Lookup(hash string) []Entry {
+	normalized, err := Normalize(hash)
+	if err != nil {
+		return nil
+	}
+	return db.byHash[normalized]
+}
+
+// Entries returns every entry, sorted by product
+func (db *DB) Entries() []Entry {
+	entries := make([]Entry, len(db.entries))
+	copy(entries, db.entries)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return strings.ToLower(entries[i].Product) < strings.ToLower(entries[j].Product)
+	})
+	return entries
+}
+
+// Search returns the entries whose product or vendor contains the query,
+// ignoring case
+func (db *DB) Search(query string) []Entry {
+	query = strings.ToLower(strings.TrimSpace(query))
+	var matches []Entry
+	for _, entry := range db.Entries() {
+		if strings.Contains(strings.ToLower(entry.Product), query) || strings.Contains(strings.ToLower(entry.Vendor), query) {
+			matches = append(matches, entry)
+		}
+	}
+	return matches
+}
+
+// Len returns the number of entries
+func (db *DB) Len() int {
+	return len(db.entries)
+}
@@ -0,0 +1,79 @@
+package fingerprint
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		hash        string
+		expected    string
+		expectError bool
+	}{
+		{"116323821", "116323821", false},
+		{"-305179312", "-305179312", false},
+		{"3989787984", "-305179312", false},
+		{"4294967296", "", true},
+		{"-2147483649", "", true},
+		{"abc", "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.hash, func(t *testing.T) {
+			result, err := Normalize(test.hash)
+			if test.expectError {
+				if err == nil {
+					t.Errorf("Expected error, got %q", result)
+				}
+				return
+			}
+			if err != nil || result != test.expected {
+				t.Errorf("Normalize(%q) = %q, %v; expected %q", test.hash, result, err, test.expected)
+			}
+		})
+	}
+}
+
+func TestDefaultDB(t *testing.T) {
+	db := Default()
+	if db.Len() == 0 {
+		t.Fatal("Embedded database is empty")
+	}
+
+	matches := db.Lookup("116323821")
+	if len(matches) != 1 || matches[0].Product != "Spring Boot" {
+		t.Errorf("Lookup(116323821) = %+v, expected Spring Boot", matches)
+	}
+	if matches := db.Lookup("3989787984"); len(matches) != 1 || matches[0].Product != "Confluence" {
+		t.Errorf("Lookup of a uint32 hash = %+v, expected Confluence", matches)
+	}
+	if matches := db.Lookup("1"); len(matches) != 0 {
+		t.Errorf("Lookup(1) = %+v, expected no match", matches)
+	}
+	if matches := db.Search("atlassian"); len(matches) != 2 {
+		t.Errorf("Search(atlassian) = %+v, expected 2 entries", matches)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		expectError bool
+	}{
+		{"Valid", `{"fingerprints":[{"hash":"1","product":"a"}]}`, false},
+		{"Invalid hash", `{"fingerprints":[{"hash":"x","product":"a"}]}`, true},
+		{"Missing product", `{"fingerprints":[{"hash":"1"}]}`, true},
+		{"Invalid JSON", `{`, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse([]byte(test.data))
+			if test.expectError && err == nil {
+				t.Error("Expected error, got nil")
+			}
+			if !test.expectError && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		})
+	}
+}
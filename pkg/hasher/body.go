@@ -0,0 +1,83 @@
+package hasher
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// BodyResult is the HTML hash of a web page
+type BodyResult struct {
+	// Hash is the MMH3 hash of the page body, as indexed by Shodan in
+	// http.html_hash
+	Hash   string
+	Length int
+	// StatusCode is the HTTP status of the page, which is hashed whatever
+	// its status
+	StatusCode int
+	// Title is the text of the page's <title> element, and TitleHash its
+	// MMH3 hash; both are empty if the page has no title
+	Title     string
+	TitleHash string
+	// Fetch is the fetch of the page, with its redirects and attempts
+	Fetch *FetchResult
+}
+
+// HashBody fetches a web page and calculates its HTML hash and title hash.
+// The body is hashed after undoing its Content-Encoding, as it is by Shodan,
+// which indexes error pages too, so the final response is hashed whatever
+// its status.
+func (h *IconHasher) HashBody(ctx context.Context, url string) (*BodyResult, error) {
+	// The page is not an image, so strict mode does not apply
+	page := h.WithOptions(func(o *HashOptions) { o.Strict, o.AnyStatus = false, true })
+	fetched, err := page.Fetch(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &BodyResult{
+		Length:     len(fetched.Data),
+		StatusCode: fetched.StatusCode,
+		Title:      ParseTitle(fetched.Data),
+		Fetch:      fetched,
+	}
+	if result.Hash, err = h.HashHTML(fetched.Data); err != nil {
+		return nil, err
+	}
+	if result.Title != "" {
+		if result.TitleHash, err = h.HashHTML([]byte(result.Title)); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// HashHTML calculates the MMH3 hash of data itself, as Shodan does for page
+// bodies, rather than of its base64 encoding as for favicons
+func (h *IconHasher) HashHTML(data []byte) (string, error) {
+	return h.calculateHash(data)
+}
+
+// ParseTitle returns the text of the first <title> element of an HTML
+// document, with surrounding whitespace removed
+func ParseTitle(data []byte) string {
+	tokenizer := html.NewTokenizer(bytes.NewReader(data))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken:
+			name, _ := tokenizer.TagName()
+			if string(name) != "title" {
+				continue
+			}
+			// The tokenizer reads the content of a title as raw text
+			if tokenizer.Next() != html.TextToken {
+				return ""
+			}
+			return strings.TrimSpace(string(tokenizer.Text()))
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package hasher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/twmb/murmur3"
+)
+
+func TestParseTitle(t *testing.T) {
+	tests := map[string]string{
+		"<html><head><title> Sign in &amp; Continue </title></head></html>": "Sign in & Continue",
+		"<title>Login</title><title>Other</title>":                          "Login",
+		"<svg><title>Icon</title></svg>":                                    "Icon",
+		"<html><body>No title</body></html>":                                "",
+		"<title></title>":                                                   "",
+	}
+	for input, expected := range tests {
+		if title := ParseTitle([]byte(input)); title != expected {
+			t.Errorf("ParseTitle(%q) = %q, expected %q", input, title, expected)
+		}
+	}
+}
+
+func TestHashBody(t *testing.T) {
+	page := "<html><head><title>Dashboard</title></head><body>Welcome</body></html>\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(page))
+	}))
+	defer server.Close()
+
+	// Strict mode applies to favicons only
+	h := New(&HashOptions{Strict: true})
+	result, err := h.HashBody(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("HashBody() returned error: %v", err)
+	}
+
+	// The body and title are hashed as they are, without base64 encoding
+	if expected := mmh3([]byte(page)); result.Hash != expected {
+		t.Errorf("Expected html hash %s, got %s", expected, result.Hash)
+	}
+	if expected := mmh3([]byte("Dashboard")); result.Title != "Dashboard" || result.TitleHash != expected {
+		t.Errorf("Expected title hash %s, got %q %s", expected, result.Title, result.TitleHash)
+	}
+	if result.Length != len(page) || result.StatusCode != http.StatusOK || result.Fetch.Type != TypeHTML {
+		t.Errorf("Unexpected result %+v", result)
+	}
+
+	unsigned, _ := New(&HashOptions{UseUint32: true}).HashHTML([]byte(page))
+	if expected := strconv.FormatUint(uint64(murmur3.Sum32([]byte(page))), 10); unsigned != expected {
+		t.Errorf("Expected unsigned hash %s, got %s", expected, unsigned)
+	}
+}
+
+func TestHashBodyErrorPage(t *testing.T) {
+	page := "<html><head><title>404 Not Found</title></head><body>nginx</body></html>"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(page))
+	}))
+	defer server.Close()
+
+	// Error pages are hashed as served, without retries
+	h := New(&HashOptions{Retry: &RetryPolicy{MaxAttempts: 3, RetryStatuses: []int{http.StatusNotFound}}})
+	result, err := h.HashBody(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("HashBody() returned error: %v", err)
+	}
+	if expected := mmh3([]byte(page)); result.Hash != expected || result.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected html hash %s with status 404, got %s with status %d", expected, result.Hash, result.StatusCode)
+	}
+	if result.Title != "404 Not Found" || len(result.Fetch.Attempts) != 1 || result.Fetch.Attempts[0].StatusCode != http.StatusNotFound {
+		t.Errorf("Unexpected result %+v", result)
+	}
+
+	// Favicon fetches still fail on the same page
+	var statusErr *StatusError
+	if _, err := New(nil).HashFromURL(server.URL); !errors.As(err, &statusErr) {
+		t.Errorf("Expected a status error for a favicon, got %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package hasher
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	neturl "net/url"
+)
+
+// Fingerprint bundles the favicon hash of a site with the metadata of its
+// home page that search engines index, for pivoting to related hosts
+type Fingerprint struct {
+	URL string `json:"url"`
+	// FinalURL is the URL of the page after redirects
+	FinalURL string `json:"final_url,omitempty"`
+	// StatusCode is the HTTP status of the page, which is fingerprinted
+	// whatever its status
+	StatusCode int `json:"status_code"`
+	// Title is the text of the page's <title> element
+	Title string `json:"title,omitempty"`
+	// Server and PoweredBy are the Server and X-Powered-By response headers
+	Server    string `json:"server,omitempty"`
+	PoweredBy string `json:"x_powered_by,omitempty"`
+	// HTMLHash is the MMH3 hash of the page body, as calculated by HashBody
+	HTMLHash string `json:"html_hash"`
+	// FaviconURL and FaviconHash describe the first icon of the page that
+	// could be fetched, declared icons first and /favicon.ico last.
+	// FaviconError is set instead when none could be fetched.
+	FaviconURL   string `json:"favicon_url,omitempty"`
+	FaviconHash  string `json:"favicon_hash,omitempty"`
+	FaviconError string `json:"favicon_error,omitempty"`
+	// Certificate is the leaf certificate of the server, or nil over plain HTTP
+	Certificate *Certificate `json:"certificate,omitempty"`
+	// Fetch is the fetch of the page, with its redirects and attempts
+	Fetch *FetchResult `json:"-"`
+}
+
+// Certificate is the part of a TLS certificate that search engines index
+type Certificate struct {
+	// Subject and Issuer are distinguished names, and SubjectCN and IssuerCN
+	// their common names
+	Subject   string `json:"subject"`
+	SubjectCN string `json:"subject_cn,omitempty"`
+	Issuer    string `json:"issuer"`
+	IssuerCN  string `json:"issuer_cn,omitempty"`
+	// SANs are the DNS names and IP addresses of the certificate
+	SANs []string `json:"sans,omitempty"`
+	// SHA256 is the hex SHA-256 fingerprint of the DER certificate
+	SHA256 string `json:"sha256"`
+	// Serial is the serial number in decimal
+	Serial string `json:"serial"`
+}
+
+// NewCertificate returns the indexed fields of a certificate
+func NewCertificate(cert *x509.Certificate) *Certificate {
+	sum := sha256.Sum256(cert.Raw)
+	c := &Certificate{
+		Subject:   cert.Subject.String(),
+		SubjectCN: cert.Subject.CommonName,
+		Issuer:    cert.Issuer.String(),
+		IssuerCN:  cert.Issuer.CommonName,
+		SHA256:    hex.EncodeToString(sum[:]),
+		Serial:    cert.SerialNumber.String(),
+	}
+	c.SANs = append(c.SANs, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		c.SANs = append(c.SANs, ip.String())
+	}
+	return c
+}
+
+// Fingerprint fetches a web page and its favicon and collects the page title,
+// the Server and X-Powered-By headers, the HTML hash and the server's TLS
+// certificate alongside the favicon hash. The page is read whatever its
+// status, as HashBody does, since error and login pages are common recon
+// targets. The cache is bypassed, so that the headers and certificate come
+// from the server, and the favicon is fetched with the same client, reusing
+// the connection of the page when the server keeps it alive.
+func (h *IconHasher) Fingerprint(ctx context.Context, url string) (*Fingerprint, error) {
+	// The page is not an image, so strict mode does not apply to it
+	live := h.WithOptions(func(o *HashOptions) { o.Cache = nil })
+	page := live.WithOptions(func(o *HashOptions) { o.Strict, o.AnyStatus = false, true })
+	fetched, err := page.Fetch(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Fingerprint{
+		URL:        url,
+		FinalURL:   fetched.FinalURL,
+		StatusCode: fetched.StatusCode,
+		Title:      ParseTitle(fetched.Data),
+		Server:     fetched.Header.Get("Server"),
+		PoweredBy:  fetched.Header.Get("X-Powered-By"),
+		Fetch:      fetched,
+	}
+	if result.HTMLHash, err = h.HashHTML(fetched.Data); err != nil {
+		return nil, err
+	}
+	if fetched.TLS != nil && len(fetched.TLS.PeerCertificates) > 0 {
+		result.Certificate = NewCertificate(fetched.TLS.PeerCertificates[0])
+	}
+
+	base, err := neturl.Parse(fetched.FinalURL)
+	if err != nil {
+		return nil, err
+	}
+	for _, icon := range ParseFavicons(fetched.Data, base) {
+		hash, err := live.fetchHash(ctx, icon.URL)
+		if err != nil {
+			result.FaviconError = err.Error()
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue
+		}
+		result.FaviconURL, result.FaviconHash, result.FaviconError = icon.URL, hash, ""
+		break
+	}
+	return result, nil
+}
+
+// fetchHash fetches the favicon at url and hashes it
+func (h *IconHasher) fetchHash(ctx context.Context, url string) (string, error) {
+	fetched, err := h.Fetch(ctx, url)
+	if err != nil {
+		return "", err
+	}
+	hash, _, err := h.HashFetched(fetched)
+	return hash, err
+}
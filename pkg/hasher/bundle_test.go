@@ -0,0 +1,114 @@
+package hasher
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	page := `<html><head><title>Router Login</title><link rel="icon" href="/missing.png"></head></html>`
+	icon := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "nginx/1.18.0")
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("X-Powered-By", "PHP/7.4.3")
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(page))
+		case "/favicon.ico":
+			w.Write(icon)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	// Strict mode applies to the favicon only
+	h := New(&HashOptions{InsecureSkipVerify: true, Strict: true})
+	result, err := h.Fingerprint(context.Background(), server.URL+"/")
+	if err != nil {
+		t.Fatalf("Fingerprint() returned error: %v", err)
+	}
+
+	if result.Title != "Router Login" || result.Server != "nginx/1.18.0" || result.PoweredBy != "PHP/7.4.3" {
+		t.Errorf("Unexpected page metadata %+v", result)
+	}
+	if expected := mmh3([]byte(page)); result.HTMLHash != expected {
+		t.Errorf("Expected html hash %s, got %s", expected, result.HTMLHash)
+	}
+
+	// The declared icon is missing, so /favicon.ico is hashed instead
+	expected, _ := h.HashFromBytes(icon)
+	if result.FaviconURL != server.URL+"/favicon.ico" || result.FaviconHash != expected || result.FaviconError != "" {
+		t.Errorf("Expected favicon %s from /favicon.ico, got %+v", expected, result)
+	}
+
+	cert := server.Certificate()
+	sum := sha256.Sum256(cert.Raw)
+	if result.Certificate == nil {
+		t.Fatal("Expected the server certificate")
+	}
+	if result.Certificate.SHA256 != hex.EncodeToString(sum[:]) || result.Certificate.Serial != cert.SerialNumber.String() {
+		t.Errorf("Unexpected certificate %+v", result.Certificate)
+	}
+	if result.Certificate.Issuer != cert.Issuer.String() || len(result.Certificate.SANs) != len(cert.DNSNames)+len(cert.IPAddresses) {
+		t.Errorf("Unexpected certificate names %+v", result.Certificate)
+	}
+}
+
+func TestFingerprintWithoutFavicon(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("<title>Empty</title>"))
+	}))
+	defer server.Close()
+
+	result, err := New(nil).Fingerprint(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Fingerprint() returned error: %v", err)
+	}
+	if result.Certificate != nil {
+		t.Errorf("Expected no certificate over plain HTTP, got %+v", result.Certificate)
+	}
+	if result.FaviconHash != "" || result.FaviconError == "" {
+		t.Errorf("Expected a favicon error, got %+v", result)
+	}
+}
+
+func TestFingerprintErrorPage(t *testing.T) {
+	page := `<html><head><title>403 Forbidden</title></head><body>Access denied</body></html>`
+	icon := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "cloudflare")
+		if r.URL.Path == "/favicon.ico" {
+			w.Write(icon)
+			return
+		}
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(page))
+	}))
+	defer server.Close()
+
+	h := New(&HashOptions{InsecureSkipVerify: true})
+	result, err := h.Fingerprint(context.Background(), server.URL+"/")
+	if err != nil {
+		t.Fatalf("Fingerprint() returned error: %v", err)
+	}
+
+	// The forbidden page is fingerprinted as HashBody hashes it
+	body, _ := h.HashBody(context.Background(), server.URL+"/")
+	if result.StatusCode != http.StatusForbidden || result.Title != "403 Forbidden" || result.HTMLHash != body.Hash {
+		t.Errorf("Expected the 403 page with html hash %s, got %+v", body.Hash, result)
+	}
+	expected, _ := h.HashFromBytes(icon)
+	if result.Server != "cloudflare" || result.Certificate == nil || result.FaviconHash != expected {
+		t.Errorf("Expected the headers, certificate and favicon, got %+v", result)
+	}
+}
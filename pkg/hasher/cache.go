@@ -0,0 +1,138 @@
+package hasher
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/cache"
+	"github.com/twmb/murmur3"
+)
+
+// Cache results reported in FetchResult.Cache
+const (
+	// CacheHit is a fresh entry served without a request
+	CacheHit = "hit"
+	// CacheRevalidated is a stale entry the server confirmed with 304 Not Modified
+	CacheRevalidated = "revalidated"
+	// CacheMiss is a URL fetched from the server, with or without a stale entry
+	CacheMiss = "miss"
+)
+
+// CacheObserver is implemented by Observers that also count cache lookups
+type CacheObserver interface {
+	// ObserveCache is called with the result of every cache lookup
+	ObserveCache(result string)
+}
+
+// observeCache reports a cache lookup to the observer, if it counts them
+func (h *IconHasher) observeCache(result string) {
+	if o, ok := h.options.Observer.(CacheObserver); ok {
+		o.ObserveCache(result)
+	}
+}
+
+// mmh3 returns the int32 MMH3 hash of already encoded data, as stored in
+// cache entries
+func mmh3(encoded []byte) string {
+	return strconv.FormatInt(int64(int32(murmur3.Sum32(encoded))), 10)
+}
+
+// fetchCached fetches a URL through the cache, if there is one
+func (h *IconHasher) fetchCached(ctx context.Context, url string) (*FetchResult, error) {
+	c := h.options.Cache
+	if c == nil || !h.cacheable() {
+		result, _, err := h.fetchWithRetries(ctx, url, nil)
+		return result, err
+	}
+
+	var cached *cache.Entry
+	if !h.options.RefreshCache {
+		cached = c.Get(url)
+	}
+	if cached != nil && c.Fresh(cached) {
+		h.observeCache(CacheHit)
+		result := &FetchResult{URL: url, Cache: CacheHit}
+		result.setCached(cached)
+		return result, nil
+	}
+
+	result, resp, err := h.fetchWithRetries(ctx, url, cached)
+	if err != nil {
+		h.observeCache(CacheMiss)
+		return nil, err
+	}
+	if resp.notModified {
+		// Failing to update the cache does not fail the fetch
+		c.Refresh(cached, resp.etag, resp.lastModified)
+		result.setCached(cached)
+		result.Cache = CacheRevalidated
+	} else {
+		if !resp.noStore {
+			c.Put(&cache.Entry{
+				URL:             url,
+				Body:            resp.data,
+				ContentType:     resp.contentType,
+				Raw:             encodedRaw(resp),
+				ContentEncoding: resp.encoding,
+				FinalURL:        resp.finalURL,
+				Redirects:       cacheRedirects(resp.redirects),
+				ETag:            resp.etag,
+				LastModified:    resp.lastModified,
+				Hash:            mmh3(h.standardBase64Encode(resp.data)),
+				StoredAt:        time.Now(),
+			})
+		}
+		result.Cache = CacheMiss
+	}
+	h.observeCache(result.Cache)
+	return result, nil
+}
+
+// cacheable reports whether fetches with the options may use the cache. It
+// is keyed by URL, which tells apart neither the servers that resolve and
+// connect-to rules pick nor the content served for headers, cookies and
+// credentials, such as a login page instead of an application's favicon.
+// Neither does it tell apart the encodings asked for with AcceptEncoding,
+// which the cached raw body depends on. Error pages read with AnyStatus are
+// not cached either.
+func (h *IconHasher) cacheable() bool {
+	o := h.options
+	return len(o.Resolve) == 0 && len(o.ConnectTo) == 0 && !o.AnyStatus &&
+		(o.AcceptEncoding == "" || o.AcceptEncoding == DefaultAcceptEncoding) &&
+		len(o.Headers) == 0 && o.Cookies == nil && o.Auth == nil && o.ClientCert == nil
+}
+
+// setCached fills in the content of a cached entry
+func (r *FetchResult) setCached(entry *cache.Entry) {
+	r.Data, r.Raw = entry.Body, entry.Raw
+	r.StatusCode = http.StatusOK
+	if r.Raw == nil {
+		r.Raw = entry.Body
+	}
+	r.ContentType, r.ContentEncoding = entry.ContentType, entry.ContentEncoding
+	r.FinalURL, r.Redirects = entry.FinalURL, nil
+	for _, redirect := range entry.Redirects {
+		r.Redirects = append(r.Redirects, Redirect(redirect))
+	}
+}
+
+// cacheRedirects converts redirects for a cache entry
+func cacheRedirects(redirects []Redirect) []cache.Redirect {
+	var out []cache.Redirect
+	for _, redirect := range redirects {
+		out = append(out, cache.Redirect(redirect))
+	}
+	return out
+}
+
+// encodedRaw returns the raw body of a response worth caching next to its
+// decoded body, or nil if the two are the same
+func encodedRaw(resp *response) []byte {
+	if bytes.Equal(resp.raw, resp.data) {
+		return nil
+	}
+	return resp.raw
+}
@@ -0,0 +1,112 @@
+package hasher
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/cache"
+)
+
+// cacheObserver counts cache lookups by result
+type cacheObserver struct {
+	fetches int
+	results map[string]int
+}
+
+func (o *cacheObserver) ObserveFetch(time.Duration, error) { o.fetches++ }
+func (o *cacheObserver) ObserveHash(int)                   {}
+func (o *cacheObserver) ObserveCache(result string)        { o.results[result]++ }
+
+func TestFetchCache(t *testing.T) {
+	var conditional []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/private.ico" {
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		w.Header().Set("ETag", `"v1"`)
+		if match := r.Header.Get("If-None-Match"); match != "" {
+			conditional = append(conditional, match)
+			if match == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		w.Write([]byte("icon"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	c, err := cache.Open(dir, &cache.Options{TTL: time.Hour})
+	if err != nil {
+		t.Fatalf("Open() returned error: %v", err)
+	}
+	observer := &cacheObserver{results: make(map[string]int)}
+	h := New(&HashOptions{Cache: c, Observer: observer})
+	url := server.URL + "/favicon.ico"
+
+	fetch := func(h *IconHasher, expected string) {
+		t.Helper()
+		result, err := h.Fetch(context.Background(), url)
+		if err != nil {
+			t.Fatalf("Fetch() returned error: %v", err)
+		}
+		if string(result.Data) != "icon" || result.Cache != expected {
+			t.Errorf("Expected the icon from cache result %q, got %q from %q", expected, result.Data, result.Cache)
+		}
+	}
+
+	fetch(h, CacheMiss)
+	fetch(h, CacheHit)
+	if observer.fetches != 1 {
+		t.Errorf("Expected a fresh entry to be served without a request, got %d fetches", observer.fetches)
+	}
+	if entry := c.Get(url); entry == nil || entry.Hash == "" {
+		t.Errorf("Expected the entry to store the hash, got %+v", entry)
+	} else if hash, _ := h.HashFromBytes(entry.Body); hash != entry.Hash {
+		t.Errorf("Expected stored hash %s, got %s", hash, entry.Hash)
+	}
+
+	// Stale entries are revalidated with their ETag
+	stale, _ := cache.Open(dir, &cache.Options{TTL: 0})
+	fetch(h.WithOptions(func(o *HashOptions) { o.Cache = stale }), CacheRevalidated)
+	if len(conditional) != 1 || conditional[0] != `"v1"` {
+		t.Errorf("Expected a conditional request, got %v", conditional)
+	}
+
+	// Refreshing ignores the cached entry
+	fetch(h.WithOptions(func(o *HashOptions) { o.RefreshCache = true }), CacheMiss)
+	if len(conditional) != 1 {
+		t.Errorf("Expected an unconditional request, got %v", conditional)
+	}
+	if observer.results[CacheHit] != 1 || observer.results[CacheRevalidated] != 1 || observer.results[CacheMiss] != 2 {
+		t.Errorf("Unexpected cache results %v", observer.results)
+	}
+
+	// Responses marked no-store are not cached
+	h.HashFromURL(server.URL + "/private.ico")
+	if c.Get(server.URL+"/private.ico") != nil {
+		t.Error("Expected a no-store response not to be cached")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 || filepath.Ext(entries[0].Name()) != ".json" {
+		t.Errorf("Expected a single cache entry, got %v", entries)
+	}
+
+	// Fetches with headers or credentials bypass the cache, whose entries
+	// were fetched without them
+	for name, update := range map[string]func(*HashOptions){
+		"headers": func(o *HashOptions) { o.Headers = http.Header{"Host": {"admin.example"}} },
+		"auth":    func(o *HashOptions) { o.Auth = &Credentials{BearerToken: "secret"} },
+		// The raw bytes of an entry depend on the encodings asked for
+		"identity": func(o *HashOptions) { o.AcceptEncoding = "identity" },
+	} {
+		result, err := h.WithOptions(update).Fetch(context.Background(), url)
+		if err != nil || result.Cache != "" {
+			t.Errorf("Expected a fetch with %s to bypass the cache, got %+v, %v", name, result, err)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package hasher
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	neturl "net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LoadCookieFile reads cookies in the Netscape cookies.txt format written by
+// curl, wget and browser extensions into a cookie jar. Expired cookies are
+// skipped; cookies set by responses are added to the jar during the run.
+func LoadCookieFile(path string) (http.CookieJar, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cookie file: %w", err)
+	}
+	defer f.Close()
+
+	jar, _ := cookiejar.New(nil)
+	now := time.Now()
+
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		// curl marks HttpOnly cookies with a prefix on an otherwise comment-like line
+		httpOnly := strings.HasPrefix(line, "#HttpOnly_")
+		if httpOnly {
+			line = strings.TrimPrefix(line, "#HttpOnly_")
+		}
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("invalid cookie file %s: line %d: expected 7 tab-separated fields", path, lineNo)
+		}
+		domain, includeSubdomains, cookiePath, secure, expires, name, value :=
+			fields[0], fields[1] == "TRUE", fields[2], fields[3] == "TRUE", fields[4], fields[5], fields[6]
+
+		expiry, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cookie file %s: line %d: invalid expiry %q", path, lineNo, expires)
+		}
+		cookie := &http.Cookie{
+			Name:     name,
+			Value:    value,
+			Path:     cookiePath,
+			Secure:   secure,
+			HttpOnly: httpOnly,
+		}
+		if expiry > 0 {
+			cookie.Expires = time.Unix(expiry, 0)
+			if cookie.Expires.Before(now) {
+				continue
+			}
+		}
+
+		host := strings.TrimPrefix(domain, ".")
+		if includeSubdomains {
+			cookie.Domain = host
+		}
+		scheme := "http"
+		if secure {
+			scheme = "https"
+		}
+		jar.SetCookies(&neturl.URL{Scheme: scheme, Host: host, Path: cookiePath}, []*http.Cookie{cookie})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read cookie file: %w", err)
+	}
+	return jar, nil
+}
@@ -0,0 +1,234 @@
+package hasher
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ResolveRule pins the address of a host and port, like curl's --resolve.
+// TLS server names and Host headers still come from the URL.
+type ResolveRule struct {
+	// Host is a host name, or "*" for every host
+	Host string
+	Port string
+	// Addrs are IP addresses, tried in order
+	Addrs []string
+}
+
+// ParseResolve parses a rule written as "host:port:addr[,addr]...", such as
+// "example.com:443:203.0.113.7" or "*:443:[2001:db8::1]"
+func ParseResolve(s string) (ResolveRule, error) {
+	invalid := func(reason string) (ResolveRule, error) {
+		return ResolveRule{}, fmt.Errorf("invalid resolve rule %q: %s", s, reason)
+	}
+
+	host, rest, ok := cutField(strings.TrimPrefix(strings.TrimSpace(s), "+"))
+	if !ok || host == "" {
+		return invalid("expected host:port:addr")
+	}
+	port, addrs, ok := cutField(rest)
+	if !ok || !validPort(port) {
+		return invalid("expected a port number")
+	}
+
+	rule := ResolveRule{Host: strings.ToLower(host), Port: port}
+	for _, addr := range strings.Split(addrs, ",") {
+		addr = strings.Trim(strings.TrimSpace(addr), "[]")
+		if net.ParseIP(addr) == nil {
+			return invalid(fmt.Sprintf("%q is not an IP address", addr))
+		}
+		rule.Addrs = append(rule.Addrs, addr)
+	}
+	return rule, nil
+}
+
+// matches reports whether the rule applies to a host and port
+func (r ResolveRule) matches(host, port string) bool {
+	return (r.Host == "*" || strings.EqualFold(r.Host, host)) && r.Port == port
+}
+
+// ConnectToRule sends the connections for a host and port to another one,
+// like curl's --connect-to. TLS server names and Host headers still come
+// from the URL.
+type ConnectToRule struct {
+	// Host and Port select the connections; empty values match any
+	Host string
+	Port string
+	// ToHost and ToPort are connected to instead; empty values keep the
+	// original host or port
+	ToHost string
+	ToPort string
+}
+
+// ParseConnectTo parses a rule written as "host:port:tohost:toport", where
+// any field may be empty, such as "example.com:443:origin.example.net:8443"
+// or "::192.0.2.10:"
+func ParseConnectTo(s string) (ConnectToRule, error) {
+	invalid := func(reason string) (ConnectToRule, error) {
+		return ConnectToRule{}, fmt.Errorf("invalid connect-to rule %q: %s", s, reason)
+	}
+
+	var fields [4]string
+	rest := strings.TrimSpace(s)
+	for i := range fields {
+		var ok bool
+		fields[i], rest, ok = cutField(rest)
+		if ok != (i < len(fields)-1) {
+			return invalid("expected host:port:tohost:toport")
+		}
+	}
+	for _, port := range []string{fields[1], fields[3]} {
+		if port != "" && !validPort(port) {
+			return invalid(fmt.Sprintf("%q is not a port number", port))
+		}
+	}
+	return ConnectToRule{Host: strings.ToLower(fields[0]), Port: fields[1], ToHost: fields[2], ToPort: fields[3]}, nil
+}
+
+// matches reports whether the rule applies to a host and port
+func (r ConnectToRule) matches(host, port string) bool {
+	return (r.Host == "" || strings.EqualFold(r.Host, host)) && (r.Port == "" || r.Port == port)
+}
+
+// cutField cuts the first colon separated field of a rule, which may be a
+// bracketed IPv6 address. ok reports whether a colon followed the field.
+func cutField(s string) (field, rest string, ok bool) {
+	if strings.HasPrefix(s, "[") {
+		end := strings.Index(s, "]")
+		if end < 0 {
+			return s, "", false
+		}
+		field, rest = s[1:end], s[end+1:]
+		if rest == "" {
+			return field, "", false
+		}
+		if rest[0] != ':' {
+			return s, "", false
+		}
+		return field, rest[1:], true
+	}
+	return strings.Cut(s, ":")
+}
+
+// validPort reports whether s is a TCP port number
+func validPort(s string) bool {
+	n, err := strconv.Atoi(s)
+	return err == nil && n > 0 && n < 65536
+}
+
+// dialer connects to the addresses chosen by the resolve and connect-to rules
+// of the options, resolving other hosts through the configured DNS server
+type dialer struct {
+	net       *net.Dialer
+	resolve   []ResolveRule
+	connectTo []ConnectToRule
+}
+
+// newDialer returns the dialer of the options, or nil if they use the
+// default one
+func newDialer(options *HashOptions) *dialer {
+	if len(options.Resolve) == 0 && len(options.ConnectTo) == 0 && options.DNSServer == "" {
+		return nil
+	}
+
+	d := &dialer{
+		net:       &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+		resolve:   options.Resolve,
+		connectTo: options.ConnectTo,
+	}
+	if server := options.DNSServer; server != "" {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(strings.Trim(server, "[]"), "53")
+		}
+		d.net.Resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var dns net.Dialer
+				return dns.DialContext(ctx, network, server)
+			},
+		}
+	}
+	return d
+}
+
+// pins reports whether a rule chooses the address connected to for a host
+// and port
+func (d *dialer) pins(host, port string) bool {
+	for _, rule := range d.connectTo {
+		if rule.matches(host, port) {
+			return true
+		}
+	}
+	for _, rule := range d.resolve {
+		if rule.matches(host, port) {
+			return true
+		}
+	}
+	return false
+}
+
+// bypassProxy wraps the proxy function of a transport so that the hosts a
+// rule pins are connected to directly. Through a proxy, the proxy would
+// resolve the host itself and the rules would apply to the connection to
+// the proxy instead.
+func (d *dialer) bypassProxy(proxy func(*http.Request) (*neturl.URL, error)) func(*http.Request) (*neturl.URL, error) {
+	if proxy == nil || (len(d.resolve) == 0 && len(d.connectTo) == 0) {
+		return proxy
+	}
+	return func(req *http.Request) (*neturl.URL, error) {
+		port := req.URL.Port()
+		if port == "" {
+			port = "80"
+			if req.URL.Scheme == "https" {
+				port = "443"
+			}
+		}
+		if d.pins(req.URL.Hostname(), port) {
+			return nil, nil
+		}
+		return proxy(req)
+	}
+}
+
+// DialContext connects to address, or to the address a rule chooses for it
+func (d *dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return d.net.DialContext(ctx, network, address)
+	}
+
+	for _, rule := range d.connectTo {
+		if rule.matches(host, port) {
+			if rule.ToHost != "" {
+				host = rule.ToHost
+			}
+			if rule.ToPort != "" {
+				port = rule.ToPort
+			}
+			break
+		}
+	}
+
+	for _, rule := range d.resolve {
+		if !rule.matches(host, port) {
+			continue
+		}
+		var lastErr error
+		for _, addr := range rule.Addrs {
+			conn, err := d.net.DialContext(ctx, network, net.JoinHostPort(addr, port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+		return nil, lastErr
+	}
+
+	return d.net.DialContext(ctx, network, net.JoinHostPort(host, port))
+}
@@ -0,0 +1,190 @@
+package hasher
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestParseResolve(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected ResolveRule
+		valid    bool
+	}{
+		{"example.com:443:203.0.113.7", ResolveRule{Host: "example.com", Port: "443", Addrs: []string{"203.0.113.7"}}, true},
+		{"+Example.com:80:192.0.2.1,192.0.2.2", ResolveRule{Host: "example.com", Port: "80", Addrs: []string{"192.0.2.1", "192.0.2.2"}}, true},
+		{"*:443:[2001:db8::1]", ResolveRule{Host: "*", Port: "443", Addrs: []string{"2001:db8::1"}}, true},
+		{"example.com:443", ResolveRule{}, false},
+		{"example.com:https:192.0.2.1", ResolveRule{}, false},
+		{"example.com:443:origin.example.net", ResolveRule{}, false},
+	}
+	for _, test := range tests {
+		rule, err := ParseResolve(test.input)
+		if (err == nil) != test.valid || (test.valid && !reflect.DeepEqual(rule, test.expected)) {
+			t.Errorf("ParseResolve(%q) = %+v, %v", test.input, rule, err)
+		}
+	}
+}
+
+func TestParseConnectTo(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected ConnectToRule
+		valid    bool
+	}{
+		{"example.com:443:origin.example.net:8443", ConnectToRule{Host: "example.com", Port: "443", ToHost: "origin.example.net", ToPort: "8443"}, true},
+		{"::192.0.2.10:", ConnectToRule{ToHost: "192.0.2.10"}, true},
+		{"example.com::[2001:db8::1]:", ConnectToRule{Host: "example.com", ToHost: "2001:db8::1"}, true},
+		{"example.com:443:origin", ConnectToRule{}, false},
+		{"example.com:443:origin:80:extra", ConnectToRule{}, false},
+		{"example.com:0::", ConnectToRule{}, false},
+	}
+	for _, test := range tests {
+		rule, err := ParseConnectTo(test.input)
+		if (err == nil) != test.valid || (test.valid && !reflect.DeepEqual(rule, test.expected)) {
+			t.Errorf("ParseConnectTo(%q) = %+v, %v", test.input, rule, err)
+		}
+	}
+}
+
+func TestDialOverrides(t *testing.T) {
+	var serverName, host string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+		w.Write([]byte{0, 0, 1, 0})
+	}))
+	server.TLS = &tls.Config{GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		serverName = hello.ServerName
+		return nil, nil
+	}}
+	server.StartTLS()
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	// The vhost is kept for SNI and the Host header while 127.0.0.1 is
+	// dialed, after 127.0.0.2 refuses the connection
+	options := DefaultOptions()
+	options.Resolve = []ResolveRule{{Host: "origin.test", Port: port, Addrs: []string{"127.0.0.2", "127.0.0.1"}}}
+	if _, err := New(options).HashFromURL("https://origin.test:" + port + "/favicon.ico"); err != nil {
+		t.Fatalf("HashFromURL() returned error: %v", err)
+	}
+	if serverName != "origin.test" || host != "origin.test:"+port {
+		t.Errorf("Expected SNI and Host origin.test, got %q and %q", serverName, host)
+	}
+
+	options = DefaultOptions()
+	options.ConnectTo = []ConnectToRule{{Host: "www.test", ToHost: "127.0.0.1", ToPort: port}}
+	if _, err := New(options).HashFromURL("https://www.test/favicon.ico"); err != nil {
+		t.Fatalf("HashFromURL() returned error: %v", err)
+	}
+	if serverName != "www.test" || host != "www.test" {
+		t.Errorf("Expected SNI and Host www.test, got %q and %q", serverName, host)
+	}
+}
+
+func TestDialOverridesBypassProxy(t *testing.T) {
+	var host string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+		w.Write(proxyTestIcon)
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	proxy := newHTTPProxyStandIn(t)
+	selector, err := NewProxySelector([]string{proxy.URL}, nil)
+	if err != nil {
+		t.Fatalf("NewProxySelector() returned error: %v", err)
+	}
+
+	// Pinned hosts are connected to directly, other hosts go through the proxy
+	options := &HashOptions{
+		Proxy:     selector,
+		Resolve:   []ResolveRule{{Host: "origin.test", Port: port, Addrs: []string{"127.0.0.1"}}},
+		ConnectTo: []ConnectToRule{{Host: "www.test", Port: "80", ToHost: "127.0.0.1", ToPort: port}},
+	}
+	h := New(options)
+	for _, url := range []string{"http://origin.test:" + port + "/favicon.ico", "http://www.test/favicon.ico"} {
+		host = ""
+		if _, err := h.HashFromURL(url); err != nil {
+			t.Fatalf("HashFromURL(%s) returned error: %v", url, err)
+		}
+		if host == "" {
+			t.Errorf("Expected %s to reach the pinned address", url)
+		}
+	}
+	if len(proxy.requests) != 0 {
+		t.Errorf("Expected pinned hosts to bypass the proxy, got %v", proxy.requests)
+	}
+
+	if _, err := h.HashFromURL("http://icons.example/favicon.ico"); err != nil {
+		t.Fatalf("HashFromURL() returned error: %v", err)
+	}
+	if len(proxy.requests) != 1 || proxy.requests[0] != "http://icons.example/favicon.ico" {
+		t.Errorf("Expected other hosts to go through the proxy, got %v", proxy.requests)
+	}
+
+	// A wildcard rule pins every host, without redirecting the proxy
+	options.Resolve = []ResolveRule{{Host: "*", Port: port, Addrs: []string{"127.0.0.1"}}}
+	options.ConnectTo = nil
+	host = ""
+	if _, err := New(options).HashFromURL("http://vhost.test:" + port + "/favicon.ico"); err != nil {
+		t.Fatalf("HashFromURL() returned error: %v", err)
+	}
+	if host != "vhost.test:"+port || len(proxy.requests) != 1 {
+		t.Errorf("Expected a direct request for vhost.test, got Host %q and proxy requests %v", host, proxy.requests)
+	}
+}
+
+func TestDNSServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte{0, 0, 1, 0})
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	// A DNS server answering 127.0.0.1 to every A query
+	dns, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket() returned error: %v", err)
+	}
+	defer dns.Close()
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := dns.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var query dnsmessage.Message
+			if query.Unpack(buf[:n]) != nil || len(query.Questions) == 0 {
+				continue
+			}
+			reply := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: query.ID, Response: true, Authoritative: true},
+				Questions: query.Questions,
+			}
+			if q := query.Questions[0]; q.Type == dnsmessage.TypeA {
+				reply.Answers = []dnsmessage.Resource{{
+					Header: dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: q.Class, TTL: 60},
+					Body:   &dnsmessage.AResource{A: [4]byte{127, 0, 0, 1}},
+				}}
+			}
+			if packed, err := reply.Pack(); err == nil {
+				dns.WriteTo(packed, addr)
+			}
+		}
+	}()
+
+	options := DefaultOptions()
+	options.DNSServer = dns.LocalAddr().String()
+	if _, err := New(options).HashFromURL("http://icons.iconhash-test.invalid:" + port + "/favicon.ico"); err != nil {
+		t.Errorf("Expected the name to be resolved by the DNS server, got %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package hasher
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Favicon is an icon declared by a web page
+type Favicon struct {
+	// URL is the absolute URL of the icon
+	URL string `json:"url"`
+	// Rel is the rel attribute of the link element, or "default" for /favicon.ico
+	Rel string `json:"rel"`
+	// Type is the declared MIME type, if any
+	Type string `json:"type,omitempty"`
+	// Sizes is the declared sizes attribute, if any
+	Sizes string `json:"sizes,omitempty"`
+}
+
+// iconRels lists the link relations that declare favicons
+var iconRels = map[string]bool{
+	"icon":                         true,
+	"shortcut icon":                true,
+	"apple-touch-icon":             true,
+	"apple-touch-icon-precomposed": true,
+	"mask-icon":                    true,
+	"fluid-icon":                   true,
+}
+
+// DiscoverFavicons fetches a web page and returns the icons it declares with
+// <link> elements, followed by the conventional /favicon.ico of the site.
+// Relative links are resolved against the page reached after redirects and,
+// if the redirect policy allows it, meta refreshes.
+func (h *IconHasher) DiscoverFavicons(ctx context.Context, pageURL string) ([]Favicon, error) {
+	base, err := url.Parse(pageURL)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("invalid page URL: %s", pageURL)
+	}
+
+	// The page is HTML, so strict mode only applies to the icons themselves
+	page := h.WithOptions(func(o *HashOptions) { o.Strict = false })
+	policy := h.redirectPolicy()
+	first := base
+	var redirects []Redirect
+	for {
+		result, err := page.Fetch(ctx, base.String())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get content from URL: %w", err)
+		}
+		if final, err := url.Parse(result.FinalURL); err == nil && result.FinalURL != "" {
+			base = final
+		}
+		redirects = append(redirects, result.Redirects...)
+
+		if !policy.FollowMetaRefresh {
+			return ParseFavicons(result.Data, base), nil
+		}
+		target := ParseMetaRefresh(result.Data, base)
+		if target == nil || target.String() == base.String() {
+			return ParseFavicons(result.Data, base), nil
+		}
+
+		redirects = append(redirects, Redirect{URL: base.String()})
+		if base, err = policy.checkRedirect(first, base, target.String(), redirects); err != nil {
+			return nil, fmt.Errorf("failed to get content from URL: %w", err)
+		}
+	}
+}
+
+// ParseFavicons extracts the icons declared by an HTML document. Relative
+// links are resolved against base, or against a <base href> in the document.
+func ParseFavicons(data []byte, base *url.URL) []Favicon {
+	var icons []Favicon
+	seen := make(map[string]bool)
+
+	add := func(icon Favicon) {
+		if !seen[icon.URL] {
+			seen[icon.URL] = true
+			icons = append(icons, icon)
+		}
+	}
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(data))
+	for {
+		tt := tokenizer.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+
+		name, hasAttr := tokenizer.TagName()
+		if !hasAttr {
+			continue
+		}
+
+		attrs := make(map[string]string)
+		for {
+			key, val, more := tokenizer.TagAttr()
+			attrs[string(key)] = string(val)
+			if !more {
+				break
+			}
+		}
+
+		switch string(name) {
+		case "base":
+			if href, err := base.Parse(strings.TrimSpace(attrs["href"])); err == nil && attrs["href"] != "" {
+				base = href
+			}
+		case "link":
+			rel := strings.ToLower(strings.Join(strings.Fields(attrs["rel"]), " "))
+			href := strings.TrimSpace(attrs["href"])
+			if !iconRels[rel] || href == "" {
+				continue
+			}
+			u, err := base.Parse(href)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				continue
+			}
+			add(Favicon{URL: u.String(), Rel: rel, Type: attrs["type"], Sizes: attrs["sizes"]})
+		}
+	}
+
+	add(Favicon{URL: base.ResolveReference(&url.URL{Path: "/favicon.ico"}).String(), Rel: "default"})
+	return icons
+}
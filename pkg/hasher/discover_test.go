@@ -0,0 +1,72 @@
+package hasher
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestParseFavicons(t *testing.T) {
+	page := []byte(`<!DOCTYPE html>
+<html><head>
+<link rel="stylesheet" href="/style.css">
+<link rel="icon" type="image/png" sizes="32x32" href="/static/icon-32.png">
+<link rel="Shortcut  Icon" href="favicon.ico">
+<link rel="apple-touch-icon" href="https://cdn.example.net/touch.png">
+<link rel="icon" href="data:image/png;base64,AAAA">
+<link rel="icon" href="/static/icon-32.png">
+</head><body></body></html>`)
+
+	base, _ := url.Parse("https://example.com/app/index.html")
+	icons := ParseFavicons(page, base)
+
+	expected := []Favicon{
+		{URL: "https://example.com/static/icon-32.png", Rel: "icon", Type: "image/png", Sizes: "32x32"},
+		{URL: "https://example.com/app/favicon.ico", Rel: "shortcut icon"},
+		{URL: "https://cdn.example.net/touch.png", Rel: "apple-touch-icon"},
+		{URL: "https://example.com/favicon.ico", Rel: "default"},
+	}
+
+	if len(icons) != len(expected) {
+		t.Fatalf("Expected %d icons, got %d: %+v", len(expected), len(icons), icons)
+	}
+	for i := range expected {
+		if icons[i] != expected[i] {
+			t.Errorf("Icon %d = %+v, expected %+v", i, icons[i], expected[i])
+		}
+	}
+}
+
+func TestParseFaviconsBaseHref(t *testing.T) {
+	page := []byte(`<html><head><base href="https://static.example.org/assets/"><link rel="icon" href="icon.svg"></head></html>`)
+	base, _ := url.Parse("https://example.com/")
+
+	icons := ParseFavicons(page, base)
+	if len(icons) == 0 || icons[0].URL != "https://static.example.org/assets/icon.svg" {
+		t.Errorf("Expected icon resolved against <base href>, got %+v", icons)
+	}
+}
+
+func TestDiscoverFavicons(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<link rel="icon" href="/icon.png">`))
+	}))
+	defer server.Close()
+
+	// Strict mode rejects non-image icons, not the page declaring them
+	strict := DefaultOptions()
+	strict.Strict = true
+	icons, err := New(strict).DiscoverFavicons(context.Background(), server.URL+"/page")
+	if err != nil {
+		t.Fatalf("DiscoverFavicons() returned error: %v", err)
+	}
+	if len(icons) != 2 || icons[0].URL != server.URL+"/icon.png" || icons[1].URL != server.URL+"/favicon.ico" {
+		t.Errorf("Unexpected icons: %+v", icons)
+	}
+
+	if _, err := New(nil).DiscoverFavicons(context.Background(), "ftp://example.com/"); err == nil {
+		t.Error("Expected error for a non-HTTP URL")
+	}
+}
@@ -0,0 +1,137 @@
+package hasher
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Hash modes select which bytes of a fetched favicon are hashed
+const (
+	// HashDecoded hashes the body after undoing its Content-Encoding, as
+	// search engines do
+	HashDecoded = "decoded"
+	// HashRaw hashes the bytes as they were transferred
+	HashRaw = "raw"
+	// HashBoth hashes the decoded body and reports the raw hash as well
+	HashBoth = "both"
+)
+
+// HashModes lists the valid hash modes
+var HashModes = []string{HashDecoded, HashRaw, HashBoth}
+
+// DefaultAcceptEncoding is the Accept-Encoding header sent with fetches. Every
+// encoding it lists is decoded.
+const DefaultAcceptEncoding = "gzip, deflate, br"
+
+// ValidHashMode reports whether mode is a hash mode; "" means HashDecoded
+func ValidHashMode(mode string) bool {
+	if mode == "" {
+		return true
+	}
+	for _, m := range HashModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// EncodingError is returned when a body cannot be decoded, either because
+// its Content-Encoding is not supported or because it is corrupt
+type EncodingError struct {
+	Encoding string
+	Err      error
+}
+
+// Error implements the error interface
+func (e *EncodingError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("unsupported Content-Encoding %q", e.Encoding)
+	}
+	return fmt.Sprintf("failed to decode %s body: %v", e.Encoding, e.Err)
+}
+
+// Unwrap returns the decoding error
+func (e *EncodingError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeContent undoes a Content-Encoding, which may list several codings in
+// the order they were applied. gzip, deflate and br are supported; identity
+// and an empty encoding return data unchanged. A body that decodes to more
+// than DefaultMaxBodySize bytes returns a BodyTooLargeError.
+func DecodeContent(data []byte, contentEncoding string) ([]byte, error) {
+	return decodeContent(data, contentEncoding, DefaultMaxBodySize)
+}
+
+// decodeContent is DecodeContent with a limit on the size of each decoded
+// coding, so that a small compressed body cannot expand without bound
+func decodeContent(data []byte, contentEncoding string, limit int64) ([]byte, error) {
+	codings := strings.Split(contentEncoding, ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		coding := strings.ToLower(strings.TrimSpace(codings[i]))
+
+		var (
+			r   io.Reader
+			err error
+		)
+		switch coding {
+		case "", "identity":
+			continue
+		case "gzip", "x-gzip":
+			r, err = gzip.NewReader(bytes.NewReader(data))
+		case "deflate":
+			r, err = deflateReader(data)
+		case "br":
+			r = brotli.NewReader(bytes.NewReader(data))
+		default:
+			return nil, &EncodingError{Encoding: coding}
+		}
+		if err != nil {
+			return nil, &EncodingError{Encoding: coding, Err: err}
+		}
+		if data, err = io.ReadAll(io.LimitReader(r, limit+1)); err != nil {
+			return nil, &EncodingError{Encoding: coding, Err: err}
+		}
+		if int64(len(data)) > limit {
+			return nil, &BodyTooLargeError{Limit: limit, Decoded: true}
+		}
+	}
+	return data, nil
+}
+
+// deflateReader reads a deflate body, which should be zlib wrapped but is
+// sent as a raw deflate stream by some servers
+func deflateReader(data []byte) (io.Reader, error) {
+	if r, err := zlib.NewReader(bytes.NewReader(data)); err == nil {
+		return r, nil
+	}
+	return flate.NewReader(bytes.NewReader(data)), nil
+}
+
+// HashFetched hashes a fetched favicon according to the hash mode of the
+// options. In HashBoth mode, rawHash is the hash of the transferred bytes;
+// otherwise it is empty and hash covers the bytes the mode selects.
+func (h *IconHasher) HashFetched(result *FetchResult) (hash, rawHash string, err error) {
+	switch h.options.HashMode {
+	case HashRaw:
+		hash, err = h.HashFromBytes(result.Raw)
+		return hash, "", err
+	case HashBoth:
+		if hash, err = h.HashFromBytes(result.Data); err != nil {
+			return "", "", err
+		}
+		rawHash, err = h.HashFromBytes(result.Raw)
+		return hash, rawHash, err
+	default:
+		hash, err = h.HashFromBytes(result.Data)
+		return hash, "", err
+	}
+}
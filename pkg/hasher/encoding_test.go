@@ -0,0 +1,183 @@
+package hasher
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/cyberspacesec/go-iconhash/pkg/cache"
+)
+
+// compress encodes data with a writer of the given coding
+func compress(t *testing.T, coding string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch coding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	}
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestDecodeContent(t *testing.T) {
+	icon := []byte{0, 0, 1, 0, 1, 0, 16, 16}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		encoding string
+	}{
+		{"Identity", icon, ""},
+		{"Gzip", compress(t, "gzip", icon), "gzip"},
+		{"Deflate", compress(t, "deflate", icon), "deflate"},
+		{"Raw deflate", compress(t, "raw-deflate", icon), "deflate"},
+		{"Brotli", compress(t, "br", icon), "br"},
+		{"Several codings", compress(t, "br", compress(t, "gzip", icon)), "gzip, br"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			decoded, err := DecodeContent(test.data, test.encoding)
+			if err != nil || !bytes.Equal(decoded, icon) {
+				t.Errorf("DecodeContent() = %v, %v", decoded, err)
+			}
+		})
+	}
+
+	var encodingErr *EncodingError
+	if _, err := DecodeContent(icon, "zstd"); !errors.As(err, &encodingErr) || encodingErr.Err != nil {
+		t.Errorf("Expected an unsupported encoding error, got %v", err)
+	}
+	if _, err := DecodeContent(icon, "gzip"); !errors.As(err, &encodingErr) || FailureReason(err) != ReasonEncoding {
+		t.Errorf("Expected a decoding error, got %v", err)
+	}
+}
+
+func TestFetchEncoding(t *testing.T) {
+	icon := []byte{0, 0, 1, 0, 1, 0, 16, 16}
+	gzipped := compress(t, "gzip", icon)
+
+	var acceptEncoding string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptEncoding = r.Header.Get("Accept-Encoding")
+		if r.URL.Path == "/zstd.ico" {
+			w.Header().Set("Content-Encoding", "zstd")
+			w.Write(icon)
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(gzipped)
+	}))
+	defer server.Close()
+
+	h := New(DefaultOptions())
+	result, err := h.Fetch(context.Background(), server.URL+"/favicon.ico")
+	if err != nil {
+		t.Fatalf("Fetch() returned error: %v", err)
+	}
+	if acceptEncoding != DefaultAcceptEncoding {
+		t.Errorf("Expected Accept-Encoding %q, got %q", DefaultAcceptEncoding, acceptEncoding)
+	}
+	if !bytes.Equal(result.Data, icon) || !bytes.Equal(result.Raw, gzipped) || result.ContentEncoding != "gzip" {
+		t.Errorf("Expected the decoded and raw gzip body, got %+v", result)
+	}
+
+	decodedHash, _ := h.HashFromBytes(icon)
+	rawHash, _ := h.HashFromBytes(gzipped)
+	tests := []struct {
+		mode        string
+		expected    string
+		expectedRaw string
+	}{
+		{"", decodedHash, ""},
+		{HashDecoded, decodedHash, ""},
+		{HashRaw, rawHash, ""},
+		{HashBoth, decodedHash, rawHash},
+	}
+	for _, test := range tests {
+		mode := h.WithOptions(func(o *HashOptions) { o.HashMode = test.mode })
+		if hash, raw, err := mode.HashFetched(result); err != nil || hash != test.expected || raw != test.expectedRaw {
+			t.Errorf("HashFetched() in mode %q = %s, %s, %v", test.mode, hash, raw, err)
+		}
+	}
+
+	// Cached entries keep the transferred bytes and their encoding
+	c, err := cache.Open(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("Open() returned error: %v", err)
+	}
+	cached := h.WithOptions(func(o *HashOptions) { o.Cache = c })
+	cached.Fetch(context.Background(), server.URL+"/favicon.ico")
+	hit, err := cached.Fetch(context.Background(), server.URL+"/favicon.ico")
+	if err != nil || hit.Cache != CacheHit || !bytes.Equal(hit.Raw, gzipped) || hit.ContentEncoding != "gzip" {
+		t.Errorf("Expected a cache hit with the raw gzip body, got %+v, %v", hit, err)
+	}
+
+	// Unsupported encodings fail unless only the raw bytes are hashed
+	identity := h.WithOptions(func(o *HashOptions) { o.AcceptEncoding = "identity" })
+	if _, err := identity.HashFromURL(server.URL + "/zstd.ico"); err == nil {
+		t.Error("Expected an error for an unsupported encoding")
+	}
+	if acceptEncoding != "identity" {
+		t.Errorf("Expected Accept-Encoding identity, got %q", acceptEncoding)
+	}
+	raw := h.WithOptions(func(o *HashOptions) { o.HashMode = HashRaw })
+	if hash, err := raw.HashFromURL(server.URL + "/zstd.ico"); err != nil || hash != decodedHash {
+		t.Errorf("Expected the raw hash, got %s, %v", hash, err)
+	}
+}
+
+func TestBodySizeLimit(t *testing.T) {
+	// 64 MiB of zeros compress to about 64 KiB
+	var bomb bytes.Buffer
+	w := gzip.NewWriter(&bomb)
+	zeros := make([]byte, 1<<20)
+	for i := 0; i < 64; i++ {
+		w.Write(zeros)
+	}
+	w.Close()
+
+	if _, err := DecodeContent(bomb.Bytes(), "gzip"); FailureReason(err) != ReasonTooLarge {
+		t.Errorf("Expected DecodeContent() to refuse the bomb, got %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/large.ico" {
+			w.Write(make([]byte, 2048))
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(bomb.Bytes())
+	}))
+	defer server.Close()
+
+	h := New(DefaultOptions())
+	var sizeErr *BodyTooLargeError
+	if _, err := h.Fetch(context.Background(), server.URL+"/bomb.ico"); !errors.As(err, &sizeErr) || !sizeErr.Decoded || sizeErr.Limit != DefaultMaxBodySize {
+		t.Errorf("Expected a decoded body too large error, got %v", err)
+	}
+
+	small := h.WithOptions(func(o *HashOptions) { o.MaxBodySize = 1024 })
+	if _, err := small.Fetch(context.Background(), server.URL+"/large.ico"); !errors.As(err, &sizeErr) || sizeErr.Decoded || sizeErr.Limit != 1024 {
+		t.Errorf("Expected a body too large error, got %v", err)
+	}
+	if _, err := small.Fetch(context.Background(), server.URL+"/bomb.ico"); !errors.As(err, &sizeErr) || sizeErr.Decoded {
+		t.Errorf("Expected the transferred body to exceed the limit, got %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package hasher
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"time"
+)
+
+// Failure reasons reported by FailureReason
+const (
+	ReasonTimeout           = "timeout"
+	ReasonCanceled          = "canceled"
+	ReasonDNS               = "dns"
+	ReasonConnectionRefused = "connection_refused"
+	ReasonConnectionReset   = "connection_reset"
+	ReasonTLS               = "tls"
+	ReasonHTTPStatus        = "http_status"
+	ReasonHostLimited       = "host_limited"
+	ReasonNotImage          = "not_image"
+	ReasonEncoding          = "encoding"
+	ReasonTooLarge          = "too_large"
+	ReasonRedirect          = "redirect"
+	ReasonOther             = "other"
+)
+
+// StatusError is returned when a fetch receives an unexpected HTTP status code
+type StatusError struct {
+	StatusCode int
+	// RetryAfter is the delay requested by a Retry-After header, if any
+	RetryAfter time.Duration
+}
+
+// Error implements the error interface
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("HTTP request failed with status code: %d", e.StatusCode)
+}
+
+// HostLimitError is returned when the HostLimiter refuses a fetch
+type HostLimitError struct {
+	Host       string
+	RetryAfter time.Duration
+}
+
+// Error implements the error interface
+func (e *HostLimitError) Error() string {
+	return fmt.Sprintf("too many requests to %s, retry after %v", e.Host, e.RetryAfter.Round(time.Millisecond))
+}
+
+// BodyTooLargeError is returned when a response body, as transferred or once
+// decoded, is larger than the MaxBodySize of the options
+type BodyTooLargeError struct {
+	Limit int64
+	// Decoded is set when the body only exceeded the limit once decoded
+	Decoded bool
+}
+
+// Error implements the error interface
+func (e *BodyTooLargeError) Error() string {
+	if e.Decoded {
+		return fmt.Sprintf("decoded response body exceeds the limit of %d bytes", e.Limit)
+	}
+	return fmt.Sprintf("response body exceeds the limit of %d bytes", e.Limit)
+}
+
+// FailureReason classifies a fetch error into a short, stable reason string
+// suitable for metrics labels
+func FailureReason(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var (
+		statusErr   *StatusError
+		limitErr    *HostLimitError
+		typeErr     *ContentTypeError
+		encodingErr *EncodingError
+		sizeErr     *BodyTooLargeError
+		redirectErr *RedirectError
+		dnsErr      *net.DNSError
+		netErr      net.Error
+		recordErr   tls.RecordHeaderError
+		verifyErr   *tls.CertificateVerificationError
+		unknownErr  x509.UnknownAuthorityError
+		hostnameErr x509.HostnameError
+		invalidErr  x509.CertificateInvalidError
+	)
+
+	switch {
+	case errors.As(err, &statusErr):
+		return ReasonHTTPStatus
+	case errors.As(err, &limitErr):
+		return ReasonHostLimited
+	case errors.As(err, &typeErr):
+		return ReasonNotImage
+	case errors.As(err, &sizeErr):
+		return ReasonTooLarge
+	case errors.As(err, &encodingErr):
+		return ReasonEncoding
+	case errors.As(err, &redirectErr):
+		return ReasonRedirect
+	case errors.Is(err, context.Canceled):
+		return ReasonCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return ReasonTimeout
+	case errors.As(err, &dnsErr):
+		return ReasonDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ReasonConnectionRefused
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE),
+		errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return ReasonConnectionReset
+	case errors.As(err, &recordErr), errors.As(err, &verifyErr), errors.As(err, &unknownErr),
+		errors.As(err, &hostnameErr), errors.As(err, &invalidErr):
+		return ReasonTLS
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return ReasonTimeout
+	default:
+		return ReasonOther
+	}
+}
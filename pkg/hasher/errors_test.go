@@ -0,0 +1,46 @@
+package hasher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+)
+
+func TestFailureReason(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{"Nil error", nil, ""},
+		{"Status error", fmt.Errorf("wrapped: %w", &StatusError{StatusCode: 404}), ReasonHTTPStatus},
+		{"Host limited", fmt.Errorf("wrapped: %w", &HostLimitError{Host: "example.com"}), ReasonHostLimited},
+		{"Not an image", &ContentTypeError{Type: TypeHTML}, ReasonNotImage},
+		{"Canceled", context.Canceled, ReasonCanceled},
+		{"Deadline exceeded", context.DeadlineExceeded, ReasonTimeout},
+		{"DNS error", &net.DNSError{Err: "no such host", Name: "example.invalid"}, ReasonDNS},
+		{"Connection refused", &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, ReasonConnectionRefused},
+		{"Connection reset", &net.OpError{Op: "read", Err: syscall.ECONNRESET}, ReasonConnectionReset},
+		{"Unexpected EOF", fmt.Errorf("wrapped: %w", io.ErrUnexpectedEOF), ReasonConnectionReset},
+		{"Other error", errors.New("boom"), ReasonOther},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if reason := FailureReason(test.err); reason != test.expected {
+				t.Errorf("FailureReason(%v) = %q, expected %q", test.err, reason, test.expected)
+			}
+		})
+	}
+}
+
+func TestStatusErrorMessage(t *testing.T) {
+	err := &StatusError{StatusCode: 503}
+	if err.Error() != "HTTP request failed with status code: 503" {
+		t.Errorf("Unexpected error message: %q", err.Error())
+	}
+}
@@ -0,0 +1,57 @@
+package hasher
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+)
+
+// FetchResult is the content of a URL and the attempts it took to fetch it
+type FetchResult struct {
+	URL string
+	// Data is the decoded body, and Raw the body as transferred, which
+	// differ when the server sent a Content-Encoding
+	Data            []byte
+	Raw             []byte
+	ContentEncoding string
+	Attempts        []Attempt
+	// Cache is CacheHit, CacheRevalidated or CacheMiss when a cache is set
+	Cache string
+	// ContentType is the declared Content-Type header, and Type the type
+	// detected by DetectContentType
+	ContentType string
+	Type        string
+	// Warning explains why the content is probably not a favicon, or is
+	// empty for images
+	Warning string
+	// FinalURL is the URL the content was fetched from, and Redirects the
+	// redirects followed to reach it
+	FinalURL  string
+	Redirects []Redirect
+	// Header and TLS are the headers and TLS connection state of the final
+	// response; both are nil for content served from the cache, and TLS is
+	// nil for plain HTTP
+	Header http.Header
+	TLS    *tls.ConnectionState
+	// StatusCode is the HTTP status of the final response, which is 200
+	// unless the options set AnyStatus, and for content from the cache
+	StatusCode int
+}
+
+// Fetch downloads the content of a URL, retrying failed attempts as allowed
+// by the retry policy of the options. The result records every attempt.
+// With a cache, fresh entries are served without a request and stale ones
+// are revalidated. In strict mode, content that is not an image is an error.
+func (h *IconHasher) Fetch(ctx context.Context, url string) (*FetchResult, error) {
+	result, err := h.fetchCached(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Type = DetectContentType(result.Data, result.ContentType)
+	result.Warning = contentTypeWarning(result.Type, result.ContentType)
+	if h.options.Strict && !IsImage(result.Type) {
+		return nil, &ContentTypeError{Type: result.Type, ContentType: result.ContentType}
+	}
+	return result, nil
+}
@@ -2,15 +2,19 @@ package hasher
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/base64"
 	"fmt"
 	"hash"
 	"io"
 	"net/http"
+	neturl "net/url"
 	"os"
 	"strings"
 	"time"
 
+	"github.com/cyberspacesec/go-iconhash/pkg/cache"
 	"github.com/twmb/murmur3"
 )
 
@@ -20,6 +24,82 @@ type HashOptions struct {
 	RequestTimeout     time.Duration
 	InsecureSkipVerify bool
 	UserAgent          string
+	// Observer receives instrumentation events, if set
+	Observer Observer
+	// HostLimiter throttles fetches per destination host, if set
+	HostLimiter HostLimiter
+	// Proxy chooses the proxy of each fetch. When nil, the HTTP_PROXY,
+	// HTTPS_PROXY and NO_PROXY environment variables are used.
+	Proxy *ProxySelector
+	// Headers are sent with every fetch. They override UserAgent, and a Host
+	// header sets the requested virtual host.
+	Headers http.Header
+	// Cookies supplies cookies for fetches, usually from LoadCookieFile
+	Cookies http.CookieJar
+	// Auth holds basic or bearer credentials sent with every fetch
+	Auth *Credentials
+	// ClientCert is presented to servers requesting mutual TLS
+	ClientCert *tls.Certificate
+	// CredentialHosts restricts Headers, Cookies, Auth and ClientCert to
+	// the hosts matching one of the patterns: a domain, which also matches
+	// its subdomains, "*.example.com" for subdomains only, or "*". Redirects
+	// to other hosts are fetched without them. Empty sends them everywhere.
+	CredentialHosts []string
+	// Retry decides whether failed fetches are retried. Nil fetches once.
+	Retry *RetryPolicy
+	// Cache serves fetches from disk and revalidates stale entries, if set.
+	// Fetches with Headers, Cookies, Auth, ClientCert, Resolve, ConnectTo or
+	// an AcceptEncoding other than DefaultAcceptEncoding bypass it.
+	Cache *cache.Cache
+	// RefreshCache fetches every URL again, ignoring cached entries but
+	// storing the new ones
+	RefreshCache bool
+	// Strict rejects responses that are not images, such as HTML error
+	// pages served with 200 OK, with a ContentTypeError
+	Strict bool
+	// HashMode is HashDecoded, the default, HashRaw or HashBoth
+	HashMode string
+	// AcceptEncoding is the Accept-Encoding header of fetches, by default
+	// DefaultAcceptEncoding; "identity" asks for an uncompressed body
+	AcceptEncoding string
+	// Redirect decides which redirects are followed. Nil uses
+	// DefaultRedirectPolicy.
+	Redirect *RedirectPolicy
+	// Resolve and ConnectTo override the addresses connected to for some
+	// hosts, keeping the URL's host for TLS and the Host header. The hosts
+	// they apply to are connected to directly, bypassing Proxy.
+	Resolve   []ResolveRule
+	ConnectTo []ConnectToRule
+	// DNSServer is the host:port of the DNS server that resolves fetched
+	// hosts; the port defaults to 53. Empty uses the system resolver.
+	DNSServer string
+	// AnyStatus reads the final response of a fetch whatever its status
+	// code instead of failing with a StatusError; FetchResult.StatusCode
+	// reports it. No status is retried, and the cache is bypassed.
+	AnyStatus bool
+	// MaxBodySize caps the size of response bodies, both as transferred and
+	// once decoded; larger bodies fail with a BodyTooLargeError. Zero uses
+	// DefaultMaxBodySize.
+	MaxBodySize int64
+}
+
+// DefaultMaxBodySize is the default limit on the size of response bodies
+const DefaultMaxBodySize = 10 << 20
+
+// HostLimiter decides whether a fetch from a destination host may proceed
+type HostLimiter interface {
+	// Allow reports whether a fetch from host may proceed now and, if not,
+	// how long to wait before retrying
+	Allow(host string) (bool, time.Duration)
+}
+
+// Observer receives instrumentation events from an IconHasher
+type Observer interface {
+	// ObserveFetch is called after every outbound fetch with its duration and
+	// error, which is nil on success
+	ObserveFetch(duration time.Duration, err error)
+	// ObserveHash is called with the number of bytes fed to the hash function
+	ObserveHash(size int)
 }
 
 // DefaultOptions returns a HashOptions with sensible defaults
@@ -36,6 +116,9 @@ func DefaultOptions() *HashOptions {
 type IconHasher struct {
 	options    *HashOptions
 	httpClient *http.Client
+	// anonClient has neither the cookie jar nor the client certificate of
+	// httpClient; it is nil when the options have neither
+	anonClient *http.Client
 }
 
 // New creates a new IconHasher with the given options
@@ -45,27 +128,88 @@ func New(options *HashOptions) *IconHasher {
 	}
 
 	transport := http.DefaultTransport.(*http.Transport).Clone()
-	if transport.TLSClientConfig != nil {
-		transport.TLSClientConfig.InsecureSkipVerify = options.InsecureSkipVerify
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: options.InsecureSkipVerify}
+	// Bodies are decoded by fetch, so that the raw bytes can be hashed too
+	transport.DisableCompression = true
+	if options.Proxy != nil {
+		transport.Proxy = options.Proxy.Proxy
+	}
+	if d := newDialer(options); d != nil {
+		transport.DialContext = d.DialContext
+		transport.Proxy = d.bypassProxy(transport.Proxy)
 	}
 
-	return &IconHasher{
-		options: options,
-		httpClient: &http.Client{
-			Timeout:   options.RequestTimeout,
-			Transport: transport,
+	h := &IconHasher{options: options}
+	if options.Cookies == nil && options.ClientCert == nil {
+		h.httpClient = newClient(options, transport, nil)
+		return h
+	}
+
+	h.anonClient = newClient(options, transport, nil)
+	transport = transport.Clone()
+	if options.ClientCert != nil {
+		transport.TLSClientConfig.Certificates = []tls.Certificate{*options.ClientCert}
+	}
+	h.httpClient = newClient(options, transport, options.Cookies)
+	return h
+}
+
+// newClient returns an HTTP client sending requests through transport
+func newClient(options *HashOptions, transport http.RoundTripper, jar http.CookieJar) *http.Client {
+	return &http.Client{
+		Timeout:   options.RequestTimeout,
+		Transport: transport,
+		Jar:       jar,
+		// Redirects are followed by fetch, which records them and applies
+		// the redirect policy of each request
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
 		},
 	}
 }
 
+// WithOptions returns a copy of the hasher whose options are modified by update.
+// The copy shares the HTTP client, so only settings applied per request (such as
+// UseUint32, UserAgent, Headers or Auth) take effect. Cookies and ClientCert
+// can be removed but not replaced.
+func (h *IconHasher) WithOptions(update func(*HashOptions)) *IconHasher {
+	options := *h.options
+	update(&options)
+
+	return &IconHasher{
+		options:    &options,
+		httpClient: h.httpClient,
+		anonClient: h.anonClient,
+	}
+}
+
+// clientFor returns the HTTP client of a request to host, which carries the
+// cookie jar and client certificate only if they are sent to host
+func (h *IconHasher) clientFor(host string) *http.Client {
+	if h.anonClient == nil {
+		return h.httpClient
+	}
+	if (h.options.Cookies == nil && h.options.ClientCert == nil) || !h.sendsCredentials(host) {
+		return h.anonClient
+	}
+	return h.httpClient
+}
+
 // HashFromURL downloads and calculates the hash of an icon from a URL
 func (h *IconHasher) HashFromURL(url string) (string, error) {
-	data, err := h.getContentFromURL(url)
+	return h.HashFromURLContext(context.Background(), url)
+}
+
+// HashFromURLContext is like HashFromURL but aborts the download, and any
+// retries, when ctx is done
+func (h *IconHasher) HashFromURLContext(ctx context.Context, url string) (string, error) {
+	result, err := h.Fetch(ctx, url)
 	if err != nil {
 		return "", fmt.Errorf("failed to get content from URL: %w", err)
 	}
 
-	return h.HashFromBytes(data)
+	hash, _, err := h.HashFetched(result)
+	return hash, err
 }
 
 // HashFromFile calculates the hash of an icon from a file
@@ -97,26 +241,164 @@ func (h *IconHasher) HashFromBytes(data []byte) (string, error) {
 	return h.calculateHash(encodedBytes)
 }
 
-// getContentFromURL fetches content from a URL
-func (h *IconHasher) getContentFromURL(url string) ([]byte, error) {
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
+// getContentOnce makes a single attempt to fetch content from a URL,
+// revalidating the cached entry if there is one
+func (h *IconHasher) getContentOnce(ctx context.Context, url string, cached *cache.Entry) (*response, error) {
+	start := time.Now()
+	resp, err := h.fetch(ctx, url, cached)
+	if h.options.Observer != nil {
+		h.options.Observer.ObserveFetch(time.Since(start), err)
 	}
+	return resp, err
+}
 
-	req.Header.Set("User-Agent", h.options.UserAgent)
+// checkHostLimit consults the host limiter before a request is sent, for the
+// first URL of a fetch and for every redirect it follows
+func (h *IconHasher) checkHostLimit(rawURL string) error {
+	if h.options.HostLimiter == nil {
+		return nil
+	}
 
-	resp, err := h.httpClient.Do(req)
+	u, err := neturl.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		// Let the request itself report malformed URLs
+		return nil
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if ok, retryAfter := h.options.HostLimiter.Allow(host); !ok {
+		return &HostLimitError{Host: host, RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// response is the outcome of a successful fetch
+type response struct {
+	// data is the decoded body, and raw the body as transferred
+	data         []byte
+	raw          []byte
+	encoding     string
+	etag         string
+	lastModified string
+	contentType  string
+	statusCode   int
+	// notModified reports that the server confirmed the cached entry
+	notModified bool
+	// noStore reports that the server asked not to cache the response
+	noStore bool
+	// redirects lists the redirects followed to finalURL
+	redirects []Redirect
+	finalURL  string
+	// header and tls are the headers and TLS state of the final response
+	header http.Header
+	tls    *tls.ConnectionState
+}
+
+// fetch performs the HTTP requests of one attempt of getContentOnce,
+// following redirects as allowed by the redirect policy. With a cached entry, the request is
+// conditional on the entry's validators.
+func (h *IconHasher) fetch(ctx context.Context, url string, cached *cache.Entry) (*response, error) {
+	first, err := neturl.Parse(url)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP request failed with status code: %d", resp.StatusCode)
+	policy := h.redirectPolicy()
+	current := first
+	var redirects []Redirect
+	for {
+		if err := h.checkHostLimit(current.String()); err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequestWithContext(ctx, "GET", current.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		h.prepareRequest(req)
+		if !strings.EqualFold(current.Host, first.Host) {
+			stripCredentials(req)
+		}
+		if cached != nil {
+			if cached.ETag != "" {
+				req.Header.Set("If-None-Match", cached.ETag)
+			}
+			if cached.LastModified != "" {
+				req.Header.Set("If-Modified-Since", cached.LastModified)
+			}
+		}
+
+		resp, err := h.clientFor(current.Hostname()).Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if !isRedirect(resp.StatusCode) {
+			result, err := h.readResponse(resp, cached)
+			resp.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			result.redirects, result.finalURL = redirects, current.String()
+			return result, nil
+		}
+
+		// Drain a little of the body so that the connection can be reused
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		redirects = append(redirects, Redirect{URL: current.String(), StatusCode: resp.StatusCode})
+		if current, err = policy.checkRedirect(first, current, resp.Header.Get("Location"), redirects); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readResponse reads the final response of a fetch
+func (h *IconHasher) readResponse(resp *http.Response, cached *cache.Entry) (*response, error) {
+	result := &response{
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+		contentType:  resp.Header.Get("Content-Type"),
+		statusCode:   resp.StatusCode,
+		encoding:     resp.Header.Get("Content-Encoding"),
+		noStore:      strings.Contains(strings.ToLower(resp.Header.Get("Cache-Control")), "no-store"),
+		header:       resp.Header,
+		tls:          resp.TLS,
+	}
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		result.notModified = true
+		return result, nil
+	}
+	if resp.StatusCode != http.StatusOK && !h.options.AnyStatus {
+		return nil, &StatusError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
 	}
 
-	return io.ReadAll(resp.Body)
+	limit := h.maxBodySize()
+	var err error
+	if result.raw, err = io.ReadAll(io.LimitReader(resp.Body, limit+1)); err != nil {
+		return nil, err
+	}
+	if int64(len(result.raw)) > limit {
+		return nil, &BodyTooLargeError{Limit: limit}
+	}
+	if result.data, err = decodeContent(result.raw, result.encoding, limit); err != nil {
+		// The raw bytes can still be hashed
+		if h.options.HashMode != HashRaw {
+			return nil, err
+		}
+		result.data = result.raw
+	}
+	return result, nil
+}
+
+// maxBodySize returns the limit on the size of response bodies
+func (h *IconHasher) maxBodySize() int64 {
+	if h.options.MaxBodySize > 0 {
+		return h.options.MaxBodySize
+	}
+	return DefaultMaxBodySize
 }
 
 // standardBase64Encode encodes bytes to base64 and formats with newlines
@@ -152,6 +434,10 @@ func (h *IconHasher) calculateHash(data []byte) (string, error) {
 		return "", fmt.Errorf("failed to calculate hash: %w", err)
 	}
 
+	if h.options.Observer != nil {
+		h.options.Observer.ObserveHash(len(data))
+	}
+
 	if h.options.UseUint32 {
 		return fmt.Sprintf("%d", h32.Sum32()), nil
 	}
@@ -1,6 +1,7 @@
 package hasher
 
 import (
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -93,6 +94,78 @@ func TestHashFromURL(t *testing.T) {
 	}
 }
 
+// denyLimiter is a HostLimiter that refuses every host, or only the host
+// named by deny if it is set
+type denyLimiter struct {
+	deny  string
+	hosts []string
+}
+
+func (l *denyLimiter) Allow(host string) (bool, time.Duration) {
+	l.hosts = append(l.hosts, host)
+	if l.deny != "" && host != l.deny {
+		return true, 0
+	}
+	return false, time.Second
+}
+
+func TestHashFromURLHostLimit(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte{0, 0, 1, 0})
+	}))
+	defer server.Close()
+
+	limiter := &denyLimiter{}
+	options := DefaultOptions()
+	options.HostLimiter = limiter
+
+	_, err := New(options).HashFromURL(server.URL)
+
+	var limitErr *HostLimitError
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("HashFromURL() error = %v, expected HostLimitError", err)
+	}
+	if limitErr.Host != "127.0.0.1" || limitErr.RetryAfter != time.Second {
+		t.Errorf("Unexpected HostLimitError: %+v", limitErr)
+	}
+	if requests != 0 {
+		t.Errorf("Expected no request to reach the server, got %d", requests)
+	}
+}
+
+func TestHostLimitRedirects(t *testing.T) {
+	requests := 0
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte{0, 0, 1, 0})
+	}))
+	defer other.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, strings.Replace(other.URL, "127.0.0.1", "localhost", 1)+"/favicon.ico", http.StatusFound)
+	}))
+	defer server.Close()
+
+	// The redirect to another host is throttled like a first request
+	limiter := &denyLimiter{deny: "localhost"}
+	options := DefaultOptions()
+	options.HostLimiter = limiter
+
+	_, err := New(options).HashFromURL(server.URL)
+
+	var limitErr *HostLimitError
+	if !errors.As(err, &limitErr) || limitErr.Host != "localhost" {
+		t.Fatalf("HashFromURL() error = %v, expected HostLimitError for localhost", err)
+	}
+	if len(limiter.hosts) != 2 || limiter.hosts[0] != "127.0.0.1" {
+		t.Errorf("Expected both hops to be checked, got %v", limiter.hosts)
+	}
+	if requests != 0 {
+		t.Errorf("Expected no request to reach the redirect target, got %d", requests)
+	}
+}
+
 func TestHashFromFile(t *testing.T) {
 	// Create a temporary test file
 	tempFile, err := os.CreateTemp("", "favicon-test-*.ico")
@@ -0,0 +1,141 @@
+package hasher
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"sync/atomic"
+)
+
+// DirectProxy is the proxy of rules whose hosts are fetched without a proxy
+const DirectProxy = "direct"
+
+// ProxyRule routes the hosts matching Pattern through a proxy
+type ProxyRule struct {
+	// Pattern is a domain, which also matches its subdomains ("example.com"),
+	// a wildcard for subdomains only ("*.example.com"), a CIDR range for IP
+	// addresses ("10.0.0.0/8"), or "*" for every host
+	Pattern string
+	// Proxy is a proxy URL, or DirectProxy to bypass proxies
+	Proxy string
+}
+
+// ParseProxyRule parses a rule written as "pattern=proxy", such as
+// "*.corp.example=socks5://jump:1080" or "localhost=direct"
+func ParseProxyRule(s string) (ProxyRule, error) {
+	pattern, proxy, ok := strings.Cut(s, "=")
+	pattern, proxy = strings.TrimSpace(pattern), strings.TrimSpace(proxy)
+	if !ok || pattern == "" || proxy == "" {
+		return ProxyRule{}, fmt.Errorf("invalid proxy rule %q: expected pattern=proxy", s)
+	}
+	return ProxyRule{Pattern: pattern, Proxy: proxy}, nil
+}
+
+// proxyRoute is a parsed ProxyRule
+type proxyRoute struct {
+	pattern string
+	network *net.IPNet
+	proxy   *neturl.URL // nil for direct connections
+}
+
+// ProxySelector chooses the proxy of each outbound request. Rules are
+// checked in order; hosts matching no rule use the proxies in rotation, or
+// the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables when no
+// proxies are configured.
+type ProxySelector struct {
+	routes  []proxyRoute
+	proxies []*neturl.URL
+	next    atomic.Uint64
+}
+
+// NewProxySelector validates the proxy URLs and rules. Proxies may be
+// http, https, socks5 or socks5h URLs, with credentials as user:password.
+func NewProxySelector(proxies []string, rules []ProxyRule) (*ProxySelector, error) {
+	s := &ProxySelector{}
+	for _, raw := range proxies {
+		u, err := parseProxyURL(raw)
+		if err != nil {
+			return nil, err
+		}
+		s.proxies = append(s.proxies, u)
+	}
+
+	for _, rule := range rules {
+		route := proxyRoute{pattern: strings.ToLower(rule.Pattern)}
+		if strings.Contains(route.pattern, "/") {
+			_, network, err := net.ParseCIDR(route.pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid proxy rule pattern %q: %w", rule.Pattern, err)
+			}
+			route.network = network
+		}
+		if !strings.EqualFold(rule.Proxy, DirectProxy) {
+			u, err := parseProxyURL(rule.Proxy)
+			if err != nil {
+				return nil, err
+			}
+			route.proxy = u
+		}
+		s.routes = append(s.routes, route)
+	}
+	return s, nil
+}
+
+// parseProxyURL parses and validates a proxy URL
+func parseProxyURL(raw string) (*neturl.URL, error) {
+	u, err := neturl.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid proxy URL %s: unsupported scheme %q (expected http, https, socks5 or socks5h)", u.Redacted(), u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %s: missing host", u.Redacted())
+	}
+	return u, nil
+}
+
+// Proxy returns the proxy for a request, or nil for a direct connection. It
+// has the signature of http.Transport.Proxy.
+func (s *ProxySelector) Proxy(req *http.Request) (*neturl.URL, error) {
+	host := strings.ToLower(req.URL.Hostname())
+	for _, route := range s.routes {
+		if route.matches(host) {
+			return route.proxy, nil
+		}
+	}
+
+	if len(s.proxies) == 0 {
+		return http.ProxyFromEnvironment(req)
+	}
+	n := s.next.Add(1) - 1
+	return s.proxies[n%uint64(len(s.proxies))], nil
+}
+
+// matches reports whether a lower case host matches the route's pattern
+func (r *proxyRoute) matches(host string) bool {
+	if r.network != nil {
+		ip := net.ParseIP(host)
+		return ip != nil && r.network.Contains(ip)
+	}
+	return matchHostPattern(r.pattern, host)
+}
+
+// matchHostPattern reports whether a lower case host matches a lower case
+// domain pattern: a domain, which also matches its subdomains, a wildcard
+// for subdomains only ("*.example.com"), or "*" for every host
+func matchHostPattern(pattern, host string) bool {
+	switch {
+	case pattern == "*":
+		return true
+	case strings.HasPrefix(pattern, "*."):
+		return strings.HasSuffix(host, pattern[1:])
+	default:
+		return host == pattern || strings.HasSuffix(host, "."+pattern)
+	}
+}
@@ -0,0 +1,308 @@
+package hasher
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	neturl "net/url"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+var proxyTestIcon = []byte("favicon through a proxy")
+
+func TestProxySelector(t *testing.T) {
+	selector, err := NewProxySelector(
+		[]string{"http://one:8080", "http://two:8080"},
+		[]ProxyRule{
+			{Pattern: "*.corp.example", Proxy: "socks5://jump:1080"},
+			{Pattern: "example.org", Proxy: "direct"},
+			{Pattern: "10.0.0.0/8", Proxy: "https://burp:8443"},
+		},
+	)
+	if err != nil {
+		t.Fatalf("NewProxySelector() returned error: %v", err)
+	}
+
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"https://git.corp.example/favicon.ico", "socks5://jump:1080"},
+		{"https://corp.example/favicon.ico", "http://one:8080"},
+		{"https://example.org/favicon.ico", ""},
+		{"https://www.EXAMPLE.org/favicon.ico", ""},
+		{"http://10.1.2.3/favicon.ico", "https://burp:8443"},
+		{"http://11.1.2.3/favicon.ico", "http://two:8080"},
+		{"http://other.example/favicon.ico", "http://one:8080"},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest(http.MethodGet, test.url, nil)
+		proxy, err := selector.Proxy(req)
+		if err != nil {
+			t.Fatalf("Proxy(%s) returned error: %v", test.url, err)
+		}
+		got := ""
+		if proxy != nil {
+			got = proxy.String()
+		}
+		if got != test.expected {
+			t.Errorf("Proxy(%s) = %q, expected %q", test.url, got, test.expected)
+		}
+	}
+
+	invalid := []struct {
+		proxies []string
+		rules   []ProxyRule
+	}{
+		{[]string{"ftp://proxy:21"}, nil},
+		{[]string{"http://"}, nil},
+		{nil, []ProxyRule{{Pattern: "10.0.0.0/33", Proxy: "direct"}}},
+		{nil, []ProxyRule{{Pattern: "example.com", Proxy: "proxy:8080"}}},
+	}
+	for _, test := range invalid {
+		if _, err := NewProxySelector(test.proxies, test.rules); err == nil {
+			t.Errorf("Expected an error for %v %v", test.proxies, test.rules)
+		}
+	}
+
+	if _, err := ParseProxyRule("example.com"); err == nil {
+		t.Error("Expected an error for a rule without a proxy")
+	}
+	if rule, err := ParseProxyRule("*.corp = socks5://jump:1080"); err != nil || rule.Pattern != "*.corp" || rule.Proxy != "socks5://jump:1080" {
+		t.Errorf("ParseProxyRule() = %+v, %v", rule, err)
+	}
+}
+
+// httpProxyStandIn answers proxied requests itself and records them
+type httpProxyStandIn struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []string
+	auth     []string
+}
+
+func newHTTPProxyStandIn(t *testing.T) *httpProxyStandIn {
+	p := &httpProxyStandIn{}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		p.requests = append(p.requests, r.URL.String())
+		p.auth = append(p.auth, r.Header.Get("Proxy-Authorization"))
+		p.mu.Unlock()
+		w.Write(proxyTestIcon)
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+func TestHashFromURLThroughHTTPProxies(t *testing.T) {
+	first, second := newHTTPProxyStandIn(t), newHTTPProxyStandIn(t)
+	withAuth, _ := neturl.Parse(first.URL)
+	withAuth.User = neturl.UserPassword("user", "pass")
+
+	selector, err := NewProxySelector([]string{withAuth.String(), second.URL}, nil)
+	if err != nil {
+		t.Fatalf("NewProxySelector() returned error: %v", err)
+	}
+	h := New(&HashOptions{Proxy: selector})
+
+	expected, _ := New(nil).HashFromBytes(proxyTestIcon)
+	for i := 0; i < 4; i++ {
+		hash, err := h.HashFromURL("http://icons.example/favicon.ico")
+		if err != nil {
+			t.Fatalf("HashFromURL() returned error: %v", err)
+		}
+		if hash != expected {
+			t.Errorf("Expected hash %s, got %s", expected, hash)
+		}
+	}
+
+	// Requests rotate across the proxies
+	if len(first.requests) != 2 || len(second.requests) != 2 {
+		t.Fatalf("Expected 2 requests per proxy, got %d and %d", len(first.requests), len(second.requests))
+	}
+	if first.requests[0] != "http://icons.example/favicon.ico" {
+		t.Errorf("Expected an absolute request URI, got %s", first.requests[0])
+	}
+	wantAuth := "Basic " + base64.StdEncoding.EncodeToString([]byte("user:pass"))
+	if first.auth[0] != wantAuth || second.auth[0] != "" {
+		t.Errorf("Unexpected Proxy-Authorization headers %q and %q", first.auth[0], second.auth[0])
+	}
+}
+
+func TestHashFromURLThroughSOCKS5(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(proxyTestIcon)
+	}))
+	defer target.Close()
+
+	socks := newSOCKS5StandIn(t, target.Listener.Addr().String(), "user", "pass")
+	direct := newHTTPProxyStandIn(t)
+
+	selector, err := NewProxySelector([]string{direct.URL}, []ProxyRule{
+		{Pattern: "*.internal", Proxy: "socks5h://user:pass@" + socks.addr},
+	})
+	if err != nil {
+		t.Fatalf("NewProxySelector() returned error: %v", err)
+	}
+	h := New(&HashOptions{Proxy: selector})
+
+	expected, _ := h.HashFromBytes(proxyTestIcon)
+	hash, err := h.HashFromURL("http://icons.internal/favicon.ico")
+	if err != nil {
+		t.Fatalf("HashFromURL() returned error: %v", err)
+	}
+	if hash != expected {
+		t.Errorf("Expected hash %s, got %s", expected, hash)
+	}
+	if got := socks.destinations(); len(got) != 1 || got[0] != "icons.internal:80" {
+		t.Errorf("Expected the SOCKS5 proxy to resolve icons.internal:80, got %v", got)
+	}
+	if len(direct.requests) != 0 {
+		t.Errorf("Expected the rule to bypass the rotation proxy, got %v", direct.requests)
+	}
+
+	// Wrong credentials are rejected by the proxy
+	selector, _ = NewProxySelector([]string{"socks5://user:wrong@" + socks.addr}, nil)
+	if _, err := New(&HashOptions{Proxy: selector}).HashFromURL("http://icons.internal/favicon.ico"); err == nil {
+		t.Error("Expected an error with wrong SOCKS5 credentials")
+	}
+}
+
+func TestInsecureSkipVerify(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(proxyTestIcon)
+	}))
+	defer server.Close()
+
+	if _, err := New(&HashOptions{InsecureSkipVerify: false}).HashFromURL(server.URL); err == nil {
+		t.Error("Expected a certificate error without InsecureSkipVerify")
+	}
+	if _, err := New(&HashOptions{InsecureSkipVerify: true}).HashFromURL(server.URL); err != nil {
+		t.Errorf("Expected InsecureSkipVerify to accept the certificate, got %v", err)
+	}
+}
+
+// socks5StandIn is a minimal SOCKS5 server with username/password
+// authentication that connects every request to one target address
+type socks5StandIn struct {
+	addr   string
+	target string
+	user   string
+	pass   string
+
+	mu   sync.Mutex
+	dest []string
+}
+
+func newSOCKS5StandIn(t *testing.T, target, user, pass string) *socks5StandIn {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	s := &socks5StandIn{addr: ln.Addr().String(), target: target, user: user, pass: pass}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *socks5StandIn) destinations() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.dest...)
+}
+
+func (s *socks5StandIn) serve(conn net.Conn) {
+	defer conn.Close()
+
+	// Greeting: version, methods; require username/password (0x02)
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil || header[0] != 5 {
+		return
+	}
+	if _, err := io.ReadFull(conn, make([]byte, header[1])); err != nil {
+		return
+	}
+	conn.Write([]byte{5, 2})
+
+	// Username/password subnegotiation (RFC 1929)
+	user, pass := readSOCKSAuth(conn)
+	if user != s.user || pass != s.pass {
+		conn.Write([]byte{1, 1})
+		return
+	}
+	conn.Write([]byte{1, 0})
+
+	// Request: version, CONNECT, reserved, address type, address, port
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil || request[1] != 1 {
+		return
+	}
+	var host string
+	switch request[3] {
+	case 1:
+		ip := make([]byte, 4)
+		io.ReadFull(conn, ip)
+		host = net.IP(ip).String()
+	case 3:
+		size := make([]byte, 1)
+		io.ReadFull(conn, size)
+		name := make([]byte, size[0])
+		io.ReadFull(conn, name)
+		host = string(name)
+	default:
+		return
+	}
+	port := make([]byte, 2)
+	io.ReadFull(conn, port)
+
+	s.mu.Lock()
+	s.dest = append(s.dest, net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))))
+	s.mu.Unlock()
+
+	upstream, err := net.Dial("tcp", s.target)
+	if err != nil {
+		conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer upstream.Close()
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+
+	go io.Copy(upstream, conn)
+	io.Copy(conn, upstream)
+}
+
+// readSOCKSAuth reads a username/password authentication request
+func readSOCKSAuth(conn net.Conn) (string, string) {
+	readString := func() string {
+		size := make([]byte, 1)
+		if _, err := io.ReadFull(conn, size); err != nil {
+			return ""
+		}
+		value := make([]byte, size[0])
+		io.ReadFull(conn, value)
+		return string(value)
+	}
+
+	version := make([]byte, 1)
+	if _, err := io.ReadFull(conn, version); err != nil || version[0] != 1 {
+		return "", ""
+	}
+	user := readString()
+	pass := readString()
+	return user, pass
+}
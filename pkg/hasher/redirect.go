@@ -0,0 +1,172 @@
+package hasher
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// DefaultMaxRedirects is the number of redirects followed by default, as by
+// the net/http client
+const DefaultMaxRedirects = 10
+
+// RedirectPolicy decides which redirects a fetch follows
+type RedirectPolicy struct {
+	// MaxRedirects is the number of redirects followed before giving up;
+	// 0 follows none, so that a redirect fails the fetch
+	MaxRedirects int
+	// SameHost refuses redirects to another host or port
+	SameHost bool
+	// FollowMetaRefresh follows <meta http-equiv="refresh"> elements of the
+	// pages fetched by DiscoverFavicons, counting them as redirects
+	FollowMetaRefresh bool
+}
+
+// DefaultRedirectPolicy returns a policy following up to 10 redirects to any host
+func DefaultRedirectPolicy() *RedirectPolicy {
+	return &RedirectPolicy{MaxRedirects: DefaultMaxRedirects}
+}
+
+// Redirect is a response that redirected a fetch
+type Redirect struct {
+	// URL is the URL that was redirected
+	URL string `json:"url"`
+	// StatusCode is the redirect status, or 0 for a meta refresh
+	StatusCode int `json:"status_code"`
+}
+
+// RedirectError is returned when the redirect policy stops a fetch at a
+// redirect. Redirects lists every redirect, including the refused one.
+type RedirectError struct {
+	Redirects []Redirect
+	// Location is the URL the refused redirect pointed to
+	Location string
+	Reason   string
+}
+
+// Error implements the error interface
+func (e *RedirectError) Error() string {
+	last := e.Redirects[len(e.Redirects)-1]
+	return fmt.Sprintf("redirect from %s to %s not followed: %s", last.URL, e.Location, e.Reason)
+}
+
+// redirectPolicy returns the policy of the options, or the default one
+func (h *IconHasher) redirectPolicy() *RedirectPolicy {
+	if h.options.Redirect != nil {
+		return h.options.Redirect
+	}
+	return DefaultRedirectPolicy()
+}
+
+// isRedirect reports whether a status code redirects to the Location header
+func isRedirect(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// checkRedirect resolves the target of a redirect from current and returns
+// it, or a RedirectError if the policy does not allow following it
+func (p *RedirectPolicy) checkRedirect(first, current *neturl.URL, location string, redirects []Redirect) (*neturl.URL, error) {
+	refuse := func(target, reason string) error {
+		return &RedirectError{Redirects: redirects, Location: target, Reason: reason}
+	}
+
+	if location == "" {
+		return nil, refuse("", "no Location header")
+	}
+	target, err := current.Parse(location)
+	if err != nil {
+		return nil, refuse(location, "invalid Location header")
+	}
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return nil, refuse(target.String(), "unsupported scheme")
+	}
+	if len(redirects) > p.MaxRedirects {
+		if p.MaxRedirects == 0 {
+			return nil, refuse(target.String(), "redirects are disabled")
+		}
+		return nil, refuse(target.String(), fmt.Sprintf("stopped after %d redirects", p.MaxRedirects))
+	}
+	if p.SameHost && !strings.EqualFold(target.Host, first.Host) {
+		return nil, refuse(target.String(), "redirect to another host")
+	}
+	return target, nil
+}
+
+// stripCredentials removes the headers that must not follow a redirect to
+// another host, as the net/http client does
+func stripCredentials(req *http.Request) {
+	req.Host = ""
+	for _, name := range []string{"Authorization", "Www-Authenticate", "Cookie", "Cookie2"} {
+		req.Header.Del(name)
+	}
+}
+
+// ParseMetaRefresh returns the URL of a <meta http-equiv="refresh"> element
+// of an HTML document, resolved against base, or nil if there is none
+func ParseMetaRefresh(data []byte, base *neturl.URL) *neturl.URL {
+	tokenizer := html.NewTokenizer(bytes.NewReader(data))
+	for {
+		tt := tokenizer.Next()
+		switch tt {
+		case html.ErrorToken:
+			return nil
+		case html.StartTagToken, html.SelfClosingTagToken:
+		default:
+			continue
+		}
+
+		name, hasAttr := tokenizer.TagName()
+		if string(name) == "body" {
+			return nil
+		}
+		if string(name) != "meta" || !hasAttr {
+			continue
+		}
+
+		attrs := make(map[string]string)
+		for {
+			key, val, more := tokenizer.TagAttr()
+			attrs[string(key)] = string(val)
+			if !more {
+				break
+			}
+		}
+		if !strings.EqualFold(strings.TrimSpace(attrs["http-equiv"]), "refresh") {
+			continue
+		}
+		if target := parseRefreshContent(attrs["content"]); target != "" {
+			if u, err := base.Parse(target); err == nil {
+				return u
+			}
+		}
+	}
+}
+
+// parseRefreshContent returns the URL of a refresh content attribute such as
+// "0; url=/home", or "" if it only reloads the page
+func parseRefreshContent(content string) string {
+	delay, rest, found := strings.Cut(content, ";")
+	if _, err := strconv.ParseFloat(strings.TrimSpace(delay), 64); err != nil {
+		// A URL without a delay is invalid but common
+		rest, found = content, true
+	}
+	if !found {
+		return ""
+	}
+
+	rest = strings.TrimSpace(rest)
+	if key, value, ok := strings.Cut(rest, "="); ok && strings.EqualFold(strings.TrimSpace(key), "url") {
+		rest = strings.TrimSpace(value)
+	}
+	return strings.Trim(rest, `"'`)
+}
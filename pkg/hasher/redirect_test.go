@@ -0,0 +1,132 @@
+package hasher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestFetchRedirects(t *testing.T) {
+	var authorization string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.Write([]byte{0, 0, 1, 0})
+	}))
+	defer other.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old.ico":
+			http.Redirect(w, r, "/moved.ico", http.StatusMovedPermanently)
+		case "/moved.ico":
+			http.Redirect(w, r, "/favicon.ico", http.StatusFound)
+		case "/cdn.ico":
+			http.Redirect(w, r, other.URL+"/favicon.ico", http.StatusTemporaryRedirect)
+		default:
+			w.Write([]byte{0, 0, 1, 0})
+		}
+	}))
+	defer server.Close()
+
+	options := DefaultOptions()
+	options.Auth = &Credentials{BearerToken: "secret"}
+	h := New(options)
+
+	result, err := h.Fetch(context.Background(), server.URL+"/old.ico")
+	if err != nil {
+		t.Fatalf("Fetch() returned error: %v", err)
+	}
+	expected := []Redirect{
+		{URL: server.URL + "/old.ico", StatusCode: http.StatusMovedPermanently},
+		{URL: server.URL + "/moved.ico", StatusCode: http.StatusFound},
+	}
+	if !reflect.DeepEqual(result.Redirects, expected) || result.FinalURL != server.URL+"/favicon.ico" {
+		t.Errorf("Unexpected redirect chain %+v to %s", result.Redirects, result.FinalURL)
+	}
+
+	// Credentials are not sent to another host
+	if _, err := h.Fetch(context.Background(), server.URL+"/cdn.ico"); err != nil || authorization != "" {
+		t.Errorf("Expected the redirect to be followed without credentials, got %v and %q", err, authorization)
+	}
+
+	tests := []struct {
+		name   string
+		policy RedirectPolicy
+		path   string
+		length int
+	}{
+		{"No follow", RedirectPolicy{}, "/old.ico", 1},
+		{"Too many redirects", RedirectPolicy{MaxRedirects: 1}, "/old.ico", 2},
+		{"Same host only", RedirectPolicy{MaxRedirects: 10, SameHost: true}, "/cdn.ico", 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy := test.policy
+			limited := h.WithOptions(func(o *HashOptions) { o.Redirect = &policy })
+			_, err := limited.Fetch(context.Background(), server.URL+test.path)
+			var redirectErr *RedirectError
+			if !errors.As(err, &redirectErr) || len(redirectErr.Redirects) != test.length || FailureReason(err) != ReasonRedirect {
+				t.Errorf("Expected a RedirectError after %d redirects, got %v", test.length, err)
+			}
+		})
+	}
+}
+
+func TestParseMetaRefresh(t *testing.T) {
+	base, _ := url.Parse("https://example.com/dir/page")
+
+	tests := []struct {
+		name     string
+		html     string
+		expected string
+	}{
+		{"Delay and URL", `<meta http-equiv="refresh" content="0; url=/home">`, "https://example.com/home"},
+		{"Quoted URL", `<META HTTP-EQUIV="Refresh" CONTENT="5;URL='next.html'">`, "https://example.com/dir/next.html"},
+		{"URL without key", `<meta http-equiv="refresh" content="0;https://other.example/">`, "https://other.example/"},
+		{"Reload only", `<meta http-equiv="refresh" content="30">`, ""},
+		{"Other meta", `<meta name="refresh" content="0; url=/home">`, ""},
+		{"In the body", `<body><meta http-equiv="refresh" content="0; url=/home">`, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			target := ParseMetaRefresh([]byte(test.html), base)
+			if (target == nil && test.expected != "") || (target != nil && target.String() != test.expected) {
+				t.Errorf("ParseMetaRefresh() = %v, expected %q", target, test.expected)
+			}
+		})
+	}
+}
+
+func TestDiscoverFaviconsMetaRefresh(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<meta http-equiv="refresh" content="0; url=/app/">`))
+		case "/app":
+			http.Redirect(w, r, "/app/", http.StatusMovedPermanently)
+		default:
+			w.Write([]byte(`<link rel="icon" href="icon.png">`))
+		}
+	}))
+	defer server.Close()
+
+	options := DefaultOptions()
+	options.Redirect = &RedirectPolicy{MaxRedirects: 5, FollowMetaRefresh: true}
+	icons, err := New(options).DiscoverFavicons(context.Background(), server.URL+"/")
+	if err != nil {
+		t.Fatalf("DiscoverFavicons() returned error: %v", err)
+	}
+	if len(icons) != 2 || icons[0].URL != server.URL+"/app/icon.png" {
+		t.Errorf("Expected the icons of the refreshed page, got %+v", icons)
+	}
+
+	// Without the option, the refresh page itself is parsed
+	icons, err = New(nil).DiscoverFavicons(context.Background(), server.URL+"/")
+	if err != nil || len(icons) != 1 || icons[0].Rel != "default" {
+		t.Errorf("Expected only the default icon, got %+v, %v", icons, err)
+	}
+}
@@ -0,0 +1,115 @@
+package hasher
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Credentials authenticate fetches. A bearer token takes precedence over a
+// username and password. Fetches follow redirects themselves and, once a
+// redirect leads to another host, strip the Authorization and Cookie headers
+// and the Host override with stripCredentials, so credentials are only sent
+// to the host of the fetched URL.
+type Credentials struct {
+	Username    string `json:"username,omitempty"`
+	Password    string `json:"password,omitempty"`
+	BearerToken string `json:"bearer_token,omitempty"`
+}
+
+// ParseBasicAuth parses credentials written as "user:password"
+func ParseBasicAuth(s string) (*Credentials, error) {
+	username, password, ok := strings.Cut(s, ":")
+	if !ok || username == "" {
+		return nil, fmt.Errorf("invalid basic auth: expected user:password")
+	}
+	return &Credentials{Username: username, Password: password}, nil
+}
+
+// ParseHeader parses a header written as "Name: value", as with curl -H
+func ParseHeader(s string) (string, string, error) {
+	name, value, ok := strings.Cut(s, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" || strings.ContainsAny(name, " \t\r\n") || strings.ContainsAny(value, "\r\n") {
+		return "", "", fmt.Errorf("invalid header %q: expected \"Name: value\"", s)
+	}
+	return http.CanonicalHeaderKey(name), strings.TrimSpace(value), nil
+}
+
+// ParseHeaders parses a list of "Name: value" headers
+func ParseHeaders(lines []string) (http.Header, error) {
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	header := make(http.Header, len(lines))
+	for _, line := range lines {
+		name, value, err := ParseHeader(line)
+		if err != nil {
+			return nil, err
+		}
+		header.Add(name, value)
+	}
+	return header, nil
+}
+
+// LoadClientCertificate loads a PEM certificate and key for mutual TLS. The
+// key may be in the certificate file, in which case keyFile can be empty.
+func LoadClientCertificate(certFile, keyFile string) (*tls.Certificate, error) {
+	if keyFile == "" {
+		keyFile = certFile
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load client certificate: %w", err)
+	}
+	return &cert, nil
+}
+
+// prepareRequest applies the User-Agent, extra headers and credentials of
+// the options to an outbound request. Headers and credentials are left out
+// for hosts outside CredentialHosts.
+func (h *IconHasher) prepareRequest(req *http.Request) {
+	req.Header.Set("User-Agent", h.options.UserAgent)
+	if h.options.AcceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", h.options.AcceptEncoding)
+	} else {
+		req.Header.Set("Accept-Encoding", DefaultAcceptEncoding)
+	}
+
+	if !h.sendsCredentials(req.URL.Hostname()) {
+		return
+	}
+	for name, values := range h.options.Headers {
+		if strings.EqualFold(name, "Host") {
+			if len(values) > 0 {
+				req.Host = values[0]
+			}
+			continue
+		}
+		req.Header[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+	}
+
+	if auth := h.options.Auth; auth != nil {
+		if auth.BearerToken != "" {
+			req.Header.Set("Authorization", "Bearer "+auth.BearerToken)
+		} else if auth.Username != "" {
+			req.SetBasicAuth(auth.Username, auth.Password)
+		}
+	}
+}
+
+// sendsCredentials reports whether the headers and credentials of the
+// options are sent to host
+func (h *IconHasher) sendsCredentials(host string) bool {
+	if len(h.options.CredentialHosts) == 0 {
+		return true
+	}
+	host = strings.ToLower(host)
+	for _, pattern := range h.options.CredentialHosts {
+		if matchHostPattern(strings.ToLower(pattern), host) {
+			return true
+		}
+	}
+	return false
+}
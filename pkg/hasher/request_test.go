@@ -0,0 +1,222 @@
+package hasher
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFetchHeadersAndAuth(t *testing.T) {
+	var got *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.Write([]byte("icon"))
+	}))
+	defer server.Close()
+
+	headers, err := ParseHeaders([]string{"Accept: image/*", "referer: https://example.com/", "Host: vhost.example", "User-Agent: custom"})
+	if err != nil {
+		t.Fatalf("ParseHeaders() returned error: %v", err)
+	}
+	auth, _ := ParseBasicAuth("user:pa:ss")
+
+	h := New(&HashOptions{UserAgent: "default", Headers: headers, Auth: auth})
+	if _, err := h.HashFromURL(server.URL); err != nil {
+		t.Fatalf("HashFromURL() returned error: %v", err)
+	}
+
+	if got.Header.Get("Accept") != "image/*" || got.Header.Get("Referer") != "https://example.com/" {
+		t.Errorf("Expected the extra headers, got %v", got.Header)
+	}
+	if got.Host != "vhost.example" || got.UserAgent() != "custom" {
+		t.Errorf("Expected Host and User-Agent overrides, got %q and %q", got.Host, got.UserAgent())
+	}
+	if user, pass, ok := got.BasicAuth(); !ok || user != "user" || pass != "pa:ss" {
+		t.Errorf("Expected basic auth, got %q %q %v", user, pass, ok)
+	}
+
+	// Bearer tokens take precedence, and apply per request with WithOptions
+	bearer := h.WithOptions(func(o *HashOptions) { o.Auth = &Credentials{Username: "user", BearerToken: "t0ken"} })
+	bearer.HashFromURL(server.URL)
+	if got.Header.Get("Authorization") != "Bearer t0ken" {
+		t.Errorf("Expected a bearer token, got %q", got.Header.Get("Authorization"))
+	}
+
+	for _, invalid := range []string{"NoColon", ": value", "Bad Name: value", "X-Test: a\r\nInjected: b"} {
+		if _, _, err := ParseHeader(invalid); err == nil {
+			t.Errorf("Expected an error for header %q", invalid)
+		}
+	}
+	if _, err := ParseBasicAuth("nopassword"); err == nil {
+		t.Error("Expected an error for basic auth without a colon")
+	}
+}
+
+func TestLoadCookieFile(t *testing.T) {
+	var cookies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, c := range r.Cookies() {
+			cookies = append(cookies, c.Name+"="+c.Value)
+		}
+		w.Write([]byte("icon"))
+	}))
+	defer server.Close()
+
+	future := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	path := filepath.Join(t.TempDir(), "cookies.txt")
+	os.WriteFile(path, []byte(strings.Join([]string{
+		"# Netscape HTTP Cookie File",
+		"",
+		"127.0.0.1\tFALSE\t/\tFALSE\t0\tsession\tabc",
+		"#HttpOnly_127.0.0.1\tFALSE\t/\tFALSE\t" + future + "\tremember\tyes",
+		"127.0.0.1\tFALSE\t/\tFALSE\t1\texpired\tgone",
+		"127.0.0.1\tFALSE\t/admin\tFALSE\t0\tadmin\tno",
+		"other.example\tTRUE\t/\tFALSE\t0\tother\tno",
+	}, "\n")), 0o600)
+
+	jar, err := LoadCookieFile(path)
+	if err != nil {
+		t.Fatalf("LoadCookieFile() returned error: %v", err)
+	}
+	if _, err := New(&HashOptions{Cookies: jar}).HashFromURL(server.URL + "/favicon.ico"); err != nil {
+		t.Fatalf("HashFromURL() returned error: %v", err)
+	}
+	if strings.Join(cookies, "; ") != "session=abc; remember=yes" {
+		t.Errorf("Unexpected cookies sent: %v", cookies)
+	}
+
+	os.WriteFile(path, []byte("example.com\tTRUE\t/\n"), 0o600)
+	if _, err := LoadCookieFile(path); err == nil {
+		t.Error("Expected an error for a malformed cookie file")
+	}
+}
+
+func TestClientCertificate(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t)
+	cert, err := LoadClientCertificate(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("LoadClientCertificate() returned error: %v", err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("icon"))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	if _, err := New(&HashOptions{InsecureSkipVerify: true}).HashFromURL(server.URL); err == nil {
+		t.Error("Expected the server to reject a fetch without a client certificate")
+	}
+	if _, err := New(&HashOptions{InsecureSkipVerify: true, ClientCert: cert}).HashFromURL(server.URL); err != nil {
+		t.Errorf("Expected the client certificate to be accepted, got %v", err)
+	}
+
+	if _, err := LoadClientCertificate(filepath.Join(t.TempDir(), "missing.pem"), ""); err == nil {
+		t.Error("Expected an error for a missing certificate")
+	}
+}
+
+func TestCredentialHosts(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t)
+	cert, err := LoadClientCertificate(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("LoadClientCertificate() returned error: %v", err)
+	}
+
+	var got *http.Request
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.Write([]byte("icon"))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	allowed := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+	jar, _ := cookiejar.New(nil)
+	for _, raw := range []string{server.URL, allowed} {
+		u, _ := url.Parse(raw)
+		jar.SetCookies(u, []*http.Cookie{{Name: "session", Value: "secret"}})
+	}
+
+	h := New(&HashOptions{
+		InsecureSkipVerify: true,
+		Headers:            http.Header{"X-Api-Key": {"secret"}},
+		Cookies:            jar,
+		Auth:               &Credentials{BearerToken: "secret"},
+		ClientCert:         cert,
+		CredentialHosts:    []string{"localhost"},
+	})
+	sent := func() []string {
+		var found []string
+		if got.Header.Get("X-Api-Key") != "" {
+			found = append(found, "header")
+		}
+		if got.Header.Get("Authorization") != "" {
+			found = append(found, "auth")
+		}
+		if len(got.Cookies()) > 0 {
+			found = append(found, "cookie")
+		}
+		if len(got.TLS.PeerCertificates) > 0 {
+			found = append(found, "certificate")
+		}
+		return found
+	}
+
+	if _, err := h.HashFromURL(server.URL); err != nil {
+		t.Fatalf("HashFromURL() returned error: %v", err)
+	}
+	if found := sent(); len(found) > 0 {
+		t.Errorf("Expected no credentials for a host outside the patterns, got %v", found)
+	}
+
+	if _, err := h.HashFromURL(allowed); err != nil {
+		t.Fatalf("HashFromURL() returned error: %v", err)
+	}
+	if found := sent(); len(found) != 4 {
+		t.Errorf("Expected every credential for an allowed host, got %v", found)
+	}
+}
+
+// writeTestCertificate writes a self-signed client certificate and its key
+func writeTestCertificate(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "iconhash test client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	return certFile, keyFile
+}
@@ -0,0 +1,286 @@
+package hasher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/cache"
+)
+
+// MaxRetryAfter caps the wait a Retry-After header can ask for when the
+// policy has no MaxBackoff
+const MaxRetryAfter = time.Minute
+
+// RetryPolicy decides whether and when failed fetches are retried. Waits
+// grow exponentially from InitialBackoff, and a Retry-After header sent with
+// a retried status code is honored when it asks for a longer wait, up to
+// MaxBackoff or MaxRetryAfter.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts including the first; 1 or less
+	// disables retries
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry. Each later wait is
+	// Multiplier times longer, up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// Jitter shortens each wait by a random fraction of up to Jitter (0 to
+	// 1), so that clients failing together do not retry in lockstep
+	Jitter float64
+	// MaxElapsed caps the total time of all attempts and waits; 0 means no
+	// cap. A retry whose wait would exceed it is not attempted.
+	MaxElapsed time.Duration
+	// RetryStatuses are the HTTP status codes that are retried
+	RetryStatuses []int
+	// RetryReasons are the FailureReason values of errors that are retried
+	RetryReasons []string
+}
+
+// DefaultRetryPolicy returns a policy retrying timeouts, dropped connections
+// and temporary HTTP errors twice
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+		MaxElapsed:     time.Minute,
+		RetryStatuses: []int{
+			http.StatusRequestTimeout,
+			http.StatusTooEarly,
+			http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+		RetryReasons: []string{ReasonTimeout, ReasonConnectionRefused, ReasonConnectionReset},
+	}
+}
+
+// retryable reports whether the policy retries a failed attempt
+func (p *RetryPolicy) retryable(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		for _, code := range p.RetryStatuses {
+			if code == statusErr.StatusCode {
+				return true
+			}
+		}
+		return false
+	}
+
+	reason := FailureReason(err)
+	for _, r := range p.RetryReasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// wait returns the delay before the given retry, counting from 1
+func (p *RetryPolicy) wait(retry int, err error) time.Duration {
+	backoff := float64(p.InitialBackoff)
+	for i := 1; i < retry && p.Multiplier > 1; i++ {
+		backoff *= p.Multiplier
+	}
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		backoff -= backoff * p.Jitter * rand.Float64()
+	}
+
+	wait := time.Duration(backoff)
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > wait {
+		wait = statusErr.RetryAfter
+		limit := p.MaxBackoff
+		if limit <= 0 {
+			limit = MaxRetryAfter
+		}
+		if wait > limit {
+			wait = limit
+		}
+	}
+	return wait
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an
+// HTTP date, returning 0 if it is missing or invalid
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// Attempt records one try of a fetch
+type Attempt struct {
+	// Number counts attempts from 1
+	Number   int
+	Start    time.Time
+	Duration time.Duration
+	// StatusCode is the HTTP status received, or 0 if there was no response
+	StatusCode int
+	// Reason is the FailureReason of a failed attempt
+	Reason string
+	Error  string
+	// Wait is the delay before the next attempt, or 0 after the last one
+	Wait time.Duration
+}
+
+// attemptJSON is the JSON form of an Attempt
+type attemptJSON struct {
+	Number     int       `json:"number"`
+	Start      time.Time `json:"start"`
+	Duration   string    `json:"duration"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Wait       string    `json:"wait,omitempty"`
+}
+
+// MarshalJSON writes durations as strings such as "1.5s"
+func (a Attempt) MarshalJSON() ([]byte, error) {
+	out := attemptJSON{
+		Number:     a.Number,
+		Start:      a.Start,
+		Duration:   a.Duration.String(),
+		StatusCode: a.StatusCode,
+		Reason:     a.Reason,
+		Error:      a.Error,
+	}
+	if a.Wait > 0 {
+		out.Wait = a.Wait.String()
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON reads an attempt written by MarshalJSON
+func (a *Attempt) UnmarshalJSON(data []byte) error {
+	var in attemptJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	*a = Attempt{Number: in.Number, Start: in.Start, StatusCode: in.StatusCode, Reason: in.Reason, Error: in.Error}
+	var err error
+	if in.Duration != "" {
+		if a.Duration, err = time.ParseDuration(in.Duration); err != nil {
+			return err
+		}
+	}
+	if in.Wait != "" {
+		if a.Wait, err = time.ParseDuration(in.Wait); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RetryError is returned when a fetch failed after more than one attempt.
+// It wraps the error of the last attempt.
+type RetryError struct {
+	Attempts []Attempt
+	Err      error
+}
+
+// Error implements the error interface
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("%v (after %d attempts)", e.Err, len(e.Attempts))
+}
+
+// Unwrap returns the error of the last attempt
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// fetchWithRetries fetches a URL, retrying failed attempts as allowed by the
+// retry policy, and returns the final response along with the result
+func (h *IconHasher) fetchWithRetries(ctx context.Context, url string, cached *cache.Entry) (*FetchResult, *response, error) {
+	policy := h.options.Retry
+	maxAttempts := 1
+	if policy != nil && policy.MaxAttempts > 1 {
+		maxAttempts = policy.MaxAttempts
+	}
+	if policy != nil && policy.MaxElapsed > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, policy.MaxElapsed)
+		defer cancel()
+	}
+
+	result := &FetchResult{URL: url}
+	start := time.Now()
+	for n := 1; ; n++ {
+		attempt := Attempt{Number: n, Start: time.Now()}
+		resp, err := h.getContentOnce(ctx, url, cached)
+		attempt.Duration = time.Since(attempt.Start)
+
+		if err == nil {
+			attempt.StatusCode = resp.statusCode
+			result.StatusCode = resp.statusCode
+			result.Data, result.Raw = resp.data, resp.raw
+			result.ContentType, result.ContentEncoding = resp.contentType, resp.encoding
+			result.FinalURL, result.Redirects = resp.finalURL, resp.redirects
+			result.Header, result.TLS = resp.header, resp.tls
+			result.Attempts = append(result.Attempts, attempt)
+			return result, resp, nil
+		}
+
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) {
+			attempt.StatusCode = statusErr.StatusCode
+		}
+		attempt.Reason = FailureReason(err)
+		attempt.Error = err.Error()
+
+		retry := n < maxAttempts && policy.retryable(err)
+		if retry {
+			attempt.Wait = policy.wait(n, err)
+			retry = policy.MaxElapsed == 0 || time.Since(start)+attempt.Wait < policy.MaxElapsed
+			// Waiting past the deadline of the context would only fail later
+			if deadline, ok := ctx.Deadline(); ok && time.Now().Add(attempt.Wait).After(deadline) {
+				retry = false
+			}
+			if !retry {
+				attempt.Wait = 0
+			}
+		}
+		result.Attempts = append(result.Attempts, attempt)
+
+		if !retry {
+			if n > 1 {
+				return nil, nil, &RetryError{Attempts: result.Attempts, Err: err}
+			}
+			return nil, nil, err
+		}
+
+		timer := time.NewTimer(attempt.Wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, nil, &RetryError{Attempts: result.Attempts, Err: ctx.Err()}
+		case <-timer.C:
+		}
+	}
+}
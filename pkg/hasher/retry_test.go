@@ -0,0 +1,181 @@
+package hasher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testRetryPolicy retries quickly and without jitter
+func testRetryPolicy(attempts int) *RetryPolicy {
+	policy := DefaultRetryPolicy()
+	policy.MaxAttempts = attempts
+	policy.InitialBackoff = time.Millisecond
+	policy.Jitter = 0
+	return policy
+}
+
+// flakyServer fails the first failures requests with status, or by dropping
+// the connection when status is 0, and then serves an icon
+func flakyServer(t *testing.T, failures int32, status int, header http.Header) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) > failures {
+			w.Write([]byte("icon"))
+			return
+		}
+		if status == 0 {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		for name, values := range header {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestFetchRetries(t *testing.T) {
+	server, _ := flakyServer(t, 2, http.StatusServiceUnavailable, nil)
+
+	result, err := New(&HashOptions{Retry: testRetryPolicy(3)}).Fetch(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Fetch() returned error: %v", err)
+	}
+	if string(result.Data) != "icon" || len(result.Attempts) != 3 {
+		t.Fatalf("Expected the icon after 3 attempts, got %q after %d", result.Data, len(result.Attempts))
+	}
+	for i, code := range []int{503, 503, 200} {
+		attempt := result.Attempts[i]
+		if attempt.Number != i+1 || attempt.StatusCode != code {
+			t.Errorf("Attempt %d: expected status %d, got %+v", i+1, code, attempt)
+		}
+	}
+	if result.Attempts[0].Wait != time.Millisecond || result.Attempts[1].Wait != 2*time.Millisecond || result.Attempts[2].Wait != 0 {
+		t.Errorf("Expected exponential waits, got %v and %v", result.Attempts[0].Wait, result.Attempts[1].Wait)
+	}
+	if result.Attempts[0].Reason != ReasonHTTPStatus {
+		t.Errorf("Expected reason %s, got %s", ReasonHTTPStatus, result.Attempts[0].Reason)
+	}
+
+	data, _ := json.Marshal(result.Attempts[0])
+	if !strings.Contains(string(data), `"status_code":503`) || !strings.Contains(string(data), `"wait":"1ms"`) {
+		t.Errorf("Unexpected attempt JSON: %s", data)
+	}
+}
+
+func TestFetchRetryLimits(t *testing.T) {
+	// Retries stop after MaxAttempts and the last error is kept
+	server, requests := flakyServer(t, 5, http.StatusBadGateway, nil)
+	_, err := New(&HashOptions{Retry: testRetryPolicy(3)}).Fetch(context.Background(), server.URL)
+	var retryErr *RetryError
+	var statusErr *StatusError
+	if !errors.As(err, &retryErr) || len(retryErr.Attempts) != 3 || !errors.As(err, &statusErr) || statusErr.StatusCode != 502 {
+		t.Errorf("Expected a 502 after 3 attempts, got %v", err)
+	}
+	if requests.Load() != 3 {
+		t.Errorf("Expected 3 requests, got %d", requests.Load())
+	}
+
+	// Status codes outside RetryStatuses fail at once
+	server, requests = flakyServer(t, 1, http.StatusNotFound, nil)
+	_, err = New(&HashOptions{Retry: testRetryPolicy(3)}).Fetch(context.Background(), server.URL)
+	if !errors.As(err, &statusErr) || errors.As(err, &retryErr) || requests.Load() != 1 {
+		t.Errorf("Expected a single 404 attempt, got %v after %d requests", err, requests.Load())
+	}
+
+	// Without a policy, nothing is retried
+	server, requests = flakyServer(t, 1, http.StatusServiceUnavailable, nil)
+	if _, err := New(nil).Fetch(context.Background(), server.URL); err == nil || requests.Load() != 1 {
+		t.Errorf("Expected a single failed attempt, got %v after %d requests", err, requests.Load())
+	}
+}
+
+func TestFetchRetriesDroppedConnections(t *testing.T) {
+	server, _ := flakyServer(t, 1, 0, nil)
+
+	hash, err := New(&HashOptions{Retry: testRetryPolicy(2)}).HashFromURL(server.URL)
+	if err != nil {
+		t.Fatalf("HashFromURL() returned error: %v", err)
+	}
+	if expected, _ := New(nil).HashFromBytes([]byte("icon")); hash != expected {
+		t.Errorf("Expected hash %s, got %s", expected, hash)
+	}
+}
+
+func TestFetchRetryAfter(t *testing.T) {
+	server, _ := flakyServer(t, 1, http.StatusTooManyRequests, http.Header{"Retry-After": {"1"}})
+
+	start := time.Now()
+	result, err := New(&HashOptions{Retry: testRetryPolicy(2)}).Fetch(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Fetch() returned error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second || result.Attempts[0].Wait != time.Second {
+		t.Errorf("Expected to wait for Retry-After, waited %v", elapsed)
+	}
+
+	// A Retry-After beyond MaxElapsed ends the fetch instead of waiting
+	server, _ = flakyServer(t, 1, http.StatusTooManyRequests, http.Header{"Retry-After": {"120"}})
+	policy := testRetryPolicy(2)
+	policy.MaxElapsed = time.Second
+	start = time.Now()
+	_, err = New(&HashOptions{Retry: policy}).Fetch(context.Background(), server.URL)
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.RetryAfter != 2*time.Minute || time.Since(start) > 500*time.Millisecond {
+		t.Errorf("Expected an immediate 429 with Retry-After, got %v after %v", err, time.Since(start))
+	}
+}
+
+func TestRetryAfterLimits(t *testing.T) {
+	// Retry-After is capped by MaxBackoff, or by MaxRetryAfter without one
+	policy := testRetryPolicy(2)
+	tooLong := &StatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: 24 * time.Hour}
+	if wait := policy.wait(1, tooLong); wait != policy.MaxBackoff {
+		t.Errorf("Expected a wait of %v, got %v", policy.MaxBackoff, wait)
+	}
+	policy.MaxBackoff = 0
+	if wait := policy.wait(1, tooLong); wait != MaxRetryAfter {
+		t.Errorf("Expected a wait of %v, got %v", MaxRetryAfter, wait)
+	}
+
+	// Without MaxElapsed, the deadline of the context still ends the fetch
+	// instead of waiting
+	server, requests := flakyServer(t, 1, http.StatusServiceUnavailable, http.Header{"Retry-After": {"5"}})
+	policy = testRetryPolicy(2)
+	policy.MaxElapsed = 0
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	_, err := New(&HashOptions{Retry: policy}).Fetch(ctx, server.URL)
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || requests.Load() != 1 || time.Since(start) > 500*time.Millisecond {
+		t.Errorf("Expected an immediate 503, got %v after %d requests and %v", err, requests.Load(), time.Since(start))
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := map[string]time.Duration{
+		"":                              0,
+		"30":                            30 * time.Second,
+		"-1":                            0,
+		"soon":                          0,
+		"Mon, 01 Jan 2024 12:01:30 GMT": 90 * time.Second,
+		"Mon, 01 Jan 2024 11:00:00 GMT": 0,
+	}
+	for value, expected := range tests {
+		if got := parseRetryAfter(value, now); got != expected {
+			t.Errorf("parseRetryAfter(%q) = %v, expected %v", value, got, expected)
+		}
+	}
+}
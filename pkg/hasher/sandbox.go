@@ -0,0 +1,156 @@
+package hasher
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultMaxFileSize is the default size limit of sandboxed files. Favicons
+// are rarely larger than a few hundred kilobytes.
+const DefaultMaxFileSize = 5 << 20
+
+var (
+	// ErrOutsideRoots is returned for paths that resolve outside the sandbox roots
+	ErrOutsideRoots = errors.New("path is outside the allowed directories")
+	// ErrFileTooLarge is returned for files larger than the sandbox size limit
+	ErrFileTooLarge = errors.New("file exceeds the size limit")
+	// ErrNotRegularFile is returned for directories, devices and other special files
+	ErrNotRegularFile = errors.New("not a regular file")
+)
+
+// FileSandbox restricts file access to a set of root directories, for
+// callers such as MCP tools that read paths chosen by someone else
+type FileSandbox struct {
+	roots   []string
+	maxSize int64
+}
+
+// NewFileSandbox creates a sandbox allowing the files below the given
+// directories. Roots are resolved to absolute paths without symlinks. A
+// maxSize of zero or less uses DefaultMaxFileSize.
+func NewFileSandbox(roots []string, maxSize int64) (*FileSandbox, error) {
+	if len(roots) == 0 {
+		return nil, errors.New("at least one root directory is required")
+	}
+	if maxSize <= 0 {
+		maxSize = DefaultMaxFileSize
+	}
+
+	s := &FileSandbox{maxSize: maxSize}
+	for _, root := range roots {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return nil, fmt.Errorf("invalid root %q: %w", root, err)
+		}
+		resolved, err := filepath.EvalSymlinks(abs)
+		if err != nil {
+			return nil, fmt.Errorf("invalid root %q: %w", root, err)
+		}
+		info, err := os.Stat(resolved)
+		if err != nil {
+			return nil, fmt.Errorf("invalid root %q: %w", root, err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("invalid root %q: not a directory", root)
+		}
+		s.roots = append(s.roots, resolved)
+	}
+	return s, nil
+}
+
+// Roots returns the resolved root directories
+func (s *FileSandbox) Roots() []string {
+	return append([]string(nil), s.roots...)
+}
+
+// MaxSize returns the size limit of files
+func (s *FileSandbox) MaxSize() int64 {
+	return s.maxSize
+}
+
+// Resolve returns the real path of a file inside the sandbox. Relative paths
+// are relative to the first root. Paths containing ".." elements are
+// rejected outright, and symlinks are followed before checking that the
+// file lies below a root.
+func (s *FileSandbox) Resolve(path string) (string, error) {
+	if path == "" {
+		return "", errors.New("path is required")
+	}
+	for _, elem := range strings.FieldsFunc(filepath.ToSlash(path), func(r rune) bool { return r == '/' }) {
+		if elem == ".." {
+			return "", ErrOutsideRoots
+		}
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.roots[0], path)
+	}
+	resolved, err := filepath.EvalSymlinks(filepath.Clean(path))
+	if err != nil {
+		return "", err
+	}
+	if !s.contains(resolved) {
+		return "", ErrOutsideRoots
+	}
+	return resolved, nil
+}
+
+// contains reports whether a resolved path lies below one of the roots
+func (s *FileSandbox) contains(path string) bool {
+	for _, root := range s.roots {
+		rel, err := filepath.Rel(root, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadFile reads a regular file inside the sandbox, enforcing the size limit
+func (s *FileSandbox) ReadFile(path string) ([]byte, error) {
+	resolved, err := s.Resolve(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(resolved)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, ErrNotRegularFile
+	}
+	if info.Size() > s.maxSize {
+		return nil, ErrFileTooLarge
+	}
+
+	// The file may grow after Stat, so the read is limited as well
+	data, err := io.ReadAll(io.LimitReader(f, s.maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > s.maxSize {
+		return nil, ErrFileTooLarge
+	}
+	return data, nil
+}
+
+// HashFromSandboxedFile calculates the hash of an icon file inside a sandbox
+func (h *IconHasher) HashFromSandboxedFile(sandbox *FileSandbox, path string) (string, error) {
+	data, err := sandbox.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+
+	return h.HashFromBytes(data)
+}
@@ -0,0 +1,68 @@
+package hasher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileSandbox(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+
+	os.WriteFile(filepath.Join(root, "favicon.ico"), []byte("icon"), 0o644)
+	os.WriteFile(filepath.Join(root, "large.ico"), make([]byte, 64), 0o644)
+	os.Mkdir(filepath.Join(root, "icons"), 0o755)
+	os.WriteFile(filepath.Join(root, "icons", "a.ico"), []byte("a"), 0o644)
+	os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0o644)
+	if err := os.Symlink(filepath.Join(outside, "secret"), filepath.Join(root, "escape.ico")); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+	os.Symlink(filepath.Join(root, "icons", "a.ico"), filepath.Join(root, "inside.ico"))
+
+	sandbox, err := NewFileSandbox([]string{root}, 32)
+	if err != nil {
+		t.Fatalf("NewFileSandbox() returned error: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		path      string
+		expected  string
+		expectErr error
+	}{
+		{"Absolute path", filepath.Join(root, "favicon.ico"), "icon", nil},
+		{"Relative path", "icons/a.ico", "a", nil},
+		{"Symlink inside root", filepath.Join(root, "inside.ico"), "a", nil},
+		{"Symlink escaping root", filepath.Join(root, "escape.ico"), "", ErrOutsideRoots},
+		{"Dot-dot escape", filepath.Join(root, "..", filepath.Base(outside), "secret"), "", ErrOutsideRoots},
+		{"Dot-dot inside root", "icons/../favicon.ico", "", ErrOutsideRoots},
+		{"Path outside roots", filepath.Join(outside, "secret"), "", ErrOutsideRoots},
+		{"File too large", filepath.Join(root, "large.ico"), "", ErrFileTooLarge},
+		{"Directory", filepath.Join(root, "icons"), "", ErrNotRegularFile},
+		{"Missing file", filepath.Join(root, "missing.ico"), "", os.ErrNotExist},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := sandbox.ReadFile(test.path)
+			if test.expectErr != nil {
+				if !errors.Is(err, test.expectErr) {
+					t.Fatalf("Expected error %v, got %v", test.expectErr, err)
+				}
+				return
+			}
+			if err != nil || string(data) != test.expected {
+				t.Errorf("ReadFile(%q) = %q, %v; expected %q", test.path, data, err, test.expected)
+			}
+		})
+	}
+
+	if _, err := NewFileSandbox(nil, 0); err == nil {
+		t.Error("Expected an error without roots")
+	}
+	if _, err := NewFileSandbox([]string{filepath.Join(root, "favicon.ico")}, 0); err == nil {
+		t.Error("Expected an error for a root that is not a directory")
+	}
+}
@@ -0,0 +1,127 @@
+package hasher
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"strings"
+)
+
+// Content types reported by DetectContentType
+const (
+	TypeICO     = "ico"
+	TypePNG     = "png"
+	TypeGIF     = "gif"
+	TypeJPEG    = "jpeg"
+	TypeSVG     = "svg"
+	TypeWebP    = "webp"
+	TypeBMP     = "bmp"
+	TypeHTML    = "html"
+	TypeUnknown = "unknown"
+)
+
+// sniffLen is how much of a body is searched for markup
+const sniffLen = 1024
+
+// magicNumbers are the signatures of binary image formats
+var magicNumbers = []struct {
+	prefix []byte
+	kind   string
+}{
+	{[]byte{0x00, 0x00, 0x01, 0x00}, TypeICO},
+	{[]byte("\x89PNG\r\n\x1a\n"), TypePNG},
+	{[]byte("GIF87a"), TypeGIF},
+	{[]byte("GIF89a"), TypeGIF},
+	{[]byte{0xff, 0xd8, 0xff}, TypeJPEG},
+	{[]byte("BM"), TypeBMP},
+}
+
+// htmlMarkers start or appear early in HTML documents
+var htmlMarkers = [][]byte{
+	[]byte("<!doctype html"),
+	[]byte("<html"),
+	[]byte("<head"),
+	[]byte("<body"),
+	[]byte("<script"),
+	[]byte("<title"),
+	[]byte("<meta"),
+}
+
+// DetectContentType classifies a response body as one of the Type constants
+// from its magic bytes, falling back to the declared Content-Type header for
+// bodies it does not recognize
+func DetectContentType(data []byte, contentType string) string {
+	for _, magic := range magicNumbers {
+		if bytes.HasPrefix(data, magic.prefix) {
+			return magic.kind
+		}
+	}
+	if len(data) >= 12 && bytes.Equal(data[:4], []byte("RIFF")) && bytes.Equal(data[8:12], []byte("WEBP")) {
+		return TypeWebP
+	}
+
+	// Markup may follow a byte order mark, whitespace, an XML declaration
+	// or comments, so look for it anywhere near the start
+	head := data
+	if len(head) > sniffLen {
+		head = head[:sniffLen]
+	}
+	head = bytes.ToLower(bytes.TrimLeft(bytes.TrimPrefix(head, []byte("\xef\xbb\xbf")), " \t\r\n"))
+	if bytes.HasPrefix(head, []byte("<")) {
+		if bytes.Contains(head, []byte("<svg")) {
+			return TypeSVG
+		}
+		for _, marker := range htmlMarkers {
+			if bytes.Contains(head, marker) {
+				return TypeHTML
+			}
+		}
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/html", "application/xhtml+xml":
+		return TypeHTML
+	case "image/svg+xml":
+		if bytes.HasPrefix(head, []byte("<")) {
+			return TypeSVG
+		}
+	}
+	return TypeUnknown
+}
+
+// IsImage reports whether a type returned by DetectContentType is an image
+func IsImage(kind string) bool {
+	return kind != TypeHTML && kind != TypeUnknown
+}
+
+// ContentTypeError is returned in strict mode when a response is not an image
+type ContentTypeError struct {
+	// Type is the detected type, TypeHTML or TypeUnknown
+	Type string
+	// ContentType is the declared Content-Type header
+	ContentType string
+}
+
+// Error implements the error interface
+func (e *ContentTypeError) Error() string {
+	if e.ContentType != "" {
+		return fmt.Sprintf("response is not an image: detected %s, Content-Type %s", e.Type, e.ContentType)
+	}
+	return fmt.Sprintf("response is not an image: detected %s", e.Type)
+}
+
+// contentTypeWarning describes a response that is probably not a favicon,
+// such as an error page served with 200 OK, or returns "" for images
+func contentTypeWarning(kind, contentType string) string {
+	if IsImage(kind) {
+		return ""
+	}
+	if kind == TypeHTML {
+		return "response is an HTML page, probably an error, login or challenge page"
+	}
+	if contentType != "" && !strings.HasPrefix(strings.ToLower(contentType), "image/") {
+		return "response is not an image (Content-Type " + contentType + ")"
+	}
+	return "response is not a recognized image format"
+}
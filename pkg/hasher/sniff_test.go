@@ -0,0 +1,75 @@
+package hasher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDetectContentType(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		contentType string
+		expected    string
+	}{
+		{"ICO", "\x00\x00\x01\x00\x01\x00", "image/x-icon", TypeICO},
+		{"PNG", "\x89PNG\r\n\x1a\n\x00\x00", "", TypePNG},
+		{"GIF", "GIF89a\x01\x00", "", TypeGIF},
+		{"JPEG", "\xff\xd8\xff\xe0", "", TypeJPEG},
+		{"WebP", "RIFF\x24\x00\x00\x00WEBPVP8 ", "", TypeWebP},
+		{"BMP", "BM\x3e\x00", "", TypeBMP},
+		{"SVG", `<svg xmlns="http://www.w3.org/2000/svg"></svg>`, "", TypeSVG},
+		{"SVG with declaration", "\xef\xbb\xbf<?xml version=\"1.0\"?>\n<!-- icon -->\n<svg></svg>", "", TypeSVG},
+		{"SVG by Content-Type", `<?xml version="1.0"?><!DOCTYPE x>`, "image/svg+xml", TypeSVG},
+		{"HTML", "\n  <!DOCTYPE html><html><head><title>Not Found</title>", "", TypeHTML},
+		{"HTML fragment", "<script>location='/login'</script>", "", TypeHTML},
+		{"HTML by Content-Type", "Access denied", "text/html; charset=utf-8", TypeHTML},
+		{"Magic bytes win", "\x89PNG\r\n\x1a\n", "text/html", TypePNG},
+		{"Empty", "", "image/x-icon", TypeUnknown},
+		{"Text", `{"error":"not found"}`, "application/json", TypeUnknown},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if kind := DetectContentType([]byte(test.data), test.contentType); kind != test.expected {
+				t.Errorf("DetectContentType() = %q, expected %q", kind, test.expected)
+			}
+		})
+	}
+}
+
+func TestFetchContentType(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/favicon.ico" {
+			w.Write([]byte{0, 0, 1, 0})
+			return
+		}
+		// A soft 404: an error page served with 200 OK
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><body>Page not found</body></html>"))
+	}))
+	defer server.Close()
+
+	h := New(DefaultOptions())
+	result, err := h.Fetch(context.Background(), server.URL+"/favicon.ico")
+	if err != nil || result.Type != TypeICO || result.Warning != "" {
+		t.Errorf("Expected an ICO without warning, got %+v, %v", result, err)
+	}
+
+	result, err = h.Fetch(context.Background(), server.URL+"/missing.ico")
+	if err != nil || result.Type != TypeHTML || result.ContentType != "text/html" || result.Warning == "" {
+		t.Errorf("Expected an HTML page with a warning, got %+v, %v", result, err)
+	}
+
+	strict := h.WithOptions(func(o *HashOptions) { o.Strict = true })
+	var typeErr *ContentTypeError
+	if _, err := strict.HashFromURL(server.URL + "/missing.ico"); !errors.As(err, &typeErr) || typeErr.Type != TypeHTML {
+		t.Errorf("Expected a ContentTypeError in strict mode, got %v", err)
+	}
+	if _, err := strict.HashFromURL(server.URL + "/favicon.ico"); err != nil {
+		t.Errorf("Expected images to pass strict mode, got %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+// Package logging configures the leveled, structured logs of the CLI and the
+// API server on top of log/slog. Logs go to stderr or a file, never to
+// stdout, which belongs to command output and the MCP stdio transport.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Options configures a logger
+type Options struct {
+	// Level is the minimum level logged: debug, info, warn or error
+	Level string
+	// Format is the handler format: text or json
+	Format string
+	// File is a file that logs are appended to instead of Output
+	File string
+	// Output receives the logs when File is empty; nil means stderr
+	Output io.Writer
+}
+
+// DefaultOptions returns options logging info and above as text to stderr
+func DefaultOptions() *Options {
+	return &Options{
+		Level:  "info",
+		Format: "text",
+	}
+}
+
+// nopCloser is returned when the logger does not own its output
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// New creates a logger from the options. The returned closer closes the log
+// file, if any, and must be called once the logger is no longer used.
+func New(options *Options) (*slog.Logger, io.Closer, error) {
+	if options == nil {
+		options = DefaultOptions()
+	}
+
+	level, err := ParseLevel(options.Level)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		out              = options.Output
+		closer io.Closer = nopCloser{}
+	)
+	if options.File != "" {
+		f, err := os.OpenFile(options.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+		out, closer = f, f
+	}
+	if out == nil {
+		out = os.Stderr
+	}
+
+	handlerOptions := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(options.Format) {
+	case "", "text":
+		handler = slog.NewTextHandler(out, handlerOptions)
+	case "json":
+		handler = slog.NewJSONHandler(out, handlerOptions)
+	default:
+		closer.Close()
+		return nil, nil, fmt.Errorf("unknown log format %q (expected text or json)", options.Format)
+	}
+
+	return slog.New(&contextHandler{Handler: handler}), closer, nil
+}
+
+// ParseLevel parses a level name. The empty string is info.
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("unknown log level %q (expected debug, info, warn or error)", s)
+	}
+}
+
+// Discard returns a logger that drops every record
+func Discard() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError + 1}))
+}
+
+// contextKey is the type of context keys defined by this package
+type contextKey int
+
+const requestIDKey contextKey = iota
+
+// WithRequestID returns a context whose log records carry the request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns the request ID stored in the context, if any
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// WithRequestIDs returns a logger that adds the request ID of the context to
+// records, for loggers not created by New
+func WithRequestIDs(logger *slog.Logger) *slog.Logger {
+	if _, ok := logger.Handler().(*contextHandler); ok {
+		return logger
+	}
+	return slog.New(&contextHandler{Handler: logger.Handler()})
+}
+
+// contextHandler adds the request ID of the context to records logged with
+// the *Context methods of slog.Logger
+type contextHandler struct {
+	slog.Handler
+}
+
+// Handle adds the request_id attribute before passing the record on
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs keeps the request ID handling for derived loggers
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup keeps the request ID handling for derived loggers
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	logger, closer, err := New(&Options{Level: "warn", Format: "json", Output: &buf})
+	if err != nil {
+		t.Fatalf("New() returned error: %v", err)
+	}
+	defer closer.Close()
+
+	logger.Info("dropped")
+	logger.WarnContext(WithRequestID(context.Background(), "req-1"), "kept", "count", 3)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected 1 log line, got %d: %q", len(lines), buf.String())
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("Invalid JSON log line %q: %v", lines[0], err)
+	}
+	if record["msg"] != "kept" || record["level"] != "WARN" || record["request_id"] != "req-1" || record["count"] != float64(3) {
+		t.Errorf("Unexpected record: %v", record)
+	}
+
+	// Derived loggers keep the request ID
+	buf.Reset()
+	logger.With("component", "test").ErrorContext(WithRequestID(context.Background(), "req-2"), "failed")
+	if !strings.Contains(buf.String(), `"request_id":"req-2"`) {
+		t.Errorf("Expected the request ID in %q", buf.String())
+	}
+}
+
+func TestNewFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "iconhash.log")
+	logger, closer, err := New(&Options{Level: "debug", File: path})
+	if err != nil {
+		t.Fatalf("New() returned error: %v", err)
+	}
+	logger.Debug("to the file")
+	closer.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read the log file: %v", err)
+	}
+	if !strings.Contains(string(data), `level=DEBUG msg="to the file"`) {
+		t.Errorf("Unexpected log file content %q", data)
+	}
+}
+
+func TestNewInvalidOptions(t *testing.T) {
+	if _, _, err := New(&Options{Level: "verbose"}); err == nil {
+		t.Error("Expected an error for an unknown level")
+	}
+	if _, _, err := New(&Options{Format: "xml"}); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, name := range []string{"debug", "INFO", "", "warn", "warning", "error"} {
+		if _, err := ParseLevel(name); err != nil {
+			t.Errorf("ParseLevel(%q) returned error: %v", name, err)
+		}
+	}
+}
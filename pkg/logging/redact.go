@@ -0,0 +1,96 @@
+package logging
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Redacted replaces secret values in logs
+const Redacted = "REDACTED"
+
+// sensitiveNames are query parameters and headers whose values are secrets
+var sensitiveNames = map[string]bool{
+	"auth":          true,
+	"authorization": true,
+	"code":          true,
+	"cookie":        true,
+	"key":           true,
+	"sig":           true,
+	"set-cookie":    true,
+}
+
+// sensitiveParts mark names containing them as secret, such as access_token
+// or x-api-key
+var sensitiveParts = []string{"token", "secret", "passw", "apikey", "api_key", "api-key", "signature", "session", "credential"}
+
+// IsSensitive reports whether a query parameter or header name carries a secret
+func IsSensitive(name string) bool {
+	name = strings.ToLower(name)
+	if sensitiveNames[name] {
+		return true
+	}
+	for _, part := range sensitiveParts {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactURL returns the URL with secret query parameters and any userinfo
+// replaced. Parameters holding URLs, such as ?url=https://...?token=x, are
+// redacted one level deep. Unparseable URLs lose their whole query.
+func RedactURL(raw string) string {
+	return redactURL(raw, 1)
+}
+
+func redactURL(raw string, depth int) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		base, _, _ := strings.Cut(raw, "?")
+		return base
+	}
+
+	if u.User != nil {
+		u.User = url.User(Redacted)
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = redactQuery(u.RawQuery, depth)
+	}
+	return u.String()
+}
+
+// RedactQuery returns an encoded query string with secret values replaced
+func RedactQuery(rawQuery string) string {
+	return redactQuery(rawQuery, 1)
+}
+
+func redactQuery(rawQuery string, depth int) string {
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return Redacted
+	}
+	for name, vals := range values {
+		for i, v := range vals {
+			switch {
+			case IsSensitive(name):
+				vals[i] = Redacted
+			case depth > 0 && (strings.HasPrefix(v, "http://") || strings.HasPrefix(v, "https://")):
+				vals[i] = redactURL(v, depth-1)
+			}
+		}
+	}
+	return values.Encode()
+}
+
+// RedactHeader returns the value of a header safe for logging. The scheme of
+// Authorization headers is kept, so "Bearer abc" becomes "Bearer REDACTED".
+func RedactHeader(name, value string) string {
+	if !IsSensitive(name) {
+		return value
+	}
+	if scheme, _, ok := strings.Cut(value, " "); ok && strings.Contains(strings.ToLower(name), "authorization") {
+		return scheme + " " + Redacted
+	}
+	return Redacted
+}
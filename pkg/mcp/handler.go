@@ -1,43 +1,85 @@
 package mcp
 
 import (
+	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"io/fs"
+	"log/slog"
 	"net/url"
 	"regexp"
 	"strings"
 
+	"github.com/cyberspacesec/go-iconhash/pkg/fingerprint"
 	"github.com/cyberspacesec/go-iconhash/pkg/hasher"
+	"github.com/cyberspacesec/go-iconhash/pkg/logging"
 	"github.com/cyberspacesec/go-iconhash/pkg/util"
 )
 
 // Handler processes MCP requests and generates responses
 type Handler struct {
-	iconHasher *hasher.IconHasher
-	logger     *util.Logger
-	options    *hasher.HashOptions
-	debug      bool
+	iconHasher   *hasher.IconHasher
+	fingerprints *fingerprint.DB
+	files        *hasher.FileSandbox
+	logger       *slog.Logger
 }
 
-// NewHandler creates a new MCP handler
+// base64Pattern matches base64 data in legacy messages: either a data URL or
+// a run of at least 32 base64 characters, so that ordinary words never match
+var base64Pattern = regexp.MustCompile(`data:[^;,\s]+;base64,([A-Za-z0-9+/]+={0,2})|\b([A-Za-z0-9+/]{32,}={0,2})`)
+
+// urlPattern matches http(s) URLs in legacy messages
+var urlPattern = regexp.MustCompile(`https?://[^\s]+`)
+
+// pathPattern matches absolute file paths in legacy messages
+var pathPattern = regexp.MustCompile(`(?:^|\s)(/[^\s]+|[A-Za-z]:\\[^\s]+)`)
+
+// NewHandler creates a new MCP handler with the default hasher options. It logs to the default slog logger,
+// or to stderr at debug level when debug is set.
 func NewHandler(debug bool) *Handler {
-	options := &hasher.HashOptions{
-		UseUint32:          false,
-		RequestTimeout:     hasher.DefaultOptions().RequestTimeout,
-		InsecureSkipVerify: true,
-		UserAgent:          hasher.DefaultOptions().UserAgent,
+	var logger *slog.Logger
+	if debug {
+		logger, _, _ = logging.New(&logging.Options{Level: "debug"})
 	}
+	return NewHandlerWithHasher(hasher.New(hasher.DefaultOptions()), logger)
+}
 
+// NewHandlerWithHasher creates an MCP handler that uses an existing hasher,
+// so that it shares its HTTP client, rate limits and instrumentation. A nil
+// logger uses the default slog logger.
+func NewHandlerWithHasher(iconHasher *hasher.IconHasher, logger *slog.Logger) *Handler {
+	if logger == nil {
+		logger = slog.Default()
+	}
 	return &Handler{
-		iconHasher: hasher.New(options),
-		logger:     util.NewLogger(debug),
-		options:    options,
-		debug:      debug,
+		iconHasher:   iconHasher,
+		fingerprints: fingerprint.Default(),
+		logger:       logger,
 	}
 }
 
-// Process processes an MCP request and returns a response
+// SetLogger replaces the handler's logger. The stdio transport owns stdout,
+// so its logger must write elsewhere, usually to stderr.
+func (h *Handler) SetLogger(logger *slog.Logger) {
+	h.logger = logger
+}
+
+// SetFileSandbox enables the hash_file tool and file paths in legacy
+// messages, limited to the sandbox. File access is disabled by default.
+func (h *Handler) SetFileSandbox(sandbox *hasher.FileSandbox) {
+	h.files = sandbox
+}
+
+// Process processes a request in the legacy message format, which guesses
+// the intent from the last user message. JSON-RPC clients use HandleMessage.
 func (h *Handler) Process(req *Request) (*Response, error) {
+	return h.ProcessContext(context.Background(), req)
+}
+
+// ProcessContext is like Process, honouring the request restrictions set in
+// ctx, such as WithoutFileAccess and WithoutURLAccess
+func (h *Handler) ProcessContext(ctx context.Context, req *Request) (*Response, error) {
 	// Validate the request
 	if err := req.Validate(); err != nil {
 		return nil, err
@@ -62,13 +104,10 @@ func (h *Handler) Process(req *Request) (*Response, error) {
 		return resp, nil
 	}
 
-	// Debug logging
-	if h.debug {
-		h.logger.Debugf("Processing MCP message: %s", lastUserMessage)
-	}
+	h.logger.DebugContext(ctx, "Processing MCP message", "length", len(lastUserMessage))
 
 	// Process the message and generate a response
-	result, err := h.processMessage(lastUserMessage)
+	result, err := h.processMessage(ctx, lastUserMessage)
 	if err != nil {
 		resp.Message.Content = fmt.Sprintf("Error: %v", err)
 		resp.Message.Meta["error"] = err.Error()
@@ -82,39 +121,39 @@ func (h *Handler) Process(req *Request) (*Response, error) {
 }
 
 // processMessage processes a user message and returns a result
-func (h *Handler) processMessage(message string) (string, error) {
-	// Check if the message contains a URL
-	urlPattern := regexp.MustCompile(`https?://[^\s]+`)
-	if urlPattern.MatchString(message) {
-		urls := urlPattern.FindAllString(message, -1)
-		if h.debug {
-			h.logger.Debugf("Found URL in message: %s", urls[0])
-		}
+func (h *Handler) processMessage(ctx context.Context, message string) (string, error) {
+	// Check if the message contains a URL, when URLs may be fetched
+	if urls := urlPattern.FindAllString(message, -1); len(urls) > 0 && h.urlAccess(ctx) {
+		h.logger.DebugContext(ctx, "Found URL in message", "url", logging.RedactURL(urls[0]))
 		return h.processURL(urls[0])
 	}
 
-	// Check if the message contains base64 data
-	base64Pattern := regexp.MustCompile(`(?:data:[^;]+;base64,)?([A-Za-z0-9+/=]+)`)
-	if base64Pattern.MatchString(message) {
-		matches := base64Pattern.FindStringSubmatch(message)
-		if len(matches) > 1 {
-			// Check if it's a valid base64 string
-			if _, err := base64.StdEncoding.DecodeString(matches[1]); err == nil {
-				if h.debug {
-					h.logger.Debugf("Found base64 data in message (length: %d)", len(matches[1]))
-				}
-				return h.processBase64(matches[1])
+	// Check if the message contains a file path, when files may be read.
+	// Paths are checked first, since long paths look like base64; base64
+	// that looks like a path does not exist and falls through.
+	if h.fileAccess(ctx) {
+		if matches := pathPattern.FindStringSubmatch(message); matches != nil {
+			if _, err := h.files.Resolve(matches[1]); !errors.Is(err, fs.ErrNotExist) {
+				return h.processFile(matches[1])
 			}
 		}
 	}
 
+	// Check if the message contains base64 data
+	for _, matches := range base64Pattern.FindAllStringSubmatch(message, -1) {
+		data := matches[1] + matches[2]
+		if _, err := base64.StdEncoding.DecodeString(data); err != nil {
+			continue
+		}
+		h.logger.DebugContext(ctx, "Found base64 data in message", "length", len(data))
+		return h.processBase64(data)
+	}
+
 	// Assume the message contains commands or requests about the tool
 	if strings.Contains(strings.ToLower(message), "help") ||
 		strings.Contains(strings.ToLower(message), "how to") ||
 		strings.Contains(strings.ToLower(message), "example") {
-		if h.debug {
-			h.logger.Debugf("Detected help request, returning help text")
-		}
+		h.logger.DebugContext(ctx, "Detected help request, returning help text")
 		return h.getHelpText(), nil
 	}
 
@@ -129,9 +168,7 @@ func (h *Handler) processURL(urlStr string) (string, error) {
 		return "", fmt.Errorf("invalid URL: %v", err)
 	}
 
-	if h.debug {
-		h.logger.Debugf("Processing URL: %s", urlStr)
-	}
+	h.logger.Debug("Processing URL", "url", logging.RedactURL(urlStr))
 
 	// Calculate hash
 	hash, err := h.iconHasher.HashFromURL(urlStr)
@@ -139,24 +176,26 @@ func (h *Handler) processURL(urlStr string) (string, error) {
 		return "", fmt.Errorf("error calculating hash: %v", err)
 	}
 
-	if h.debug {
-		h.logger.Debugf("Calculated hash: %s", hash)
-	}
+	h.logger.Debug("Calculated hash", "hash", hash)
 
-	// Format the result
-	result := fmt.Sprintf("Favicon Hash for %s:\n\n", urlStr)
-	result += fmt.Sprintf("Plain hash: %s\n", hash)
-	result += fmt.Sprintf("Fofa format: %s\n", util.FormatHash(hash, util.FormatFofa))
-	result += fmt.Sprintf("Shodan format: %s\n", util.FormatHash(hash, util.FormatShodan))
+	return formatHashText(urlStr, hash), nil
+}
 
-	return result, nil
+// processFile processes a file path and returns the hash
+func (h *Handler) processFile(path string) (string, error) {
+	h.logger.Debug("Processing file", "path", path)
+
+	hash, err := h.iconHasher.HashFromSandboxedFile(h.files, path)
+	if err != nil {
+		return "", fmt.Errorf("error calculating hash: %v", err)
+	}
+
+	return formatHashText(path, hash), nil
 }
 
 // processBase64 processes base64 data and returns the hash
 func (h *Handler) processBase64(data string) (string, error) {
-	if h.debug {
-		h.logger.Debugf("Processing base64 data (length: %d)", len(data))
-	}
+	h.logger.Debug("Processing base64 data", "length", len(data))
 
 	// Calculate hash
 	hash, err := h.iconHasher.HashFromBase64(data)
@@ -164,17 +203,18 @@ func (h *Handler) processBase64(data string) (string, error) {
 		return "", fmt.Errorf("error calculating hash: %v", err)
 	}
 
-	if h.debug {
-		h.logger.Debugf("Calculated hash: %s", hash)
-	}
+	h.logger.Debug("Calculated hash", "hash", hash)
+
+	return formatHashText("provided base64 data", hash), nil
+}
 
-	// Format the result
-	result := "Favicon Hash for provided base64 data:\n\n"
+// formatHashText describes a hash and its search queries for a source
+func formatHashText(source, hash string) string {
+	result := fmt.Sprintf("Favicon Hash for %s:\n\n", source)
 	result += fmt.Sprintf("Plain hash: %s\n", hash)
 	result += fmt.Sprintf("Fofa format: %s\n", util.FormatHash(hash, util.FormatFofa))
 	result += fmt.Sprintf("Shodan format: %s\n", util.FormatHash(hash, util.FormatShodan))
-
-	return result, nil
+	return result
 }
 
 // getHelpText returns help text for the MCP
@@ -1,10 +1,15 @@
 package mcp
 
 import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
-	"github.com/cyberspacesec/go-iconhash/pkg/util"
+	"github.com/cyberspacesec/go-iconhash/pkg/hasher"
+	"github.com/cyberspacesec/go-iconhash/pkg/logging"
 )
 
 func TestNewRequest(t *testing.T) {
@@ -124,7 +129,7 @@ func TestResponseComplete(t *testing.T) {
 
 // MockHandler is a mock implementation of Handler for testing
 type MockHandler struct {
-	logger *util.Logger
+	logger *slog.Logger
 }
 
 // Process implements a mock version of the Process method
@@ -169,7 +174,7 @@ func (h *MockHandler) Process(req *Request) (*Response, error) {
 func TestHandlerProcess(t *testing.T) {
 	// Create a mock handler for testing
 	mockHandler := &MockHandler{
-		logger: util.NewLogger(false),
+		logger: logging.Discard(),
 	}
 
 	tests := []struct {
@@ -218,3 +223,50 @@ func TestHandlerProcess(t *testing.T) {
 		})
 	}
 }
+
+func TestLegacyBase64Detection(t *testing.T) {
+	h := NewHandler(false)
+
+	// Ordinary words are valid base64 but must not be treated as favicon data
+	if _, err := h.processMessage(context.Background(), "Please calculate this"); err == nil {
+		t.Error("Expected ordinary text to be rejected")
+	}
+
+	result, err := h.processMessage(context.Background(), "Hash data:image/x-icon;base64,AAABAAEAEBA= please")
+	if err != nil || !strings.Contains(result, "Plain hash:") {
+		t.Errorf("Expected data URL to be hashed, got %q, %v", result, err)
+	}
+
+	result, err = h.processMessage(context.Background(), "Hash AAABAAEAEBAAAAEAIABoBAAAFgAAACgAAAAQ")
+	if err != nil || !strings.Contains(result, "Plain hash:") {
+		t.Errorf("Expected long base64 run to be hashed, got %q, %v", result, err)
+	}
+}
+
+func TestLegacyFilePath(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "favicon.ico"), []byte("icon"), 0o644)
+	message := "Hash " + filepath.Join(root, "favicon.ico")
+	hash, _ := hasher.New(nil).HashFromBytes([]byte("icon"))
+
+	// Long paths may be mistaken for base64 without a sandbox, but the
+	// file itself is never read
+	h := NewHandler(false)
+	if result, _ := h.processMessage(context.Background(), message); strings.Contains(result, hash) {
+		t.Error("Expected file paths to be ignored without a sandbox")
+	}
+
+	sandbox, _ := hasher.NewFileSandbox([]string{root}, 0)
+	h.SetFileSandbox(sandbox)
+	result, err := h.processMessage(context.Background(), message)
+	if err != nil || !strings.Contains(result, hash) {
+		t.Errorf("Expected the file to be hashed, got %q, %v", result, err)
+	}
+
+	if result, _ := h.processMessage(WithoutFileAccess(context.Background()), message); strings.Contains(result, hash) {
+		t.Error("Expected file paths to be ignored without file access")
+	}
+	if _, err := h.processMessage(context.Background(), "Hash /etc/passwd"); err == nil {
+		t.Error("Expected paths outside the roots to be refused")
+	}
+}
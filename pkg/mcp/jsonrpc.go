@@ -0,0 +1,147 @@
+package mcp
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// JSONRPCVersion is the JSON-RPC version used by the Model Context Protocol
+const JSONRPCVersion = "2.0"
+
+// JSON-RPC error codes
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+
+	// CodeResourceNotFound is the MCP error code for unknown resource URIs
+	CodeResourceNotFound = -32002
+)
+
+// RPCRequest is a JSON-RPC 2.0 request or notification. Notifications have no ID.
+type RPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// RPCResponse is a JSON-RPC 2.0 response
+type RPCResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// RPCNotification is a JSON-RPC 2.0 notification sent by the server
+type RPCNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+// RPCError is the error object of a JSON-RPC 2.0 response
+type RPCError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// Error implements the error interface
+func (e *RPCError) Error() string {
+	return e.Message
+}
+
+// IsNotification reports whether the request expects no response
+func (r *RPCRequest) IsNotification() bool {
+	return len(r.ID) == 0
+}
+
+// nullID is the ID of responses to requests whose ID could not be read
+var nullID = json.RawMessage("null")
+
+// newRPCResult creates a successful response
+func newRPCResult(id json.RawMessage, result interface{}) *RPCResponse {
+	return &RPCResponse{JSONRPC: JSONRPCVersion, ID: id, Result: result}
+}
+
+// newRPCError creates an error response
+func newRPCError(id json.RawMessage, code int, message string) *RPCResponse {
+	if len(id) == 0 {
+		id = nullID
+	}
+	return &RPCResponse{
+		JSONRPC: JSONRPCVersion,
+		ID:      id,
+		Error:   &RPCError{Code: code, Message: message},
+	}
+}
+
+// IsJSONRPC reports whether a message body is JSON-RPC rather than the legacy
+// request shape: either a batch array or an object with a "jsonrpc" member
+func IsJSONRPC(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		return true
+	}
+
+	var probe struct {
+		JSONRPC *string `json:"jsonrpc"`
+	}
+	return json.Unmarshal(data, &probe) == nil && probe.JSONRPC != nil
+}
+
+// messageHeaders decodes the id and method of every message in a message or
+// batch. Messages that cannot be decoded get a null ID, since they will be
+// answered with an error.
+func messageHeaders(data []byte) []RPCRequest {
+	data = bytes.TrimSpace(data)
+
+	var raw []json.RawMessage
+	if len(data) > 0 && data[0] == '[' {
+		if json.Unmarshal(data, &raw) != nil {
+			return nil
+		}
+	} else {
+		raw = []json.RawMessage{data}
+	}
+
+	headers := make([]RPCRequest, 0, len(raw))
+	for _, msg := range raw {
+		var req RPCRequest
+		if json.Unmarshal(msg, &req) != nil {
+			req = RPCRequest{ID: nullID}
+		}
+		req.Params = nil
+		headers = append(headers, req)
+	}
+	return headers
+}
+
+// HasRequests reports whether a message or batch will be answered, that is
+// whether it contains a request or an invalid message
+func HasRequests(data []byte) bool {
+	headers := messageHeaders(data)
+	if len(headers) == 0 {
+		return true
+	}
+	for _, req := range headers {
+		if !req.IsNotification() {
+			return true
+		}
+	}
+	return false
+}
+
+// IsInitializeRequest reports whether a message is an initialize request
+func IsInitializeRequest(data []byte) bool {
+	for _, req := range messageHeaders(data) {
+		if req.Method == "initialize" && !req.IsNotification() {
+			return true
+		}
+	}
+	return false
+}
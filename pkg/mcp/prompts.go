@@ -0,0 +1,166 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/util"
+)
+
+// Prompt describes a prompt template that clients can get with prompts/get
+type Prompt struct {
+	Name        string           `json:"name"`
+	Title       string           `json:"title,omitempty"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+
+	get func(h *Handler, ctx context.Context, args map[string]string) (*GetPromptResult, error)
+}
+
+// PromptArgument describes an argument of a prompt template
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// GetPromptParams are the parameters of the prompts/get request
+type GetPromptParams struct {
+	Name      string            `json:"name"`
+	Arguments map[string]string `json:"arguments,omitempty"`
+}
+
+// GetPromptResult is the result of the prompts/get request
+type GetPromptResult struct {
+	Description string          `json:"description,omitempty"`
+	Messages    []PromptMessage `json:"messages"`
+}
+
+// PromptMessage is a message of a prompt
+type PromptMessage struct {
+	Role    string  `json:"role"`
+	Content Content `json:"content"`
+}
+
+// Prompts returns the prompt templates exposed by the server
+func Prompts() []Prompt {
+	return []Prompt{
+		{
+			Name:        "investigate_favicon",
+			Title:       "Investigate a favicon",
+			Description: "Identify what a site or favicon hash belongs to and how to find related hosts.",
+			Arguments: []PromptArgument{
+				{Name: "target", Description: "URL of a site or favicon, or a favicon hash", Required: true},
+			},
+			get: (*Handler).promptInvestigateFavicon,
+		},
+		{
+			Name:        "hunt_product",
+			Title:       "Hunt a product by favicon",
+			Description: "Build favicon hash queries that find deployments of a product.",
+			Arguments: []PromptArgument{
+				{Name: "product", Description: "Product or vendor name, e.g. Jenkins", Required: true},
+				{Name: "engine", Description: "Search engine: " + strings.Join(queryEngines, ", ") + " (default: fofa)"},
+			},
+			get: (*Handler).promptHuntProduct,
+		},
+	}
+}
+
+// GetPrompt renders a prompt template
+func (h *Handler) GetPrompt(ctx context.Context, params *GetPromptParams) (*GetPromptResult, *RPCError) {
+	for _, prompt := range Prompts() {
+		if prompt.Name != params.Name {
+			continue
+		}
+
+		for _, arg := range prompt.Arguments {
+			if arg.Required && strings.TrimSpace(params.Arguments[arg.Name]) == "" {
+				return nil, &RPCError{Code: CodeInvalidParams, Message: "Invalid arguments: " + arg.Name + " is required"}
+			}
+		}
+
+		result, err := prompt.get(h, ctx, params.Arguments)
+		if err != nil {
+			if rpcErr, ok := err.(*RPCError); ok {
+				return nil, rpcErr
+			}
+			return nil, &RPCError{Code: CodeInternalError, Message: err.Error()}
+		}
+		return result, nil
+	}
+
+	return nil, &RPCError{Code: CodeInvalidParams, Message: "Unknown prompt: " + params.Name}
+}
+
+// promptInvestigateFavicon implements the investigate_favicon prompt
+func (h *Handler) promptInvestigateFavicon(ctx context.Context, args map[string]string) (*GetPromptResult, error) {
+	target := strings.TrimSpace(args["target"])
+
+	if isHashValue(target) {
+		info, _ := h.ReadResource(ctx, &ReadResourceParams{URI: hashTemplatePrefix + target})
+		return &GetPromptResult{
+			Description: "Investigate favicon hash " + target,
+			Messages: []PromptMessage{
+				userText(fmt.Sprintf("Investigate the favicon hash %s. Using the hash details below, say which products "+
+					"are known to serve it, give the Fofa and Shodan queries that find hosts with this favicon, "+
+					"and suggest filters that narrow the results down.", target)),
+				{Role: "user", Content: Content{Type: "resource", Resource: &info.Contents[0]}},
+			},
+		}, nil
+	}
+
+	if err := validateHTTPURL(target); err != nil {
+		return nil, &RPCError{Code: CodeInvalidParams, Message: "Invalid arguments: target must be an http or https URL or a decimal hash"}
+	}
+	return &GetPromptResult{
+		Description: "Investigate the favicons of " + target,
+		Messages: []PromptMessage{
+			userText(fmt.Sprintf("Investigate the favicon of %s.\n\n"+
+				"1. Call discover_favicons with url %q and hash true to find and hash its icons. "+
+				"If the URL points at an image, call hash_url instead.\n"+
+				"2. For every hash, read the resource %s{hash} to see the known products and the search queries.\n"+
+				"3. Summarize what the site is likely running, how confident the match is, "+
+				"and the Fofa and Shodan queries that find related hosts.", target, target, hashTemplatePrefix)),
+		},
+	}, nil
+}
+
+// promptHuntProduct implements the hunt_product prompt
+func (h *Handler) promptHuntProduct(ctx context.Context, args map[string]string) (*GetPromptResult, error) {
+	product := strings.TrimSpace(args["product"])
+	engineName := args["engine"]
+	if engineName == "" {
+		engineName = "fofa"
+	}
+	engine, ok := util.LookupEngine(engineName)
+	if !ok {
+		return nil, &RPCError{Code: CodeInvalidParams, Message: "Invalid arguments: engine must be one of " + strings.Join(queryEngines, ", ")}
+	}
+
+	var b strings.Builder
+	matches := h.fingerprints.Search(product)
+	if len(matches) == 0 {
+		fmt.Fprintf(&b, "No favicon hash for %q is in the built-in fingerprint database. "+
+			"Find a known deployment of the product, hash its favicon with discover_favicons or hash_url, "+
+			"and give the %s query for that hash (syntax: %s).", product, engine.Title, engine.Syntax)
+	} else {
+		fmt.Fprintf(&b, "Known favicon hashes for %q:\n\n", product)
+		for _, entry := range matches {
+			fmt.Fprintf(&b, "- %s %s: %s\n", entry.Vendor, entry.Product, util.FormatHash(entry.Hash, engine.Format))
+		}
+		fmt.Fprintf(&b, "\nExplain which %s queries above find deployments of %s, combine them into one query where the engine allows it, "+
+			"and point out that a matching favicon alone does not prove which version is running.", engine.Title, product)
+	}
+
+	return &GetPromptResult{
+		Description: "Find deployments of " + product,
+		Messages:    []PromptMessage{userText(b.String())},
+	}, nil
+}
+
+// userText builds a user message with a text block
+func userText(text string) PromptMessage {
+	return PromptMessage{Role: "user", Content: Content{Type: "text", Text: text}}
+}
@@ -0,0 +1,204 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/fingerprint"
+	"github.com/cyberspacesec/go-iconhash/pkg/util"
+)
+
+// Resource URIs
+const (
+	resourceEngines      = "iconhash://engines"
+	resourceFingerprints = "iconhash://fingerprints"
+	resourceRecent       = "iconhash://results/recent"
+	hashTemplatePrefix   = "iconhash://hash/"
+)
+
+// Resource describes a resource that clients can read with resources/read
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ResourceTemplate describes a family of resources by an RFC 6570 URI template
+type ResourceTemplate struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ReadResourceParams are the parameters of the resources/read request
+type ReadResourceParams struct {
+	URI string `json:"uri"`
+}
+
+// ReadResourceResult is the result of the resources/read request
+type ReadResourceResult struct {
+	Contents []ResourceContents `json:"contents"`
+}
+
+// ResourceContents is the text content of a resource
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text"`
+}
+
+// HashInfo is the content of an iconhash://hash/{value} resource
+type HashInfo struct {
+	Hash         string              `json:"hash"`
+	Uint32       string              `json:"uint32"`
+	Queries      map[string]string   `json:"queries"`
+	Fingerprints []fingerprint.Entry `json:"fingerprints"`
+	Seen         []RecentResult      `json:"seen,omitempty"`
+}
+
+// Resources returns the static resources exposed by the server
+func Resources() []Resource {
+	return []Resource{
+		{
+			URI:         resourceEngines,
+			Name:        "engines",
+			Title:       "Search engine syntax",
+			Description: "How each supported search engine indexes favicon hashes and the query syntax to search for them.",
+			MimeType:    "text/markdown",
+		},
+		{
+			URI:         resourceFingerprints,
+			Name:        "fingerprints",
+			Title:       "Known favicon hashes",
+			Description: "The built-in database mapping favicon hashes to products.",
+			MimeType:    "application/json",
+		},
+		{
+			URI:         resourceRecent,
+			Name:        "recent-results",
+			Title:       "Recent results",
+			Description: fmt.Sprintf("The last %d hashes calculated by tools in this session, newest first.", maxRecentResults),
+			MimeType:    "application/json",
+		},
+	}
+}
+
+// ResourceTemplates returns the resource templates exposed by the server
+func ResourceTemplates() []ResourceTemplate {
+	return []ResourceTemplate{
+		{
+			URITemplate: hashTemplatePrefix + "{value}",
+			Name:        "hash",
+			Title:       "Favicon hash",
+			Description: "A favicon hash given as int32 or uint32: its search queries for every engine, " +
+				"the known products serving it and where it was seen in this session.",
+			MimeType: "application/json",
+		},
+	}
+}
+
+// ReadResource returns the contents of a resource
+func (h *Handler) ReadResource(ctx context.Context, params *ReadResourceParams) (*ReadResourceResult, *RPCError) {
+	var (
+		mimeType = "application/json"
+		text     string
+	)
+
+	switch {
+	case params.URI == resourceEngines:
+		mimeType = "text/markdown"
+		text = enginesDocument()
+	case params.URI == resourceFingerprints:
+		text = string(encode(map[string]interface{}{"fingerprints": h.fingerprints.Entries()}))
+	case params.URI == resourceRecent:
+		recent := []RecentResult{}
+		if session := SessionFromContext(ctx); session != nil {
+			recent = session.Recent()
+		}
+		text = string(encode(map[string]interface{}{"results": recent}))
+	case strings.HasPrefix(params.URI, hashTemplatePrefix):
+		value, err := url.PathUnescape(strings.TrimPrefix(params.URI, hashTemplatePrefix))
+		if err != nil || !isHashValue(value) {
+			return nil, &RPCError{Code: CodeInvalidParams, Message: "Invalid hash in resource URI: " + params.URI}
+		}
+		text = string(encode(h.hashInfo(ctx, value)))
+	default:
+		return nil, &RPCError{
+			Code:    CodeResourceNotFound,
+			Message: "Resource not found",
+			Data:    map[string]string{"uri": params.URI},
+		}
+	}
+
+	return &ReadResourceResult{
+		Contents: []ResourceContents{{URI: params.URI, MimeType: mimeType, Text: text}},
+	}, nil
+}
+
+// hashInfo describes a hash for the iconhash://hash/{value} resource
+func (h *Handler) hashInfo(ctx context.Context, value string) *HashInfo {
+	hash, _ := fingerprint.Normalize(value)
+	signed, _ := strconv.ParseInt(hash, 10, 32)
+
+	info := &HashInfo{
+		Hash:         hash,
+		Uint32:       strconv.FormatUint(uint64(uint32(signed)), 10),
+		Queries:      make(map[string]string),
+		Fingerprints: h.fingerprints.Lookup(hash),
+	}
+	if info.Fingerprints == nil {
+		info.Fingerprints = []fingerprint.Entry{}
+	}
+	for _, engine := range util.Engines() {
+		info.Queries[engine.Name] = util.FormatHash(hash, engine.Format)
+	}
+	if session := SessionFromContext(ctx); session != nil {
+		for _, result := range session.Recent() {
+			if normalized, err := fingerprint.Normalize(result.Hash); err == nil && normalized == hash {
+				info.Seen = append(info.Seen, result)
+			}
+		}
+	}
+	return info
+}
+
+// enginesDocument renders the search engine syntax documentation
+func enginesDocument() string {
+	var b strings.Builder
+	b.WriteString("# Favicon hash search syntax\n\n")
+	b.WriteString("iconhash calculates the MMH3 hash used by the search engines below. ")
+	b.WriteString("Hashes are signed int32 values unless --uint32 is used; convert uint32 values before searching.\n")
+	for _, engine := range util.Engines() {
+		fmt.Fprintf(&b, "\n## %s\n\n", engine.Title)
+		if engine.Field != "" {
+			fmt.Fprintf(&b, "- Field: `%s`\n", engine.Field)
+		}
+		fmt.Fprintf(&b, "- Syntax: `%s`\n", engine.Syntax)
+		if engine.BodySyntax != "" {
+			fmt.Fprintf(&b, "- HTML body hash: `%s`\n", engine.BodySyntax)
+		}
+		fmt.Fprintf(&b, "- format_query engine: `%s`\n\n", engine.Name)
+		b.WriteString(engine.Notes + "\n")
+	}
+	return b.String()
+}
+
+// decodeResourceParams decodes the parameters of resources/read
+func decodeResourceParams(raw json.RawMessage) (*ReadResourceParams, *RPCError) {
+	var params ReadResourceParams
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
+	}
+	if params.URI == "" {
+		return nil, &RPCError{Code: CodeInvalidParams, Message: "Invalid params: uri is required"}
+	}
+	return &params, nil
+}
@@ -0,0 +1,143 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestResourcesList(t *testing.T) {
+	h := NewHandler(false)
+
+	resp := call(t, h, `{"jsonrpc":"2.0","id":1,"method":"resources/list"}`)
+	var list struct {
+		Resources []Resource `json:"resources"`
+	}
+	json.Unmarshal(resp.Result.(json.RawMessage), &list)
+	if len(list.Resources) != len(Resources()) {
+		t.Errorf("Expected %d resources, got %+v", len(Resources()), resp.Result)
+	}
+
+	resp = call(t, h, `{"jsonrpc":"2.0","id":2,"method":"resources/templates/list"}`)
+	if !strings.Contains(string(resp.Result.(json.RawMessage)), `"uriTemplate":"iconhash://hash/{value}"`) {
+		t.Errorf("Expected the hash template, got %s", resp.Result)
+	}
+}
+
+func TestReadResource(t *testing.T) {
+	h := NewHandler(false)
+
+	tests := []struct {
+		name           string
+		uri            string
+		expectCode     int
+		expectMimeType string
+		expectContains string
+	}{
+		{"Engines", "iconhash://engines", 0, "text/markdown", "http.favicon.hash:<hash>"},
+		{"Fingerprints", "iconhash://fingerprints", 0, "application/json", `"product":"Jenkins"`},
+		{"Recent without session", "iconhash://results/recent", 0, "application/json", `{"results":[]}`},
+		{"Known hash", "iconhash://hash/81586312", 0, "application/json", `"product":"Jenkins"`},
+		{"Uint32 hash", "iconhash://hash/3989787984", 0, "application/json", `"fofa":"icon_hash=\"-305179312\""`},
+		{"Invalid hash", "iconhash://hash/abc", CodeInvalidParams, "", ""},
+		{"Unknown resource", "iconhash://nope", CodeResourceNotFound, "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, rpcErr := h.ReadResource(context.Background(), &ReadResourceParams{URI: test.uri})
+			if test.expectCode != 0 {
+				if rpcErr == nil || rpcErr.Code != test.expectCode {
+					t.Fatalf("Expected error code %d, got %+v", test.expectCode, rpcErr)
+				}
+				return
+			}
+			if rpcErr != nil {
+				t.Fatalf("Unexpected error: %v", rpcErr)
+			}
+
+			contents := result.Contents[0]
+			if contents.URI != test.uri || contents.MimeType != test.expectMimeType {
+				t.Errorf("Unexpected contents %s %s", contents.URI, contents.MimeType)
+			}
+			if !strings.Contains(contents.Text, test.expectContains) {
+				t.Errorf("Expected contents containing %q, got %s", test.expectContains, contents.Text)
+			}
+		})
+	}
+
+	resp := call(t, h, `{"jsonrpc":"2.0","id":1,"method":"resources/read","params":{}}`)
+	if resp.Error == nil || resp.Error.Code != CodeInvalidParams {
+		t.Errorf("Expected invalid params without a uri, got %+v", resp.Error)
+	}
+}
+
+func TestRecentResults(t *testing.T) {
+	h := NewHandler(false)
+	session := NewSession()
+	ctx := WithSession(context.Background(), session)
+
+	h.HandleMessage(ctx, []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"hash_base64","arguments":{"data":"AAABAAEAEBA="}}}`))
+	recent := session.Recent()
+	if len(recent) != 1 || recent[0].Source != "provided base64 data" {
+		t.Fatalf("Expected the hash to be recorded, got %+v", recent)
+	}
+
+	result, _ := h.ReadResource(ctx, &ReadResourceParams{URI: hashTemplatePrefix + recent[0].Hash})
+	var info HashInfo
+	json.Unmarshal([]byte(result.Contents[0].Text), &info)
+	if len(info.Seen) != 1 || info.Queries["shodan"] != "http.favicon.hash:"+recent[0].Hash {
+		t.Errorf("Unexpected hash info %+v", info)
+	}
+
+	for i := 0; i < maxRecentResults+5; i++ {
+		session.record("source", "1")
+	}
+	if len(session.Recent()) != maxRecentResults {
+		t.Errorf("Expected at most %d recent results, got %d", maxRecentResults, len(session.Recent()))
+	}
+}
+
+func TestPrompts(t *testing.T) {
+	h := NewHandler(false)
+
+	resp := call(t, h, `{"jsonrpc":"2.0","id":1,"method":"prompts/list"}`)
+	if !strings.Contains(string(resp.Result.(json.RawMessage)), `"name":"investigate_favicon"`) {
+		t.Errorf("Expected investigate_favicon in prompts/list, got %s", resp.Result)
+	}
+
+	tests := []struct {
+		name           string
+		params         string
+		expectCode     int
+		expectContains string
+	}{
+		{"Investigate URL", `{"name":"investigate_favicon","arguments":{"target":"https://example.com/"}}`, 0, "discover_favicons"},
+		{"Investigate hash", `{"name":"investigate_favicon","arguments":{"target":"81586312"}}`, 0, `"type":"resource"`},
+		{"Investigate invalid target", `{"name":"investigate_favicon","arguments":{"target":"example"}}`, CodeInvalidParams, ""},
+		{"Missing argument", `{"name":"investigate_favicon","arguments":{}}`, CodeInvalidParams, ""},
+		{"Hunt known product", `{"name":"hunt_product","arguments":{"product":"jenkins","engine":"shodan"}}`, 0, "http.favicon.hash:81586312"},
+		{"Hunt unknown product", `{"name":"hunt_product","arguments":{"product":"nothing"}}`, 0, "No favicon hash"},
+		{"Hunt invalid engine", `{"name":"hunt_product","arguments":{"product":"jenkins","engine":"bing"}}`, CodeInvalidParams, ""},
+		{"Unknown prompt", `{"name":"nope"}`, CodeInvalidParams, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := call(t, h, `{"jsonrpc":"2.0","id":2,"method":"prompts/get","params":`+test.params+`}`)
+			if test.expectCode != 0 {
+				if resp.Error == nil || resp.Error.Code != test.expectCode {
+					t.Fatalf("Expected error code %d, got %+v", test.expectCode, resp.Error)
+				}
+				return
+			}
+			if resp.Error != nil {
+				t.Fatalf("Unexpected error: %v", resp.Error)
+			}
+			if !strings.Contains(string(resp.Result.(json.RawMessage)), test.expectContains) {
+				t.Errorf("Expected result containing %q, got %s", test.expectContains, resp.Result)
+			}
+		})
+	}
+}
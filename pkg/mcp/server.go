@@ -0,0 +1,255 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+)
+
+// LatestProtocolVersion is the newest MCP revision implemented by the server
+const LatestProtocolVersion = "2025-06-18"
+
+// SupportedProtocolVersions lists the MCP revisions the server can speak,
+// newest first
+var SupportedProtocolVersions = []string{LatestProtocolVersion, "2025-03-26", "2024-11-05"}
+
+// ServerName is the name reported in the initialize result
+const ServerName = "iconhash"
+
+// ServerVersion is the version reported in the initialize result
+var ServerVersion = "dev"
+
+// serverInstructions is returned to clients during initialization
+const serverInstructions = "Calculate favicon MMH3 hashes for Fofa and Shodan searches. " +
+	"Use discover_favicons to find the icons of a site, hash_url or hash_base64 to hash one, " +
+	"hash_body for the HTML hash of a page, and format_query to turn a hash into a search query. " +
+	"Read iconhash://hash/{value} for the products known to serve a hash and iconhash://engines for the query syntax."
+
+// InitializeParams are the parameters of the initialize request
+type InitializeParams struct {
+	ProtocolVersion string                 `json:"protocolVersion"`
+	Capabilities    map[string]interface{} `json:"capabilities"`
+	ClientInfo      Implementation         `json:"clientInfo"`
+}
+
+// InitializeResult is the result of the initialize request
+type InitializeResult struct {
+	ProtocolVersion string             `json:"protocolVersion"`
+	Capabilities    ServerCapabilities `json:"capabilities"`
+	ServerInfo      Implementation     `json:"serverInfo"`
+	Instructions    string             `json:"instructions,omitempty"`
+}
+
+// Implementation identifies an MCP client or server
+type Implementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// ServerCapabilities advertises the features supported by the server
+type ServerCapabilities struct {
+	Tools     *ToolsCapability     `json:"tools,omitempty"`
+	Resources *ResourcesCapability `json:"resources,omitempty"`
+	Prompts   *PromptsCapability   `json:"prompts,omitempty"`
+}
+
+// ToolsCapability describes the tools feature
+type ToolsCapability struct {
+	ListChanged bool `json:"listChanged"`
+}
+
+// ResourcesCapability describes the resources feature
+type ResourcesCapability struct {
+	Subscribe   bool `json:"subscribe"`
+	ListChanged bool `json:"listChanged"`
+}
+
+// PromptsCapability describes the prompts feature
+type PromptsCapability struct {
+	ListChanged bool `json:"listChanged"`
+}
+
+// HandleMessage processes a JSON-RPC message or batch and returns the encoded
+// response. It returns nil when nothing needs to be sent, which is the case
+// for notifications.
+func (h *Handler) HandleMessage(ctx context.Context, data []byte) []byte {
+	data = bytes.TrimSpace(data)
+
+	if len(data) > 0 && data[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(data, &batch); err != nil {
+			return encode(newRPCError(nil, CodeParseError, "Parse error: "+err.Error()))
+		}
+		if len(batch) == 0 {
+			return encode(newRPCError(nil, CodeInvalidRequest, "Invalid request: empty batch"))
+		}
+
+		var responses []*RPCResponse
+		for _, msg := range batch {
+			if resp := h.handleRaw(ctx, msg); resp != nil {
+				responses = append(responses, resp)
+			}
+		}
+		if len(responses) == 0 {
+			return nil
+		}
+		return encode(responses)
+	}
+
+	if resp := h.handleRaw(ctx, data); resp != nil {
+		return encode(resp)
+	}
+	return nil
+}
+
+// handleRaw decodes and handles a single JSON-RPC message
+func (h *Handler) handleRaw(ctx context.Context, data []byte) *RPCResponse {
+	var req RPCRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		if _, ok := err.(*json.SyntaxError); ok {
+			return newRPCError(nil, CodeParseError, "Parse error: "+err.Error())
+		}
+		return newRPCError(nil, CodeInvalidRequest, "Invalid request: "+err.Error())
+	}
+	return h.HandleRequest(ctx, &req)
+}
+
+// HandleRequest handles a decoded JSON-RPC request. It returns nil for notifications.
+func (h *Handler) HandleRequest(ctx context.Context, req *RPCRequest) *RPCResponse {
+	if req.JSONRPC != JSONRPCVersion || req.Method == "" {
+		return newRPCError(req.ID, CodeInvalidRequest, "Invalid request: expected a JSON-RPC 2.0 request with a method")
+	}
+
+	h.logger.DebugContext(ctx, "MCP request", "method", req.Method)
+
+	// Requests of a session can be cancelled with notifications/cancelled
+	if session := SessionFromContext(ctx); session != nil && !req.IsNotification() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		defer session.track(req.ID, cancel)()
+	}
+
+	result, rpcErr := h.dispatch(ctx, req)
+	if req.IsNotification() {
+		return nil
+	}
+	if rpcErr != nil {
+		return &RPCResponse{JSONRPC: JSONRPCVersion, ID: req.ID, Error: rpcErr}
+	}
+	// A response needs a result, even for a notification method sent with an id
+	if result == nil {
+		result = struct{}{}
+	}
+	return newRPCResult(req.ID, result)
+}
+
+// dispatch calls the method handler for a request
+func (h *Handler) dispatch(ctx context.Context, req *RPCRequest) (interface{}, *RPCError) {
+	switch req.Method {
+	case "initialize":
+		var params InitializeParams
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		result := h.initialize(&params)
+		if session := SessionFromContext(ctx); session != nil {
+			session.setProtocolVersion(result.ProtocolVersion)
+		}
+		return result, nil
+	case "ping":
+		return struct{}{}, nil
+	case "tools/list":
+		return map[string]interface{}{"tools": h.tools(ctx)}, nil
+	case "tools/call":
+		var params CallToolParams
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.CallTool(ctx, &params)
+	case "resources/list":
+		return map[string]interface{}{"resources": Resources()}, nil
+	case "resources/templates/list":
+		return map[string]interface{}{"resourceTemplates": ResourceTemplates()}, nil
+	case "resources/read":
+		params, err := decodeResourceParams(req.Params)
+		if err != nil {
+			return nil, err
+		}
+		return h.ReadResource(ctx, params)
+	case "prompts/list":
+		return map[string]interface{}{"prompts": Prompts()}, nil
+	case "prompts/get":
+		var params GetPromptParams
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.GetPrompt(ctx, &params)
+	case "notifications/cancelled":
+		var params CancelledParams
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		if session := SessionFromContext(ctx); session != nil && len(params.RequestID) > 0 {
+			if session.cancel(params.RequestID) {
+				h.logger.DebugContext(ctx, "MCP request cancelled", "id", string(params.RequestID), "reason", params.Reason)
+			}
+		}
+		return nil, nil
+	case "notifications/initialized":
+		return nil, nil
+	default:
+		return nil, &RPCError{Code: CodeMethodNotFound, Message: "Method not found: " + req.Method}
+	}
+}
+
+// CancelledParams are the parameters of the notifications/cancelled notification
+type CancelledParams struct {
+	RequestID json.RawMessage `json:"requestId"`
+	Reason    string          `json:"reason,omitempty"`
+}
+
+// initialize negotiates the protocol version and returns the server capabilities
+func (h *Handler) initialize(params *InitializeParams) *InitializeResult {
+	version := LatestProtocolVersion
+	for _, v := range SupportedProtocolVersions {
+		if v == params.ProtocolVersion {
+			version = v
+			break
+		}
+	}
+
+	h.logger.Debug("MCP client initialized", "client", params.ClientInfo.Name, "client_version", params.ClientInfo.Version,
+		"requested_protocol", params.ProtocolVersion, "protocol", version)
+
+	return &InitializeResult{
+		ProtocolVersion: version,
+		Capabilities: ServerCapabilities{
+			Tools:     &ToolsCapability{},
+			Resources: &ResourcesCapability{},
+			Prompts:   &PromptsCapability{},
+		},
+		ServerInfo:   Implementation{Name: ServerName, Version: ServerVersion},
+		Instructions: serverInstructions,
+	}
+}
+
+// decodeParams decodes request parameters. Unknown members such as _meta are ignored.
+func decodeParams(params json.RawMessage, v interface{}) *RPCError {
+	if len(params) == 0 {
+		params = []byte("{}")
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return &RPCError{Code: CodeInvalidParams, Message: "Invalid params: " + err.Error()}
+	}
+	return nil
+}
+
+// encode marshals a response. Responses contain only marshalable values.
+func encode(v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(newRPCError(nil, CodeInternalError, "Internal error: "+err.Error()))
+	}
+	return data
+}
@@ -0,0 +1,277 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/hasher"
+)
+
+// call sends a JSON-RPC message and decodes the single response
+func call(t *testing.T, h *Handler, message string) *RPCResponse {
+	t.Helper()
+
+	data := h.HandleMessage(context.Background(), []byte(message))
+	if data == nil {
+		t.Fatalf("No response to %s", message)
+	}
+
+	var resp struct {
+		RPCResponse
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		t.Fatalf("Invalid response %s: %v", data, err)
+	}
+	resp.RPCResponse.Result = resp.Result
+	return &resp.RPCResponse
+}
+
+func TestInitialize(t *testing.T) {
+	h := NewHandler(false)
+
+	tests := []struct {
+		name      string
+		requested string
+		expected  string
+	}{
+		{"Latest version", LatestProtocolVersion, LatestProtocolVersion},
+		{"Older supported version", "2024-11-05", "2024-11-05"},
+		{"Unsupported version", "1999-01-01", LatestProtocolVersion},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := call(t, h, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"`+test.requested+`","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`)
+			if resp.Error != nil {
+				t.Fatalf("Unexpected error: %v", resp.Error)
+			}
+
+			var result InitializeResult
+			json.Unmarshal(resp.Result.(json.RawMessage), &result)
+			if result.ProtocolVersion != test.expected {
+				t.Errorf("Expected protocol version %s, got %s", test.expected, result.ProtocolVersion)
+			}
+			if result.Capabilities.Tools == nil || result.ServerInfo.Name != ServerName {
+				t.Errorf("Unexpected initialize result: %+v", result)
+			}
+		})
+	}
+}
+
+func TestToolsList(t *testing.T) {
+	resp := call(t, NewHandler(false), `{"jsonrpc":"2.0","id":"a","method":"tools/list"}`)
+	if resp.Error != nil || string(resp.ID) != `"a"` {
+		t.Fatalf("Unexpected response: %+v", resp)
+	}
+
+	var result struct {
+		Tools []Tool `json:"tools"`
+	}
+	json.Unmarshal(resp.Result.(json.RawMessage), &result)
+
+	names := make(map[string]bool)
+	for _, tool := range result.Tools {
+		names[tool.Name] = true
+		if tool.InputSchema == nil || tool.InputSchema.Type != "object" || len(tool.InputSchema.Required) == 0 {
+			t.Errorf("Tool %s has no usable input schema", tool.Name)
+		}
+	}
+	for _, name := range []string{"hash_url", "hash_body", "hash_base64", "discover_favicons", "format_query"} {
+		if !names[name] {
+			t.Errorf("Tool %s is not listed", name)
+		}
+	}
+}
+
+func TestToolsCall(t *testing.T) {
+	h := NewHandler(false)
+
+	tests := []struct {
+		name           string
+		params         string
+		expectCode     int
+		expectIsError  bool
+		expectContains string
+	}{
+		{"Hash base64", `{"name":"hash_base64","arguments":{"data":"AAABAAEAEBA="}}`, 0, false, "Fofa format: icon_hash="},
+		{"Format query", `{"name":"format_query","arguments":{"hash":"-1234","engine":"shodan"}}`, 0, false, "http.favicon.hash:-1234"},
+		{"Format body query", `{"name":"format_query","arguments":{"hash":"-1234","engine":"shodan","field":"body"}}`, 0, false, "http.html_hash:-1234"},
+		{"Body query without field", `{"name":"format_query","arguments":{"hash":"-1234","field":"body"}}`, 0, true, "fofa does not index"},
+		{"Invalid hash", `{"name":"format_query","arguments":{"hash":"abc"}}`, CodeInvalidParams, false, ""},
+		{"Missing argument", `{"name":"hash_url","arguments":{}}`, CodeInvalidParams, false, ""},
+		{"Unknown argument", `{"name":"hash_base64","arguments":{"data":"AAAA","extra":1}}`, CodeInvalidParams, false, ""},
+		{"Unknown tool", `{"name":"nope","arguments":{}}`, CodeInvalidParams, false, ""},
+		{"Fetch failure", `{"name":"hash_url","arguments":{"url":"http://127.0.0.1:1/favicon.ico"}}`, 0, true, "Error:"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := call(t, h, `{"jsonrpc":"2.0","id":7,"method":"tools/call","params":`+test.params+`}`)
+
+			if test.expectCode != 0 {
+				if resp.Error == nil || resp.Error.Code != test.expectCode {
+					t.Fatalf("Expected error code %d, got %+v", test.expectCode, resp.Error)
+				}
+				return
+			}
+			if resp.Error != nil {
+				t.Fatalf("Unexpected error: %v", resp.Error)
+			}
+
+			var result CallToolResult
+			json.Unmarshal(resp.Result.(json.RawMessage), &result)
+			if result.IsError != test.expectIsError {
+				t.Errorf("Expected isError %v, got %v", test.expectIsError, result.IsError)
+			}
+			if len(result.Content) == 0 || !strings.Contains(result.Content[0].Text, test.expectContains) {
+				t.Errorf("Expected content containing %q, got %+v", test.expectContains, result.Content)
+			}
+		})
+	}
+}
+
+func TestHandleMessageErrors(t *testing.T) {
+	h := NewHandler(false)
+
+	tests := []struct {
+		name     string
+		message  string
+		expected int
+	}{
+		{"Parse error", `{"jsonrpc":`, CodeParseError},
+		{"Missing method", `{"jsonrpc":"2.0","id":1}`, CodeInvalidRequest},
+		{"Wrong version", `{"jsonrpc":"1.0","id":1,"method":"ping"}`, CodeInvalidRequest},
+		{"Unknown method", `{"jsonrpc":"2.0","id":1,"method":"sampling/createMessage"}`, CodeMethodNotFound},
+		{"Empty batch", `[]`, CodeInvalidRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := call(t, h, test.message)
+			if resp.Error == nil || resp.Error.Code != test.expected {
+				t.Errorf("Expected error code %d, got %+v", test.expected, resp.Error)
+			}
+		})
+	}
+}
+
+func TestNotificationsAndBatches(t *testing.T) {
+	h := NewHandler(false)
+
+	if resp := h.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)); resp != nil {
+		t.Errorf("Expected no response to a notification, got %s", resp)
+	}
+
+	// Sent with an id, a notification method still gets a result
+	if resp := h.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":7,"method":"notifications/initialized"}`)); string(resp) != `{"jsonrpc":"2.0","id":7,"result":{}}` {
+		t.Errorf("Expected an empty result, got %s", resp)
+	}
+
+	data := h.HandleMessage(context.Background(), []byte(`[
+		{"jsonrpc":"2.0","id":1,"method":"ping"},
+		{"jsonrpc":"2.0","method":"notifications/initialized"},
+		{"jsonrpc":"2.0","id":2,"method":"tools/list"}
+	]`))
+
+	var responses []RPCResponse
+	if err := json.Unmarshal(data, &responses); err != nil {
+		t.Fatalf("Invalid batch response %s: %v", data, err)
+	}
+	if len(responses) != 2 {
+		t.Errorf("Expected 2 responses, got %d", len(responses))
+	}
+}
+
+func TestIsJSONRPC(t *testing.T) {
+	if !IsJSONRPC([]byte(`{"jsonrpc":"2.0","method":"ping"}`)) || !IsJSONRPC([]byte(` [{}]`)) {
+		t.Error("JSON-RPC message not detected")
+	}
+	if IsJSONRPC([]byte(`{"version":"1.0","protocol":"Model Context Protocol"}`)) {
+		t.Error("Legacy message detected as JSON-RPC")
+	}
+}
+
+func TestHashFileTool(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "favicon.ico"), []byte("icon"), 0o644)
+
+	h := NewHandler(false)
+	hashFile := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"hash_file","arguments":{"path":"favicon.ico"}}}`
+
+	// File access is disabled by default
+	if resp := call(t, h, hashFile); resp.Error == nil || resp.Error.Code != CodeInvalidParams {
+		t.Fatalf("Expected hash_file to be unknown without a sandbox, got %+v", resp)
+	}
+	if resp := call(t, h, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`); strings.Contains(string(resp.Result.(json.RawMessage)), "hash_file") {
+		t.Error("Expected hash_file to be hidden without a sandbox")
+	}
+
+	sandbox, err := hasher.NewFileSandbox([]string{root}, 0)
+	if err != nil {
+		t.Fatalf("NewFileSandbox() returned error: %v", err)
+	}
+	h.SetFileSandbox(sandbox)
+
+	resp := call(t, h, hashFile)
+	if resp.Error != nil || !strings.Contains(string(resp.Result.(json.RawMessage)), `"source":"favicon.ico"`) {
+		t.Fatalf("Expected the file to be hashed, got %+v %s", resp.Error, resp.Result)
+	}
+
+	resp = call(t, h, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"hash_file","arguments":{"path":"../outside.ico"}}}`)
+	if resp.Error != nil || !strings.Contains(string(resp.Result.(json.RawMessage)), `"isError":true`) {
+		t.Errorf("Expected an escaping path to fail, got %+v %s", resp.Error, resp.Result)
+	}
+
+	data := h.HandleMessage(WithoutFileAccess(context.Background()), []byte(hashFile))
+	if !strings.Contains(string(data), "Unknown tool") {
+		t.Errorf("Expected hash_file to be unavailable without file access, got %s", data)
+	}
+}
+
+func TestToolsWithoutURLAccess(t *testing.T) {
+	h := NewHandler(false)
+	ctx := WithoutURLAccess(context.Background())
+
+	var names []string
+	for _, tool := range h.tools(ctx) {
+		names = append(names, tool.Name)
+	}
+	if expected := []string{"hash_base64", "format_query"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected only the tools that fetch nothing, %v, got %v", expected, names)
+	}
+
+	data := h.HandleMessage(ctx, []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"hash_url","arguments":{"url":"http://127.0.0.1/favicon.ico"}}}`))
+	if !strings.Contains(string(data), "Unknown tool") {
+		t.Errorf("Expected hash_url to be unavailable without URL access, got %s", data)
+	}
+}
+
+func TestHashBodyTool(t *testing.T) {
+	page := "<html><head><title>Webmail</title></head></html>"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(page))
+	}))
+	defer ts.Close()
+
+	resp := call(t, NewHandler(false), `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"hash_body","arguments":{"url":"`+ts.URL+`"}}}`)
+	if resp.Error != nil {
+		t.Fatalf("Unexpected error: %v", resp.Error)
+	}
+	var result struct {
+		StructuredContent BodyHashResult `json:"structuredContent"`
+	}
+	json.Unmarshal(resp.Result.(json.RawMessage), &result)
+
+	hash, _ := hasher.New(nil).HashHTML([]byte(page))
+	if got := result.StructuredContent; got.Hash != hash || got.Shodan != "http.html_hash:"+hash || got.Title != "Webmail" || got.TitleHash == "" || got.StatusCode != http.StatusOK {
+		t.Errorf("Unexpected result %+v, expected hash %s", got, hash)
+	}
+}
@@ -0,0 +1,324 @@
+package mcp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Session holds the state of one MCP client connection: its in-flight
+// requests, so that they can be cancelled, and the stream of server-to-client
+// messages that are not sent as part of a response.
+type Session struct {
+	// ID is the session ID sent in the Mcp-Session-Id header
+	ID string
+	// Owner identifies the client that created the session, such as an API key name
+	Owner string
+
+	mu              sync.Mutex
+	protocolVersion string
+	inFlight        map[string]context.CancelFunc
+	lastSeen        time.Time
+	outbound        chan []byte
+	closed          chan struct{}
+	closeOnce       sync.Once
+	recent          []RecentResult
+}
+
+// maxRecentResults bounds the number of results remembered per session
+const maxRecentResults = 50
+
+// RecentResult is a hash calculated by a tool during a session
+type RecentResult struct {
+	Source string    `json:"source"`
+	Hash   string    `json:"hash"`
+	Time   time.Time `json:"time"`
+}
+
+// newSession creates a session with a random ID
+func newSession(owner string) *Session {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return &Session{
+		ID:       hex.EncodeToString(b),
+		Owner:    owner,
+		inFlight: make(map[string]context.CancelFunc),
+		lastSeen: time.Now(),
+		outbound: make(chan []byte, 64),
+		closed:   make(chan struct{}),
+	}
+}
+
+// NewSession creates a session that is not tracked by a SessionStore, as
+// used by the stdio transport
+func NewSession() *Session {
+	return newSession("")
+}
+
+// ProtocolVersion returns the protocol version negotiated by initialize
+func (s *Session) ProtocolVersion() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.protocolVersion
+}
+
+// setProtocolVersion records the negotiated protocol version
+func (s *Session) setProtocolVersion(version string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.protocolVersion = version
+}
+
+// Send queues a server-to-client message for the session's stream. Messages
+// are dropped when nobody reads the stream and its buffer is full.
+func (s *Session) Send(msg []byte) {
+	select {
+	case s.outbound <- msg:
+	case <-s.closed:
+	default:
+	}
+}
+
+// Outbound returns the channel of queued server-to-client messages
+func (s *Session) Outbound() <-chan []byte {
+	return s.outbound
+}
+
+// Done returns a channel that is closed when the session is terminated
+func (s *Session) Done() <-chan struct{} {
+	return s.closed
+}
+
+// Close terminates the session and cancels its in-flight requests
+func (s *Session) Close() {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for id, cancel := range s.inFlight {
+			cancel()
+			delete(s.inFlight, id)
+		}
+	})
+}
+
+// track registers a cancellable in-flight request and returns the function
+// that unregisters it
+func (s *Session) track(id json.RawMessage, cancel context.CancelFunc) func() {
+	key := string(id)
+	s.mu.Lock()
+	s.inFlight[key] = cancel
+	s.mu.Unlock()
+
+	return func() {
+		s.mu.Lock()
+		delete(s.inFlight, key)
+		s.mu.Unlock()
+	}
+}
+
+// cancel cancels an in-flight request by ID
+func (s *Session) cancel(id json.RawMessage) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cancel, ok := s.inFlight[string(id)]
+	if ok {
+		cancel()
+		delete(s.inFlight, string(id))
+	}
+	return ok
+}
+
+// record remembers a hash calculated during the session
+func (s *Session) record(source, hash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recent = append(s.recent, RecentResult{Source: source, Hash: hash, Time: time.Now().UTC()})
+	if len(s.recent) > maxRecentResults {
+		s.recent = s.recent[len(s.recent)-maxRecentResults:]
+	}
+}
+
+// Recent returns the hashes calculated during the session, newest first
+func (s *Session) Recent() []RecentResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	recent := make([]RecentResult, len(s.recent))
+	for i, result := range s.recent {
+		recent[len(s.recent)-1-i] = result
+	}
+	return recent
+}
+
+// touch records activity on the session
+func (s *Session) touch(now time.Time) {
+	s.mu.Lock()
+	s.lastSeen = now
+	s.mu.Unlock()
+}
+
+// idleSince returns the time of the last activity on the session
+func (s *Session) idleSince() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastSeen
+}
+
+// SessionStore tracks the sessions of the Streamable HTTP transport
+type SessionStore struct {
+	mu          sync.Mutex
+	sessions    map[string]*Session
+	idleTimeout time.Duration
+	maxSessions int
+}
+
+// NewSessionStore creates a store that expires sessions after idleTimeout
+// and holds at most maxSessions sessions, evicting the least recently used
+func NewSessionStore(idleTimeout time.Duration, maxSessions int) *SessionStore {
+	return &SessionStore{
+		sessions:    make(map[string]*Session),
+		idleTimeout: idleTimeout,
+		maxSessions: maxSessions,
+	}
+}
+
+// Create starts a new session for the given owner
+func (st *SessionStore) Create(owner string) *Session {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	now := time.Now()
+	st.expire(now)
+	for len(st.sessions) >= st.maxSessions && st.maxSessions > 0 {
+		st.evictOldest()
+	}
+
+	s := newSession(owner)
+	st.sessions[s.ID] = s
+	return s
+}
+
+// Get returns a live session by ID and records activity on it
+func (st *SessionStore) Get(id string) (*Session, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	now := time.Now()
+	s, ok := st.sessions[id]
+	if !ok {
+		return nil, false
+	}
+	if st.idleTimeout > 0 && now.Sub(s.idleSince()) > st.idleTimeout {
+		s.Close()
+		delete(st.sessions, id)
+		return nil, false
+	}
+	s.touch(now)
+	return s, true
+}
+
+// Delete terminates a session
+func (st *SessionStore) Delete(id string) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	s, ok := st.sessions[id]
+	if ok {
+		s.Close()
+		delete(st.sessions, id)
+	}
+	return ok
+}
+
+// Len returns the number of tracked sessions
+func (st *SessionStore) Len() int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return len(st.sessions)
+}
+
+// expire removes idle sessions
+func (st *SessionStore) expire(now time.Time) {
+	if st.idleTimeout <= 0 {
+		return
+	}
+	for id, s := range st.sessions {
+		if now.Sub(s.idleSince()) > st.idleTimeout {
+			s.Close()
+			delete(st.sessions, id)
+		}
+	}
+}
+
+// evictOldest removes the least recently used session
+func (st *SessionStore) evictOldest() {
+	var oldest *Session
+	for _, s := range st.sessions {
+		if oldest == nil || s.idleSince().Before(oldest.idleSince()) {
+			oldest = s
+		}
+	}
+	if oldest != nil {
+		oldest.Close()
+		delete(st.sessions, oldest.ID)
+	}
+}
+
+// contextKey is the type of context keys set by the transports
+type contextKey int
+
+const (
+	sessionKey contextKey = iota
+	notifierKey
+	progressKey
+	noFilesKey
+	noURLsKey
+)
+
+// Notifier delivers a server-to-client message during a request
+type Notifier func(msg []byte)
+
+// WithSession returns a context for requests belonging to a session
+func WithSession(ctx context.Context, s *Session) context.Context {
+	return context.WithValue(ctx, sessionKey, s)
+}
+
+// SessionFromContext returns the session of a request, if any
+func SessionFromContext(ctx context.Context) *Session {
+	s, _ := ctx.Value(sessionKey).(*Session)
+	return s
+}
+
+// WithNotifier returns a context whose requests send notifications, such as
+// progress updates, through fn
+func WithNotifier(ctx context.Context, fn Notifier) context.Context {
+	return context.WithValue(ctx, notifierKey, fn)
+}
+
+// WithoutFileAccess returns a context whose requests cannot use the tools
+// that read local files, for clients not allowed to read them
+func WithoutFileAccess(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noFilesKey, true)
+}
+
+// WithoutURLAccess returns a context whose requests cannot use the tools
+// that fetch URLs, for clients not allowed to make the server fetch them
+func WithoutURLAccess(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noURLsKey, true)
+}
+
+// notify sends a notification through the context's notifier. Without a
+// notifier it falls back to the session stream, if any.
+func notify(ctx context.Context, method string, params interface{}) {
+	msg := encode(&RPCNotification{JSONRPC: JSONRPCVersion, Method: method, Params: params})
+	if fn, ok := ctx.Value(notifierKey).(Notifier); ok && fn != nil {
+		fn(msg)
+		return
+	}
+	if s := SessionFromContext(ctx); s != nil {
+		s.Send(msg)
+	}
+}
@@ -0,0 +1,164 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProgressNotifications(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("icon"))
+	}))
+	defer ts.Close()
+
+	var mu sync.Mutex
+	var notifications []RPCRequest
+	ctx := WithNotifier(context.Background(), func(msg []byte) {
+		var n RPCRequest
+		json.Unmarshal(msg, &n)
+		mu.Lock()
+		notifications = append(notifications, n)
+		mu.Unlock()
+	})
+
+	h := NewHandler(false)
+	data := h.HandleMessage(ctx, []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"hash_urls",`+
+		`"arguments":{"urls":["`+ts.URL+`/a.ico","`+ts.URL+`/b.ico"]},"_meta":{"progressToken":"tok"}}}`))
+
+	var resp struct {
+		Result CallToolResult `json:"result"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil || resp.Result.IsError {
+		t.Fatalf("Unexpected response %s", data)
+	}
+	if strings.Count(resp.Result.Content[0].Text, ts.URL) != 2 {
+		t.Errorf("Expected both URLs in the result, got %q", resp.Result.Content[0].Text)
+	}
+
+	if len(notifications) != 3 {
+		t.Fatalf("Expected 3 progress notifications, got %d", len(notifications))
+	}
+	var last ProgressParams
+	json.Unmarshal(notifications[2].Params, &last)
+	if notifications[2].Method != "notifications/progress" || string(last.ProgressToken) != `"tok"` || last.Progress != 2 || last.Total != 2 {
+		t.Errorf("Unexpected final notification %s %+v", notifications[2].Method, last)
+	}
+
+	// Without a progress token nothing is sent
+	notifications = nil
+	h.HandleMessage(ctx, []byte(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"hash_urls","arguments":{"urls":["`+ts.URL+`/a.ico"]}}}`))
+	if len(notifications) != 0 {
+		t.Errorf("Expected no notifications without a progress token, got %d", len(notifications))
+	}
+}
+
+func TestCancelRequest(t *testing.T) {
+	started := make(chan struct{}, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	h := NewHandler(false)
+	session := NewSession()
+	ctx := WithSession(context.Background(), session)
+
+	done := make(chan []byte, 1)
+	go func() {
+		done <- h.HandleMessage(ctx, []byte(`{"jsonrpc":"2.0","id":"slow","method":"tools/call","params":{"name":"hash_urls",`+
+			`"arguments":{"urls":["`+ts.URL+`/a.ico","`+ts.URL+`/b.ico"]}}}`))
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Request did not start")
+	}
+	if resp := h.HandleMessage(ctx, []byte(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":"slow","reason":"test"}}`)); resp != nil {
+		t.Errorf("Expected no response to a notification, got %s", resp)
+	}
+
+	select {
+	case data := <-done:
+		if !strings.Contains(string(data), `"isError":true`) || !strings.Contains(string(data), "cancelled") {
+			t.Errorf("Expected a cancelled tool result, got %s", data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Request was not cancelled")
+	}
+}
+
+func TestSessionStore(t *testing.T) {
+	store := NewSessionStore(time.Hour, 2)
+
+	first := store.Create("alice")
+	second := store.Create("bob")
+	if first.ID == second.ID || len(first.ID) != 32 {
+		t.Fatalf("Expected distinct random session IDs, got %q and %q", first.ID, second.ID)
+	}
+	if s, ok := store.Get(first.ID); !ok || s.Owner != "alice" {
+		t.Fatalf("Expected to find the first session")
+	}
+
+	// The least recently used session is evicted when the store is full
+	second.touch(time.Now().Add(-time.Minute))
+	store.Create("carol")
+	if _, ok := store.Get(second.ID); ok {
+		t.Error("Expected the least recently used session to be evicted")
+	}
+	select {
+	case <-second.Done():
+	default:
+		t.Error("Expected the evicted session to be closed")
+	}
+
+	// Idle sessions expire
+	first.touch(time.Now().Add(-2 * time.Hour))
+	if _, ok := store.Get(first.ID); ok {
+		t.Error("Expected the idle session to expire")
+	}
+
+	if store.Delete("missing") {
+		t.Error("Expected deleting an unknown session to fail")
+	}
+	if store.Len() != 1 {
+		t.Errorf("Expected 1 session, got %d", store.Len())
+	}
+}
+
+func TestMessageClassification(t *testing.T) {
+	tests := []struct {
+		name         string
+		message      string
+		hasRequests  bool
+		isInitialize bool
+	}{
+		{"Request", `{"jsonrpc":"2.0","id":1,"method":"ping"}`, true, false},
+		{"Initialize", `{"jsonrpc":"2.0","id":1,"method":"initialize"}`, true, true},
+		{"Notification", `{"jsonrpc":"2.0","method":"notifications/initialized"}`, false, false},
+		{"Notification batch", `[{"jsonrpc":"2.0","method":"a"},{"jsonrpc":"2.0","method":"b"}]`, false, false},
+		{"Mixed batch", `[{"jsonrpc":"2.0","method":"a"},{"jsonrpc":"2.0","id":2,"method":"initialize"}]`, true, true},
+		{"Invalid", `{"jsonrpc":`, true, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := HasRequests([]byte(test.message)); got != test.hasRequests {
+				t.Errorf("HasRequests() = %v, expected %v", got, test.hasRequests)
+			}
+			if got := IsInitializeRequest([]byte(test.message)); got != test.isInitialize {
+				t.Errorf("IsInitializeRequest() = %v, expected %v", got, test.isInitialize)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+// ServeStdio serves MCP over newline-delimited JSON-RPC: each line read from
+// in is one message, and each response is written to out as one line.
+// Requests are handled concurrently, so a slow tool call does not block
+// pings or its own cancellation. ServeStdio returns nil when it reaches
+// EOF, or the context error when ctx is done, in both cases only after every
+// pending request has been handled.
+func (h *Handler) ServeStdio(ctx context.Context, in io.Reader, out io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		writeMu sync.Mutex
+		wg      sync.WaitGroup
+	)
+
+	write := func(resp []byte) {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		out.Write(append(resp, '\n'))
+	}
+
+	// The connection is a single session; notifications such as progress
+	// updates are written inline between responses
+	session := NewSession()
+	defer session.Close()
+	ctx = WithNotifier(WithSession(ctx, session), write)
+
+	lines := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		reader := bufio.NewReader(in)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(bytes.TrimSpace(line)) > 0 {
+				select {
+				case lines <- line:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err != nil {
+				readErr <- err
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Pending handlers see the cancellation; none may write to out
+			// once ServeStdio has returned
+			wg.Wait()
+			return ctx.Err()
+		case err := <-readErr:
+			wg.Wait()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		case line := <-lines:
+			wg.Add(1)
+			go func(line []byte) {
+				defer wg.Done()
+				if resp := h.HandleMessage(ctx, line); resp != nil {
+					write(resp)
+				}
+			}(line)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestServeStdio(t *testing.T) {
+	in := strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18"}}
+{"jsonrpc":"2.0","method":"notifications/initialized"}
+
+{"jsonrpc":"2.0","id":2,"method":"ping"}
+{"jsonrpc":"2.0","id":3,"method":"tools/list"}
+`)
+	var out, logs bytes.Buffer
+
+	h := NewHandler(false)
+	h.SetLogger(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	if err := h.ServeStdio(context.Background(), in, &out); err != nil {
+		t.Fatalf("ServeStdio() returned error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 response lines, got %d: %q", len(lines), out.String())
+	}
+
+	ids := make(map[string]bool)
+	for _, line := range lines {
+		var resp RPCResponse
+		if err := json.Unmarshal([]byte(line), &resp); err != nil {
+			t.Fatalf("Invalid response line %q: %v", line, err)
+		}
+		if resp.Error != nil {
+			t.Errorf("Unexpected error response: %s", line)
+		}
+		ids[string(resp.ID)] = true
+	}
+	for _, id := range []string{"1", "2", "3"} {
+		if !ids[id] {
+			t.Errorf("No response for request %s", id)
+		}
+	}
+
+	if logs.Len() == 0 {
+		t.Error("Expected debug logs on the log output")
+	}
+}
+
+// lateWriter records writes made after it is closed
+type lateWriter struct {
+	mu     sync.Mutex
+	closed bool
+	late   int
+}
+
+func (w *lateWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		w.late++
+	}
+	return len(p), nil
+}
+
+func TestServeStdioWaitsOnCancel(t *testing.T) {
+	started := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	in, writer := io.Pipe()
+	defer writer.Close()
+	out := &lateWriter{}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error, 1)
+	go func() { done <- NewHandler(false).ServeStdio(ctx, in, out) }()
+	io.WriteString(writer, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"hash_url","arguments":{"url":"`+server.URL+`"}}}`+"\n")
+	<-started
+	cancel()
+
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	out.mu.Lock()
+	out.closed = true
+	out.mu.Unlock()
+	time.Sleep(100 * time.Millisecond)
+
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	if out.late != 0 {
+		t.Errorf("Expected no writes after ServeStdio returned, got %d", out.late)
+	}
+}
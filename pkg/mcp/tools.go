@@ -0,0 +1,734 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/hasher"
+	"github.com/cyberspacesec/go-iconhash/pkg/util"
+)
+
+// Tool describes a tool that clients can call with tools/call
+type Tool struct {
+	Name        string           `json:"name"`
+	Title       string           `json:"title,omitempty"`
+	Description string           `json:"description"`
+	InputSchema *Schema          `json:"inputSchema"`
+	Annotations *ToolAnnotations `json:"annotations,omitempty"`
+
+	call func(h *Handler, ctx context.Context, args json.RawMessage) (*CallToolResult, error)
+}
+
+// ToolAnnotations are hints about the behaviour of a tool
+type ToolAnnotations struct {
+	ReadOnlyHint  bool `json:"readOnlyHint"`
+	OpenWorldHint bool `json:"openWorldHint"`
+}
+
+// Schema is the subset of JSON Schema used for tool inputs
+type Schema struct {
+	Type                 string             `json:"type"`
+	Description          string             `json:"description,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Default              interface{}        `json:"default,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+}
+
+// CallToolParams are the parameters of the tools/call request
+type CallToolParams struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+	Meta      *RequestMeta    `json:"_meta,omitempty"`
+}
+
+// RequestMeta is the _meta member of request parameters
+type RequestMeta struct {
+	// ProgressToken asks the server to send notifications/progress for the request
+	ProgressToken json.RawMessage `json:"progressToken,omitempty"`
+}
+
+// ProgressParams are the parameters of the notifications/progress notification
+type ProgressParams struct {
+	ProgressToken json.RawMessage `json:"progressToken"`
+	Progress      float64         `json:"progress"`
+	Total         float64         `json:"total,omitempty"`
+	Message       string          `json:"message,omitempty"`
+}
+
+// CallToolResult is the result of the tools/call request. Tool failures are
+// reported with IsError rather than as protocol errors, so the model can see them.
+type CallToolResult struct {
+	Content           []Content   `json:"content"`
+	StructuredContent interface{} `json:"structuredContent,omitempty"`
+	IsError           bool        `json:"isError,omitempty"`
+}
+
+// Content is a content block of a tool result or prompt message
+type Content struct {
+	Type     string            `json:"type"`
+	Text     string            `json:"text,omitempty"`
+	Resource *ResourceContents `json:"resource,omitempty"`
+}
+
+// HashResult is the structured result of the hashing tools
+type HashResult struct {
+	Source string `json:"source"`
+	Hash   string `json:"hash"`
+	Fofa   string `json:"fofa"`
+	Shodan string `json:"shodan"`
+	// Type, Warning and ContentEncoding describe the content of fetched
+	// favicons, and RawHash is the hash of the transferred bytes in the
+	// both hash mode
+	Type            string `json:"type,omitempty"`
+	Warning         string `json:"warning,omitempty"`
+	ContentEncoding string `json:"content_encoding,omitempty"`
+	RawHash         string `json:"raw_hash,omitempty"`
+	// FinalURL and Redirects report where a fetch was redirected to
+	FinalURL  string            `json:"final_url,omitempty"`
+	Redirects []hasher.Redirect `json:"redirects,omitempty"`
+}
+
+// URLHashResult is the result for one URL of the hash_urls tool
+type URLHashResult struct {
+	URL     string `json:"url"`
+	Hash    string `json:"hash,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Warning string `json:"warning,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// BodyHashResult is the structured result of the hash_body tool
+type BodyHashResult struct {
+	URL  string `json:"url"`
+	Hash string `json:"hash"`
+	// Shodan is the http.html_hash query; Fofa does not index body hashes
+	Shodan string `json:"shodan"`
+	// StatusCode is the HTTP status of the page, which is hashed whatever
+	// its status
+	StatusCode int    `json:"status_code"`
+	Length     int    `json:"length"`
+	Title      string `json:"title,omitempty"`
+	TitleHash  string `json:"title_hash,omitempty"`
+	FinalURL   string `json:"final_url,omitempty"`
+}
+
+// DiscoveredFavicon is a favicon found by discover_favicons, with its hash if requested
+type DiscoveredFavicon struct {
+	hasher.Favicon
+	Hash  string `json:"hash,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// queryEngines lists the engines accepted by format_query
+var queryEngines = []string{"fofa", "shodan", "plain"}
+
+// queryFields lists the hashes format_query formats: favicon hashes and HTML
+// body hashes
+var queryFields = []string{"favicon", "body"}
+
+// maxHashURLs limits the number of URLs of a single hash_urls call
+const maxHashURLs = 100
+
+var (
+	noAdditional = new(bool)
+	uint32Schema = &Schema{Type: "boolean", Description: "Output the hash as uint32 instead of int32", Default: false}
+	strictSchema = &Schema{Type: "boolean", Description: "Fail when the content is not an image, such as an HTML error page", Default: false}
+)
+
+// Tools returns the tools exposed by the server
+func Tools() []Tool {
+	return []Tool{
+		{
+			Name:        "hash_url",
+			Title:       "Hash favicon from URL",
+			Description: "Download a favicon from a URL and calculate its MMH3 hash, with ready-made Fofa and Shodan queries.",
+			InputSchema: &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"url":    {Type: "string", Format: "uri", Description: "URL of the favicon, e.g. https://example.com/favicon.ico"},
+					"uint32": uint32Schema,
+					"strict": strictSchema,
+				},
+				Required:             []string{"url"},
+				AdditionalProperties: noAdditional,
+			},
+			Annotations: &ToolAnnotations{ReadOnlyHint: true, OpenWorldHint: true},
+			call:        (*Handler).toolHashURL,
+		},
+		{
+			Name:  "hash_urls",
+			Title: "Hash favicons from several URLs",
+			Description: fmt.Sprintf("Download and hash up to %d favicons, one after another. "+
+				"Sends progress notifications when the request has a progress token and stops when cancelled.", maxHashURLs),
+			InputSchema: &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"urls":   {Type: "array", Description: "Favicon URLs", Items: &Schema{Type: "string", Format: "uri"}},
+					"uint32": uint32Schema,
+					"strict": strictSchema,
+				},
+				Required:             []string{"urls"},
+				AdditionalProperties: noAdditional,
+			},
+			Annotations: &ToolAnnotations{ReadOnlyHint: true, OpenWorldHint: true},
+			call:        (*Handler).toolHashURLs,
+		},
+		{
+			Name:  "hash_body",
+			Title: "Hash web page body",
+			Description: "Download a web page and calculate the MMH3 hash of its body, as indexed by Shodan in http.html_hash, " +
+				"and of its title. Pages sharing a body hash run the same application.",
+			InputSchema: &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"url":    {Type: "string", Format: "uri", Description: "URL of the web page, e.g. https://example.com/"},
+					"uint32": uint32Schema,
+				},
+				Required:             []string{"url"},
+				AdditionalProperties: noAdditional,
+			},
+			Annotations: &ToolAnnotations{ReadOnlyHint: true, OpenWorldHint: true},
+			call:        (*Handler).toolHashBody,
+		},
+		{
+			Name:        "hash_base64",
+			Title:       "Hash base64 favicon data",
+			Description: "Calculate the MMH3 hash of base64 encoded favicon data, with ready-made Fofa and Shodan queries.",
+			InputSchema: &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"data":   {Type: "string", Description: "Base64 encoded favicon, optionally with a data URL prefix"},
+					"uint32": uint32Schema,
+				},
+				Required:             []string{"data"},
+				AdditionalProperties: noAdditional,
+			},
+			Annotations: &ToolAnnotations{ReadOnlyHint: true},
+			call:        (*Handler).toolHashBase64,
+		},
+		{
+			Name:        "discover_favicons",
+			Title:       "Discover favicons of a web page",
+			Description: "Fetch a web page and list the favicons it declares with <link rel=\"icon\"> and similar elements, plus the default /favicon.ico. Optionally hash every icon found.",
+			InputSchema: &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"url":    {Type: "string", Format: "uri", Description: "URL of the web page, e.g. https://example.com/"},
+					"hash":   {Type: "boolean", Description: "Also download and hash every icon", Default: false},
+					"uint32": uint32Schema,
+				},
+				Required:             []string{"url"},
+				AdditionalProperties: noAdditional,
+			},
+			Annotations: &ToolAnnotations{ReadOnlyHint: true, OpenWorldHint: true},
+			call:        (*Handler).toolDiscoverFavicons,
+		},
+		{
+			Name:        "format_query",
+			Title:       "Format search query",
+			Description: "Format a favicon hash, or an HTML body hash from hash_body, as a search query for a search engine.",
+			InputSchema: &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"hash":   {Type: "string", Description: "Hash as a decimal int32 or uint32"},
+					"engine": {Type: "string", Description: "Search engine", Enum: queryEngines, Default: "fofa"},
+					"field": {Type: "string", Description: "Kind of hash; Fofa does not index body hashes",
+						Enum: queryFields, Default: "favicon"},
+				},
+				Required:             []string{"hash"},
+				AdditionalProperties: noAdditional,
+			},
+			Annotations: &ToolAnnotations{ReadOnlyHint: true},
+			call:        (*Handler).toolFormatQuery,
+		},
+	}
+}
+
+// fileTools returns the tools that read local files, which are only
+// available when the handler has a file sandbox
+func fileTools() []Tool {
+	return []Tool{
+		{
+			Name:  "hash_file",
+			Title: "Hash local favicon file",
+			Description: "Calculate the MMH3 hash of a favicon file on the server, with ready-made Fofa and Shodan queries. " +
+				"Only files below the configured root directories can be read; relative paths are relative to the first root.",
+			InputSchema: &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"path":   {Type: "string", Description: "Path of the favicon file"},
+					"uint32": uint32Schema,
+				},
+				Required:             []string{"path"},
+				AdditionalProperties: noAdditional,
+			},
+			Annotations: &ToolAnnotations{ReadOnlyHint: true},
+			call:        (*Handler).toolHashFile,
+		},
+	}
+}
+
+// fileAccess reports whether a request may read local files
+func (h *Handler) fileAccess(ctx context.Context) bool {
+	noFiles, _ := ctx.Value(noFilesKey).(bool)
+	return h.files != nil && !noFiles
+}
+
+// urlAccess reports whether a request may fetch URLs
+func (h *Handler) urlAccess(ctx context.Context) bool {
+	noURLs, _ := ctx.Value(noURLsKey).(bool)
+	return !noURLs
+}
+
+// tools returns the tools available to a request. The tools that fetch
+// URLs are those with the open world hint.
+func (h *Handler) tools(ctx context.Context) []Tool {
+	tools := Tools()
+	if !h.urlAccess(ctx) {
+		local := tools[:0]
+		for _, tool := range tools {
+			if tool.Annotations == nil || !tool.Annotations.OpenWorldHint {
+				local = append(local, tool)
+			}
+		}
+		tools = local
+	}
+	if h.fileAccess(ctx) {
+		tools = append(tools, fileTools()...)
+	}
+	return tools
+}
+
+// CallTool runs a tool. Unknown tools and malformed arguments are protocol
+// errors; failures while running the tool are returned as error results.
+func (h *Handler) CallTool(ctx context.Context, params *CallToolParams) (*CallToolResult, *RPCError) {
+	for _, tool := range h.tools(ctx) {
+		if tool.Name != params.Name {
+			continue
+		}
+
+		h.logger.DebugContext(ctx, "MCP tool call", "tool", params.Name)
+		if params.Meta != nil && len(params.Meta.ProgressToken) > 0 {
+			ctx = context.WithValue(ctx, progressKey, params.Meta.ProgressToken)
+		}
+
+		result, err := tool.call(h, ctx, params.Arguments)
+		if err != nil {
+			if rpcErr, ok := err.(*RPCError); ok {
+				return nil, rpcErr
+			}
+			return errorResult(err), nil
+		}
+		return result, nil
+	}
+
+	return nil, &RPCError{Code: CodeInvalidParams, Message: "Unknown tool: " + params.Name}
+}
+
+// decodeArguments decodes tool arguments, rejecting unknown fields
+func decodeArguments(args json.RawMessage, v interface{}) error {
+	if len(args) == 0 {
+		args = []byte("{}")
+	}
+	dec := json.NewDecoder(bytes.NewReader(args))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return &RPCError{Code: CodeInvalidParams, Message: "Invalid arguments: " + err.Error()}
+	}
+	return nil
+}
+
+// missingArgument returns the protocol error for a missing required argument
+func missingArgument(name string) error {
+	return &RPCError{Code: CodeInvalidParams, Message: "Invalid arguments: " + name + " is required"}
+}
+
+// toolHashURL implements the hash_url tool
+func (h *Handler) toolHashURL(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var in struct {
+		URL    string `json:"url"`
+		Uint32 bool   `json:"uint32"`
+		Strict bool   `json:"strict"`
+	}
+	if err := decodeArguments(args, &in); err != nil {
+		return nil, err
+	}
+	if in.URL == "" {
+		return nil, missingArgument("url")
+	}
+	if err := validateHTTPURL(in.URL); err != nil {
+		return nil, err
+	}
+
+	hash, rawHash, fetched, err := hashURL(ctx, h.urlHasherFor(in.Uint32, in.Strict), in.URL)
+	if err != nil {
+		return nil, fmt.Errorf("error calculating hash: %w", err)
+	}
+	recordResult(ctx, in.URL, hash)
+
+	result := hashResult(in.URL, hash)
+	structured := result.StructuredContent.(HashResult)
+	structured.Type, structured.Warning = fetched.Type, fetched.Warning
+	structured.ContentEncoding, structured.RawHash = fetched.ContentEncoding, rawHash
+	if len(fetched.Redirects) > 0 {
+		structured.FinalURL, structured.Redirects = fetched.FinalURL, fetched.Redirects
+		result.Content[0].Text += fmt.Sprintf("Redirected %d times to: %s\n", len(fetched.Redirects), fetched.FinalURL)
+	}
+	result.StructuredContent = structured
+	if fetched.ContentEncoding != "" {
+		result.Content[0].Text += fmt.Sprintf("Content-Encoding: %s\n", fetched.ContentEncoding)
+	}
+	if rawHash != "" {
+		result.Content[0].Text += fmt.Sprintf("Raw hash: %s\n", rawHash)
+	}
+	if fetched.Warning != "" {
+		result.Content[0].Text += fmt.Sprintf("\nWarning: %s (detected type: %s); the hash is probably not a favicon hash.\n", fetched.Warning, fetched.Type)
+	}
+	return result, nil
+}
+
+// toolHashURLs implements the hash_urls tool
+func (h *Handler) toolHashURLs(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var in struct {
+		URLs   []string `json:"urls"`
+		Uint32 bool     `json:"uint32"`
+		Strict bool     `json:"strict"`
+	}
+	if err := decodeArguments(args, &in); err != nil {
+		return nil, err
+	}
+	if len(in.URLs) == 0 {
+		return nil, missingArgument("urls")
+	}
+	if len(in.URLs) > maxHashURLs {
+		return nil, &RPCError{Code: CodeInvalidParams, Message: fmt.Sprintf("Invalid arguments: at most %d urls are allowed", maxHashURLs)}
+	}
+	for _, u := range in.URLs {
+		if err := validateHTTPURL(u); err != nil {
+			return nil, err
+		}
+	}
+
+	iconHasher := h.urlHasherFor(in.Uint32, in.Strict)
+	total := float64(len(in.URLs))
+	results := make([]URLHashResult, 0, len(in.URLs))
+	var b strings.Builder
+
+	for i, u := range in.URLs {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("cancelled after %d of %d URLs", i, len(in.URLs))
+		}
+		reportProgress(ctx, float64(i), total, "Hashing "+u)
+
+		result := URLHashResult{URL: u}
+		if hash, _, fetched, err := hashURL(ctx, iconHasher, u); err != nil {
+			result.Error = err.Error()
+			fmt.Fprintf(&b, "%s: error: %v\n", u, err)
+		} else {
+			result.Hash, result.Type, result.Warning = hash, fetched.Type, fetched.Warning
+			recordResult(ctx, u, hash)
+			if fetched.Warning != "" {
+				fmt.Fprintf(&b, "%s: %s (warning: %s)\n", u, hash, fetched.Warning)
+			} else {
+				fmt.Fprintf(&b, "%s: %s\n", u, hash)
+			}
+		}
+		results = append(results, result)
+	}
+	reportProgress(ctx, total, total, "Done")
+
+	return &CallToolResult{
+		Content:           []Content{{Type: "text", Text: b.String()}},
+		StructuredContent: map[string]interface{}{"results": results},
+	}, nil
+}
+
+// toolHashBody implements the hash_body tool
+func (h *Handler) toolHashBody(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var in struct {
+		URL    string `json:"url"`
+		Uint32 bool   `json:"uint32"`
+	}
+	if err := decodeArguments(args, &in); err != nil {
+		return nil, err
+	}
+	if in.URL == "" {
+		return nil, missingArgument("url")
+	}
+	if err := validateHTTPURL(in.URL); err != nil {
+		return nil, err
+	}
+
+	body, err := h.hasherFor(in.Uint32).HashBody(ctx, in.URL)
+	if err != nil {
+		return nil, fmt.Errorf("error calculating hash: %w", err)
+	}
+
+	result := BodyHashResult{
+		URL:        in.URL,
+		Hash:       body.Hash,
+		Shodan:     util.FormatBodyHash(body.Hash, util.FormatShodan),
+		StatusCode: body.StatusCode,
+		Length:     body.Length,
+		Title:      body.Title,
+		TitleHash:  body.TitleHash,
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "HTML hash of %s: %s\n", in.URL, body.Hash)
+	fmt.Fprintf(&b, "Shodan format: %s\n", result.Shodan)
+	fmt.Fprintf(&b, "Status: %d\n", body.StatusCode)
+	if body.Title != "" {
+		fmt.Fprintf(&b, "Title: %s (hash %s)\n", body.Title, body.TitleHash)
+	}
+	if len(body.Fetch.Redirects) > 0 {
+		result.FinalURL = body.Fetch.FinalURL
+		fmt.Fprintf(&b, "Redirected %d times to: %s\n", len(body.Fetch.Redirects), body.Fetch.FinalURL)
+	}
+	return &CallToolResult{
+		Content:           []Content{{Type: "text", Text: b.String()}},
+		StructuredContent: result,
+	}, nil
+}
+
+// toolHashBase64 implements the hash_base64 tool
+func (h *Handler) toolHashBase64(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var in struct {
+		Data   string `json:"data"`
+		Uint32 bool   `json:"uint32"`
+	}
+	if err := decodeArguments(args, &in); err != nil {
+		return nil, err
+	}
+	if in.Data == "" {
+		return nil, missingArgument("data")
+	}
+
+	hash, err := h.hasherFor(in.Uint32).HashFromBase64(in.Data)
+	if err != nil {
+		return nil, fmt.Errorf("error calculating hash: %w", err)
+	}
+	recordResult(ctx, "provided base64 data", hash)
+	return hashResult("provided base64 data", hash), nil
+}
+
+// toolHashFile implements the hash_file tool
+func (h *Handler) toolHashFile(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var in struct {
+		Path   string `json:"path"`
+		Uint32 bool   `json:"uint32"`
+	}
+	if err := decodeArguments(args, &in); err != nil {
+		return nil, err
+	}
+	if in.Path == "" {
+		return nil, missingArgument("path")
+	}
+
+	hash, err := h.hasherFor(in.Uint32).HashFromSandboxedFile(h.files, in.Path)
+	if err != nil {
+		return nil, fmt.Errorf("error calculating hash: %w", err)
+	}
+	recordResult(ctx, in.Path, hash)
+	return hashResult(in.Path, hash), nil
+}
+
+// toolDiscoverFavicons implements the discover_favicons tool
+func (h *Handler) toolDiscoverFavicons(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var in struct {
+		URL    string `json:"url"`
+		Hash   bool   `json:"hash"`
+		Uint32 bool   `json:"uint32"`
+	}
+	if err := decodeArguments(args, &in); err != nil {
+		return nil, err
+	}
+	if in.URL == "" {
+		return nil, missingArgument("url")
+	}
+	if err := validateHTTPURL(in.URL); err != nil {
+		return nil, err
+	}
+
+	iconHasher := h.hasherFor(in.Uint32)
+	reportProgress(ctx, 0, 0, "Fetching "+in.URL)
+	icons, err := iconHasher.DiscoverFavicons(ctx, in.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make([]DiscoveredFavicon, len(icons))
+	var b strings.Builder
+	fmt.Fprintf(&b, "Favicons of %s:\n\n", in.URL)
+	for i, icon := range icons {
+		found[i] = DiscoveredFavicon{Favicon: icon}
+		fmt.Fprintf(&b, "- %s (%s)", icon.URL, icon.Rel)
+
+		if in.Hash {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			reportProgress(ctx, float64(i+1), float64(len(icons)+1), "Hashing "+icon.URL)
+			hash, err := iconHasher.HashFromURLContext(ctx, icon.URL)
+			if err != nil {
+				found[i].Error = err.Error()
+				fmt.Fprintf(&b, ": %v", err)
+			} else {
+				found[i].Hash = hash
+				recordResult(ctx, icon.URL, hash)
+				fmt.Fprintf(&b, ": %s", hash)
+			}
+		}
+		b.WriteString("\n")
+	}
+
+	return &CallToolResult{
+		Content:           []Content{{Type: "text", Text: b.String()}},
+		StructuredContent: map[string]interface{}{"url": in.URL, "favicons": found},
+	}, nil
+}
+
+// toolFormatQuery implements the format_query tool
+func (h *Handler) toolFormatQuery(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var in struct {
+		Hash   string `json:"hash"`
+		Engine string `json:"engine"`
+		Field  string `json:"field"`
+	}
+	if err := decodeArguments(args, &in); err != nil {
+		return nil, err
+	}
+	if in.Hash == "" {
+		return nil, missingArgument("hash")
+	}
+	if !isHashValue(in.Hash) {
+		return nil, &RPCError{Code: CodeInvalidParams, Message: "Invalid arguments: hash must be a decimal int32 or uint32"}
+	}
+
+	var format util.OutputFormat
+	switch in.Engine {
+	case "", "fofa":
+		in.Engine = "fofa"
+		format = util.FormatFofa
+	case "shodan":
+		format = util.FormatShodan
+	case "plain":
+		format = util.FormatPlain
+	default:
+		return nil, &RPCError{Code: CodeInvalidParams, Message: "Invalid arguments: engine must be one of " + strings.Join(queryEngines, ", ")}
+	}
+
+	var query string
+	switch in.Field {
+	case "", "favicon":
+		query = util.FormatHash(in.Hash, format)
+	case "body":
+		if query = util.FormatBodyHash(in.Hash, format); query == "" {
+			return nil, fmt.Errorf("%s does not index HTML body hashes", in.Engine)
+		}
+	default:
+		return nil, &RPCError{Code: CodeInvalidParams, Message: "Invalid arguments: field must be one of " + strings.Join(queryFields, ", ")}
+	}
+	return &CallToolResult{
+		Content:           []Content{{Type: "text", Text: query}},
+		StructuredContent: map[string]string{"engine": in.Engine, "query": query},
+	}, nil
+}
+
+// reportProgress sends a progress notification if the client asked for them.
+// A total of zero means the total is unknown.
+func reportProgress(ctx context.Context, progress, total float64, message string) {
+	token, ok := ctx.Value(progressKey).(json.RawMessage)
+	if !ok {
+		return
+	}
+	notify(ctx, "notifications/progress", &ProgressParams{
+		ProgressToken: token,
+		Progress:      progress,
+		Total:         total,
+		Message:       message,
+	})
+}
+
+// recordResult remembers a calculated hash in the request's session, where
+// it is listed by the iconhash://results/recent resource
+func recordResult(ctx context.Context, source, hash string) {
+	if session := SessionFromContext(ctx); session != nil {
+		session.record(source, hash)
+	}
+}
+
+// hasherFor returns the handler's hasher with the requested output type
+func (h *Handler) hasherFor(useUint32 bool) *hasher.IconHasher {
+	return h.iconHasher.WithOptions(func(o *hasher.HashOptions) {
+		o.UseUint32 = useUint32
+	})
+}
+
+// urlHasherFor returns the handler's hasher with the requested output type,
+// rejecting content that is not an image if strict is set
+func (h *Handler) urlHasherFor(useUint32, strict bool) *hasher.IconHasher {
+	return h.iconHasher.WithOptions(func(o *hasher.HashOptions) {
+		o.UseUint32 = useUint32
+		o.Strict = o.Strict || strict
+	})
+}
+
+// hashURL fetches and hashes a favicon in the hasher's hash mode, returning
+// the fetch result for its detected content type and encoding
+func hashURL(ctx context.Context, iconHasher *hasher.IconHasher, url string) (string, string, *hasher.FetchResult, error) {
+	fetched, err := iconHasher.Fetch(ctx, url)
+	if err != nil {
+		return "", "", nil, err
+	}
+	hash, rawHash, err := iconHasher.HashFetched(fetched)
+	if err != nil {
+		return "", "", nil, err
+	}
+	return hash, rawHash, fetched, nil
+}
+
+// hashResult builds the result of a hashing tool
+func hashResult(source, hash string) *CallToolResult {
+	result := HashResult{
+		Source: source,
+		Hash:   hash,
+		Fofa:   util.FormatHash(hash, util.FormatFofa),
+		Shodan: util.FormatHash(hash, util.FormatShodan),
+	}
+	return &CallToolResult{
+		Content:           []Content{{Type: "text", Text: formatHashText(source, hash)}},
+		StructuredContent: result,
+	}
+}
+
+// errorResult reports a tool failure to the client
+func errorResult(err error) *CallToolResult {
+	return &CallToolResult{
+		Content: []Content{{Type: "text", Text: "Error: " + err.Error()}},
+		IsError: true,
+	}
+}
+
+// validateHTTPURL checks that a tool argument is an absolute http(s) URL
+func validateHTTPURL(rawURL string) error {
+	u, err := url.ParseRequestURI(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return &RPCError{Code: CodeInvalidParams, Message: "Invalid arguments: url must be an absolute http or https URL"}
+	}
+	return nil
+}
+
+// isHashValue reports whether s fits in an int32 or uint32
+func isHashValue(s string) bool {
+	v, err := strconv.ParseInt(s, 10, 64)
+	return err == nil && v >= -1<<31 && v <= 1<<32-1
+}
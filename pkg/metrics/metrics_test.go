@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCounter(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("test_requests_total", "Total requests.", "route", "status")
+
+	c.Inc("/a", "200")
+	c.Inc("/a", "200")
+	c.Add(3, "/b", "500")
+
+	if v := c.Value("/a", "200"); v != 2 {
+		t.Errorf("Value(/a, 200) = %v, expected 2", v)
+	}
+
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText() returned error: %v", err)
+	}
+
+	expected := `# HELP test_requests_total Total requests.
+# TYPE test_requests_total counter
+test_requests_total{route="/a",status="200"} 2
+test_requests_total{route="/b",status="500"} 3
+`
+	if buf.String() != expected {
+		t.Errorf("WriteText() =\n%s\nexpected\n%s", buf.String(), expected)
+	}
+}
+
+func TestUnlabeledMetricsStartAtZero(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("test_bytes_total", "Bytes.")
+	r.NewGauge("test_in_flight", "In flight.")
+
+	var buf bytes.Buffer
+	r.WriteText(&buf)
+
+	if !strings.Contains(buf.String(), "test_bytes_total 0\n") {
+		t.Errorf("Expected zero counter sample, got:\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "test_in_flight 0\n") {
+		t.Errorf("Expected zero gauge sample, got:\n%s", buf.String())
+	}
+}
+
+func TestGauge(t *testing.T) {
+	r := NewRegistry()
+	g := r.NewGauge("test_in_flight", "In flight.")
+
+	g.Inc()
+	g.Inc()
+	g.Dec()
+
+	if v := g.Value(); v != 1 {
+		t.Errorf("Value() = %v, expected 1", v)
+	}
+
+	g.Set(7)
+	if v := g.Value(); v != 7 {
+		t.Errorf("Value() after Set(7) = %v, expected 7", v)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogram("test_duration_seconds", "Duration.", []float64{1, 0.1}, "route")
+
+	h.Observe(0.05, "/a")
+	h.Observe(0.5, "/a")
+	h.Observe(5, "/a")
+
+	if n := h.Count("/a"); n != 3 {
+		t.Errorf("Count(/a) = %d, expected 3", n)
+	}
+
+	var buf bytes.Buffer
+	r.WriteText(&buf)
+
+	expected := `# HELP test_duration_seconds Duration.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{route="/a",le="0.1"} 1
+test_duration_seconds_bucket{route="/a",le="1"} 2
+test_duration_seconds_bucket{route="/a",le="+Inf"} 3
+test_duration_seconds_sum{route="/a"} 5.55
+test_duration_seconds_count{route="/a"} 3
+`
+	if buf.String() != expected {
+		t.Errorf("WriteText() =\n%s\nexpected\n%s", buf.String(), expected)
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("test_total", "Help with \\ and\nnewline.", "reason")
+	c.Inc("say \"hi\"\n")
+
+	var buf bytes.Buffer
+	r.WriteText(&buf)
+
+	if !strings.Contains(buf.String(), `# HELP test_total Help with \\ and\nnewline.`) {
+		t.Errorf("Help text not escaped:\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), `test_total{reason="say \"hi\"\n"} 1`) {
+		t.Errorf("Label value not escaped:\n%s", buf.String())
+	}
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("test_total", "Total.").Inc()
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if ct := w.Header().Get("Content-Type"); ct != ContentType {
+		t.Errorf("Content-Type = %q, expected %q", ct, ContentType)
+	}
+	if !strings.Contains(w.Body.String(), "test_total 1") {
+		t.Errorf("Unexpected body:\n%s", w.Body.String())
+	}
+}
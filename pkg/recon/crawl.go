@@ -0,0 +1,269 @@
+package recon
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	neturl "net/url"
+	"path"
+	"strings"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/hasher"
+	"golang.org/x/net/html"
+)
+
+// Default crawl limits
+const (
+	DefaultMaxDepth = 2
+	DefaultMaxPages = 50
+)
+
+// CrawlOptions configures Crawl
+type CrawlOptions struct {
+	// MaxDepth is the number of links followed from the start page, by
+	// default DefaultMaxDepth; a negative depth only visits the start page
+	MaxDepth int
+	// MaxPages is the number of pages visited, by default DefaultMaxPages
+	MaxPages int
+	// Concurrency is the number of pages or icons requested at once, by
+	// default DefaultConcurrency
+	Concurrency int
+	// IgnoreRobots visits pages disallowed by the robots.txt of the site
+	IgnoreRobots bool
+}
+
+// Page is a page visited by Crawl
+type Page struct {
+	URL   string `json:"url"`
+	Depth int    `json:"depth"`
+	// Icons is the number of icons the page declares
+	Icons int    `json:"icons"`
+	Error string `json:"error,omitempty"`
+}
+
+// CrawlReport is the outcome of Crawl
+type CrawlReport struct {
+	URL   string `json:"url"`
+	Pages []Page `json:"pages"`
+	// Disallowed is the number of links not followed because robots.txt
+	// disallows them
+	Disallowed int `json:"disallowed,omitempty"`
+	// Icons are the distinct icons found, in the order of their first page
+	Icons []Icon `json:"icons"`
+}
+
+// staticExtensions are the extensions of linked files that are not pages
+var staticExtensions = map[string]bool{
+	".7z": true, ".avi": true, ".bmp": true, ".css": true, ".csv": true, ".doc": true, ".docx": true,
+	".eot": true, ".exe": true, ".gif": true, ".gz": true, ".ico": true, ".iso": true, ".jpeg": true,
+	".jpg": true, ".js": true, ".json": true, ".mov": true, ".mp3": true, ".mp4": true, ".pdf": true,
+	".png": true, ".ppt": true, ".pptx": true, ".rar": true, ".svg": true, ".tar": true, ".tgz": true,
+	".ttf": true, ".txt": true, ".wav": true, ".webm": true, ".webp": true, ".woff": true,
+	".woff2": true, ".xls": true, ".xlsx": true, ".xml": true, ".zip": true,
+}
+
+// crawledPage is a visited page with the icons and links it contains
+type crawledPage struct {
+	Page
+	icons []hasher.Favicon
+	links []*neturl.URL
+}
+
+// Crawl visits the pages of a site breadth first from startURL, following
+// links to the same origin, and reports the distinct icons declared by the
+// pages, each with the pages declaring it. The /favicon.ico of the site is
+// reported too. Pages disallowed by the site's robots.txt are not visited
+// unless options.IgnoreRobots is set.
+func Crawl(ctx context.Context, h *hasher.IconHasher, startURL string, options *CrawlOptions) (*CrawlReport, error) {
+	start, err := neturl.Parse(startURL)
+	if err != nil || (start.Scheme != "http" && start.Scheme != "https") || start.Host == "" {
+		return nil, fmt.Errorf("invalid URL: %s", startURL)
+	}
+	start.Fragment = ""
+	if start.Path == "" {
+		start.Path = "/"
+	}
+	if options == nil {
+		options = &CrawlOptions{}
+	}
+	maxDepth, maxPages := options.MaxDepth, options.MaxPages
+	if maxDepth == 0 {
+		maxDepth = DefaultMaxDepth
+	} else if maxDepth < 0 {
+		maxDepth = 0
+	}
+	if maxPages <= 0 {
+		maxPages = DefaultMaxPages
+	}
+
+	// Pages are HTML, so strict mode only applies to the icons themselves
+	page := h.WithOptions(func(o *hasher.HashOptions) { o.Strict = false })
+	rules := &robots{}
+	if !options.IgnoreRobots {
+		robotsURL := start.ResolveReference(&neturl.URL{Path: "/robots.txt"}).String()
+		if result, err := page.Fetch(ctx, robotsURL); err == nil {
+			rules = parseRobots(result.Data, robotsAgent)
+		}
+	}
+
+	report := &CrawlReport{URL: start.String()}
+	visited := map[string]bool{start.String(): true}
+	level := []*neturl.URL{start}
+	if !rules.allowed(requestPath(start)) {
+		report.Disallowed++
+		level = nil
+	}
+
+	var iconURLs []string
+	iconPages := make(map[string][]string)
+	for depth := 0; len(level) > 0 && len(report.Pages) < maxPages; depth++ {
+		if n := maxPages - len(report.Pages); len(level) > n {
+			level = level[:n]
+		}
+		pages := make([]crawledPage, len(level))
+		forEach(ctx, len(level), options.Concurrency, func(i int) {
+			pages[i] = crawlPage(ctx, page, level[i], depth)
+		})
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		level = nil
+		for _, p := range pages {
+			report.Pages = append(report.Pages, p.Page)
+			for _, icon := range p.icons {
+				if _, ok := iconPages[icon.URL]; !ok {
+					iconURLs = append(iconURLs, icon.URL)
+					iconPages[icon.URL] = nil
+				}
+				// Every page has the default icon, which is not declared
+				if icon.Rel != "default" {
+					iconPages[icon.URL] = append(iconPages[icon.URL], p.URL)
+				}
+			}
+
+			if depth >= maxDepth {
+				continue
+			}
+			for _, link := range p.links {
+				if link.Scheme != start.Scheme || link.Host != start.Host || visited[link.String()] {
+					continue
+				}
+				visited[link.String()] = true
+				if !rules.allowed(requestPath(link)) {
+					report.Disallowed++
+					continue
+				}
+				level = append(level, link)
+			}
+		}
+	}
+
+	responses := make([]Response, len(iconURLs))
+	forEach(ctx, len(iconURLs), options.Concurrency, func(i int) {
+		responses[i] = fetchResponse(ctx, h, iconURLs[i])
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	icons := make(map[string]int)
+	for _, resp := range responses {
+		if resp.Error != "" || !hasher.IsImage(resp.Type) {
+			continue
+		}
+		report.Icons = addIcon(report.Icons, icons, resp, iconPages[resp.URL]...)
+	}
+	return report, nil
+}
+
+// crawlPage fetches a page and extracts its icons and links
+func crawlPage(ctx context.Context, h *hasher.IconHasher, u *neturl.URL, depth int) crawledPage {
+	p := crawledPage{Page: Page{URL: u.String(), Depth: depth}}
+	result, err := h.Fetch(ctx, u.String())
+	if err != nil {
+		p.Error = err.Error()
+		return p
+	}
+	if result.Type != hasher.TypeHTML {
+		p.Error = "not an HTML page"
+		return p
+	}
+
+	base := u
+	if final, err := neturl.Parse(result.FinalURL); err == nil && result.FinalURL != "" {
+		base = final
+	}
+	p.icons = hasher.ParseFavicons(result.Data, base)
+	p.links = parseLinks(result.Data, base)
+	for _, icon := range p.icons {
+		if icon.Rel != "default" {
+			p.Icons++
+		}
+	}
+	return p
+}
+
+// parseLinks returns the http and https URLs of the <a> and <area> elements
+// of an HTML document, resolved against base or a <base href> and without
+// their fragment, leaving out links to files that are not pages
+func parseLinks(data []byte, base *neturl.URL) []*neturl.URL {
+	var links []*neturl.URL
+	tokenizer := html.NewTokenizer(bytes.NewReader(data))
+	for {
+		tt := tokenizer.Next()
+		if tt == html.ErrorToken {
+			return links
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+
+		name, hasAttr := tokenizer.TagName()
+		if !hasAttr {
+			continue
+		}
+		tag := string(name)
+		if tag != "a" && tag != "area" && tag != "base" {
+			continue
+		}
+		var href string
+		for {
+			key, val, more := tokenizer.TagAttr()
+			if string(key) == "href" {
+				href = strings.TrimSpace(string(val))
+			}
+			if !more {
+				break
+			}
+		}
+		if href == "" {
+			continue
+		}
+
+		u, err := base.Parse(href)
+		if err != nil {
+			continue
+		}
+		if tag == "base" {
+			base = u
+			continue
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || staticExtensions[strings.ToLower(path.Ext(u.Path))] {
+			continue
+		}
+		u.Fragment, u.RawFragment = "", ""
+		if u.Path == "" {
+			u.Path = "/"
+		}
+		links = append(links, u)
+	}
+}
+
+// requestPath returns the path and query of a URL, as matched by robots.txt
+func requestPath(u *neturl.URL) string {
+	if u.RawQuery != "" {
+		return u.EscapedPath() + "?" + u.RawQuery
+	}
+	return u.EscapedPath()
+}
@@ -0,0 +1,94 @@
+package recon
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/hasher"
+)
+
+func TestCrawl(t *testing.T) {
+	pages := map[string]string{
+		"/": `<html><head><link rel="icon" href="/main.png"></head><body>
+			<a href="/app1/">App 1</a> <a href="app2/#login">App 2</a> <a href="/private/">Private</a>
+			<a href="https://other.example/">Other</a> <a href="/manual.pdf">Manual</a> <a href="#top">Top</a>
+			</body></html>`,
+		"/app1/":      `<html><head><link rel="icon" href="icon.png"></head><body><a href="deep/">Deep</a></body></html>`,
+		"/app2/":      `<html><head><link rel="shortcut icon" href="/main.png"></head><body><a href="/">Home</a></body></html>`,
+		"/app1/deep/": `<html><head><link rel="icon" href="/deep.png"></head><body><a href="deeper/">Deeper</a></body></html>`,
+		"/private/":   `<html><head><link rel="icon" href="/private.png"></head></html>`,
+	}
+	icons := map[string]string{
+		"/main.png":      "\x89PNG\r\n\x1a\n main",
+		"/app1/icon.png": "\x89PNG\r\n\x1a\n app1",
+		"/deep.png":      "\x89PNG\r\n\x1a\n deep",
+		"/private.png":   "\x89PNG\r\n\x1a\n private",
+		"/favicon.ico":   "\x00\x00\x01\x00 default",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+		} else if page, ok := pages[r.URL.Path]; ok {
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(page))
+		} else if icon, ok := icons[r.URL.Path]; ok {
+			w.Write([]byte(icon))
+		} else {
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	h := hasher.New(&hasher.HashOptions{Strict: true})
+	report, err := Crawl(context.Background(), h, server.URL, nil)
+	if err != nil {
+		t.Fatalf("Crawl() returned error: %v", err)
+	}
+
+	var visited []string
+	for _, page := range report.Pages {
+		visited = append(visited, page.URL)
+	}
+	expected := []string{server.URL + "/", server.URL + "/app1/", server.URL + "/app2/", server.URL + "/app1/deep/"}
+	if !reflect.DeepEqual(visited, expected) {
+		t.Errorf("Expected pages %v, got %v", expected, visited)
+	}
+	if report.Disallowed != 1 {
+		t.Errorf("Expected 1 page disallowed by robots.txt, got %d", report.Disallowed)
+	}
+	if page := report.Pages[3]; page.Depth != 2 || page.Icons != 1 {
+		t.Errorf("Unexpected page %+v", page)
+	}
+
+	var found []string
+	for _, icon := range report.Icons {
+		found = append(found, icon.URLs[0])
+	}
+	expected = []string{server.URL + "/main.png", server.URL + "/favicon.ico", server.URL + "/app1/icon.png", server.URL + "/deep.png"}
+	if !reflect.DeepEqual(found, expected) {
+		t.Fatalf("Expected icons %v, got %v", expected, found)
+	}
+	if pages := report.Icons[0].Pages; !reflect.DeepEqual(pages, []string{server.URL + "/", server.URL + "/app2/"}) {
+		t.Errorf("Expected the main icon to be declared by the home page and app 2, got %v", pages)
+	}
+	if len(report.Icons[1].Pages) != 0 {
+		t.Errorf("Expected the default icon not to list pages, got %v", report.Icons[1].Pages)
+	}
+
+	// Limits and robots.txt can be relaxed
+	report, _ = Crawl(context.Background(), h, server.URL, &CrawlOptions{MaxPages: 2})
+	if len(report.Pages) != 2 {
+		t.Errorf("Expected 2 pages, got %d", len(report.Pages))
+	}
+	report, _ = Crawl(context.Background(), h, server.URL, &CrawlOptions{MaxDepth: -1})
+	if len(report.Pages) != 1 {
+		t.Errorf("Expected only the start page, got %d", len(report.Pages))
+	}
+	report, _ = Crawl(context.Background(), h, server.URL, &CrawlOptions{MaxDepth: 1, IgnoreRobots: true})
+	if len(report.Pages) != 4 || report.Pages[3].URL != server.URL+"/private/" {
+		t.Errorf("Expected the private page to be visited, got %+v", report.Pages)
+	}
+}
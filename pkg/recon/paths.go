@@ -0,0 +1,139 @@
+package recon
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	neturl "net/url"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/hasher"
+)
+
+//go:embed paths.txt
+var defaultPaths []byte
+
+// DefaultPaths returns the favicon locations embedded in the binary: the
+// conventional ones, common static asset directories and the paths used by
+// known products
+func DefaultPaths() []string {
+	paths, _ := ReadWordlist(bytes.NewReader(defaultPaths))
+	return paths
+}
+
+// Icon is a distinct favicon found on a host
+type Icon struct {
+	Hash   string `json:"hash"`
+	Length int    `json:"length"`
+	Type   string `json:"type"`
+	// URLs are the URLs that served the icon
+	URLs []string `json:"urls"`
+	// Pages are the pages that declare the icon, when found by Crawl
+	Pages []string `json:"pages,omitempty"`
+}
+
+// PathOptions configures ProbePaths
+type PathOptions struct {
+	// Concurrency is the number of paths requested at once, by default
+	// DefaultConcurrency
+	Concurrency int
+	// NoBaseline skips the baseline requests, so that responses sent for
+	// any path are reported as icons
+	NoBaseline bool
+}
+
+// PathReport is the outcome of ProbePaths
+type PathReport struct {
+	URL string `json:"url"`
+	// Probed is the number of paths requested
+	Probed int `json:"probed"`
+	// Baseline are the responses to random paths that do not exist
+	Baseline []Response `json:"baseline,omitempty"`
+	// Icons are the distinct icons found, in the order of their first path
+	Icons []Icon `json:"icons"`
+}
+
+// ProbePaths requests each path, resolved against baseURL, and reports the
+// distinct icons found, each with the URLs that served it. Responses that
+// are not images, and responses matching those to random paths, such as the
+// catch-all page of a single page application, are skipped.
+func ProbePaths(ctx context.Context, h *hasher.IconHasher, baseURL string, paths []string, options *PathOptions) (*PathReport, error) {
+	base, err := neturl.Parse(baseURL)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("invalid URL: %s", baseURL)
+	}
+	if options == nil {
+		options = &PathOptions{}
+	}
+
+	var urls []string
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		u, err := base.Parse(path)
+		if err != nil || seen[u.String()] {
+			continue
+		}
+		seen[u.String()] = true
+		urls = append(urls, u.String())
+	}
+
+	report := &PathReport{URL: base.String(), Probed: len(urls)}
+	baseline := make(map[string]bool)
+	if !options.NoBaseline {
+		random := randomName()
+		for _, path := range []string{"/" + random + ".ico", "/" + random + "/favicon.ico"} {
+			resp := fetchResponse(ctx, h, base.ResolveReference(&neturl.URL{Path: path}).String())
+			report.Baseline = append(report.Baseline, resp)
+			baseline[resp.key()] = true
+		}
+	}
+
+	responses := make([]Response, len(urls))
+	forEach(ctx, len(urls), options.Concurrency, func(i int) {
+		responses[i] = fetchResponse(ctx, h, urls[i])
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	icons := make(map[string]int)
+	for _, resp := range responses {
+		if resp.Error != "" || !hasher.IsImage(resp.Type) || baseline[resp.key()] {
+			continue
+		}
+		report.Icons = addIcon(report.Icons, icons, resp)
+	}
+	return report, nil
+}
+
+// addIcon adds the URL of a response, and the pages declaring it, to the icon
+// with its hash and length, or adds a new icon to icons, indexed by index
+func addIcon(icons []Icon, index map[string]int, resp Response, pages ...string) []Icon {
+	key := resp.key()
+	i, ok := index[key]
+	if !ok {
+		i = len(icons)
+		index[key] = i
+		icons = append(icons, Icon{Hash: resp.Hash, Length: resp.Length, Type: resp.Type})
+	}
+	icon := &icons[i]
+	if !contains(icon.URLs, resp.URL) {
+		icon.URLs = append(icon.URLs, resp.URL)
+	}
+	for _, page := range pages {
+		if !contains(icon.Pages, page) {
+			icon.Pages = append(icon.Pages, page)
+		}
+	}
+	return icons
+}
+
+// contains reports whether s contains v
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
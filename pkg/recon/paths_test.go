@@ -0,0 +1,80 @@
+package recon
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/hasher"
+)
+
+func TestDefaultPaths(t *testing.T) {
+	paths := DefaultPaths()
+	if len(paths) < 50 || paths[0] != "/favicon.ico" {
+		t.Fatalf("Expected the embedded wordlist to start with /favicon.ico, got %d paths", len(paths))
+	}
+	for _, path := range paths {
+		if !strings.HasPrefix(path, "/") {
+			t.Errorf("Expected an absolute path, got %q", path)
+		}
+	}
+}
+
+func TestProbePaths(t *testing.T) {
+	ico := "\x00\x00\x01\x00 app"
+	png := "\x89PNG\r\n\x1a\n product"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/favicon.ico", "/static/favicon.ico":
+			w.Write([]byte(ico))
+		case "/images/logo_icon.ico":
+			w.Write([]byte(png))
+		case "/login/favicon.ico":
+			w.Write([]byte("<html><body>Sign in</body></html>"))
+		case "/admin/favicon.ico":
+			http.NotFound(w, r)
+		default:
+			// A catch-all icon, as served by some frameworks for any path
+			w.Write([]byte("\x00\x00\x01\x00 default"))
+		}
+	}))
+	defer server.Close()
+
+	paths := []string{"/favicon.ico", "/static/favicon.ico", "/images/logo_icon.ico", "/login/favicon.ico",
+		"/admin/favicon.ico", "/missing.ico", "/favicon.ico"}
+	report, err := ProbePaths(context.Background(), hasher.New(nil), server.URL, paths, &PathOptions{Concurrency: 3})
+	if err != nil {
+		t.Fatalf("ProbePaths() returned error: %v", err)
+	}
+	if report.Probed != 6 || len(report.Baseline) != 2 {
+		t.Errorf("Expected 6 probed paths and 2 baseline requests, got %d and %d", report.Probed, len(report.Baseline))
+	}
+
+	var found [][]string
+	for _, icon := range report.Icons {
+		found = append(found, icon.URLs)
+	}
+	expected := [][]string{
+		{server.URL + "/favicon.ico", server.URL + "/static/favicon.ico"},
+		{server.URL + "/images/logo_icon.ico"},
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Fatalf("Expected icons %v, got %v", expected, found)
+	}
+	if icon := report.Icons[1]; icon.Type != hasher.TypePNG || icon.Length != len(png) {
+		t.Errorf("Unexpected icon %+v", icon)
+	}
+
+	// Without a baseline, the catch-all icon is reported too
+	report, _ = ProbePaths(context.Background(), hasher.New(nil), server.URL, paths, &PathOptions{NoBaseline: true})
+	if len(report.Icons) != 3 || report.Icons[2].URLs[0] != server.URL+"/missing.ico" {
+		t.Errorf("Expected the catch-all icon without a baseline, got %+v", report.Icons)
+	}
+
+	if _, err := ProbePaths(context.Background(), hasher.New(nil), "ftp://example.com", paths, nil); err == nil {
+		t.Error("Expected an error for an ftp URL")
+	}
+}
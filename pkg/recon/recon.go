@@ -0,0 +1,124 @@
+// Package recon finds the favicons a server serves beyond its root
+// /favicon.ico, fetching them through the hasher's fetch layer.
+package recon
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/hasher"
+)
+
+// DefaultConcurrency is the number of fetches run at once by default
+const DefaultConcurrency = 10
+
+// ReadWordlist reads one entry per line, skipping blank lines and lines
+// starting with #
+func ReadWordlist(r io.Reader) ([]string, error) {
+	var words []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, line)
+	}
+	return words, scanner.Err()
+}
+
+// forEach calls fn for the indexes 0 to n-1, running up to concurrency calls
+// at once, and stops starting new calls once ctx is done
+func forEach(ctx context.Context, n, concurrency int, fn func(i int)) {
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+
+feed:
+	for i := 0; i < n && ctx.Err() == nil; i++ {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+}
+
+// randomName returns a random name that no server knows, as a host name or path
+func randomName() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Response is the outcome of fetching a favicon
+type Response struct {
+	URL string `json:"url"`
+	// StatusCode is the final HTTP status, or 0 if no response was received
+	StatusCode int    `json:"status_code,omitempty"`
+	Hash       string `json:"hash,omitempty"`
+	Length     int    `json:"length"`
+	Type       string `json:"type,omitempty"`
+	FinalURL   string `json:"final_url,omitempty"`
+	// Error and Reason describe a failed fetch, Reason as returned by
+	// hasher.FailureReason
+	Error  string `json:"error,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// fetchResponse fetches and hashes the favicon at url
+func fetchResponse(ctx context.Context, h *hasher.IconHasher, url string) Response {
+	resp := Response{URL: url}
+	result, err := h.Fetch(ctx, url)
+	if err == nil {
+		resp.StatusCode = http.StatusOK
+		resp.Length = len(result.Data)
+		resp.Type = result.Type
+		resp.FinalURL = result.FinalURL
+		resp.Hash, _, err = h.HashFetched(result)
+	}
+	if err != nil {
+		resp.Error = err.Error()
+		resp.Reason = hasher.FailureReason(err)
+		var statusErr *hasher.StatusError
+		if errors.As(err, &statusErr) {
+			resp.StatusCode = statusErr.StatusCode
+		}
+	}
+	return resp
+}
+
+// key identifies responses that are very likely the same content: the same
+// hash and length, or the same failure
+func (r Response) key() string {
+	switch {
+	case r.Error == "":
+		return fmt.Sprintf("%s/%d", r.Hash, r.Length)
+	case r.StatusCode != 0:
+		return fmt.Sprintf("status/%d", r.StatusCode)
+	default:
+		return "error/" + r.Reason
+	}
+}
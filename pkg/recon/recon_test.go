@@ -0,0 +1,45 @@
+package recon
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestReadWordlist(t *testing.T) {
+	words, err := ReadWordlist(strings.NewReader("# hosts\nwww.example.com\n\n  mail.example.com  \n"))
+	if err != nil {
+		t.Fatalf("ReadWordlist() returned error: %v", err)
+	}
+	if expected := []string{"www.example.com", "mail.example.com"}; !reflect.DeepEqual(words, expected) {
+		t.Errorf("Expected %v, got %v", expected, words)
+	}
+}
+
+func TestForEach(t *testing.T) {
+	var running, peak, calls int32
+	forEach(context.Background(), 20, 3, func(int) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		atomic.AddInt32(&calls, 1)
+		atomic.AddInt32(&running, -1)
+	})
+	if calls != 20 || peak > 3 {
+		t.Errorf("Expected 20 calls, at most 3 at once, got %d calls, %d at once", calls, peak)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls = 0
+	forEach(ctx, 20, 3, func(int) { atomic.AddInt32(&calls, 1) })
+	if calls != 0 {
+		t.Errorf("Expected no calls after cancellation, got %d", calls)
+	}
+}
@@ -0,0 +1,124 @@
+package recon
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+)
+
+// robotsAgent is the user agent token looked up in robots.txt files; groups
+// for "*" apply when no group names it
+const robotsAgent = "iconhash"
+
+// robots holds the rules of a robots.txt file that apply to the crawler
+type robots struct {
+	rules []robotsRule
+}
+
+// robotsRule is an Allow or Disallow line
+type robotsRule struct {
+	pattern string
+	allow   bool
+}
+
+// robotsGroup is a group of rules for the user agents listed before them
+type robotsGroup struct {
+	agents []string
+	rules  []robotsRule
+}
+
+// parseRobots parses a robots.txt file, keeping the rules of the groups
+// naming agent or, if there are none, those of the groups for "*"
+func parseRobots(data []byte, agent string) *robots {
+	var groups []*robotsGroup
+	var current *robotsGroup
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// A user agent after rules starts a new group
+			if current == nil || len(current.rules) > 0 {
+				current = &robotsGroup{}
+				groups = append(groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+		case "allow", "disallow":
+			// An empty Disallow allows everything, as does no rule
+			if current != nil && value != "" {
+				current.rules = append(current.rules, robotsRule{pattern: value, allow: key == "allow"})
+			}
+		}
+	}
+
+	find := func(name string) *robots {
+		var r *robots
+		for _, group := range groups {
+			for _, a := range group.agents {
+				if a == name {
+					if r == nil {
+						r = &robots{}
+					}
+					r.rules = append(r.rules, group.rules...)
+					break
+				}
+			}
+		}
+		return r
+	}
+	if r := find(strings.ToLower(agent)); r != nil {
+		return r
+	}
+	if r := find("*"); r != nil {
+		return r
+	}
+	return &robots{}
+}
+
+// allowed reports whether the rules allow a path, which includes the query
+// string. The longest matching rule wins, and Allow wins ties.
+func (r *robots) allowed(path string) bool {
+	allow, length := true, -1
+	for _, rule := range r.rules {
+		if !matchRobots(rule.pattern, path) {
+			continue
+		}
+		if n := len(rule.pattern); n > length || (n == length && rule.allow) {
+			allow, length = rule.allow, n
+		}
+	}
+	return allow
+}
+
+// matchRobots reports whether a path matches a robots.txt pattern, which may
+// contain * wildcards and end with $ to anchor it at the end of the path
+func matchRobots(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for _, part := range parts[1:] {
+		i := strings.Index(rest, part)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(part):]
+	}
+	if !anchored {
+		return true
+	}
+	if len(parts) == 1 {
+		return rest == ""
+	}
+	return strings.HasSuffix(path, parts[len(parts)-1])
+}
@@ -0,0 +1,44 @@
+package recon
+
+import "testing"
+
+func TestRobots(t *testing.T) {
+	data := []byte(`# robots.txt
+User-agent: Googlebot
+Disallow: /
+
+User-agent: *
+Disallow: /admin
+Disallow: /*.php$
+Allow: /admin/public
+Disallow:
+
+User-agent: iconhash
+User-agent: other
+Disallow: /private # comment
+`)
+	tests := []struct {
+		agent string
+		path  string
+		allow bool
+	}{
+		{"iconhash", "/", true},
+		{"iconhash", "/private/page", false},
+		{"iconhash", "/admin", true},
+		{"crawler", "/admin/users", false},
+		{"crawler", "/admin/public/logo", true},
+		{"crawler", "/index.php", false},
+		{"crawler", "/index.php?page=1", true},
+		{"crawler", "/home", true},
+		{"googlebot", "/home", false},
+	}
+	for _, test := range tests {
+		if allow := parseRobots(data, test.agent).allowed(test.path); allow != test.allow {
+			t.Errorf("Expected %s to be allowed=%v for %s, got %v", test.path, test.allow, test.agent, allow)
+		}
+	}
+
+	if !parseRobots([]byte("<html>Not found</html>"), "iconhash").allowed("/admin") {
+		t.Error("Expected a file without rules to allow everything")
+	}
+}
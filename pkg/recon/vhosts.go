@@ -0,0 +1,165 @@
+package recon
+
+import (
+	"context"
+	"fmt"
+	"net"
+	neturl "net/url"
+	"strconv"
+	"strings"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/hasher"
+)
+
+// VHostOptions configures EnumerateVHosts
+type VHostOptions struct {
+	// Scheme is "https", the default, or "http"
+	Scheme string
+	// Port is the port connected to, by default that of the scheme
+	Port int
+	// Path is the requested path, by default /favicon.ico
+	Path string
+	// Concurrency is the number of hosts requested at once, by default
+	// DefaultConcurrency
+	Concurrency int
+	// NoBaseline skips the baseline requests, so that no group is marked
+	// as a baseline
+	NoBaseline bool
+}
+
+// VHostGroup is a set of virtual hosts that sent the same response
+type VHostGroup struct {
+	Hash       string `json:"hash,omitempty"`
+	Length     int    `json:"length"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Type       string `json:"type,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+	// Hosts are the host names that sent the response
+	Hosts []string `json:"hosts"`
+	// Baseline reports that the server sent the same response for its IP
+	// address or for a host name that does not exist, so that the hosts in
+	// the group are probably not configured on it
+	Baseline bool `json:"baseline,omitempty"`
+}
+
+// VHostReport is the outcome of EnumerateVHosts
+type VHostReport struct {
+	// Address is the IP address connected to
+	Address string `json:"address"`
+	// Baseline are the responses to the IP address and to a random host name
+	Baseline []Response `json:"baseline,omitempty"`
+	// Groups are the distinct responses, in the order of their first host
+	Groups []VHostGroup `json:"groups"`
+}
+
+// EnumerateVHosts requests the favicon of each host name from a single IP
+// address, sending the host name as the TLS server name and Host header, and
+// groups the host names by the response they received. Hosts whose response
+// matches the response to the bare IP address or to a random host name are
+// marked as baseline, as the server most likely does not know them.
+//
+// Fetches use the options, with every host resolved to addr and connected to
+// directly, bypassing any proxy of the options or the environment.
+func EnumerateVHosts(ctx context.Context, fetch *hasher.HashOptions, addr string, hosts []string, options *VHostOptions) (*VHostReport, error) {
+	ip := net.ParseIP(strings.Trim(addr, "[]"))
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address: %s", addr)
+	}
+	if options == nil {
+		options = &VHostOptions{}
+	}
+	scheme := strings.ToLower(options.Scheme)
+	if scheme == "" {
+		scheme = "https"
+	}
+	if scheme != "http" && scheme != "https" {
+		return nil, fmt.Errorf("invalid scheme: %s", options.Scheme)
+	}
+	port := options.Port
+	if port == 0 {
+		port = 443
+		if scheme == "http" {
+			port = 80
+		}
+	}
+	path := options.Path
+	if path == "" {
+		path = "/favicon.ico"
+	} else if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	if fetch == nil {
+		fetch = hasher.DefaultOptions()
+	}
+	opts := *fetch
+	rule := hasher.ResolveRule{Host: "*", Port: strconv.Itoa(port), Addrs: []string{ip.String()}}
+	opts.Resolve = append([]hasher.ResolveRule{rule}, fetch.Resolve...)
+	h := hasher.New(&opts)
+
+	urlFor := func(host string) string {
+		u := neturl.URL{Scheme: scheme, Host: host, Path: path}
+		if (scheme == "https" && port != 443) || (scheme == "http" && port != 80) {
+			u.Host = net.JoinHostPort(host, strconv.Itoa(port))
+		} else if strings.Contains(host, ":") {
+			u.Host = "[" + host + "]"
+		}
+		return u.String()
+	}
+
+	report := &VHostReport{Address: ip.String()}
+	baseline := make(map[string]bool)
+	if !options.NoBaseline {
+		for _, host := range []string{ip.String(), randomName() + ".invalid"} {
+			resp := fetchResponse(ctx, h, urlFor(host))
+			report.Baseline = append(report.Baseline, resp)
+			baseline[resp.key()] = true
+		}
+	}
+
+	hosts = normalizeHosts(hosts)
+	responses := make([]Response, len(hosts))
+	forEach(ctx, len(hosts), options.Concurrency, func(i int) {
+		responses[i] = fetchResponse(ctx, h, urlFor(hosts[i]))
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string]int)
+	for i, resp := range responses {
+		key := resp.key()
+		if g, ok := groups[key]; ok {
+			report.Groups[g].Hosts = append(report.Groups[g].Hosts, hosts[i])
+			continue
+		}
+		groups[key] = len(report.Groups)
+		report.Groups = append(report.Groups, VHostGroup{
+			Hash:       resp.Hash,
+			Length:     resp.Length,
+			StatusCode: resp.StatusCode,
+			Type:       resp.Type,
+			Error:      resp.Error,
+			Reason:     resp.Reason,
+			Hosts:      []string{hosts[i]},
+			Baseline:   baseline[key],
+		})
+	}
+	return report, nil
+}
+
+// normalizeHosts lowercases host names and drops blank and repeated ones
+func normalizeHosts(hosts []string) []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, host := range hosts {
+		host = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+		if host == "" || seen[host] {
+			continue
+		}
+		seen[host] = true
+		result = append(result, host)
+	}
+	return result
+}
@@ -0,0 +1,99 @@
+package recon
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cyberspacesec/go-iconhash/pkg/hasher"
+)
+
+func TestEnumerateVHosts(t *testing.T) {
+	icons := map[string]string{"app.test": "app icon", "mail.test": "mail icon", "www.app.test": "app icon"}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, _ := net.SplitHostPort(r.Host)
+		if r.TLS.ServerName != "" && r.TLS.ServerName != host {
+			t.Errorf("Expected server name %s, got %s", host, r.TLS.ServerName)
+		}
+		if host == "admin.test" {
+			http.NotFound(w, r)
+			return
+		}
+		icon, ok := icons[host]
+		if !ok {
+			icon = "default icon"
+		}
+		w.Write([]byte(icon))
+	}))
+	defer server.Close()
+
+	addr := server.Listener.Addr().(*net.TCPAddr)
+	hosts := []string{"app.test", "Mail.test", "unknown.test", "www.app.test", "admin.test", "app.test"}
+	report, err := EnumerateVHosts(context.Background(), &hasher.HashOptions{InsecureSkipVerify: true},
+		"127.0.0.1", hosts, &VHostOptions{Port: addr.Port, Concurrency: 2})
+	if err != nil {
+		t.Fatalf("EnumerateVHosts() returned error: %v", err)
+	}
+
+	if len(report.Baseline) != 2 || report.Baseline[0].URL != "https://127.0.0.1:"+strconv.Itoa(addr.Port)+"/favicon.ico" {
+		t.Errorf("Unexpected baseline %+v", report.Baseline)
+	}
+	var grouped [][]string
+	for _, group := range report.Groups {
+		grouped = append(grouped, group.Hosts)
+	}
+	expected := [][]string{{"app.test", "www.app.test"}, {"mail.test"}, {"unknown.test"}, {"admin.test"}}
+	if !reflect.DeepEqual(grouped, expected) {
+		t.Fatalf("Expected groups %v, got %v", expected, grouped)
+	}
+	if report.Groups[0].Baseline || !report.Groups[2].Baseline {
+		t.Error("Expected only the response to an unknown host to match the baseline")
+	}
+	if g := report.Groups[0]; g.Length != len("app icon") || g.Hash == "" || g.Hash == report.Groups[1].Hash {
+		t.Errorf("Unexpected group %+v", g)
+	}
+	if g := report.Groups[3]; g.StatusCode != http.StatusNotFound || g.Reason != hasher.ReasonHTTPStatus {
+		t.Errorf("Expected a 404 group, got %+v", g)
+	}
+
+	if _, err := EnumerateVHosts(context.Background(), nil, "example.com", hosts, nil); err == nil {
+		t.Error("Expected an error for a host name instead of an IP address")
+	}
+}
+
+func TestEnumerateVHostsWithProxy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	defer server.Close()
+
+	// A proxy would resolve the host names itself, so it must not be used
+	var proxied atomic.Int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied.Add(1)
+		w.Write([]byte("proxy"))
+	}))
+	defer proxy.Close()
+	selector, err := hasher.NewProxySelector([]string{proxy.URL}, nil)
+	if err != nil {
+		t.Fatalf("NewProxySelector() returned error: %v", err)
+	}
+
+	addr := server.Listener.Addr().(*net.TCPAddr)
+	report, err := EnumerateVHosts(context.Background(), &hasher.HashOptions{Proxy: selector},
+		"127.0.0.1", []string{"app.test", "mail.test"}, &VHostOptions{Scheme: "http", Port: addr.Port, NoBaseline: true})
+	if err != nil {
+		t.Fatalf("EnumerateVHosts() returned error: %v", err)
+	}
+	if n := proxied.Load(); n != 0 {
+		t.Errorf("Expected no request through the proxy, got %d", n)
+	}
+	if len(report.Groups) != 2 || report.Groups[0].StatusCode != http.StatusOK || report.Groups[0].Hash == report.Groups[1].Hash {
+		t.Errorf("Expected a distinct response from the server for each host, got %+v", report.Groups)
+	}
+}
@@ -0,0 +1,66 @@
+package util
+
+// Engine describes how a search engine indexes favicon hashes
+type Engine struct {
+	// Name is the format name used by the CLI, the API and MCP
+	Name string `json:"name"`
+	// Title is the display name of the engine
+	Title string `json:"title"`
+	// Format is the output format producing queries for the engine
+	Format OutputFormat `json:"-"`
+	// Field is the search field holding the favicon hash
+	Field string `json:"field,omitempty"`
+	// Syntax shows the query syntax with a placeholder hash
+	Syntax string `json:"syntax"`
+	// BodyField and BodySyntax are the field and syntax of HTML body
+	// hashes, empty if the engine does not index them
+	BodyField  string `json:"body_field,omitempty"`
+	BodySyntax string `json:"body_syntax,omitempty"`
+	// Notes describe the hash the engine expects
+	Notes string `json:"notes"`
+}
+
+// Engines returns the search engines supported by FormatHash
+func Engines() []Engine {
+	return []Engine{
+		{
+			Name:   "fofa",
+			Title:  "Fofa",
+			Format: FormatFofa,
+			Field:  "icon_hash",
+			Syntax: FormatHash("<hash>", FormatFofa),
+			Notes: "Fofa indexes the same signed int32 hash as Shodan. " +
+				"The value is quoted and can be combined with other fields using && and ||.",
+		},
+		{
+			Name:       "shodan",
+			Title:      "Shodan",
+			Format:     FormatShodan,
+			Field:      "http.favicon.hash",
+			Syntax:     FormatHash("<hash>", FormatShodan),
+			BodyField:  "http.html_hash",
+			BodySyntax: FormatBodyHash("<hash>", FormatShodan),
+			Notes: "Shodan indexes the signed int32 MMH3 hash of the favicon encoded as base64 " +
+				"with a newline every 76 characters. The value is not quoted; negative hashes keep their sign. " +
+				"http.html_hash is the hash of the page body itself, without base64 encoding.",
+		},
+		{
+			Name:       "plain",
+			Title:      "Plain",
+			Format:     FormatPlain,
+			Syntax:     FormatHash("<hash>", FormatPlain),
+			BodySyntax: FormatBodyHash("<hash>", FormatPlain),
+			Notes:      "The bare hash, for other tools. Use --uint32 where an unsigned value is expected.",
+		},
+	}
+}
+
+// LookupEngine returns the engine with the given format name
+func LookupEngine(name string) (Engine, bool) {
+	for _, engine := range Engines() {
+		if engine.Name == name {
+			return engine, true
+		}
+	}
+	return Engine{}, false
+}
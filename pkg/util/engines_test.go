@@ -0,0 +1,19 @@
+package util
+
+import "testing"
+
+func TestLookupEngine(t *testing.T) {
+	for _, engine := range Engines() {
+		found, ok := LookupEngine(engine.Name)
+		if !ok || found.Format != engine.Format {
+			t.Errorf("LookupEngine(%q) = %+v, %v", engine.Name, found, ok)
+		}
+	}
+
+	if engine, _ := LookupEngine("shodan"); engine.Syntax != "http.favicon.hash:<hash>" {
+		t.Errorf("Unexpected Shodan syntax %q", engine.Syntax)
+	}
+	if _, ok := LookupEngine("bing"); ok {
+		t.Error("Expected LookupEngine(bing) to fail")
+	}
+}
@@ -1,6 +1,9 @@
 package util
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // OutputFormat represents the format of the hash output
 type OutputFormat int
@@ -26,6 +29,96 @@ func FormatHash(hash string, format OutputFormat) string {
 	}
 }
 
+// FormatBodyHash formats an HTML body hash, as calculated by
+// IconHasher.HashBody, as a query in the given output format. It returns ""
+// for engines that do not index body hashes, which is the case of Fofa.
+func FormatBodyHash(hash string, format OutputFormat) string {
+	switch format {
+	case FormatFofa:
+		return ""
+	case FormatShodan:
+		return fmt.Sprintf("http.html_hash:%s", hash)
+	default:
+		return hash
+	}
+}
+
+// Fields of a fingerprint bundle that FormatQuery formats
+const (
+	FieldFavicon     = "favicon"
+	FieldHTML        = "html"
+	FieldTitle       = "title"
+	FieldServer      = "server"
+	FieldPoweredBy   = "x_powered_by"
+	FieldCertSubject = "cert_subject"
+	FieldCertIssuer  = "cert_issuer"
+	FieldCertSAN     = "cert_san"
+	FieldCertSHA256  = "cert_sha256"
+	FieldCertSerial  = "cert_serial"
+)
+
+// FormatQuery formats a value of a fingerprint bundle field as a query in the
+// given output format. Certificate subjects and issuers are given as common
+// names, and serials in decimal. It returns "" for fields the engine does not
+// index, such as HTML hashes and certificate fingerprints on Fofa.
+func FormatQuery(field, value string, format OutputFormat) string {
+	switch field {
+	case FieldFavicon:
+		return FormatHash(value, format)
+	case FieldHTML:
+		return FormatBodyHash(value, format)
+	}
+
+	switch format {
+	case FormatFofa:
+		switch field {
+		case FieldTitle:
+			return fmt.Sprintf("title=%s", quote(value))
+		case FieldServer:
+			return fmt.Sprintf("server=%s", quote(value))
+		case FieldPoweredBy:
+			return fmt.Sprintf("header=%s", quote("X-Powered-By: "+value))
+		case FieldCertSubject:
+			return fmt.Sprintf("cert.subject.cn=%s", quote(value))
+		case FieldCertIssuer:
+			return fmt.Sprintf("cert.issuer.cn=%s", quote(value))
+		case FieldCertSAN:
+			return fmt.Sprintf("cert.domain=%s", quote(value))
+		case FieldCertSerial:
+			return fmt.Sprintf("cert.sn=%s", quote(value))
+		}
+		return ""
+	case FormatShodan:
+		switch field {
+		case FieldTitle:
+			return fmt.Sprintf("http.title:%s", quote(value))
+		case FieldServer:
+			return quote("Server: " + value)
+		case FieldPoweredBy:
+			return quote("X-Powered-By: " + value)
+		case FieldCertSubject:
+			return fmt.Sprintf("ssl.cert.subject.cn:%s", quote(value))
+		case FieldCertIssuer:
+			return fmt.Sprintf("ssl.cert.issuer.cn:%s", quote(value))
+		case FieldCertSAN:
+			return fmt.Sprintf("ssl:%s", quote(value))
+		case FieldCertSHA256:
+			return fmt.Sprintf("ssl.cert.fingerprint:%s", value)
+		case FieldCertSerial:
+			return fmt.Sprintf("ssl.cert.serial:%s", value)
+		}
+		return ""
+	default:
+		return value
+	}
+}
+
+// quote double quotes a query value, escaping the quotes and backslashes it
+// contains
+func quote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
 // OutputOptions contains configuration for output
 type OutputOptions struct {
 	Format OutputFormat
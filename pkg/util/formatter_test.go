@@ -27,6 +27,53 @@ func TestFormatHash(t *testing.T) {
 	}
 }
 
+func TestFormatBodyHash(t *testing.T) {
+	tests := []struct {
+		format   OutputFormat
+		expected string
+	}{
+		{FormatPlain, "-12345"},
+		{FormatFofa, ""},
+		{FormatShodan, "http.html_hash:-12345"},
+	}
+
+	for _, test := range tests {
+		if result := FormatBodyHash("-12345", test.format); result != test.expected {
+			t.Errorf("FormatBodyHash(-12345, %v) = %q, expected %q", test.format, result, test.expected)
+		}
+	}
+}
+
+func TestFormatQuery(t *testing.T) {
+	tests := []struct {
+		field    string
+		value    string
+		format   OutputFormat
+		expected string
+	}{
+		{FieldFavicon, "-12345", FormatShodan, "http.favicon.hash:-12345"},
+		{FieldHTML, "-12345", FormatFofa, ""},
+		{FieldTitle, `Say "hi"`, FormatShodan, `http.title:"Say \"hi\""`},
+		{FieldTitle, "Login", FormatFofa, `title="Login"`},
+		{FieldServer, "nginx", FormatShodan, `"Server: nginx"`},
+		{FieldServer, "nginx", FormatFofa, `server="nginx"`},
+		{FieldPoweredBy, "PHP/7.4", FormatFofa, `header="X-Powered-By: PHP/7.4"`},
+		{FieldCertSubject, "example.com", FormatShodan, `ssl.cert.subject.cn:"example.com"`},
+		{FieldCertIssuer, "R3", FormatFofa, `cert.issuer.cn="R3"`},
+		{FieldCertSAN, "www.example.com", FormatFofa, `cert.domain="www.example.com"`},
+		{FieldCertSHA256, "ab01", FormatShodan, "ssl.cert.fingerprint:ab01"},
+		{FieldCertSHA256, "ab01", FormatFofa, ""},
+		{FieldCertSerial, "1234", FormatShodan, "ssl.cert.serial:1234"},
+		{FieldCertSerial, "1234", FormatPlain, "1234"},
+	}
+
+	for _, test := range tests {
+		if result := FormatQuery(test.field, test.value, test.format); result != test.expected {
+			t.Errorf("FormatQuery(%s, %q, %v) = %q, expected %q", test.field, test.value, test.format, result, test.expected)
+		}
+	}
+}
+
 func TestNewOutputOptions(t *testing.T) {
 	options := NewOutputOptions()
 
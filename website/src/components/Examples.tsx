@@ -125,7 +125,7 @@ iconhash -b encoded.txt`}
             <ExampleDescription>{t('examples.apiServer.description')}</ExampleDescription>
             <CodeBlock>
               <pre>
-{`# Start on default port (8000)
+{`# Start on default port (8080)
 iconhash server
 
 # Start on custom port with debug output